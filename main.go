@@ -7,15 +7,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/coreos/go-iptables/iptables"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/rancher/submariner/pkg/cableengine/ipsec"
 	"github.com/rancher/submariner/pkg/controllers/datastoresyncer"
+	"github.com/rancher/submariner/pkg/controllers/globalnet"
+	"github.com/rancher/submariner/pkg/controllers/servicediscovery"
+	"github.com/rancher/submariner/pkg/controllers/trafficfilter"
 	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/discovery"
+	"github.com/rancher/submariner/pkg/topology"
 	"github.com/rancher/submariner/pkg/types"
 	"github.com/rancher/submariner/pkg/util"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
@@ -26,7 +34,9 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
 	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
+	submarinerScheme "github.com/rancher/submariner/pkg/client/clientset/versioned/scheme"
 	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions"
 	"github.com/rancher/submariner/pkg/controllers/tunnel"
 	subk8s "github.com/rancher/submariner/pkg/datastore/kubernetes"
@@ -37,11 +47,13 @@ import (
 var (
 	localMasterURL  string
 	localKubeconfig string
+	uninstall       bool
 )
 
 func init() {
 	flag.StringVar(&localKubeconfig, "kubeconfig", "", "Path to kubeconfig of local cluster. Only required if out-of-cluster.")
 	flag.StringVar(&localMasterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	flag.BoolVar(&uninstall, "uninstall", false, "Deregister this cluster and its endpoints from the broker and exit.")
 }
 
 func main() {
@@ -73,65 +85,122 @@ func main() {
 		klog.Exitf("Error building submariner clientset: %s", err.Error())
 	}
 
+	if !topology.IsValidRole(submSpec.ClusterRole) {
+		klog.Fatalf("Invalid cluster role %q, it must be %q, %q or empty", submSpec.ClusterRole, submarinerv1.ClusterRoleHub,
+			submarinerv1.ClusterRoleSpoke)
+	}
+
+	if uninstall {
+		if err = datastoresyncer.Deregister(submSpec.ClusterID, submSpec.Namespace, submarinerClient, newDatastore(submSpec, stopCh)); err != nil {
+			klog.Fatalf("Error deregistering cluster %s: %v", submSpec.ClusterID, err)
+		}
+		klog.Infof("Cluster %s was deregistered from the broker", submSpec.ClusterID)
+		return
+	}
+
+	// The submariner types are added to the scheme so that events can refer to our CRDs
+	utilruntime.Must(submarinerScheme.AddToScheme(scheme.Scheme))
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.V(4).Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(submSpec.Namespace)})
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "submariner-controller"})
+
 	kubeInformerFactory := kubeInformers.NewSharedInformerFactoryWithOptions(kubeClient, time.Second*30,
 		kubeInformers.WithNamespace(submSpec.Namespace))
 	submarinerInformerFactory := submarinerInformers.NewSharedInformerFactoryWithOptions(submarinerClient, time.Second*30,
 		submarinerInformers.WithNamespace(submSpec.Namespace))
 
 	start := func(context.Context) {
-		localCluster, err := util.GetLocalCluster(submSpec)
+		datastore := newDatastore(submSpec, stopCh)
+
+		// The CIDRs which are set explicitly take precedence over the discovered ones
+		var discoveredNetwork types.ClusterNetwork
+		if len(submSpec.ClusterCidr) == 0 || len(submSpec.ServiceCidr) == 0 {
+			discoveredNetwork, err = discovery.Discover(kubeClient)
+			if err != nil {
+				klog.Warningf("Error discovering the CIDRs of the cluster: %v", err)
+			}
+		}
+
+		localCluster, err := util.GetLocalCluster(submSpec, discoveredNetwork, datastore)
 		if err != nil {
 			klog.Fatalf("Fatal error occurred while retrieving local cluster from %#v: %v", submSpec, err)
 		}
 
+		// Once registered, the local Cluster CRD is the source of truth for the CIDRs and color codes
+		localCluster, err = datastoresyncer.LocalClusterFromCRD(submarinerClient, submSpec.Namespace, localCluster)
+		if err != nil {
+			klog.Fatalf("Fatal error occurred while reading the local Cluster CRD: %v", err)
+		}
+
+		// With globalnet the other clusters reach this one through its global CIDR only
+		localSubnets := util.GetClusterRoutableCIDRs(localCluster.Spec)
+
 		localEndpoint, err := util.GetLocalEndpoint(submSpec.ClusterID, "ipsec", nil, submSpec.NatEnabled,
-			append(submSpec.ServiceCidr, submSpec.ClusterCidr...), util.GetLocalIP())
+			localSubnets, util.GetLocalIP())
 
 		if err != nil {
 			klog.Fatalf("Fatal error occurred while retrieving local endpoint from %#v: %v", submSpec, err)
 		}
 
-		cableEngine, err := ipsec.NewEngine(append(submSpec.ClusterCidr, submSpec.ServiceCidr...), localCluster, localEndpoint)
+		cableEngine, err := ipsec.NewEngine(localSubnets, localCluster, localEndpoint)
 		if err != nil {
 			klog.Fatalf("Fatal error occurred creating ipsec engine: %v", err)
 		}
 
-		tunnelController := tunnel.NewController(submSpec.Namespace, cableEngine, kubeClient, submarinerClient,
-			submarinerInformerFactory.Submariner().V1().Endpoints())
+		if submSpec.TunnelWorkers < 1 {
+			klog.Fatalf("Invalid number of tunnel workers %d", submSpec.TunnelWorkers)
+		}
 
-		var datastore datastore.Datastore
-		switch submSpec.Broker {
-		case "phpapi":
-			secure, err := util.ParseSecure(submSpec.Token)
-			if err != nil {
-				klog.Fatalf("Error parsing secure token: %v", err)
-			}
+		tunnelController := tunnel.NewController(submSpec.ClusterID, submSpec.Namespace, cableEngine, kubeClient, submSpec.TunnelWorkers,
+			submarinerInformerFactory.Submariner().V1().Clusters(), submarinerInformerFactory.Submariner().V1().Endpoints(),
+			submarinerInformerFactory.Submariner().V1().PeeringPolicies())
 
-			datastore, err = phpapi.NewPHPAPI(secure.APIKey)
-			if err != nil {
-				klog.Fatalf("Error creating PHPAPI datastore: %v", err)
-			}
-		case "k8s":
-			datastore, err = subk8s.NewDatastore(submSpec.ClusterID, stopCh)
+		// Services are exported from, and global IPs assigned to services and pods of, all namespaces
+		clusterKubeInformerFactory := kubeInformers.NewSharedInformerFactory(kubeClient, time.Second*30)
+		clusterSubmarinerInformerFactory := submarinerInformers.NewSharedInformerFactory(submarinerClient, time.Second*30)
+
+		ipt, err := iptables.New()
+		if err != nil {
+			klog.Fatalf("Error initializing iptables: %v", err)
+		}
+
+		var globalnetController *globalnet.Controller
+		if len(localCluster.Spec.GlobalCIDR) > 0 {
+			globalnetController, err = globalnet.NewController(localCluster.Spec.GlobalCIDR[0], submSpec.GlobalnetCidrRange,
+				ipt, kubeClient, clusterKubeInformerFactory.Core().V1().Services(), clusterKubeInformerFactory.Core().V1().Pods())
 			if err != nil {
-				klog.Fatalf("Error creating kubernetes datastore: %v", err)
+				klog.Fatalf("Error creating the globalnet controller: %v", err)
 			}
-		default:
-			klog.Fatalf("Invalid backend '%s' was specified", submSpec.Broker)
 		}
 
+		serviceDiscoveryController := servicediscovery.NewController(submSpec.ClusterID, submSpec.Namespace, submSpec.ColorCodes,
+			len(localCluster.Spec.GlobalCIDR) > 0, kubeClient, submarinerClient, clusterKubeInformerFactory.Core().V1().Services(),
+			clusterSubmarinerInformerFactory.Submariner().V1().ServiceExports(), datastore)
+
+		trafficFilterController := trafficfilter.NewController(submSpec.ClusterID, submSpec.Namespace, ipt, recorder,
+			submarinerInformerFactory.Submariner().V1().TrafficPolicies(), submarinerInformerFactory.Submariner().V1().Clusters(),
+			clusterKubeInformerFactory.Core().V1().Pods())
+
 		klog.V(6).Infof("Creating new datastore syncer")
 		dsSyncer := datastoresyncer.NewDatastoreSyncer(submSpec.ClusterID, submSpec.Namespace, kubeClient, submarinerClient,
-			submarinerInformerFactory.Submariner().V1().Clusters(), submarinerInformerFactory.Submariner().V1().Endpoints(), datastore,
-			submSpec.ColorCodes, localCluster, localEndpoint)
+			submarinerInformerFactory.Submariner().V1().Clusters(), submarinerInformerFactory.Submariner().V1().Endpoints(),
+			submarinerInformerFactory.Submariner().V1().PeeringPolicies(), datastore,
+			localCluster.Spec.ColorCodes, localCluster, localEndpoint, recorder, datastoresyncer.LeaseConfig{
+				HeartbeatInterval: submSpec.HeartbeatInterval,
+				LeaseTimeout:      submSpec.LeaseTimeout,
+				ReaperInterval:    submSpec.ReaperInterval,
+			})
 
 		kubeInformerFactory.Start(stopCh)
 		submarinerInformerFactory.Start(stopCh)
+		clusterKubeInformerFactory.Start(stopCh)
+		clusterSubmarinerInformerFactory.Start(stopCh)
 
 		klog.V(4).Infof("Starting controllers")
 
 		var wg sync.WaitGroup
-		wg.Add(3)
+		wg.Add(5)
 		go func() {
 			defer wg.Done()
 			if err = cableEngine.StartEngine(); err != nil {
@@ -153,6 +222,30 @@ func main() {
 			}
 		}()
 
+		go func() {
+			defer wg.Done()
+			if err = serviceDiscoveryController.Run(stopCh); err != nil {
+				klog.Fatalf("Error running the service discovery controller: %v", err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			if err = trafficFilterController.Run(stopCh); err != nil {
+				klog.Fatalf("Error running the traffic filter controller: %v", err)
+			}
+		}()
+
+		if globalnetController != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err = globalnetController.Run(stopCh); err != nil {
+					klog.Fatalf("Error running the globalnet controller: %v", err)
+				}
+			}()
+		}
+
 		wg.Wait()
 	}
 
@@ -161,14 +254,35 @@ func main() {
 		klog.Fatal(err)
 	}
 
-	eventBroadcaster := record.NewBroadcaster()
-	eventBroadcaster.StartLogging(klog.V(4).Infof)
-	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "submariner-controller"})
-
 	startLeaderElection(leClient, recorder, start)
 	klog.Fatal("All controllers stopped or exited. Stopping main loop")
 }
 
+func newDatastore(submSpec types.SubmarinerSpecification, stopCh <-chan struct{}) datastore.Datastore {
+	var datastore datastore.Datastore
+	var err error
+	switch submSpec.Broker {
+	case "phpapi":
+		secure, err := util.ParseSecure(submSpec.Token)
+		if err != nil {
+			klog.Fatalf("Error parsing secure token: %v", err)
+		}
+
+		datastore, err = phpapi.NewPHPAPI(secure.APIKey)
+		if err != nil {
+			klog.Fatalf("Error creating PHPAPI datastore: %v", err)
+		}
+	case "k8s":
+		datastore, err = subk8s.NewDatastore(submSpec.ClusterID, stopCh)
+		if err != nil {
+			klog.Fatalf("Error creating kubernetes datastore: %v", err)
+		}
+	default:
+		klog.Fatalf("Invalid backend '%s' was specified", submSpec.Broker)
+	}
+	return datastore
+}
+
 func startLeaderElection(leaderElectionClient kubernetes.Interface, recorder record.EventRecorder, run func(ctx context.Context)) {
 	id, err := os.Hostname()
 	if err != nil {
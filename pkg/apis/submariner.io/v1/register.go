@@ -31,6 +31,18 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&ClusterList{},
 		&Endpoint{},
 		&EndpointList{},
+		&EndpointLease{},
+		&EndpointLeaseList{},
+		&ServiceExport{},
+		&ServiceExportList{},
+		&ServiceImport{},
+		&ServiceImportList{},
+		&CIDRReservation{},
+		&CIDRReservationList{},
+		&PeeringPolicy{},
+		&PeeringPolicyList{},
+		&TrafficPolicy{},
+		&TrafficPolicyList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
@@ -3,6 +3,7 @@ package v1
 import (
 	"net"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -13,7 +14,8 @@ import (
 type Cluster struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
-	Spec              ClusterSpec `json:"spec"`
+	Spec              ClusterSpec   `json:"spec"`
+	Status            ClusterStatus `json:"status,omitempty"`
 }
 
 type ClusterSpec struct {
@@ -21,6 +23,47 @@ type ClusterSpec struct {
 	ColorCodes  []string `json:"color_codes"`
 	ServiceCIDR []string `json:"service_cidr"`
 	ClusterCIDR []string `json:"cluster_cidr"`
+	// The CIDR from the globalnet address space that the cluster is reachable through when globalnet is enabled,
+	// in which case it's used instead of ClusterCIDR and ServiceCIDR by the other clusters
+	GlobalCIDR []string `json:"global_cidr,omitempty"`
+	// The CIDR of the node network, whose host network traffic is routed to the other clusters like the pod traffic
+	// when it's set. It's ignored when globalnet is enabled.
+	NodeCIDR []string `json:"node_cidr,omitempty"`
+	// The labels the peering policies of the other clusters select the cluster by
+	Labels map[string]string `json:"labels,omitempty"`
+	// The role of the cluster in a hub-and-spoke topology, the clusters without a role are connected in a full mesh
+	Role ClusterRole `json:"role,omitempty"`
+}
+
+type ClusterRole string
+
+const (
+	// A hub is connected to every other cluster, and forwards the traffic between the clusters which aren't
+	// connected to each other
+	ClusterRoleHub ClusterRole = "hub"
+	// A spoke is only connected to the hubs
+	ClusterRoleSpoke ClusterRole = "spoke"
+)
+
+// ClusterStatus is only maintained on the local Cluster CRDs, it isn't synced through the central datastore
+type ClusterStatus struct {
+	Conditions []ClusterCondition `json:"conditions,omitempty"`
+}
+
+type ClusterConditionType string
+
+const (
+	// The CIDRs of one or more remote clusters overlap with this cluster or with other remote clusters, the
+	// overlapping clusters are not connected
+	ClusterConditionCIDROverlap ClusterConditionType = "CIDROverlap"
+)
+
+type ClusterCondition struct {
+	Type               ClusterConditionType   `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	LastTransitionTime metav1.Time            `json:"last_transition_time,omitempty"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -60,3 +103,194 @@ type EndpointList struct {
 	metav1.ListMeta `json:"metadata"`
 	Items           []Endpoint `json:"items"`
 }
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// EndpointLease holds the liveness lease of an Endpoint, under the same name. It is kept apart from the Endpoint so
+// that renewing it doesn't notify everyone watching the Endpoints.
+type EndpointLease struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              EndpointLeaseSpec `json:"spec"`
+}
+
+type EndpointLeaseSpec struct {
+	ClusterID string      `json:"cluster_id"`
+	CableName string      `json:"cable_name"`
+	RenewTime metav1.Time `json:"renew_time"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type EndpointLeaseList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []EndpointLease `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceExport exports the service with the same name and namespace to the other clusters
+type ServiceExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type ServiceExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []ServiceExport `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ServiceImport is a service exported by a remote cluster
+type ServiceImport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ServiceImportSpec `json:"spec"`
+}
+
+type ServiceImportSpec struct {
+	ClusterID   string `json:"cluster_id"`
+	Namespace   string `json:"namespace"`
+	ServiceName string `json:"service_name"`
+	// The ClusterIP of the service, or its global IP when globalnet is enabled
+	IPs   []string      `json:"ips"`
+	Ports []ServicePort `json:"ports,omitempty"`
+}
+
+type ServicePort struct {
+	Name     string          `json:"name,omitempty"`
+	Protocol corev1.Protocol `json:"protocol"`
+	Port     int32           `json:"port"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type ServiceImportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []ServiceImport `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// CIDRReservation records, in the broker, that a CIDR block of an IPAM pool is allocated to a cluster
+type CIDRReservation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              CIDRReservationSpec `json:"spec"`
+}
+
+type CIDRReservationSpec struct {
+	ClusterID string `json:"cluster_id"`
+	// The pool the block was allocated from: cluster, service or global
+	Pool string `json:"pool"`
+	CIDR string `json:"cidr"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type CIDRReservationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []CIDRReservation `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PeeringPolicy allows or denies peering with the remote clusters it selects. A remote cluster is peered with
+// unless a Deny policy selects it, or Allow policies exist and none of them selects it.
+type PeeringPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PeeringPolicySpec `json:"spec"`
+}
+
+type PeeringPolicyAction string
+
+const (
+	PeeringPolicyAllow PeeringPolicyAction = "Allow"
+	PeeringPolicyDeny  PeeringPolicyAction = "Deny"
+)
+
+// PeeringPolicySpec selects the remote clusters which match all the criteria that are set, a policy without any
+// criteria selects every remote cluster
+type PeeringPolicySpec struct {
+	Action PeeringPolicyAction `json:"action"`
+	// The IDs of the selected clusters
+	ClusterIDs []string `json:"cluster_ids,omitempty"`
+	// The selector of the labels of the selected clusters. The clusters set their own labels, so this is not
+	// authoritative, unlike the cluster IDs.
+	ClusterSelector *metav1.LabelSelector `json:"cluster_selector,omitempty"`
+	// The selected clusters share at least one of these color codes, which the clusters also set themselves
+	ColorCodes []string `json:"color_codes,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type PeeringPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []PeeringPolicy `json:"items"`
+}
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// TrafficPolicy allows traffic from the remote clusters to local destinations. Once a TrafficPolicy exists, the
+// gateway drops the traffic from the remote clusters that no policy allows.
+type TrafficPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TrafficPolicySpec `json:"spec"`
+}
+
+// TrafficPolicySpec allows the traffic from any of its sources to any of its destinations on any of its ports,
+// an empty list allows all the remote clusters, all the local destinations or all the ports
+type TrafficPolicySpec struct {
+	From  []TrafficPolicySource      `json:"from,omitempty"`
+	To    []TrafficPolicyDestination `json:"to,omitempty"`
+	Ports []TrafficPolicyPort        `json:"ports,omitempty"`
+}
+
+// TrafficPolicySource selects the given CIDRs, or all the CIDRs of the remote cluster when no CIDRs are given
+type TrafficPolicySource struct {
+	ClusterID string   `json:"cluster_id,omitempty"`
+	CIDRs     []string `json:"cidrs,omitempty"`
+}
+
+// TrafficPolicyDestination selects the pods of a local namespace and local CIDRs. The CIDRs can't select the
+// ClusterIPs of the services, which kube-proxy translates to the IPs of their pods before the traffic is filtered.
+type TrafficPolicyDestination struct {
+	Namespace string   `json:"namespace,omitempty"`
+	CIDRs     []string `json:"cidrs,omitempty"`
+}
+
+type TrafficPolicyPort struct {
+	// TCP when empty
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+	Port     int32           `json:"port"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type TrafficPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []TrafficPolicy `json:"items"`
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/rancher/submariner/pkg/brokerwebhook/validator"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
+	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions"
+	"github.com/rancher/submariner/pkg/signals"
+)
+
+var (
+	masterURL  string
+	kubeconfig string
+)
+
+type SubmarinerBrokerWebhookSpecification struct {
+	// The broker namespace holding the Cluster, Endpoint, EndpointLease, ServiceImport and CIDRReservation objects
+	Namespace               string
+	ServiceAccountNamespace string
+	ServiceAccountPrefix    string        `default:"submariner-"`
+	AdminGroups             []string      `default:"system:masters"`
+	LeaseTimeout            time.Duration `default:"60s"`
+	Port                    int           `default:"8443"`
+	TLSCertFile             string        `default:"/etc/submariner-broker-webhook/tls.crt"`
+	TLSKeyFile              string        `default:"/etc/submariner-broker-webhook/tls.key"`
+}
+
+func main() {
+	klog.InitFlags(nil)
+	flag.Parse()
+	var sbws SubmarinerBrokerWebhookSpecification
+
+	err := envconfig.Process("submariner_webhook", &sbws)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	if sbws.ServiceAccountNamespace == "" {
+		sbws.ServiceAccountNamespace = sbws.Namespace
+	}
+
+	klog.V(2).Info("Starting submariner-broker-webhook")
+	// set up signals so we handle the first shutdown signal gracefully
+	stopCh := signals.SetupSignalHandler()
+
+	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+	if err != nil {
+		klog.Fatalf("Error building kubeconfig: %s", err.Error())
+	}
+
+	submarinerClient, err := submarinerClientset.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Error building submariner clientset: %s", err.Error())
+	}
+
+	submarinerInformerFactory := submarinerInformers.NewSharedInformerFactoryWithOptions(submarinerClient, time.Second*30,
+		submarinerInformers.WithNamespace(sbws.Namespace))
+	clusterInformer := submarinerInformerFactory.Submariner().V1().Clusters()
+	endpointInformer := submarinerInformerFactory.Submariner().V1().Endpoints()
+	leaseInformer := submarinerInformerFactory.Submariner().V1().EndpointLeases()
+	serviceImportInformer := submarinerInformerFactory.Submariner().V1().ServiceImports()
+	reservationInformer := submarinerInformerFactory.Submariner().V1().CIDRReservations()
+
+	v := validator.NewValidator(validator.Config{
+		ServiceAccountNamespace: sbws.ServiceAccountNamespace,
+		ServiceAccountPrefix:    sbws.ServiceAccountPrefix,
+		AdminGroups:             sbws.AdminGroups,
+		LeaseTimeout:            sbws.LeaseTimeout,
+	}, clusterInformer.Lister().Clusters(sbws.Namespace), endpointInformer.Lister().Endpoints(sbws.Namespace),
+		leaseInformer.Lister().EndpointLeases(sbws.Namespace), serviceImportInformer.Lister().ServiceImports(sbws.Namespace),
+		reservationInformer.Lister().CIDRReservations(sbws.Namespace))
+
+	submarinerInformerFactory.Start(stopCh)
+
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, clusterInformer.Informer().HasSynced, endpointInformer.Informer().HasSynced,
+		leaseInformer.Informer().HasSynced, serviceImportInformer.Informer().HasSynced,
+		reservationInformer.Informer().HasSynced); !ok {
+		klog.Fatal("Failed to wait for caches to sync")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/validate", v)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", sbws.Port),
+		Handler: mux,
+	}
+
+	go func() {
+		<-stopCh
+		klog.Info("Shutting down the broker webhook")
+		if err := server.Close(); err != nil {
+			klog.Errorf("Error closing the broker webhook server: %v", err)
+		}
+	}()
+
+	klog.Infof("Serving the broker webhook on port %d", sbws.Port)
+	err = server.ListenAndServeTLS(sbws.TLSCertFile, sbws.TLSKeyFile)
+	if err != nil && err != http.ErrServerClosed {
+		klog.Fatalf("Error serving the broker webhook: %v", err)
+	}
+}
+
+func init() {
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+}
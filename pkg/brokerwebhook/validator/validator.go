@@ -0,0 +1,568 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	listers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/ipam"
+	"github.com/rancher/submariner/pkg/netutil"
+	"github.com/rancher/submariner/pkg/topology"
+	"github.com/rancher/submariner/pkg/types"
+	"github.com/rancher/submariner/pkg/util"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+)
+
+/*
+ * The validator backs the validating admission webhook of the broker cluster. Member clusters write their
+ * Cluster, Endpoint, EndpointLease, ServiceImport and CIDRReservation objects to the broker using a service account of their own, and the validator makes
+ * sure that they can only write the objects of their own cluster, that the objects are complete and that
+ * their CIDRs don't overlap the ones of the other clusters nor the reserved ones. The clusters may only delete
+ * the objects of other clusters to reap the endpoints whose lease has expired.
+ */
+
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+type Config struct {
+	// The namespace holding the service accounts of the member clusters
+	ServiceAccountNamespace string
+	// The name of the service account of a member cluster is this prefix followed by its cluster ID
+	ServiceAccountPrefix string
+	// Members of these groups may write the objects of any cluster
+	AdminGroups []string
+	// How long an endpoint lease may go without being renewed before the other clusters may delete the endpoint
+	LeaseTimeout time.Duration
+}
+
+type Validator struct {
+	config              Config
+	clusterLister       listers.ClusterNamespaceLister
+	endpointLister      listers.EndpointNamespaceLister
+	leaseLister         listers.EndpointLeaseNamespaceLister
+	serviceImportLister listers.ServiceImportNamespaceLister
+	reservationLister   listers.CIDRReservationNamespaceLister
+}
+
+// conflictError denies a write that lost a race against another one, the webhook reports it as a conflict so that
+// the writer retries
+type conflictError struct {
+	error
+}
+
+// ownedObject holds the cluster ID that the spec of every submariner object written to the broker has
+type ownedObject struct {
+	Spec struct {
+		ClusterID string `json:"cluster_id"`
+	} `json:"spec"`
+}
+
+func NewValidator(config Config, clusterLister listers.ClusterNamespaceLister, endpointLister listers.EndpointNamespaceLister,
+	leaseLister listers.EndpointLeaseNamespaceLister, serviceImportLister listers.ServiceImportNamespaceLister,
+	reservationLister listers.CIDRReservationNamespaceLister) *Validator {
+	return &Validator{
+		config:              config,
+		clusterLister:       clusterLister,
+		endpointLister:      endpointLister,
+		leaseLister:         leaseLister,
+		serviceImportLister: serviceImportLister,
+		reservationLister:   reservationLister,
+	}
+}
+
+// ServeHTTP handles the AdmissionReview requests sent by the API server
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading the request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review := admissionv1beta1.AdmissionReview{}
+	if err = json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("Error decoding the AdmissionReview %s: %v", string(body), err), http.StatusBadRequest)
+		return
+	}
+
+	review.Response = v.Validate(review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	response, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding the AdmissionReview: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err = w.Write(response); err != nil {
+		klog.Errorf("Error writing the AdmissionReview response: %v", err)
+	}
+}
+
+// Validate decides whether the Cluster, Endpoint, EndpointLease, ServiceImport or CIDRReservation write described by
+// the request is allowed
+func (v *Validator) Validate(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	var err error
+	switch request.Operation {
+	case admissionv1beta1.Create, admissionv1beta1.Update:
+		switch request.Kind.Kind {
+		case "Cluster":
+			err = v.validateCluster(request)
+		case "Endpoint":
+			err = v.validateEndpoint(request)
+		case "EndpointLease":
+			err = v.validateEndpointLease(request)
+		case "ServiceImport":
+			err = v.validateServiceImport(request)
+		case "CIDRReservation":
+			err = v.validateCIDRReservation(request)
+		default:
+			err = fmt.Errorf("unexpected kind %s", request.Kind.Kind)
+		}
+	case admissionv1beta1.Delete:
+		err = v.validateDelete(request)
+	default:
+		err = fmt.Errorf("unexpected operation %s", request.Operation)
+	}
+
+	if err != nil {
+		klog.Infof("Denying %s of %s %s/%s by %s: %v", request.Operation, request.Kind.Kind, request.Namespace,
+			request.Name, request.UserInfo.Username, err)
+		reason, code := metav1.StatusReasonForbidden, int32(http.StatusForbidden)
+		if _, ok := err.(conflictError); ok {
+			reason, code = metav1.StatusReasonConflict, http.StatusConflict
+		}
+		return &admissionv1beta1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Reason:  reason,
+				Code:    code,
+				Message: err.Error(),
+			},
+		}
+	}
+
+	klog.V(4).Infof("Allowing %s of %s %s/%s by %s", request.Operation, request.Kind.Kind, request.Namespace,
+		request.Name, request.UserInfo.Username)
+	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+}
+
+func (v *Validator) validateCluster(request *admissionv1beta1.AdmissionRequest) error {
+	cluster := &submarinerv1.Cluster{}
+	if err := json.Unmarshal(request.Object.Raw, cluster); err != nil {
+		return fmt.Errorf("error decoding the Cluster: %v", err)
+	}
+
+	if request.Operation == admissionv1beta1.Update {
+		oldCluster := &submarinerv1.Cluster{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldCluster); err != nil {
+			return fmt.Errorf("error decoding the existing Cluster: %v", err)
+		}
+		if err := v.validateIdentity(request, oldCluster.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateIdentity(request, cluster.Spec.ClusterID); err != nil {
+		return err
+	}
+
+	spec := cluster.Spec
+	if spec.ClusterID == "" {
+		return fmt.Errorf("the cluster ID is required")
+	}
+
+	expectedName, _ := util.GetClusterCRDName(&types.SubmarinerCluster{ID: spec.ClusterID, Spec: spec})
+	if cluster.Name != expectedName {
+		return fmt.Errorf("the name of the Cluster must be %q", expectedName)
+	}
+
+	if len(spec.ClusterCIDR) == 0 {
+		return fmt.Errorf("the cluster CIDR is required")
+	}
+
+	if len(spec.ServiceCIDR) == 0 {
+		return fmt.Errorf("the service CIDR is required")
+	}
+
+	for _, cidr := range spec.GlobalCIDR {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid global CIDR %q: %v", cidr, err)
+		}
+	}
+
+	for _, cidr := range spec.NodeCIDR {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid node CIDR %q: %v", cidr, err)
+		}
+	}
+
+	if !topology.IsValidRole(spec.Role) {
+		return fmt.Errorf("invalid role %q, it must be %q, %q or empty", spec.Role, submarinerv1.ClusterRoleHub,
+			submarinerv1.ClusterRoleSpoke)
+	}
+
+	return v.validateCIDRs(spec.ClusterID, util.GetClusterRoutableCIDRs(spec))
+}
+
+func (v *Validator) validateEndpoint(request *admissionv1beta1.AdmissionRequest) error {
+	endpoint := &submarinerv1.Endpoint{}
+	if err := json.Unmarshal(request.Object.Raw, endpoint); err != nil {
+		return fmt.Errorf("error decoding the Endpoint: %v", err)
+	}
+
+	var oldEndpoint *submarinerv1.Endpoint
+	if request.Operation == admissionv1beta1.Update {
+		oldEndpoint = &submarinerv1.Endpoint{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldEndpoint); err != nil {
+			return fmt.Errorf("error decoding the existing Endpoint: %v", err)
+		}
+		if err := v.validateIdentity(request, oldEndpoint.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateIdentity(request, endpoint.Spec.ClusterID); err != nil {
+		return err
+	}
+
+	spec := endpoint.Spec
+	switch {
+	case spec.ClusterID == "":
+		return fmt.Errorf("the cluster ID is required")
+	case spec.CableName == "":
+		return fmt.Errorf("the cable name is required")
+	case spec.Hostname == "":
+		return fmt.Errorf("the host name is required")
+	case spec.Backend == "":
+		return fmt.Errorf("the backend is required")
+	case spec.PrivateIP == nil:
+		return fmt.Errorf("the private IP is required")
+	}
+
+	expectedName, _ := util.GetEndpointCRDNameFromParams(spec.ClusterID, spec.CableName)
+	if endpoint.Name != expectedName {
+		return fmt.Errorf("the name of the Endpoint must be %q", expectedName)
+	}
+
+	// Updates that leave the spec alone, such as label changes, don't need the subnets checked again
+	if oldEndpoint != nil && reflect.DeepEqual(oldEndpoint.Spec, spec) {
+		return nil
+	}
+
+	return v.validateCIDRs(spec.ClusterID, spec.Subnets)
+}
+
+func (v *Validator) validateEndpointLease(request *admissionv1beta1.AdmissionRequest) error {
+	lease := &submarinerv1.EndpointLease{}
+	if err := json.Unmarshal(request.Object.Raw, lease); err != nil {
+		return fmt.Errorf("error decoding the EndpointLease: %v", err)
+	}
+
+	if request.Operation == admissionv1beta1.Update {
+		oldLease := &submarinerv1.EndpointLease{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldLease); err != nil {
+			return fmt.Errorf("error decoding the existing EndpointLease: %v", err)
+		}
+		if err := v.validateIdentity(request, oldLease.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateIdentity(request, lease.Spec.ClusterID); err != nil {
+		return err
+	}
+
+	spec := lease.Spec
+	switch {
+	case spec.ClusterID == "":
+		return fmt.Errorf("the cluster ID is required")
+	case spec.CableName == "":
+		return fmt.Errorf("the cable name is required")
+	}
+
+	expectedName, _ := util.GetEndpointCRDNameFromParams(spec.ClusterID, spec.CableName)
+	if lease.Name != expectedName {
+		return fmt.Errorf("the name of the EndpointLease must be %q", expectedName)
+	}
+	return nil
+}
+
+func (v *Validator) validateServiceImport(request *admissionv1beta1.AdmissionRequest) error {
+	serviceImport := &submarinerv1.ServiceImport{}
+	if err := json.Unmarshal(request.Object.Raw, serviceImport); err != nil {
+		return fmt.Errorf("error decoding the ServiceImport: %v", err)
+	}
+
+	if request.Operation == admissionv1beta1.Update {
+		oldServiceImport := &submarinerv1.ServiceImport{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldServiceImport); err != nil {
+			return fmt.Errorf("error decoding the existing ServiceImport: %v", err)
+		}
+		if err := v.validateIdentity(request, oldServiceImport.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateIdentity(request, serviceImport.Spec.ClusterID); err != nil {
+		return err
+	}
+
+	spec := serviceImport.Spec
+	switch {
+	case spec.ClusterID == "":
+		return fmt.Errorf("the cluster ID is required")
+	case spec.Namespace == "":
+		return fmt.Errorf("the namespace is required")
+	case spec.ServiceName == "":
+		return fmt.Errorf("the service name is required")
+	}
+
+	expectedName, _ := util.GetServiceImportCRDNameFromParams(spec.ClusterID, spec.Namespace, spec.ServiceName)
+	if serviceImport.Name != expectedName {
+		return fmt.Errorf("the name of the ServiceImport must be %q", expectedName)
+	}
+
+	for _, ip := range spec.IPs {
+		if net.ParseIP(ip) == nil {
+			return fmt.Errorf("invalid IP %q", ip)
+		}
+	}
+	return nil
+}
+
+func (v *Validator) validateCIDRReservation(request *admissionv1beta1.AdmissionRequest) error {
+	reservation := &submarinerv1.CIDRReservation{}
+	if err := json.Unmarshal(request.Object.Raw, reservation); err != nil {
+		return fmt.Errorf("error decoding the CIDRReservation: %v", err)
+	}
+
+	if request.Operation == admissionv1beta1.Update {
+		oldReservation := &submarinerv1.CIDRReservation{}
+		if err := json.Unmarshal(request.OldObject.Raw, oldReservation); err != nil {
+			return fmt.Errorf("error decoding the existing CIDRReservation: %v", err)
+		}
+		if err := v.validateIdentity(request, oldReservation.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateIdentity(request, reservation.Spec.ClusterID); err != nil {
+		return err
+	}
+
+	spec := reservation.Spec
+	switch {
+	case spec.ClusterID == "":
+		return fmt.Errorf("the cluster ID is required")
+	case spec.Pool != ipam.ClusterPool && spec.Pool != ipam.ServicePool && spec.Pool != ipam.GlobalPool:
+		return fmt.Errorf("unknown pool %q", spec.Pool)
+	case spec.CIDR == "":
+		return fmt.Errorf("the CIDR is required")
+	}
+
+	expectedName, _ := util.GetCIDRReservationCRDName(&types.SubmarinerCIDRReservation{Spec: spec})
+	if reservation.Name != expectedName {
+		return fmt.Errorf("the name of the CIDRReservation must be %q", expectedName)
+	}
+
+	if err := v.validateCIDRs(spec.ClusterID, []string{spec.CIDR}); err != nil {
+		return err
+	}
+
+	// The names only keep two clusters from reserving the same block of a pool, blocks of another size or pool
+	// overlapping it have to be refused here
+	reservations, err := v.reservationLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing the existing CIDRReservations: %v", err)
+	}
+
+	for _, existing := range reservations {
+		if existing.Name == reservation.Name {
+			continue
+		}
+		overlaps, err := netutil.IsOverlappingCIDR([]string{existing.Spec.CIDR}, spec.CIDR)
+		if err != nil {
+			klog.Warningf("Error checking CIDR %s against the reserved CIDR %s: %v", spec.CIDR, existing.Spec.CIDR, err)
+			continue
+		}
+		if overlaps {
+			return conflictError{fmt.Errorf("CIDR %s overlaps with the %s CIDR %s reserved by cluster %q", spec.CIDR,
+				existing.Spec.Pool, existing.Spec.CIDR, existing.Spec.ClusterID)}
+		}
+	}
+	return nil
+}
+
+// validateDelete checks that the requesting user may delete the object. Besides its own cluster, the other clusters
+// may delete an Endpoint or EndpointLease once the lease has expired, as they reap the endpoints whose gateway is gone.
+func (v *Validator) validateDelete(request *admissionv1beta1.AdmissionRequest) error {
+	clusterID, err := v.ownerOf(request)
+	if err != nil {
+		return err
+	}
+
+	identityErr := v.validateIdentity(request, clusterID)
+	if identityErr == nil || (request.Kind.Kind != "Endpoint" && request.Kind.Kind != "EndpointLease") {
+		return identityErr
+	}
+
+	if _, ok := v.clusterIDForUser(request.UserInfo.Username); !ok {
+		return identityErr
+	}
+
+	// The lease has the name of its endpoint
+	lease, err := v.leaseLister.Get(request.Name)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%v, and the endpoint has no lease to expire", identityErr)
+	} else if err != nil {
+		return fmt.Errorf("error retrieving the EndpointLease %s: %v", request.Name, err)
+	}
+
+	if expiry := lease.Spec.RenewTime.Add(v.config.LeaseTimeout); time.Now().Before(expiry) {
+		return fmt.Errorf("%v before the lease of the endpoint expires at %v", identityErr, expiry)
+	}
+	return nil
+}
+
+// ownerOf returns the ID of the cluster owning the object deleted by the request
+func (v *Validator) ownerOf(request *admissionv1beta1.AdmissionRequest) (string, error) {
+	if len(request.OldObject.Raw) > 0 {
+		object := &ownedObject{}
+		if err := json.Unmarshal(request.OldObject.Raw, object); err != nil {
+			return "", fmt.Errorf("error decoding the existing %s: %v", request.Kind.Kind, err)
+		}
+		return object.Spec.ClusterID, nil
+	}
+
+	// The API servers before 1.15 don't send the object being deleted
+	switch request.Kind.Kind {
+	case "Cluster":
+		cluster, err := v.clusterLister.Get(request.Name)
+		if err != nil {
+			return "", fmt.Errorf("error retrieving the Cluster %s: %v", request.Name, err)
+		}
+		return cluster.Spec.ClusterID, nil
+	case "Endpoint":
+		endpoint, err := v.endpointLister.Get(request.Name)
+		if err != nil {
+			return "", fmt.Errorf("error retrieving the Endpoint %s: %v", request.Name, err)
+		}
+		return endpoint.Spec.ClusterID, nil
+	case "EndpointLease":
+		lease, err := v.leaseLister.Get(request.Name)
+		if err != nil {
+			return "", fmt.Errorf("error retrieving the EndpointLease %s: %v", request.Name, err)
+		}
+		return lease.Spec.ClusterID, nil
+	case "ServiceImport":
+		serviceImport, err := v.serviceImportLister.Get(request.Name)
+		if err != nil {
+			return "", fmt.Errorf("error retrieving the ServiceImport %s: %v", request.Name, err)
+		}
+		return serviceImport.Spec.ClusterID, nil
+	case "CIDRReservation":
+		reservation, err := v.reservationLister.Get(request.Name)
+		if err != nil {
+			return "", fmt.Errorf("error retrieving the CIDRReservation %s: %v", request.Name, err)
+		}
+		return reservation.Spec.ClusterID, nil
+	}
+	return "", fmt.Errorf("unexpected kind %s", request.Kind.Kind)
+}
+
+// validateIdentity checks that the requesting user may write the objects of the given cluster
+func (v *Validator) validateIdentity(request *admissionv1beta1.AdmissionRequest, clusterID string) error {
+	userInfo := request.UserInfo
+	for _, group := range userInfo.Groups {
+		for _, adminGroup := range v.config.AdminGroups {
+			if group == adminGroup {
+				return nil
+			}
+		}
+	}
+
+	identity, ok := v.clusterIDForUser(userInfo.Username)
+	if !ok {
+		return fmt.Errorf("user %s is not the service account of a member cluster", userInfo.Username)
+	}
+
+	if identity != clusterID {
+		return fmt.Errorf("the service account of cluster %q may not write the objects of cluster %q", identity, clusterID)
+	}
+	return nil
+}
+
+// clusterIDForUser returns the cluster ID of the member cluster using the given service account user name
+func (v *Validator) clusterIDForUser(username string) (string, bool) {
+	prefix := serviceAccountUsernamePrefix + v.config.ServiceAccountNamespace + ":" + v.config.ServiceAccountPrefix
+	if !strings.HasPrefix(username, prefix) || len(username) == len(prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(username, prefix), true
+}
+
+// validateCIDRs checks that the CIDRs are valid and don't overlap the ones of any other cluster
+func (v *Validator) validateCIDRs(clusterID string, cidrs []string) error {
+	for _, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %q: %v", cidr, err)
+		}
+	}
+
+	clusters, err := v.clusterLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing the existing Clusters: %v", err)
+	}
+
+	for _, cluster := range clusters {
+		if cluster.Spec.ClusterID == clusterID {
+			continue
+		}
+		if err = checkOverlap(cidrs, util.GetClusterRoutableCIDRs(cluster.Spec), cluster.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	endpoints, err := v.endpointLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing the existing Endpoints: %v", err)
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Spec.ClusterID == clusterID {
+			continue
+		}
+		if err = checkOverlap(cidrs, endpoint.Spec.Subnets, endpoint.Spec.ClusterID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkOverlap(cidrs []string, existingCIDRs []string, existingClusterID string) error {
+	for _, cidr := range cidrs {
+		overlaps, err := netutil.IsOverlappingCIDR(existingCIDRs, cidr)
+		if err != nil {
+			klog.Warningf("Error checking CIDR %s against the CIDRs %v of cluster %s: %v", cidr, existingCIDRs,
+				existingClusterID, err)
+			continue
+		}
+		if overlaps {
+			return fmt.Errorf("CIDR %s overlaps with the CIDRs %v of cluster %q", cidr, existingCIDRs, existingClusterID)
+		}
+	}
+	return nil
+}
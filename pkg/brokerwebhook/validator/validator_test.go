@@ -0,0 +1,483 @@
+package validator_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/brokerwebhook/validator"
+	listers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/types"
+	"github.com/rancher/submariner/pkg/util"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+const brokerNamespace = "submariner-k8s-broker"
+
+var _ = Describe("Validator", func() {
+	var v *validator.Validator
+	var clusters, endpoints, leases, serviceImports, reservations cache.Indexer
+
+	BeforeEach(func() {
+		clusters = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		endpoints = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		leases = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		serviceImports = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		reservations = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		Expect(clusters.Add(newCluster("east", "10.0.0.0/16", "10.96.0.0/16"))).To(Succeed())
+		Expect(endpoints.Add(newEndpoint("east", "10.0.0.0/16", "10.96.0.0/16"))).To(Succeed())
+
+		v = validator.NewValidator(validator.Config{
+			ServiceAccountNamespace: brokerNamespace,
+			ServiceAccountPrefix:    "submariner-",
+			AdminGroups:             []string{"system:masters"},
+			LeaseTimeout:            time.Minute,
+		}, listers.NewClusterLister(clusters).Clusters(brokerNamespace), listers.NewEndpointLister(endpoints).Endpoints(brokerNamespace),
+			listers.NewEndpointLeaseLister(leases).EndpointLeases(brokerNamespace),
+			listers.NewServiceImportLister(serviceImports).ServiceImports(brokerNamespace),
+			listers.NewCIDRReservationLister(reservations).CIDRReservations(brokerNamespace))
+	})
+
+	Describe("Cluster writes", func() {
+		Context("by the service account of the cluster", func() {
+			It("should be allowed", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", newCluster("west", "10.1.0.0/16", "10.97.0.0/16"), nil))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by the service account of another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "north", newCluster("west", "10.1.0.0/16", "10.97.0.0/16"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("that change the cluster ID of another cluster's object", func() {
+			It("should be denied", func() {
+				old := newCluster("east", "10.0.0.0/16", "10.96.0.0/16")
+				updated := newCluster("west", "10.0.0.0/16", "10.96.0.0/16")
+				updated.Name = "east"
+				response := v.Validate(newRequest(admissionv1beta1.Update, "Cluster", "west", updated, old))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("by a user that is not a cluster service account", func() {
+			It("should be denied", func() {
+				request := newRequest(admissionv1beta1.Create, "Cluster", "west", newCluster("west", "10.1.0.0/16", "10.97.0.0/16"), nil)
+				request.UserInfo = authenticationv1.UserInfo{Username: "jane"}
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+			})
+		})
+
+		Context("by a member of an admin group", func() {
+			It("should be allowed for any cluster", func() {
+				request := newRequest(admissionv1beta1.Create, "Cluster", "west", newCluster("west", "10.1.0.0/16", "10.97.0.0/16"), nil)
+				request.UserInfo = authenticationv1.UserInfo{Username: "admin", Groups: []string{"system:masters"}}
+				Expect(v.Validate(request).Allowed).To(BeTrue())
+			})
+		})
+
+		Context("with CIDRs overlapping another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", newCluster("west", "10.0.128.0/17", "10.97.0.0/16"), nil))
+				Expect(response.Allowed).To(BeFalse())
+				Expect(response.Result.Message).To(ContainSubstring("east"))
+			})
+		})
+
+		Context("that update the cluster's own CIDRs", func() {
+			It("should be allowed", func() {
+				old := newCluster("east", "10.0.0.0/16", "10.96.0.0/16")
+				response := v.Validate(newRequest(admissionv1beta1.Update, "Cluster", "east", newCluster("east", "10.0.0.0/15", "10.96.0.0/16"), old))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("with a missing cluster CIDR", func() {
+			It("should be denied", func() {
+				cluster := newCluster("west", "10.1.0.0/16", "10.97.0.0/16")
+				cluster.Spec.ClusterCIDR = nil
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil)).Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with an invalid CIDR", func() {
+			It("should be denied", func() {
+				cluster := newCluster("west", "10.1.0.0", "10.97.0.0/16")
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil)).Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a node CIDR overlapping another cluster", func() {
+			It("should be denied", func() {
+				cluster := newCluster("west", "10.1.0.0/16", "10.97.0.0/16")
+				cluster.Spec.NodeCIDR = []string{"10.96.128.0/24"}
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil))
+				Expect(response.Allowed).To(BeFalse())
+				Expect(response.Result.Message).To(ContainSubstring("east"))
+			})
+		})
+
+		Context("with an invalid node CIDR", func() {
+			It("should be denied", func() {
+				cluster := newCluster("west", "10.1.0.0/16", "10.97.0.0/16")
+				cluster.Spec.NodeCIDR = []string{"192.168.10.0"}
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil)).Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a name that doesn't match the cluster ID", func() {
+			It("should be denied", func() {
+				cluster := newCluster("west", "10.1.0.0/16", "10.97.0.0/16")
+				cluster.Name = "east"
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil)).Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a role", func() {
+			It("should be allowed for the hub and spoke roles only", func() {
+				cluster := newCluster("west", "10.1.0.0/16", "10.97.0.0/16")
+				cluster.Spec.Role = submarinerv1.ClusterRoleSpoke
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil)).Allowed).To(BeTrue())
+
+				cluster.Spec.Role = "leaf"
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Cluster", "west", cluster, nil)).Allowed).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("Endpoint writes", func() {
+		Context("by the service account of the cluster", func() {
+			It("should be allowed", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Endpoint", "west", newEndpoint("west", "10.1.0.0/16"), nil))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by the service account of another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Endpoint", "north", newEndpoint("west", "10.1.0.0/16"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with subnets overlapping another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "Endpoint", "west", newEndpoint("west", "10.96.0.0/24"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("that leave the spec of an endpoint unchanged", func() {
+			It("should be allowed without checking its subnets again", func() {
+				old := newEndpoint("west", "10.96.0.0/24")
+				labelled := newEndpoint("west", "10.96.0.0/24")
+				labelled.Labels = map[string]string{"app": "submariner"}
+				response := v.Validate(newRequest(admissionv1beta1.Update, "Endpoint", "west", labelled, old))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("that change the subnets of an endpoint to overlap another cluster", func() {
+			It("should be denied", func() {
+				old := newEndpoint("west", "10.1.0.0/16")
+				response := v.Validate(newRequest(admissionv1beta1.Update, "Endpoint", "west", newEndpoint("west", "10.96.0.0/24"), old))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a missing private IP", func() {
+			It("should be denied", func() {
+				endpoint := newEndpoint("west", "10.1.0.0/16")
+				endpoint.Spec.PrivateIP = nil
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "Endpoint", "west", endpoint, nil)).Allowed).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("EndpointLease writes", func() {
+		Context("by the service account of the cluster", func() {
+			It("should be allowed", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "EndpointLease", "west", newEndpointLease("west"), nil))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by the service account of another cluster", func() {
+			It("should be denied", func() {
+				old := newEndpointLease("east")
+				response := v.Validate(newRequest(admissionv1beta1.Update, "EndpointLease", "north", newEndpointLease("east"), old))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a name other than the one of its endpoint", func() {
+			It("should be denied", func() {
+				lease := newEndpointLease("west")
+				lease.Name = "west"
+				Expect(v.Validate(newRequest(admissionv1beta1.Create, "EndpointLease", "west", lease, nil)).Allowed).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("ServiceImport writes", func() {
+		Context("by the service account of the cluster", func() {
+			It("should be allowed", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "ServiceImport", "west", newServiceImport("west", "10.1.0.10"), nil))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by the service account of another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "ServiceImport", "north", newServiceImport("west", "10.1.0.10"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with an invalid IP", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "ServiceImport", "west", newServiceImport("west", "10.1.0"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("CIDRReservation writes", func() {
+		Context("by the service account of the cluster", func() {
+			It("should be allowed", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "CIDRReservation", "west", newCIDRReservation("west", "cluster", "10.1.0.0/16"), nil))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by the service account of another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "CIDRReservation", "north", newCIDRReservation("west", "cluster", "10.1.0.0/16"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with an unknown pool", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "CIDRReservation", "west", newCIDRReservation("west", "pods", "10.1.0.0/16"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a CIDR overlapping another cluster", func() {
+			It("should be denied", func() {
+				response := v.Validate(newRequest(admissionv1beta1.Create, "CIDRReservation", "west", newCIDRReservation("west", "cluster", "10.0.0.0/12"), nil))
+				Expect(response.Allowed).To(BeFalse())
+			})
+		})
+
+		Context("with a CIDR overlapping a block of another size reserved by another cluster", func() {
+			It("should be denied as a conflict", func() {
+				Expect(reservations.Add(newCIDRReservation("north", "cluster", "10.2.0.0/16"))).To(Succeed())
+				response := v.Validate(newRequest(admissionv1beta1.Create, "CIDRReservation", "west", newCIDRReservation("west", "cluster", "10.2.128.0/17"), nil))
+				Expect(response.Allowed).To(BeFalse())
+				Expect(response.Result.Reason).To(Equal(metav1.StatusReasonConflict))
+			})
+		})
+
+		Context("with a CIDR overlapping a block reserved from another pool", func() {
+			It("should be denied as a conflict", func() {
+				Expect(reservations.Add(newCIDRReservation("north", "service", "10.2.0.0/16"))).To(Succeed())
+				response := v.Validate(newRequest(admissionv1beta1.Create, "CIDRReservation", "west", newCIDRReservation("west", "cluster", "10.2.0.0/16"), nil))
+				Expect(response.Allowed).To(BeFalse())
+				Expect(response.Result.Reason).To(Equal(metav1.StatusReasonConflict))
+			})
+		})
+
+		Context("that rewrite an existing reservation", func() {
+			It("should be allowed", func() {
+				reservation := newCIDRReservation("west", "cluster", "10.2.0.0/16")
+				Expect(reservations.Add(reservation)).To(Succeed())
+				response := v.Validate(newRequest(admissionv1beta1.Update, "CIDRReservation", "west", reservation, reservation))
+				Expect(response.Allowed).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Deletions", func() {
+		Context("by the service account of the cluster", func() {
+			It("should be allowed", func() {
+				request := newRequest(admissionv1beta1.Delete, "ServiceImport", "east", nil, newServiceImport("east", "10.0.0.10"))
+				Expect(v.Validate(request).Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by the service account of another cluster", func() {
+			It("should be denied for clusters, service imports and CIDR reservations", func() {
+				request := newRequest(admissionv1beta1.Delete, "Cluster", "north", nil, newCluster("east", "10.0.0.0/16", "10.96.0.0/16"))
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+
+				request = newRequest(admissionv1beta1.Delete, "ServiceImport", "north", nil, newServiceImport("east", "10.0.0.10"))
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+
+				request = newRequest(admissionv1beta1.Delete, "CIDRReservation", "north", nil,
+					newCIDRReservation("east", "cluster", "10.0.0.0/16"))
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+			})
+
+			It("should be denied for an endpoint without a lease", func() {
+				request := newRequest(admissionv1beta1.Delete, "Endpoint", "north", nil, newEndpoint("east", "10.0.0.0/16"))
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+			})
+
+			It("should be denied for an endpoint whose lease is still valid", func() {
+				Expect(leases.Add(newEndpointLease("east"))).To(Succeed())
+				request := newRequest(admissionv1beta1.Delete, "Endpoint", "north", nil, newEndpoint("east", "10.0.0.0/16"))
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+			})
+
+			It("should be allowed for an endpoint and its lease once the lease has expired", func() {
+				lease := newEndpointLease("east")
+				lease.Spec.RenewTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+				Expect(leases.Add(lease)).To(Succeed())
+
+				request := newRequest(admissionv1beta1.Delete, "Endpoint", "north", nil, newEndpoint("east", "10.0.0.0/16"))
+				Expect(v.Validate(request).Allowed).To(BeTrue())
+
+				request = newRequest(admissionv1beta1.Delete, "EndpointLease", "north", nil, lease)
+				Expect(v.Validate(request).Allowed).To(BeTrue())
+			})
+
+			It("should be denied when the API server doesn't send the deleted object", func() {
+				request := newRequest(admissionv1beta1.Delete, "Cluster", "north", nil, nil)
+				request.Name = "east"
+				Expect(v.Validate(request).Allowed).To(BeFalse())
+
+				request.UserInfo.Username = "system:serviceaccount:" + brokerNamespace + ":submariner-east"
+				Expect(v.Validate(request).Allowed).To(BeTrue())
+			})
+		})
+
+		Context("by an admin", func() {
+			It("should be allowed", func() {
+				request := newRequest(admissionv1beta1.Delete, "Cluster", "north", nil, newCluster("east", "10.0.0.0/16", "10.96.0.0/16"))
+				request.UserInfo.Groups = []string{"system:masters"}
+				Expect(v.Validate(request).Allowed).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("The HTTP handler", func() {
+		It("should respond with the AdmissionReview for the request", func() {
+			request := newRequest(admissionv1beta1.Create, "Cluster", "north", newCluster("west", "10.1.0.0/16", "10.97.0.0/16"), nil)
+			request.UID = "1234"
+			body, err := json.Marshal(admissionv1beta1.AdmissionReview{Request: request})
+			Expect(err).ToNot(HaveOccurred())
+
+			recorder := httptest.NewRecorder()
+			v.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			review := admissionv1beta1.AdmissionReview{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &review)).To(Succeed())
+			Expect(review.Response).ToNot(BeNil())
+			Expect(review.Response.UID).To(Equal(request.UID))
+			Expect(review.Response.Allowed).To(BeFalse())
+		})
+
+		It("should reject malformed requests", func() {
+			recorder := httptest.NewRecorder()
+			v.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{"))))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
+
+func newRequest(operation admissionv1beta1.Operation, kind, identity string, object, oldObject runtime.Object) *admissionv1beta1.AdmissionRequest {
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "submariner.io", Version: "v1", Kind: kind},
+		Namespace: brokerNamespace,
+		Operation: operation,
+		UserInfo: authenticationv1.UserInfo{
+			Username: "system:serviceaccount:" + brokerNamespace + ":submariner-" + identity,
+		},
+	}
+
+	if object != nil {
+		request.Name = object.(metav1.Object).GetName()
+		request.Object.Raw, _ = json.Marshal(object)
+	}
+	if oldObject != nil {
+		request.Name = oldObject.(metav1.Object).GetName()
+		request.OldObject.Raw, _ = json.Marshal(oldObject)
+	}
+	return request
+}
+
+func newCluster(clusterID string, clusterCIDR, serviceCIDR string) *submarinerv1.Cluster {
+	return &submarinerv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterID, Namespace: brokerNamespace},
+		Spec: submarinerv1.ClusterSpec{
+			ClusterID:   clusterID,
+			ClusterCIDR: []string{clusterCIDR},
+			ServiceCIDR: []string{serviceCIDR},
+		},
+	}
+}
+
+func newEndpoint(clusterID string, subnets ...string) *submarinerv1.Endpoint {
+	cableName := "submariner-cable-" + clusterID + "-192-168-0-1"
+	return &submarinerv1.Endpoint{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterID + "-" + cableName, Namespace: brokerNamespace},
+		Spec: submarinerv1.EndpointSpec{
+			ClusterID: clusterID,
+			CableName: cableName,
+			Hostname:  "gateway-" + clusterID,
+			Backend:   "ipsec",
+			PrivateIP: net.ParseIP("192.168.0.1"),
+			Subnets:   subnets,
+		},
+	}
+}
+
+func newEndpointLease(clusterID string) *submarinerv1.EndpointLease {
+	endpoint := newEndpoint(clusterID)
+	return &submarinerv1.EndpointLease{
+		ObjectMeta: metav1.ObjectMeta{Name: endpoint.Name, Namespace: brokerNamespace},
+		Spec: submarinerv1.EndpointLeaseSpec{
+			ClusterID: clusterID,
+			CableName: endpoint.Spec.CableName,
+			RenewTime: metav1.Now(),
+		},
+	}
+}
+
+func newCIDRReservation(clusterID, pool, cidr string) *submarinerv1.CIDRReservation {
+	spec := submarinerv1.CIDRReservationSpec{ClusterID: clusterID, Pool: pool, CIDR: cidr}
+	name, _ := util.GetCIDRReservationCRDName(&types.SubmarinerCIDRReservation{Spec: spec})
+	return &submarinerv1.CIDRReservation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: brokerNamespace},
+		Spec:       spec,
+	}
+}
+
+func newServiceImport(clusterID string, ip string) *submarinerv1.ServiceImport {
+	return &submarinerv1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterID + "-default-nginx", Namespace: brokerNamespace},
+		Spec: submarinerv1.ServiceImportSpec{
+			ClusterID:   clusterID,
+			Namespace:   "default",
+			ServiceName: "nginx",
+			IPs:         []string{ip},
+		},
+	}
+}
@@ -8,4 +8,6 @@ type Engine interface {
 	StartEngine() error
 	InstallCable(types.SubmarinerEndpoint) error
 	RemoveCable(string) error
+	// UpdateLocalEndpoint applies the changes of the local endpoint, such as its subnets, to the installed cables
+	UpdateLocalEndpoint(types.SubmarinerEndpoint) error
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -31,6 +32,11 @@ const (
 
 	// DefaultChildSaRekeyInterval specifies the default rekey interval for CHILD_SA
 	DefaultChildSaRekeyInterval = "1h"
+
+	// The reqids of the child SAs of the cables are allocated from [ReqIDBase, ReqIDBase+ReqIDCount), so that the
+	// XFRM policies charon installs for them can be told apart from the ones of other IPsec software
+	ReqIDBase  = 0x5b0000
+	ReqIDCount = 0x10000
 )
 
 type engine struct {
@@ -46,6 +52,11 @@ type engine struct {
 
 	debug   bool
 	logFile string
+
+	// The remote endpoints of the cables loaded in charon, keyed by cable name
+	installedEndpoints map[string]types.SubmarinerEndpoint
+	// The reqids of the child SAs of the cables, keyed by cable name
+	reqIDs map[string]int
 }
 
 type specification struct {
@@ -73,6 +84,8 @@ func NewEngine(localSubnets []string, localCluster types.SubmarinerCluster, loca
 		secretKey:                 ipSecSpec.PSK,
 		debug:                     ipSecSpec.Debug,
 		logFile:                   ipSecSpec.LogFile,
+		installedEndpoints:        map[string]types.SubmarinerEndpoint{},
+		reqIDs:                    map[string]int{},
 	}, nil
 }
 
@@ -164,6 +177,11 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 		return nil
 	}
 
+	// The check of the active connections and the loading of the cable must not interleave with the installation of
+	// another cable of the same cluster by another tunnel worker
+	i.Lock()
+	defer i.Unlock()
+
 	klog.V(2).Infof("Installing cable %s", endpoint.Spec.CableName)
 	activeConnections, err := i.getActiveConns(endpoint.Spec.ClusterID, client)
 	if err != nil {
@@ -172,17 +190,109 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 	for _, active := range activeConnections {
 		klog.V(6).Infof("Analyzing currently active connection: %s", active)
 		if active == endpoint.Spec.CableName {
-			klog.V(6).Infof("Cable %s is already installed, not installing twice", active)
-			return nil
+			if i.isInstalled(endpoint) {
+				klog.V(6).Infof("Cable %s is already installed, not installing twice", active)
+				return nil
+			}
+
+			klog.Infof("The endpoint of cable %s changed, reloading it", active)
+			return i.reloadCableLocked(endpoint, client)
 		}
 		if util.GetClusterIDFromCableName(active) == endpoint.Spec.ClusterID {
 			return fmt.Errorf("error while installing cable %s, already found a pre-existing cable belonging to this cluster %s", active, endpoint.Spec.ClusterID)
 		}
 	}
 
+	return i.loadCable(endpoint, client)
+}
+
+// isInstalled returns true if the cable was loaded for the same endpoint spec and transit subnets, it must be called
+// with the lock held
+func (i *engine) isInstalled(endpoint types.SubmarinerEndpoint) bool {
+	installed, ok := i.installedEndpoints[endpoint.Spec.CableName]
+	return ok && reflect.DeepEqual(installed, endpoint)
+}
+
+// reloadCable loads the connection again with the current local and remote settings, and renegotiates its child
+// SA so that the new traffic selectors apply without tearing down the IKE SA
+func (i *engine) reloadCable(endpoint types.SubmarinerEndpoint, client *goStrongswanVici.ClientConn) error {
 	i.Lock()
 	defer i.Unlock()
 
+	return i.reloadCableLocked(endpoint, client)
+}
+
+// reloadCableLocked is reloadCable, it must be called with the lock held
+func (i *engine) reloadCableLocked(endpoint types.SubmarinerEndpoint, client *goStrongswanVici.ClientConn) error {
+	if previous, ok := i.installedEndpoints[endpoint.Spec.CableName]; ok {
+		i.removeCableRules(previous, i.localSubnets)
+
+		var removedSubnets []string
+		for _, subnet := range remoteSubnets(previous) {
+			if !containsString(remoteSubnets(endpoint), subnet) {
+				removedSubnets = append(removedSubnets, subnet)
+			}
+		}
+		removeRemoteSubnetRules(removedSubnets)
+	}
+
+	if err := i.loadCable(endpoint, client); err != nil {
+		return err
+	}
+
+	child := "submariner-child-" + endpoint.Spec.CableName
+	if err := client.Terminate(&goStrongswanVici.TerminateRequest{Child: child}); err != nil {
+		klog.Warningf("Error terminating child SA %s: %v", child, err)
+	}
+
+	if err := client.Initiate(child, endpoint.Spec.CableName); err != nil {
+		return fmt.Errorf("Error initiating child SA %s: %v", child, err)
+	}
+	return nil
+}
+
+// UpdateLocalEndpoint reloads the installed cables when the local endpoint changes, the endpoints of the other
+// gateways of the local cluster are ignored
+func (i *engine) UpdateLocalEndpoint(endpoint types.SubmarinerEndpoint) error {
+	i.Lock()
+	if endpoint.Spec.CableName != i.localEndpoint.Spec.CableName || reflect.DeepEqual(endpoint.Spec, i.localEndpoint.Spec) {
+		i.Unlock()
+		return nil
+	}
+
+	klog.Infof("The local endpoint changed from %#v to %#v, reloading the cables", i.localEndpoint.Spec, endpoint.Spec)
+	previousSubnets := i.localSubnets
+	i.localEndpoint = endpoint
+	i.localSubnets = endpoint.Spec.Subnets
+
+	var installed []types.SubmarinerEndpoint
+	for _, remote := range i.installedEndpoints {
+		i.removeCableRules(remote, previousSubnets)
+		installed = append(installed, remote)
+	}
+	i.Unlock()
+
+	if len(installed) == 0 {
+		return nil
+	}
+
+	client, err := getClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for _, remote := range installed {
+		if err = i.reloadCable(remote, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCable loads the connection to the endpoint in charon and installs its iptables rules, it must be called
+// with the lock held
+func (i *engine) loadCable(endpoint types.SubmarinerEndpoint, client *goStrongswanVici.ClientConn) error {
 	if err := i.loadSharedKey(endpoint, client); err != nil {
 		return fmt.Errorf("Encountered issue while trying to load shared keys: %v", err)
 	}
@@ -200,13 +310,18 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 	var localTs, remoteTs, localAddr, remoteAddr []string
 	localTs = append(localTs, fmt.Sprintf("%s/32", i.localEndpoint.Spec.PrivateIP.String()))
 	localTs = append(localTs, i.localSubnets...)
+	localTs = append(localTs, endpoint.LocalTransitSubnets...)
 
 	localAddr = append(localAddr, i.localEndpoint.Spec.PrivateIP.String())
 
 	remoteTs = append(remoteTs, fmt.Sprintf("%s/32", endpoint.Spec.PrivateIP.String()))
-	remoteTs = append(remoteTs, endpoint.Spec.Subnets...)
+	remoteTs = append(remoteTs, remoteSubnets(endpoint)...)
 
 	remoteAddr = append(remoteAddr, remoteEndpointIP)
+	reqID, err := i.reqID(endpoint.Spec.CableName)
+	if err != nil {
+		return err
+	}
 	// todo: make the ESP proposals configurable
 	childSAConf := goStrongswanVici.ChildSAConf{
 		Local_ts:      localTs,
@@ -215,7 +330,7 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 		StartAction:   "start",
 		CloseAction:   "restart",
 		Mode:          "tunnel",
-		ReqID:         "0",
+		ReqID:         strconv.Itoa(reqID),
 		RekeyTime:     i.ipSecChildSaRekeyInterval,
 		InstallPolicy: "yes",
 	}
@@ -256,6 +371,7 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 	if err != nil {
 		return fmt.Errorf("failed loading connection %s: %v", endpoint.Spec.CableName, err)
 	}
+	i.installedEndpoints[endpoint.Spec.CableName] = endpoint
 
 	ifi, err := util.GetDefaultGatewayInterface()
 	if err != nil {
@@ -280,7 +396,7 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 		}
 
 		if ipAddr.To4() != nil {
-			for _, subnet := range endpoint.Spec.Subnets {
+			for _, subnet := range remoteSubnets(endpoint) {
 				ruleSpec := []string{"-s", ipNet.String(), "-d", subnet, "-i", ifi.Name, "-j", "ACCEPT"}
 				klog.V(8).Infof("Installing iptables rule: %s", strings.Join(ruleSpec, " "))
 				if err = ipt.AppendUnique("filter", "SUBMARINER-FORWARD", ruleSpec...); err != nil {
@@ -293,6 +409,11 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 					klog.Errorf("error appending iptables rule \"%s\": %v\n", strings.Join(ruleSpec, " "), err)
 				}
 
+				// The traffic of the node network keeps its source when the node CIDR is one of the local subnets
+				if isInSubnets(ipNet, i.localSubnets) {
+					continue
+				}
+
 				// -t nat -I POSTROUTING -s <local-network-cidr> -d <remote-cidr> -j SNAT --to-source <this-local-ip>
 				ruleSpec = []string{"-s", ipNet.String(), "-d", subnet, "-j", "SNAT", "--to-source", ipAddr.String()}
 				klog.V(8).Infof("Installing iptables rule: %s", strings.Join(ruleSpec, " "))
@@ -316,11 +437,68 @@ func (i *engine) installCableInternal(endpoint types.SubmarinerEndpoint, client
 		}
 	}
 
+	// A hub forwards the traffic of the remote cluster to the clusters it isn't connected to
+	for _, ruleSpec := range transitRules(endpoint) {
+		klog.V(8).Infof("Installing iptables rule for transit traffic: %v", ruleSpec)
+		if err = ipt.AppendUnique("filter", "SUBMARINER-FORWARD", ruleSpec...); err != nil {
+			klog.Errorf("error appending iptables transit rule \"%s\": %v\n", strings.Join(ruleSpec, " "), err)
+		}
+	}
+
 	klog.V(2).Infof("Loaded connection: %v", endpoint.Spec.CableName)
 
 	return nil
 }
 
+// removeCableRules removes the iptables rules that masquerade the traffic of the endpoint to the given local
+// subnets and that forward its transit traffic, the rules are installed again for the current subnets when the
+// cable is loaded
+func (i *engine) removeCableRules(endpoint types.SubmarinerEndpoint, localSubnets []string) {
+	ipt, err := iptables.New()
+	if err != nil {
+		klog.Errorf("Error while initializing iptables: %v", err)
+		return
+	}
+
+	remoteEndpointIP := endpoint.Spec.PrivateIP.String()
+	if endpoint.Spec.NATEnabled {
+		remoteEndpointIP = endpoint.Spec.PublicIP.String()
+	}
+
+	for _, localSubnet := range localSubnets {
+		ruleSpec := []string{"-s", remoteEndpointIP, "-d", localSubnet, "-j", "MASQUERADE"}
+		klog.V(8).Infof("Removing iptables rule for MASQ incoming traffic: %v", ruleSpec)
+		if err = ipt.Delete("nat", "SUBMARINER-POSTROUTING", ruleSpec...); err != nil {
+			klog.V(6).Infof("Error deleting iptables MASQ rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+		}
+	}
+
+	for _, ruleSpec := range transitRules(endpoint) {
+		klog.V(8).Infof("Removing iptables rule for transit traffic: %v", ruleSpec)
+		if err = ipt.Delete("filter", "SUBMARINER-FORWARD", ruleSpec...); err != nil {
+			klog.V(6).Infof("Error deleting iptables transit rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+		}
+	}
+}
+
+// remoteSubnets returns the subnets reached through the cable to the endpoint, including the subnets of the
+// clusters which the remote hub forwards the traffic to
+func remoteSubnets(endpoint types.SubmarinerEndpoint) []string {
+	return append(append([]string{}, endpoint.Spec.Subnets...), endpoint.RemoteTransitSubnets...)
+}
+
+// transitRules returns the rules which accept the traffic from the subnets of the endpoint to the subnets of the
+// clusters which the local hub forwards it to
+func transitRules(endpoint types.SubmarinerEndpoint) [][]string {
+	var ruleSpecs [][]string
+	for _, subnet := range endpoint.Spec.Subnets {
+		for _, transitSubnet := range endpoint.LocalTransitSubnets {
+			ruleSpecs = append(ruleSpecs, []string{"-s", subnet, "-d", transitSubnet, "-j", "ACCEPT"})
+		}
+	}
+	return ruleSpecs
+}
+
 func (i *engine) RemoveCable(cableID string) error {
 	client, err := getClient()
 	if err != nil {
@@ -336,6 +514,12 @@ func (i *engine) removeCableInternal(cableID string, client *goStrongswanVici.Cl
 	i.Lock()
 	defer i.Unlock()
 
+	if endpoint, ok := i.installedEndpoints[cableID]; ok {
+		i.removeCableRules(endpoint, i.localSubnets)
+		delete(i.installedEndpoints, cableID)
+	}
+	delete(i.reqIDs, cableID)
+
 	klog.Infof("Unloading connection %s", cableID)
 	err := client.UnloadConn(&goStrongswanVici.UnloadConnRequest{
 		Name: cableID,
@@ -403,9 +587,8 @@ func (i *engine) removeCableInternal(cableID string, client *goStrongswanVici.Cl
 	return nil
 }
 
+// getActiveConns returns the connections loaded in charon for the cluster, it must be called with the lock held
 func (i *engine) getActiveConns(clusterID string, client *goStrongswanVici.ClientConn) ([]string, error) {
-	i.Lock()
-	defer i.Unlock()
 	var connections []string
 	prefix := fmt.Sprintf("submariner-cable-%s-", clusterID)
 
@@ -454,6 +637,115 @@ func (i *engine) loadSharedKey(endpoint types.SubmarinerEndpoint, client *goStro
 	return nil
 }
 
+// reqID returns the reqid of the child SA of the cable, allocating the lowest free one for a new cable. It must be
+// called with the lock held.
+func (i *engine) reqID(cableName string) (int, error) {
+	if reqID, ok := i.reqIDs[cableName]; ok {
+		return reqID, nil
+	}
+
+	reqID, err := allocateReqID(i.reqIDs)
+	if err != nil {
+		return 0, fmt.Errorf("Error allocating a reqid for cable %s: %v", cableName, err)
+	}
+	i.reqIDs[cableName] = reqID
+	return reqID, nil
+}
+
+func allocateReqID(allocated map[string]int) (int, error) {
+	used := map[int]bool{}
+	for _, reqID := range allocated {
+		used[reqID] = true
+	}
+
+	for reqID := ReqIDBase; reqID < ReqIDBase+ReqIDCount; reqID++ {
+		if !used[reqID] {
+			return reqID, nil
+		}
+	}
+	return 0, fmt.Errorf("all the %d reqids are in use", ReqIDCount)
+}
+
+// IsOwnReqID returns whether the reqid belongs to the range of the cables
+func IsOwnReqID(reqID int) bool {
+	return reqID >= ReqIDBase && reqID < ReqIDBase+ReqIDCount
+}
+
+// removeRemoteSubnetRules removes the forwarding and SNAT rules installed for the subnets of a remote endpoint
+func removeRemoteSubnetRules(subnets []string) {
+	if len(subnets) == 0 {
+		return
+	}
+
+	ifi, err := util.GetDefaultGatewayInterface()
+	if err != nil {
+		klog.Errorf("Error retrieving the default gateway interface: %v", err)
+		return
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		klog.Errorf("Error while initializing iptables: %v", err)
+		return
+	}
+
+	addresses, err := ifi.Addrs()
+	if err != nil {
+		klog.Errorf("Error retrieving the addresses of %s: %v", ifi.Name, err)
+		return
+	}
+
+	for _, addr := range addresses {
+		ipAddr, ipNet, err := net.ParseCIDR(addr.String())
+		if err != nil || ipAddr.To4() == nil {
+			continue
+		}
+
+		for _, subnet := range subnets {
+			rules := []struct {
+				table, chain string
+				ruleSpec     []string
+			}{
+				{"filter", "SUBMARINER-FORWARD", []string{"-s", ipNet.String(), "-d", subnet, "-i", ifi.Name, "-j", "ACCEPT"}},
+				{"filter", "SUBMARINER-FORWARD", []string{"-d", ipNet.String(), "-s", subnet, "-i", ifi.Name, "-j", "ACCEPT"}},
+				{"nat", "SUBMARINER-POSTROUTING", []string{"-s", ipNet.String(), "-d", subnet, "-j", "SNAT", "--to-source", ipAddr.String()}},
+			}
+
+			for _, rule := range rules {
+				klog.V(8).Infof("Removing iptables rule: %s", strings.Join(rule.ruleSpec, " "))
+				if err = ipt.Delete(rule.table, rule.chain, rule.ruleSpec...); err != nil {
+					klog.V(6).Infof("Error deleting iptables rule \"%s\": %v", strings.Join(rule.ruleSpec, " "), err)
+				}
+			}
+		}
+	}
+}
+
+// isInSubnets returns whether the network is contained in one of the subnets
+func isInSubnets(ipNet *net.IPNet, subnets []string) bool {
+	ones, _ := ipNet.Mask.Size()
+	for _, subnet := range subnets {
+		_, subnetNet, err := net.ParseCIDR(subnet)
+		if err != nil {
+			continue
+		}
+		subnetOnes, _ := subnetNet.Mask.Size()
+		if subnetOnes <= ones && subnetNet.Contains(ipNet.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(c []string, s string) bool {
+	for _, v := range c {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func runCharon(debug bool, logFile string) error {
 	klog.Infof("Starting Charon")
 	// Ignore error
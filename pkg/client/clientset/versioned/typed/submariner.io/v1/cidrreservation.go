@@ -0,0 +1,174 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	scheme "github.com/rancher/submariner/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// CIDRReservationsGetter has a method to return a CIDRReservationInterface.
+// A group's client should implement this interface.
+type CIDRReservationsGetter interface {
+	CIDRReservations(namespace string) CIDRReservationInterface
+}
+
+// CIDRReservationInterface has methods to work with CIDRReservation resources.
+type CIDRReservationInterface interface {
+	Create(*v1.CIDRReservation) (*v1.CIDRReservation, error)
+	Update(*v1.CIDRReservation) (*v1.CIDRReservation, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (*v1.CIDRReservation, error)
+	List(opts metav1.ListOptions) (*v1.CIDRReservationList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.CIDRReservation, err error)
+	CIDRReservationExpansion
+}
+
+// cIDRReservations implements CIDRReservationInterface
+type cIDRReservations struct {
+	client rest.Interface
+	ns     string
+}
+
+// newCIDRReservations returns a CIDRReservations
+func newCIDRReservations(c *SubmarinerV1Client, namespace string) *cIDRReservations {
+	return &cIDRReservations{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the cIDRReservation, and returns the corresponding cIDRReservation object, and an error if there is any.
+func (c *cIDRReservations) Get(name string, options metav1.GetOptions) (result *v1.CIDRReservation, err error) {
+	result = &v1.CIDRReservation{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of CIDRReservations that match those selectors.
+func (c *cIDRReservations) List(opts metav1.ListOptions) (result *v1.CIDRReservationList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.CIDRReservationList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested cIDRReservations.
+func (c *cIDRReservations) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a cIDRReservation and creates it.  Returns the server's representation of the cIDRReservation, and an error, if there is any.
+func (c *cIDRReservations) Create(cIDRReservation *v1.CIDRReservation) (result *v1.CIDRReservation, err error) {
+	result = &v1.CIDRReservation{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		Body(cIDRReservation).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a cIDRReservation and updates it. Returns the server's representation of the cIDRReservation, and an error, if there is any.
+func (c *cIDRReservations) Update(cIDRReservation *v1.CIDRReservation) (result *v1.CIDRReservation, err error) {
+	result = &v1.CIDRReservation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		Name(cIDRReservation.Name).
+		Body(cIDRReservation).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the cIDRReservation and deletes it. Returns an error if one occurs.
+func (c *cIDRReservations) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *cIDRReservations) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched cIDRReservation.
+func (c *cIDRReservations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.CIDRReservation, err error) {
+	result = &v1.CIDRReservation{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("cidrreservations").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -0,0 +1,174 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	scheme "github.com/rancher/submariner/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// EndpointLeasesGetter has a method to return a EndpointLeaseInterface.
+// A group's client should implement this interface.
+type EndpointLeasesGetter interface {
+	EndpointLeases(namespace string) EndpointLeaseInterface
+}
+
+// EndpointLeaseInterface has methods to work with EndpointLease resources.
+type EndpointLeaseInterface interface {
+	Create(*v1.EndpointLease) (*v1.EndpointLease, error)
+	Update(*v1.EndpointLease) (*v1.EndpointLease, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (*v1.EndpointLease, error)
+	List(opts metav1.ListOptions) (*v1.EndpointLeaseList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.EndpointLease, err error)
+	EndpointLeaseExpansion
+}
+
+// endpointLeases implements EndpointLeaseInterface
+type endpointLeases struct {
+	client rest.Interface
+	ns     string
+}
+
+// newEndpointLeases returns a EndpointLeases
+func newEndpointLeases(c *SubmarinerV1Client, namespace string) *endpointLeases {
+	return &endpointLeases{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the endpointLease, and returns the corresponding endpointLease object, and an error if there is any.
+func (c *endpointLeases) Get(name string, options metav1.GetOptions) (result *v1.EndpointLease, err error) {
+	result = &v1.EndpointLease{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of EndpointLeases that match those selectors.
+func (c *endpointLeases) List(opts metav1.ListOptions) (result *v1.EndpointLeaseList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.EndpointLeaseList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested endpointLeases.
+func (c *endpointLeases) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a endpointLease and creates it.  Returns the server's representation of the endpointLease, and an error, if there is any.
+func (c *endpointLeases) Create(endpointLease *v1.EndpointLease) (result *v1.EndpointLease, err error) {
+	result = &v1.EndpointLease{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		Body(endpointLease).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a endpointLease and updates it. Returns the server's representation of the endpointLease, and an error, if there is any.
+func (c *endpointLeases) Update(endpointLease *v1.EndpointLease) (result *v1.EndpointLease, err error) {
+	result = &v1.EndpointLease{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		Name(endpointLease.Name).
+		Body(endpointLease).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the endpointLease and deletes it. Returns an error if one occurs.
+func (c *endpointLeases) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *endpointLeases) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("endpointleases").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched endpointLease.
+func (c *endpointLeases) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.EndpointLease, err error) {
+	result = &v1.EndpointLease{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("endpointleases").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -0,0 +1,128 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	submarineriov1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeCIDRReservations implements CIDRReservationInterface
+type FakeCIDRReservations struct {
+	Fake *FakeSubmarinerV1
+	ns   string
+}
+
+var cidrreservationsResource = schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "cidrreservations"}
+
+var cidrreservationsKind = schema.GroupVersionKind{Group: "submariner.io", Version: "v1", Kind: "CIDRReservation"}
+
+// Get takes name of the cIDRReservation, and returns the corresponding cIDRReservation object, and an error if there is any.
+func (c *FakeCIDRReservations) Get(name string, options v1.GetOptions) (result *submarineriov1.CIDRReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(cidrreservationsResource, c.ns, name), &submarineriov1.CIDRReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.CIDRReservation), err
+}
+
+// List takes label and field selectors, and returns the list of CIDRReservations that match those selectors.
+func (c *FakeCIDRReservations) List(opts v1.ListOptions) (result *submarineriov1.CIDRReservationList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(cidrreservationsResource, cidrreservationsKind, c.ns, opts), &submarineriov1.CIDRReservationList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &submarineriov1.CIDRReservationList{ListMeta: obj.(*submarineriov1.CIDRReservationList).ListMeta}
+	for _, item := range obj.(*submarineriov1.CIDRReservationList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested cIDRReservations.
+func (c *FakeCIDRReservations) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(cidrreservationsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a cIDRReservation and creates it.  Returns the server's representation of the cIDRReservation, and an error, if there is any.
+func (c *FakeCIDRReservations) Create(cIDRReservation *submarineriov1.CIDRReservation) (result *submarineriov1.CIDRReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(cidrreservationsResource, c.ns, cIDRReservation), &submarineriov1.CIDRReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.CIDRReservation), err
+}
+
+// Update takes the representation of a cIDRReservation and updates it. Returns the server's representation of the cIDRReservation, and an error, if there is any.
+func (c *FakeCIDRReservations) Update(cIDRReservation *submarineriov1.CIDRReservation) (result *submarineriov1.CIDRReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(cidrreservationsResource, c.ns, cIDRReservation), &submarineriov1.CIDRReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.CIDRReservation), err
+}
+
+// Delete takes name of the cIDRReservation and deletes it. Returns an error if one occurs.
+func (c *FakeCIDRReservations) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(cidrreservationsResource, c.ns, name), &submarineriov1.CIDRReservation{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeCIDRReservations) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(cidrreservationsResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &submarineriov1.CIDRReservationList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched cIDRReservation.
+func (c *FakeCIDRReservations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *submarineriov1.CIDRReservation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(cidrreservationsResource, c.ns, name, pt, data, subresources...), &submarineriov1.CIDRReservation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.CIDRReservation), err
+}
@@ -0,0 +1,128 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	submarineriov1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeEndpointLeases implements EndpointLeaseInterface
+type FakeEndpointLeases struct {
+	Fake *FakeSubmarinerV1
+	ns   string
+}
+
+var endpointleasesResource = schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "endpointleases"}
+
+var endpointleasesKind = schema.GroupVersionKind{Group: "submariner.io", Version: "v1", Kind: "EndpointLease"}
+
+// Get takes name of the endpointLease, and returns the corresponding endpointLease object, and an error if there is any.
+func (c *FakeEndpointLeases) Get(name string, options v1.GetOptions) (result *submarineriov1.EndpointLease, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(endpointleasesResource, c.ns, name), &submarineriov1.EndpointLease{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.EndpointLease), err
+}
+
+// List takes label and field selectors, and returns the list of EndpointLeases that match those selectors.
+func (c *FakeEndpointLeases) List(opts v1.ListOptions) (result *submarineriov1.EndpointLeaseList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(endpointleasesResource, endpointleasesKind, c.ns, opts), &submarineriov1.EndpointLeaseList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &submarineriov1.EndpointLeaseList{ListMeta: obj.(*submarineriov1.EndpointLeaseList).ListMeta}
+	for _, item := range obj.(*submarineriov1.EndpointLeaseList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested endpointLeases.
+func (c *FakeEndpointLeases) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(endpointleasesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a endpointLease and creates it.  Returns the server's representation of the endpointLease, and an error, if there is any.
+func (c *FakeEndpointLeases) Create(endpointLease *submarineriov1.EndpointLease) (result *submarineriov1.EndpointLease, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(endpointleasesResource, c.ns, endpointLease), &submarineriov1.EndpointLease{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.EndpointLease), err
+}
+
+// Update takes the representation of a endpointLease and updates it. Returns the server's representation of the endpointLease, and an error, if there is any.
+func (c *FakeEndpointLeases) Update(endpointLease *submarineriov1.EndpointLease) (result *submarineriov1.EndpointLease, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(endpointleasesResource, c.ns, endpointLease), &submarineriov1.EndpointLease{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.EndpointLease), err
+}
+
+// Delete takes name of the endpointLease and deletes it. Returns an error if one occurs.
+func (c *FakeEndpointLeases) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(endpointleasesResource, c.ns, name), &submarineriov1.EndpointLease{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeEndpointLeases) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(endpointleasesResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &submarineriov1.EndpointLeaseList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched endpointLease.
+func (c *FakeEndpointLeases) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *submarineriov1.EndpointLease, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(endpointleasesResource, c.ns, name, pt, data, subresources...), &submarineriov1.EndpointLease{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.EndpointLease), err
+}
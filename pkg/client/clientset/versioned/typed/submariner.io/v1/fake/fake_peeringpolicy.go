@@ -0,0 +1,128 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	submarineriov1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakePeeringPolicies implements PeeringPolicyInterface
+type FakePeeringPolicies struct {
+	Fake *FakeSubmarinerV1
+	ns   string
+}
+
+var peeringpoliciesResource = schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "peeringpolicies"}
+
+var peeringpoliciesKind = schema.GroupVersionKind{Group: "submariner.io", Version: "v1", Kind: "PeeringPolicy"}
+
+// Get takes name of the peeringPolicy, and returns the corresponding peeringPolicy object, and an error if there is any.
+func (c *FakePeeringPolicies) Get(name string, options v1.GetOptions) (result *submarineriov1.PeeringPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(peeringpoliciesResource, c.ns, name), &submarineriov1.PeeringPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.PeeringPolicy), err
+}
+
+// List takes label and field selectors, and returns the list of PeeringPolicies that match those selectors.
+func (c *FakePeeringPolicies) List(opts v1.ListOptions) (result *submarineriov1.PeeringPolicyList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(peeringpoliciesResource, peeringpoliciesKind, c.ns, opts), &submarineriov1.PeeringPolicyList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &submarineriov1.PeeringPolicyList{ListMeta: obj.(*submarineriov1.PeeringPolicyList).ListMeta}
+	for _, item := range obj.(*submarineriov1.PeeringPolicyList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested peeringPolicies.
+func (c *FakePeeringPolicies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(peeringpoliciesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a peeringPolicy and creates it.  Returns the server's representation of the peeringPolicy, and an error, if there is any.
+func (c *FakePeeringPolicies) Create(peeringPolicy *submarineriov1.PeeringPolicy) (result *submarineriov1.PeeringPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(peeringpoliciesResource, c.ns, peeringPolicy), &submarineriov1.PeeringPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.PeeringPolicy), err
+}
+
+// Update takes the representation of a peeringPolicy and updates it. Returns the server's representation of the peeringPolicy, and an error, if there is any.
+func (c *FakePeeringPolicies) Update(peeringPolicy *submarineriov1.PeeringPolicy) (result *submarineriov1.PeeringPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(peeringpoliciesResource, c.ns, peeringPolicy), &submarineriov1.PeeringPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.PeeringPolicy), err
+}
+
+// Delete takes name of the peeringPolicy and deletes it. Returns an error if one occurs.
+func (c *FakePeeringPolicies) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(peeringpoliciesResource, c.ns, name), &submarineriov1.PeeringPolicy{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakePeeringPolicies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(peeringpoliciesResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &submarineriov1.PeeringPolicyList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched peeringPolicy.
+func (c *FakePeeringPolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *submarineriov1.PeeringPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(peeringpoliciesResource, c.ns, name, pt, data, subresources...), &submarineriov1.PeeringPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.PeeringPolicy), err
+}
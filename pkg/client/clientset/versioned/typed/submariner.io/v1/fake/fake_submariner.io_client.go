@@ -28,6 +28,10 @@ type FakeSubmarinerV1 struct {
 	*testing.Fake
 }
 
+func (c *FakeSubmarinerV1) CIDRReservations(namespace string) v1.CIDRReservationInterface {
+	return &FakeCIDRReservations{c, namespace}
+}
+
 func (c *FakeSubmarinerV1) Clusters(namespace string) v1.ClusterInterface {
 	return &FakeClusters{c, namespace}
 }
@@ -36,6 +40,26 @@ func (c *FakeSubmarinerV1) Endpoints(namespace string) v1.EndpointInterface {
 	return &FakeEndpoints{c, namespace}
 }
 
+func (c *FakeSubmarinerV1) EndpointLeases(namespace string) v1.EndpointLeaseInterface {
+	return &FakeEndpointLeases{c, namespace}
+}
+
+func (c *FakeSubmarinerV1) PeeringPolicies(namespace string) v1.PeeringPolicyInterface {
+	return &FakePeeringPolicies{c, namespace}
+}
+
+func (c *FakeSubmarinerV1) ServiceExports(namespace string) v1.ServiceExportInterface {
+	return &FakeServiceExports{c, namespace}
+}
+
+func (c *FakeSubmarinerV1) ServiceImports(namespace string) v1.ServiceImportInterface {
+	return &FakeServiceImports{c, namespace}
+}
+
+func (c *FakeSubmarinerV1) TrafficPolicies(namespace string) v1.TrafficPolicyInterface {
+	return &FakeTrafficPolicies{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeSubmarinerV1) RESTClient() rest.Interface {
@@ -0,0 +1,128 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	submarineriov1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeTrafficPolicies implements TrafficPolicyInterface
+type FakeTrafficPolicies struct {
+	Fake *FakeSubmarinerV1
+	ns   string
+}
+
+var trafficpoliciesResource = schema.GroupVersionResource{Group: "submariner.io", Version: "v1", Resource: "trafficpolicies"}
+
+var trafficpoliciesKind = schema.GroupVersionKind{Group: "submariner.io", Version: "v1", Kind: "TrafficPolicy"}
+
+// Get takes name of the trafficPolicy, and returns the corresponding trafficPolicy object, and an error if there is any.
+func (c *FakeTrafficPolicies) Get(name string, options v1.GetOptions) (result *submarineriov1.TrafficPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(trafficpoliciesResource, c.ns, name), &submarineriov1.TrafficPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.TrafficPolicy), err
+}
+
+// List takes label and field selectors, and returns the list of TrafficPolicies that match those selectors.
+func (c *FakeTrafficPolicies) List(opts v1.ListOptions) (result *submarineriov1.TrafficPolicyList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(trafficpoliciesResource, trafficpoliciesKind, c.ns, opts), &submarineriov1.TrafficPolicyList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &submarineriov1.TrafficPolicyList{ListMeta: obj.(*submarineriov1.TrafficPolicyList).ListMeta}
+	for _, item := range obj.(*submarineriov1.TrafficPolicyList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested trafficPolicies.
+func (c *FakeTrafficPolicies) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(trafficpoliciesResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a trafficPolicy and creates it.  Returns the server's representation of the trafficPolicy, and an error, if there is any.
+func (c *FakeTrafficPolicies) Create(trafficPolicy *submarineriov1.TrafficPolicy) (result *submarineriov1.TrafficPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(trafficpoliciesResource, c.ns, trafficPolicy), &submarineriov1.TrafficPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.TrafficPolicy), err
+}
+
+// Update takes the representation of a trafficPolicy and updates it. Returns the server's representation of the trafficPolicy, and an error, if there is any.
+func (c *FakeTrafficPolicies) Update(trafficPolicy *submarineriov1.TrafficPolicy) (result *submarineriov1.TrafficPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(trafficpoliciesResource, c.ns, trafficPolicy), &submarineriov1.TrafficPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.TrafficPolicy), err
+}
+
+// Delete takes name of the trafficPolicy and deletes it. Returns an error if one occurs.
+func (c *FakeTrafficPolicies) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(trafficpoliciesResource, c.ns, name), &submarineriov1.TrafficPolicy{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeTrafficPolicies) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(trafficpoliciesResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &submarineriov1.TrafficPolicyList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched trafficPolicy.
+func (c *FakeTrafficPolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *submarineriov1.TrafficPolicy, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(trafficpoliciesResource, c.ns, name, pt, data, subresources...), &submarineriov1.TrafficPolicy{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*submarineriov1.TrafficPolicy), err
+}
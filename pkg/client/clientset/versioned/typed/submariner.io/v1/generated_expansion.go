@@ -18,6 +18,18 @@ limitations under the License.
 
 package v1
 
+type CIDRReservationExpansion interface{}
+
 type ClusterExpansion interface{}
 
 type EndpointExpansion interface{}
+
+type EndpointLeaseExpansion interface{}
+
+type PeeringPolicyExpansion interface{}
+
+type ServiceExportExpansion interface{}
+
+type ServiceImportExpansion interface{}
+
+type TrafficPolicyExpansion interface{}
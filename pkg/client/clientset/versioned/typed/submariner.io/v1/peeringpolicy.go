@@ -0,0 +1,174 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	scheme "github.com/rancher/submariner/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// PeeringPoliciesGetter has a method to return a PeeringPolicyInterface.
+// A group's client should implement this interface.
+type PeeringPoliciesGetter interface {
+	PeeringPolicies(namespace string) PeeringPolicyInterface
+}
+
+// PeeringPolicyInterface has methods to work with PeeringPolicy resources.
+type PeeringPolicyInterface interface {
+	Create(*v1.PeeringPolicy) (*v1.PeeringPolicy, error)
+	Update(*v1.PeeringPolicy) (*v1.PeeringPolicy, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (*v1.PeeringPolicy, error)
+	List(opts metav1.ListOptions) (*v1.PeeringPolicyList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.PeeringPolicy, err error)
+	PeeringPolicyExpansion
+}
+
+// peeringPolicies implements PeeringPolicyInterface
+type peeringPolicies struct {
+	client rest.Interface
+	ns     string
+}
+
+// newPeeringPolicies returns a PeeringPolicies
+func newPeeringPolicies(c *SubmarinerV1Client, namespace string) *peeringPolicies {
+	return &peeringPolicies{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the peeringPolicy, and returns the corresponding peeringPolicy object, and an error if there is any.
+func (c *peeringPolicies) Get(name string, options metav1.GetOptions) (result *v1.PeeringPolicy, err error) {
+	result = &v1.PeeringPolicy{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of PeeringPolicies that match those selectors.
+func (c *peeringPolicies) List(opts metav1.ListOptions) (result *v1.PeeringPolicyList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.PeeringPolicyList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested peeringPolicies.
+func (c *peeringPolicies) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a peeringPolicy and creates it.  Returns the server's representation of the peeringPolicy, and an error, if there is any.
+func (c *peeringPolicies) Create(peeringPolicy *v1.PeeringPolicy) (result *v1.PeeringPolicy, err error) {
+	result = &v1.PeeringPolicy{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		Body(peeringPolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a peeringPolicy and updates it. Returns the server's representation of the peeringPolicy, and an error, if there is any.
+func (c *peeringPolicies) Update(peeringPolicy *v1.PeeringPolicy) (result *v1.PeeringPolicy, err error) {
+	result = &v1.PeeringPolicy{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		Name(peeringPolicy.Name).
+		Body(peeringPolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the peeringPolicy and deletes it. Returns an error if one occurs.
+func (c *peeringPolicies) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *peeringPolicies) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched peeringPolicy.
+func (c *peeringPolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.PeeringPolicy, err error) {
+	result = &v1.PeeringPolicy{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("peeringpolicies").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -27,8 +27,14 @@ import (
 
 type SubmarinerV1Interface interface {
 	RESTClient() rest.Interface
+	CIDRReservationsGetter
 	ClustersGetter
 	EndpointsGetter
+	EndpointLeasesGetter
+	PeeringPoliciesGetter
+	ServiceExportsGetter
+	ServiceImportsGetter
+	TrafficPoliciesGetter
 }
 
 // SubmarinerV1Client is used to interact with features provided by the submariner.io group.
@@ -36,6 +42,10 @@ type SubmarinerV1Client struct {
 	restClient rest.Interface
 }
 
+func (c *SubmarinerV1Client) CIDRReservations(namespace string) CIDRReservationInterface {
+	return newCIDRReservations(c, namespace)
+}
+
 func (c *SubmarinerV1Client) Clusters(namespace string) ClusterInterface {
 	return newClusters(c, namespace)
 }
@@ -44,6 +54,26 @@ func (c *SubmarinerV1Client) Endpoints(namespace string) EndpointInterface {
 	return newEndpoints(c, namespace)
 }
 
+func (c *SubmarinerV1Client) EndpointLeases(namespace string) EndpointLeaseInterface {
+	return newEndpointLeases(c, namespace)
+}
+
+func (c *SubmarinerV1Client) PeeringPolicies(namespace string) PeeringPolicyInterface {
+	return newPeeringPolicies(c, namespace)
+}
+
+func (c *SubmarinerV1Client) ServiceExports(namespace string) ServiceExportInterface {
+	return newServiceExports(c, namespace)
+}
+
+func (c *SubmarinerV1Client) ServiceImports(namespace string) ServiceImportInterface {
+	return newServiceImports(c, namespace)
+}
+
+func (c *SubmarinerV1Client) TrafficPolicies(namespace string) TrafficPolicyInterface {
+	return newTrafficPolicies(c, namespace)
+}
+
 // NewForConfig creates a new SubmarinerV1Client for the given config.
 func NewForConfig(c *rest.Config) (*SubmarinerV1Client, error) {
 	config := *c
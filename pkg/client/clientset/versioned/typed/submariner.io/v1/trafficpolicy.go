@@ -0,0 +1,174 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	"time"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	scheme "github.com/rancher/submariner/pkg/client/clientset/versioned/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// TrafficPoliciesGetter has a method to return a TrafficPolicyInterface.
+// A group's client should implement this interface.
+type TrafficPoliciesGetter interface {
+	TrafficPolicies(namespace string) TrafficPolicyInterface
+}
+
+// TrafficPolicyInterface has methods to work with TrafficPolicy resources.
+type TrafficPolicyInterface interface {
+	Create(*v1.TrafficPolicy) (*v1.TrafficPolicy, error)
+	Update(*v1.TrafficPolicy) (*v1.TrafficPolicy, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
+	Get(name string, options metav1.GetOptions) (*v1.TrafficPolicy, error)
+	List(opts metav1.ListOptions) (*v1.TrafficPolicyList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.TrafficPolicy, err error)
+	TrafficPolicyExpansion
+}
+
+// trafficPolicies implements TrafficPolicyInterface
+type trafficPolicies struct {
+	client rest.Interface
+	ns     string
+}
+
+// newTrafficPolicies returns a TrafficPolicies
+func newTrafficPolicies(c *SubmarinerV1Client, namespace string) *trafficPolicies {
+	return &trafficPolicies{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the trafficPolicy, and returns the corresponding trafficPolicy object, and an error if there is any.
+func (c *trafficPolicies) Get(name string, options metav1.GetOptions) (result *v1.TrafficPolicy, err error) {
+	result = &v1.TrafficPolicy{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of TrafficPolicies that match those selectors.
+func (c *trafficPolicies) List(opts metav1.ListOptions) (result *v1.TrafficPolicyList, err error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	result = &v1.TrafficPolicyList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested trafficPolicies.
+func (c *trafficPolicies) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
+
+// Create takes the representation of a trafficPolicy and creates it.  Returns the server's representation of the trafficPolicy, and an error, if there is any.
+func (c *trafficPolicies) Create(trafficPolicy *v1.TrafficPolicy) (result *v1.TrafficPolicy, err error) {
+	result = &v1.TrafficPolicy{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		Body(trafficPolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a trafficPolicy and updates it. Returns the server's representation of the trafficPolicy, and an error, if there is any.
+func (c *trafficPolicies) Update(trafficPolicy *v1.TrafficPolicy) (result *v1.TrafficPolicy, err error) {
+	result = &v1.TrafficPolicy{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		Name(trafficPolicy.Name).
+		Body(trafficPolicy).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the trafficPolicy and deletes it. Returns an error if one occurs.
+func (c *trafficPolicies) Delete(name string, options *metav1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *trafficPolicies) DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	var timeout time.Duration
+	if listOptions.TimeoutSeconds != nil {
+		timeout = time.Duration(*listOptions.TimeoutSeconds) * time.Second
+	}
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Timeout(timeout).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched trafficPolicy.
+func (c *trafficPolicies) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1.TrafficPolicy, err error) {
+	result = &v1.TrafficPolicy{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("trafficpolicies").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
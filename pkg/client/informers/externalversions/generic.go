@@ -53,10 +53,22 @@ func (f *genericInformer) Lister() cache.GenericLister {
 func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource) (GenericInformer, error) {
 	switch resource {
 	// Group=submariner.io, Version=v1
+	case v1.SchemeGroupVersion.WithResource("cidrreservations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().CIDRReservations().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("clusters"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().Clusters().Informer()}, nil
 	case v1.SchemeGroupVersion.WithResource("endpoints"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().Endpoints().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("endpointleases"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().EndpointLeases().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("peeringpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().PeeringPolicies().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("serviceexports"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().ServiceExports().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("serviceimports"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().ServiceImports().Informer()}, nil
+	case v1.SchemeGroupVersion.WithResource("trafficpolicies"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Submariner().V1().TrafficPolicies().Informer()}, nil
 
 	}
 
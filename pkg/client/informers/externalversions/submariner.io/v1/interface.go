@@ -24,10 +24,22 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// CIDRReservations returns a CIDRReservationInformer.
+	CIDRReservations() CIDRReservationInformer
 	// Clusters returns a ClusterInformer.
 	Clusters() ClusterInformer
 	// Endpoints returns a EndpointInformer.
 	Endpoints() EndpointInformer
+	// EndpointLeases returns a EndpointLeaseInformer.
+	EndpointLeases() EndpointLeaseInformer
+	// PeeringPolicies returns a PeeringPolicyInformer.
+	PeeringPolicies() PeeringPolicyInformer
+	// ServiceExports returns a ServiceExportInformer.
+	ServiceExports() ServiceExportInformer
+	// ServiceImports returns a ServiceImportInformer.
+	ServiceImports() ServiceImportInformer
+	// TrafficPolicies returns a TrafficPolicyInformer.
+	TrafficPolicies() TrafficPolicyInformer
 }
 
 type version struct {
@@ -41,6 +53,11 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// CIDRReservations returns a CIDRReservationInformer.
+func (v *version) CIDRReservations() CIDRReservationInformer {
+	return &cIDRReservationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // Clusters returns a ClusterInformer.
 func (v *version) Clusters() ClusterInformer {
 	return &clusterInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
@@ -50,3 +67,28 @@ func (v *version) Clusters() ClusterInformer {
 func (v *version) Endpoints() EndpointInformer {
 	return &endpointInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// EndpointLeases returns a EndpointLeaseInformer.
+func (v *version) EndpointLeases() EndpointLeaseInformer {
+	return &endpointLeaseInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// PeeringPolicies returns a PeeringPolicyInformer.
+func (v *version) PeeringPolicies() PeeringPolicyInformer {
+	return &peeringPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ServiceExports returns a ServiceExportInformer.
+func (v *version) ServiceExports() ServiceExportInformer {
+	return &serviceExportInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// ServiceImports returns a ServiceImportInformer.
+func (v *version) ServiceImports() ServiceImportInformer {
+	return &serviceImportInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// TrafficPolicies returns a TrafficPolicyInformer.
+func (v *version) TrafficPolicies() TrafficPolicyInformer {
+	return &trafficPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CIDRReservationLister helps list CIDRReservations.
+type CIDRReservationLister interface {
+	// List lists all CIDRReservations in the indexer.
+	List(selector labels.Selector) (ret []*v1.CIDRReservation, err error)
+	// CIDRReservations returns an object that can list and get CIDRReservations.
+	CIDRReservations(namespace string) CIDRReservationNamespaceLister
+	CIDRReservationListerExpansion
+}
+
+// cIDRReservationLister implements the CIDRReservationLister interface.
+type cIDRReservationLister struct {
+	indexer cache.Indexer
+}
+
+// NewCIDRReservationLister returns a new CIDRReservationLister.
+func NewCIDRReservationLister(indexer cache.Indexer) CIDRReservationLister {
+	return &cIDRReservationLister{indexer: indexer}
+}
+
+// List lists all CIDRReservations in the indexer.
+func (s *cIDRReservationLister) List(selector labels.Selector) (ret []*v1.CIDRReservation, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.CIDRReservation))
+	})
+	return ret, err
+}
+
+// CIDRReservations returns an object that can list and get CIDRReservations.
+func (s *cIDRReservationLister) CIDRReservations(namespace string) CIDRReservationNamespaceLister {
+	return cIDRReservationNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// CIDRReservationNamespaceLister helps list and get CIDRReservations.
+type CIDRReservationNamespaceLister interface {
+	// List lists all CIDRReservations in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1.CIDRReservation, err error)
+	// Get retrieves the CIDRReservation from the indexer for a given namespace and name.
+	Get(name string) (*v1.CIDRReservation, error)
+	CIDRReservationNamespaceListerExpansion
+}
+
+// cIDRReservationNamespaceLister implements the CIDRReservationNamespaceLister
+// interface.
+type cIDRReservationNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all CIDRReservations in the indexer for a given namespace.
+func (s cIDRReservationNamespaceLister) List(selector labels.Selector) (ret []*v1.CIDRReservation, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.CIDRReservation))
+	})
+	return ret, err
+}
+
+// Get retrieves the CIDRReservation from the indexer for a given namespace and name.
+func (s cIDRReservationNamespaceLister) Get(name string) (*v1.CIDRReservation, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("cidrreservation"), name)
+	}
+	return obj.(*v1.CIDRReservation), nil
+}
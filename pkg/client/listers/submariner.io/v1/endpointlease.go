@@ -0,0 +1,94 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// EndpointLeaseLister helps list EndpointLeases.
+type EndpointLeaseLister interface {
+	// List lists all EndpointLeases in the indexer.
+	List(selector labels.Selector) (ret []*v1.EndpointLease, err error)
+	// EndpointLeases returns an object that can list and get EndpointLeases.
+	EndpointLeases(namespace string) EndpointLeaseNamespaceLister
+	EndpointLeaseListerExpansion
+}
+
+// endpointLeaseLister implements the EndpointLeaseLister interface.
+type endpointLeaseLister struct {
+	indexer cache.Indexer
+}
+
+// NewEndpointLeaseLister returns a new EndpointLeaseLister.
+func NewEndpointLeaseLister(indexer cache.Indexer) EndpointLeaseLister {
+	return &endpointLeaseLister{indexer: indexer}
+}
+
+// List lists all EndpointLeases in the indexer.
+func (s *endpointLeaseLister) List(selector labels.Selector) (ret []*v1.EndpointLease, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.EndpointLease))
+	})
+	return ret, err
+}
+
+// EndpointLeases returns an object that can list and get EndpointLeases.
+func (s *endpointLeaseLister) EndpointLeases(namespace string) EndpointLeaseNamespaceLister {
+	return endpointLeaseNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// EndpointLeaseNamespaceLister helps list and get EndpointLeases.
+type EndpointLeaseNamespaceLister interface {
+	// List lists all EndpointLeases in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1.EndpointLease, err error)
+	// Get retrieves the EndpointLease from the indexer for a given namespace and name.
+	Get(name string) (*v1.EndpointLease, error)
+	EndpointLeaseNamespaceListerExpansion
+}
+
+// endpointLeaseNamespaceLister implements the EndpointLeaseNamespaceLister
+// interface.
+type endpointLeaseNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all EndpointLeases in the indexer for a given namespace.
+func (s endpointLeaseNamespaceLister) List(selector labels.Selector) (ret []*v1.EndpointLease, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.EndpointLease))
+	})
+	return ret, err
+}
+
+// Get retrieves the EndpointLease from the indexer for a given namespace and name.
+func (s endpointLeaseNamespaceLister) Get(name string) (*v1.EndpointLease, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("endpointlease"), name)
+	}
+	return obj.(*v1.EndpointLease), nil
+}
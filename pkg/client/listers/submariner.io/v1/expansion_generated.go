@@ -18,6 +18,14 @@ limitations under the License.
 
 package v1
 
+// CIDRReservationListerExpansion allows custom methods to be added to
+// CIDRReservationLister.
+type CIDRReservationListerExpansion interface{}
+
+// CIDRReservationNamespaceListerExpansion allows custom methods to be added to
+// CIDRReservationNamespaceLister.
+type CIDRReservationNamespaceListerExpansion interface{}
+
 // ClusterListerExpansion allows custom methods to be added to
 // ClusterLister.
 type ClusterListerExpansion interface{}
@@ -33,3 +41,43 @@ type EndpointListerExpansion interface{}
 // EndpointNamespaceListerExpansion allows custom methods to be added to
 // EndpointNamespaceLister.
 type EndpointNamespaceListerExpansion interface{}
+
+// EndpointLeaseListerExpansion allows custom methods to be added to
+// EndpointLeaseLister.
+type EndpointLeaseListerExpansion interface{}
+
+// EndpointLeaseNamespaceListerExpansion allows custom methods to be added to
+// EndpointLeaseNamespaceLister.
+type EndpointLeaseNamespaceListerExpansion interface{}
+
+// PeeringPolicyListerExpansion allows custom methods to be added to
+// PeeringPolicyLister.
+type PeeringPolicyListerExpansion interface{}
+
+// PeeringPolicyNamespaceListerExpansion allows custom methods to be added to
+// PeeringPolicyNamespaceLister.
+type PeeringPolicyNamespaceListerExpansion interface{}
+
+// ServiceExportListerExpansion allows custom methods to be added to
+// ServiceExportLister.
+type ServiceExportListerExpansion interface{}
+
+// ServiceExportNamespaceListerExpansion allows custom methods to be added to
+// ServiceExportNamespaceLister.
+type ServiceExportNamespaceListerExpansion interface{}
+
+// ServiceImportListerExpansion allows custom methods to be added to
+// ServiceImportLister.
+type ServiceImportListerExpansion interface{}
+
+// ServiceImportNamespaceListerExpansion allows custom methods to be added to
+// ServiceImportNamespaceLister.
+type ServiceImportNamespaceListerExpansion interface{}
+
+// TrafficPolicyListerExpansion allows custom methods to be added to
+// TrafficPolicyLister.
+type TrafficPolicyListerExpansion interface{}
+
+// TrafficPolicyNamespaceListerExpansion allows custom methods to be added to
+// TrafficPolicyNamespaceLister.
+type TrafficPolicyNamespaceListerExpansion interface{}
@@ -0,0 +1,94 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PeeringPolicyLister helps list PeeringPolicies.
+type PeeringPolicyLister interface {
+	// List lists all PeeringPolicies in the indexer.
+	List(selector labels.Selector) (ret []*v1.PeeringPolicy, err error)
+	// PeeringPolicies returns an object that can list and get PeeringPolicies.
+	PeeringPolicies(namespace string) PeeringPolicyNamespaceLister
+	PeeringPolicyListerExpansion
+}
+
+// peeringPolicyLister implements the PeeringPolicyLister interface.
+type peeringPolicyLister struct {
+	indexer cache.Indexer
+}
+
+// NewPeeringPolicyLister returns a new PeeringPolicyLister.
+func NewPeeringPolicyLister(indexer cache.Indexer) PeeringPolicyLister {
+	return &peeringPolicyLister{indexer: indexer}
+}
+
+// List lists all PeeringPolicies in the indexer.
+func (s *peeringPolicyLister) List(selector labels.Selector) (ret []*v1.PeeringPolicy, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.PeeringPolicy))
+	})
+	return ret, err
+}
+
+// PeeringPolicies returns an object that can list and get PeeringPolicies.
+func (s *peeringPolicyLister) PeeringPolicies(namespace string) PeeringPolicyNamespaceLister {
+	return peeringPolicyNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// PeeringPolicyNamespaceLister helps list and get PeeringPolicies.
+type PeeringPolicyNamespaceLister interface {
+	// List lists all PeeringPolicies in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1.PeeringPolicy, err error)
+	// Get retrieves the PeeringPolicy from the indexer for a given namespace and name.
+	Get(name string) (*v1.PeeringPolicy, error)
+	PeeringPolicyNamespaceListerExpansion
+}
+
+// peeringPolicyNamespaceLister implements the PeeringPolicyNamespaceLister
+// interface.
+type peeringPolicyNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all PeeringPolicies in the indexer for a given namespace.
+func (s peeringPolicyNamespaceLister) List(selector labels.Selector) (ret []*v1.PeeringPolicy, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1.PeeringPolicy))
+	})
+	return ret, err
+}
+
+// Get retrieves the PeeringPolicy from the indexer for a given namespace and name.
+func (s peeringPolicyNamespaceLister) Get(name string) (*v1.PeeringPolicy, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1.Resource("peeringpolicy"), name)
+	}
+	return obj.(*v1.PeeringPolicy), nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,19 +13,78 @@ import (
 	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
 	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions/submariner.io/v1"
 	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/netutil"
+	"github.com/rancher/submariner/pkg/peering"
 	"github.com/rancher/submariner/pkg/types"
 	"github.com/rancher/submariner/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog"
 )
 
+// DeregistrationFinalizer is set on the local Cluster CRD so that deleting it first removes this cluster and its
+// endpoints from the central datastore.
+const DeregistrationFinalizer = "submariner.io/deregistration"
+
+// The reason of the events recorded when the CIDRs of a remote cluster overlap with another cluster
+const reasonCIDROverlap = "CIDROverlap"
+
+// The key of the work item that reconciles the remote clusters when the peering policies change
+const peeringPoliciesKey = "peeringpolicies"
+
+// LeaseConfig holds the thresholds used to keep the local endpoint alive in the broker and to expire
+// endpoints that stopped renewing their lease.
+type LeaseConfig struct {
+	// How often the local endpoint renews its lease in the broker
+	HeartbeatInterval time.Duration
+	// How long an endpoint lease may go without being renewed before the endpoint is reaped
+	LeaseTimeout time.Duration
+	// How often the reaper looks for stale endpoints
+	ReaperInterval time.Duration
+}
+
+// Validate checks that the intervals are positive, and that the lease outlives the heartbeats and the interval of
+// the reaper
+func (c LeaseConfig) Validate() error {
+	if c.HeartbeatInterval <= 0 {
+		return fmt.Errorf("the heartbeat interval (%v) must be positive", c.HeartbeatInterval)
+	}
+
+	if c.ReaperInterval <= 0 {
+		return fmt.Errorf("the reaper interval (%v) must be positive", c.ReaperInterval)
+	}
+
+	if c.LeaseTimeout <= c.HeartbeatInterval {
+		return fmt.Errorf("the endpoint lease timeout (%v) must be greater than the heartbeat interval (%v)",
+			c.LeaseTimeout, c.HeartbeatInterval)
+	}
+
+	if c.ReaperInterval > c.LeaseTimeout {
+		return fmt.Errorf("the reaper interval (%v) must not be greater than the endpoint lease timeout (%v)",
+			c.ReaperInterval, c.LeaseTimeout)
+	}
+	return nil
+}
+
+// observedLease tracks when the reaper last saw an endpoint's lease change. Expiry is measured against the
+// local clock rather than the renew time written by the remote gateway, so clock skew between clusters
+// can't cause healthy endpoints to be reaped.
+type observedLease struct {
+	renewTime  time.Time
+	observedAt time.Time
+}
+
 type DatastoreSyncer struct {
+	sync.Mutex
 	objectNamespace            string
 	thisClusterID              string
 	colorCodes                 []string
@@ -31,15 +92,28 @@ type DatastoreSyncer struct {
 	submarinerClientset        submarinerClientset.Interface
 	submarinerClusterInformer  submarinerInformers.ClusterInformer
 	submarinerEndpointInformer submarinerInformers.EndpointInformer
+	peeringPolicyInformer      submarinerInformers.PeeringPolicyInformer
 	datastore                  datastore.Datastore
 	localCluster               types.SubmarinerCluster
 	localEndpoint              types.SubmarinerEndpoint
+	leaseConfig                LeaseConfig
+	observedLeases             map[string]observedLease
+	deregistered               bool
+	recorder                   record.EventRecorder
+	// The remote clusters that aren't synced locally because their CIDRs overlap, mapped to the clusters they
+	// collide with
+	overlappingClusters map[string][]string
+	// The remote clusters that aren't synced locally because the peering policies deny them
+	deniedClusters map[string]bool
+	// Cancels the watches of the central datastore, which are restarted when the color codes change
+	cancelWatches context.CancelFunc
 
 	clusterWorkqueue  workqueue.RateLimitingInterface
 	endpointWorkqueue workqueue.RateLimitingInterface
+	peeringWorkqueue  workqueue.RateLimitingInterface
 }
 
-func NewDatastoreSyncer(thisClusterID string, objectNamespace string, kubeClientSet kubernetes.Interface, submarinerClientset submarinerClientset.Interface, submarinerClusterInformer submarinerInformers.ClusterInformer, submarinerEndpointInformer submarinerInformers.EndpointInformer, datastore datastore.Datastore, colorcodes []string, localCluster types.SubmarinerCluster, localEndpoint types.SubmarinerEndpoint) *DatastoreSyncer {
+func NewDatastoreSyncer(thisClusterID string, objectNamespace string, kubeClientSet kubernetes.Interface, submarinerClientset submarinerClientset.Interface, submarinerClusterInformer submarinerInformers.ClusterInformer, submarinerEndpointInformer submarinerInformers.EndpointInformer, peeringPolicyInformer submarinerInformers.PeeringPolicyInformer, datastore datastore.Datastore, colorcodes []string, localCluster types.SubmarinerCluster, localEndpoint types.SubmarinerEndpoint, recorder record.EventRecorder, leaseConfig LeaseConfig) *DatastoreSyncer {
 	newDatastoreSyncer := DatastoreSyncer{
 		thisClusterID:              thisClusterID,
 		objectNamespace:            objectNamespace,
@@ -48,11 +122,18 @@ func NewDatastoreSyncer(thisClusterID string, objectNamespace string, kubeClient
 		datastore:                  datastore,
 		submarinerClusterInformer:  submarinerClusterInformer,
 		submarinerEndpointInformer: submarinerEndpointInformer,
+		peeringPolicyInformer:      peeringPolicyInformer,
 		clusterWorkqueue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Clusters"),
 		endpointWorkqueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Endpoints"),
+		peeringWorkqueue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "PeeringPolicies"),
 		colorCodes:                 colorcodes,
 		localCluster:               localCluster,
 		localEndpoint:              localEndpoint,
+		leaseConfig:                leaseConfig,
+		observedLeases:             map[string]observedLease{},
+		recorder:                   recorder,
+		overlappingClusters:        map[string][]string{},
+		deniedClusters:             map[string]bool{},
 	}
 
 	submarinerClusterInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
@@ -71,18 +152,60 @@ func NewDatastoreSyncer(thisClusterID string, objectNamespace string, kubeClient
 		DeleteFunc: newDatastoreSyncer.enqueueEndpoint,
 	}, 60*time.Second)
 
+	peeringPolicyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: newDatastoreSyncer.enqueuePeeringPolicies,
+		UpdateFunc: func(old, new interface{}) {
+			newDatastoreSyncer.enqueuePeeringPolicies(new)
+		},
+		DeleteFunc: newDatastoreSyncer.enqueuePeeringPolicies,
+	})
+
 	return &newDatastoreSyncer
 }
 
+// LocalClusterFromCRD returns the local cluster with the CIDRs, color codes and labels of its existing Cluster CRD, which
+// take precedence over the configured ones once the cluster has been registered. The global CIDR stays the one
+// reserved in the broker.
+func LocalClusterFromCRD(submarinerClientset submarinerClientset.Interface, objectNamespace string,
+	localCluster types.SubmarinerCluster) (types.SubmarinerCluster, error) {
+	cluster, err := submarinerClientset.SubmarinerV1().Clusters(objectNamespace).Get(localCluster.ID, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return localCluster, nil
+	} else if err != nil {
+		return localCluster, fmt.Errorf("Error retrieving the local Cluster CRD %s: %v", localCluster.ID, err)
+	}
+
+	if len(cluster.Spec.ClusterCIDR) > 0 {
+		localCluster.Spec.ClusterCIDR = cluster.Spec.ClusterCIDR
+	}
+	if len(cluster.Spec.ServiceCIDR) > 0 {
+		localCluster.Spec.ServiceCIDR = cluster.Spec.ServiceCIDR
+	}
+	if len(cluster.Spec.NodeCIDR) > 0 {
+		localCluster.Spec.NodeCIDR = cluster.Spec.NodeCIDR
+	}
+	if len(cluster.Spec.ColorCodes) > 0 {
+		localCluster.Spec.ColorCodes = cluster.Spec.ColorCodes
+	}
+	if len(cluster.Spec.Labels) > 0 {
+		localCluster.Spec.Labels = cluster.Spec.Labels
+	}
+	if cluster.Spec.Role != "" {
+		localCluster.Spec.Role = cluster.Spec.Role
+	}
+	return localCluster, nil
+}
+
 func (d *DatastoreSyncer) ensureExclusiveEndpoint() {
 	klog.V(4).Infof("Ensuring we are the only endpoint active for this cluster")
-	endpoints, err := d.datastore.GetEndpoints(d.localCluster.ID)
+	localEndpoint := d.getLocalEndpoint()
+	endpoints, err := d.datastore.GetEndpoints(d.thisClusterID)
 	if err != nil {
 		klog.Fatalf("Error while retrieving endpoints %v", err)
 	}
 
 	for _, endpoint := range endpoints {
-		if !util.CompareEndpointSpec(endpoint.Spec, d.localEndpoint.Spec) {
+		if !util.CompareEndpointSpec(endpoint.Spec, localEndpoint.Spec) {
 			endpointCrdName, err := util.GetEndpointCRDName(&endpoint)
 			if err != nil {
 				klog.Errorf("Error while converting endpoint to CRD Name %s", endpoint.Spec.CableName)
@@ -94,9 +217,9 @@ func (d *DatastoreSyncer) ensureExclusiveEndpoint() {
 			if err != nil {
 				klog.Errorf("Error while deleting endpoint CRD for %s: %v", endpointCrdName, err)
 			}
-			err = d.datastore.RemoveEndpoint(d.localCluster.ID, endpoint.Spec.CableName)
+			err = d.datastore.RemoveEndpoint(d.thisClusterID, endpoint.Spec.CableName)
 			if err != nil {
-				klog.Errorf("Error while removing endpoint in remote datastore for %s: %v", endpoint.Spec.CableName, d.localCluster.ID)
+				klog.Errorf("Error while removing endpoint in remote datastore for %s: %v", endpoint.Spec.CableName, d.thisClusterID)
 			}
 			klog.V(4).Infof("Removed endpoint %s", endpointCrdName)
 		}
@@ -125,31 +248,58 @@ func (d *DatastoreSyncer) enqueueEndpoint(obj interface{}) {
 	d.endpointWorkqueue.AddRateLimited(key)
 }
 
+// enqueuePeeringPolicies queues the reconciliation of the remote clusters, which doesn't depend on the policy
+// that changed
+func (d *DatastoreSyncer) enqueuePeeringPolicies(obj interface{}) {
+	klog.V(8).Infof("Enqueueing the peering policies after a change of %v", obj)
+	d.peeringWorkqueue.AddRateLimited(peeringPoliciesKey)
+}
+
 func (d *DatastoreSyncer) Run(stopCh <-chan struct{}) error {
 	defer utilruntime.HandleCrash()
 
 	defer d.clusterWorkqueue.ShutDown()
 	defer d.endpointWorkqueue.ShutDown()
+	defer d.peeringWorkqueue.ShutDown()
 	klog.V(4).Infof("Starting the DatastoreSyncer")
+	if err := d.leaseConfig.Validate(); err != nil {
+		return err
+	}
+
 	klog.Info("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, d.submarinerClusterInformer.Informer().HasSynced, d.submarinerEndpointInformer.Informer().HasSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, d.submarinerClusterInformer.Informer().HasSynced, d.submarinerEndpointInformer.Informer().HasSynced,
+		d.peeringPolicyInformer.Informer().HasSynced); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
 	d.ensureExclusiveEndpoint()
 
-	err := d.reconcileClusterCRD(&d.localCluster, false)
+	localCluster := d.getLocalCluster()
+	err := d.reconcileClusterCRD(&localCluster, false)
 	if err != nil {
 		return fmt.Errorf("Error reconciling local Cluster CRD: %v", err)
 	}
 
-	err = d.reconcileEndpointCRD(&d.localEndpoint, false)
+	err = d.ensureDeregistrationFinalizer()
+	if err != nil {
+		return fmt.Errorf("Error adding the deregistration finalizer to the local Cluster CRD: %v", err)
+	}
+
+	if err = d.updateOverlapCondition(""); err != nil {
+		return fmt.Errorf("Error initializing the %s condition of the local Cluster CRD: %v",
+			submarinerv1.ClusterConditionCIDROverlap, err)
+	}
+
+	localEndpoint := d.getLocalEndpoint()
+	err = d.reconcileEndpointCRD(&localEndpoint, false)
 	if err != nil {
 		return fmt.Errorf("Error reconciling local Endpoint CRD: %v", err)
 	}
 
-	go utilruntime.HandleError(d.datastore.WatchClusters(context.TODO(), d.thisClusterID, d.colorCodes, d.reconcileClusterCRD))
-	go utilruntime.HandleError(d.datastore.WatchEndpoints(context.TODO(), d.thisClusterID, d.colorCodes, d.reconcileEndpointCRD))
+	defer d.stopWatches()
+	if err = d.startWatches(d.getColorCodes()); err != nil {
+		return err
+	}
 
 	klog.Info("Started datastoresyncer workers")
 
@@ -157,13 +307,105 @@ func (d *DatastoreSyncer) Run(stopCh <-chan struct{}) error {
 
 	go wait.Until(d.runEndpointWorker, time.Second, stopCh)
 
-	//go wait.Until(d.runReaper, time.Second, stopCh)
+	go wait.Until(d.runPeeringWorker, time.Second, stopCh)
+
+	go wait.Until(d.renewLease, d.leaseConfig.HeartbeatInterval, stopCh)
+
+	go wait.Until(d.runReaper, d.leaseConfig.ReaperInterval, stopCh)
 
 	<-stopCh
 	klog.Info("Shutting down datastoresyncer workers")
 	return nil
 }
 
+// startWatches watches the clusters and endpoints sharing the given color codes in the central datastore,
+// replacing the previous watches
+func (d *DatastoreSyncer) startWatches(colorCodes []string) error {
+	d.stopWatches()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := d.datastore.WatchClusters(ctx, d.thisClusterID, colorCodes, d.reconcileClusterCRD); err != nil {
+		cancel()
+		return fmt.Errorf("Error watching clusters in the central datastore: %v", err)
+	}
+
+	if err := d.datastore.WatchEndpoints(ctx, d.thisClusterID, colorCodes, d.reconcileEndpointCRD); err != nil {
+		cancel()
+		return fmt.Errorf("Error watching endpoints in the central datastore: %v", err)
+	}
+
+	d.Lock()
+	d.cancelWatches = cancel
+	d.colorCodes = colorCodes
+	d.Unlock()
+	return nil
+}
+
+func (d *DatastoreSyncer) stopWatches() {
+	d.Lock()
+	defer d.Unlock()
+	if d.cancelWatches != nil {
+		d.cancelWatches()
+		d.cancelWatches = nil
+	}
+}
+
+func (d *DatastoreSyncer) getColorCodes() []string {
+	d.Lock()
+	defer d.Unlock()
+	return d.colorCodes
+}
+
+func (d *DatastoreSyncer) getLocalCluster() types.SubmarinerCluster {
+	d.Lock()
+	defer d.Unlock()
+	return d.localCluster
+}
+
+func (d *DatastoreSyncer) getLocalEndpoint() types.SubmarinerEndpoint {
+	d.Lock()
+	defer d.Unlock()
+	return d.localEndpoint
+}
+
+// updateLocalCluster applies the edits of the local Cluster CRD: the watches are restarted when the color codes
+// change, and the subnets of the local Endpoint CRD follow the CIDRs of the cluster
+func (d *DatastoreSyncer) updateLocalCluster(spec submarinerv1.ClusterSpec) error {
+	d.Lock()
+	d.localCluster.Spec = spec
+	d.Unlock()
+
+	if !reflect.DeepEqual(spec.ColorCodes, d.getColorCodes()) {
+		klog.Infof("The color codes of the local cluster changed to %v, watching the matching clusters", spec.ColorCodes)
+		if err := d.startWatches(spec.ColorCodes); err != nil {
+			return err
+		}
+	}
+
+	localEndpoint := d.getLocalEndpoint()
+	subnets := util.GetClusterRoutableCIDRs(spec)
+	if reflect.DeepEqual(localEndpoint.Spec.Subnets, subnets) {
+		return nil
+	}
+
+	endpointName, err := util.GetEndpointCRDName(&localEndpoint)
+	if err != nil {
+		return fmt.Errorf("Error converting the Endpoint CRD name: %v", err)
+	}
+
+	klog.Infof("The CIDRs of the local cluster changed, updating the subnets of Endpoint CRD %s to %v", endpointName, subnets)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		endpoint, err := d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).Get(endpointName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		endpoint.Spec.Subnets = subnets
+		_, err = d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).Update(endpoint)
+		return err
+	})
+}
+
 func (d *DatastoreSyncer) runClusterWorker() {
 	for d.processNextClusterWorkItem() {
 	}
@@ -194,15 +436,38 @@ func (d *DatastoreSyncer) processNextClusterWorkItem() bool {
 			d.clusterWorkqueue.Forget(obj)
 			return nil
 		}
+		if cluster.DeletionTimestamp != nil {
+			klog.Infof("The local Cluster CRD is being deleted, deregistering cluster %s from the central datastore", key)
+			if err = d.deregister(); err != nil {
+				d.clusterWorkqueue.AddRateLimited(obj)
+				return fmt.Errorf("Error deregistering cluster %s, requeuing: %v", key, err)
+			}
+			d.clusterWorkqueue.Forget(obj)
+			return nil
+		}
+		if d.isDeregistered() {
+			klog.V(4).Infof("This cluster was deregistered, not updating the central datastore")
+			d.clusterWorkqueue.Forget(obj)
+			return nil
+		}
 		myCluster := types.SubmarinerCluster{
 			ID:   cluster.Name,
 			Spec: cluster.Spec,
 		}
 		klog.V(4).Infof("Attempting to trigger an update of the central datastore with the updated CRD")
 		err = d.datastore.SetCluster(&myCluster)
-		klog.V(4).Infof("Update of cluster in central datastore was successful")
 		if err != nil {
+			if isRetriable(err) {
+				d.clusterWorkqueue.AddRateLimited(obj)
+				return fmt.Errorf("Error updating the cluster in the central datastore, requeuing: %v", err)
+			}
 			klog.Errorf("There was an error updating the cluster in the central datastore, error: %v", err)
+		} else {
+			klog.V(4).Infof("Update of cluster in central datastore was successful")
+		}
+		if err = d.updateLocalCluster(cluster.Spec); err != nil {
+			d.clusterWorkqueue.AddRateLimited(obj)
+			return fmt.Errorf("Error applying the changes of the local Cluster CRD, requeuing: %v", err)
 		}
 		d.clusterWorkqueue.Forget(obj)
 		return nil
@@ -245,17 +510,29 @@ func (d *DatastoreSyncer) processNextEndpointWorkItem() bool {
 			d.endpointWorkqueue.Forget(obj)
 			return nil
 		}
-		if d.localEndpoint.Spec.CableName != endpoint.Spec.CableName {
+		if d.getLocalEndpoint().Spec.CableName != endpoint.Spec.CableName {
 			klog.V(4).Infof("This endpoint is not me, not updating central datastore")
 			d.endpointWorkqueue.Forget(obj)
 			return nil
 		}
+		if d.isDeregistered() {
+			klog.V(4).Infof("This cluster was deregistered, not updating the central datastore")
+			d.endpointWorkqueue.Forget(obj)
+			return nil
+		}
 		myEndpoint := types.SubmarinerEndpoint{
 			Spec: endpoint.Spec,
 		}
+		d.Lock()
+		d.localEndpoint = myEndpoint
+		d.Unlock()
 		klog.V(4).Infof("Attempting to trigger an update of the central datastore with the updated endpoint CRD")
 		err = d.datastore.SetEndpoint(&myEndpoint)
 		if err != nil {
+			if isRetriable(err) {
+				d.endpointWorkqueue.AddRateLimited(obj)
+				return fmt.Errorf("Error updating the endpoint in the central datastore, requeuing: %v", err)
+			}
 			klog.Errorf("There was an error updating the endpoint in the central datastore, error: %v", err)
 		} else {
 			klog.V(4).Infof("Update of endpoint in central datastore was successful")
@@ -271,17 +548,203 @@ func (d *DatastoreSyncer) processNextEndpointWorkItem() bool {
 	return true
 }
 
+func (d *DatastoreSyncer) runPeeringWorker() {
+	for d.processNextPeeringWorkItem() {
+	}
+}
+
+func (d *DatastoreSyncer) processNextPeeringWorkItem() bool {
+	obj, shutdown := d.peeringWorkqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer d.peeringWorkqueue.Done(obj)
+
+	if err := d.reconcilePeering(); err != nil {
+		d.peeringWorkqueue.AddRateLimited(obj)
+		utilruntime.HandleError(fmt.Errorf("Error applying the peering policies, requeuing: %v", err))
+		return true
+	}
+	d.peeringWorkqueue.Forget(obj)
+	return true
+}
+
+// reconcilePeering reconciles the Cluster CRDs of the remote clusters in the central datastore, so that the
+// clusters which the peering policies now deny are removed and the ones they now allow are synced
+func (d *DatastoreSyncer) reconcilePeering() error {
+	clusters, err := d.datastore.GetClusters(d.getColorCodes())
+	if err != nil {
+		return datastore.Wrapf(err, "Error retrieving the clusters from the central datastore")
+	}
+
+	for i := range clusters {
+		if clusters[i].ID == d.thisClusterID {
+			continue
+		}
+
+		if err = d.reconcileClusterCRD(&clusters[i], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPeeringAllowed evaluates the peering policies for the remote cluster, it's denied when they can't be evaluated
+func (d *DatastoreSyncer) isPeeringAllowed(cluster *submarinerv1.ClusterSpec) bool {
+	policies, err := d.peeringPolicyInformer.Lister().PeeringPolicies(d.objectNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Error listing the PeeringPolicies: %v", err)
+		return false
+	}
+
+	allowed, err := peering.IsAllowed(policies, cluster)
+	if err != nil {
+		klog.Errorf("Error evaluating the peering policies for cluster %s, not peering with it: %v", cluster.ClusterID, err)
+		return false
+	}
+	return allowed
+}
+
+// isClusterPeeringAllowed evaluates the peering policies for the remote cluster of an endpoint, using its synced
+// Cluster CRD, or the cluster from the central datastore when the Cluster CRD isn't synced yet
+func (d *DatastoreSyncer) isClusterPeeringAllowed(clusterID string) bool {
+	d.Lock()
+	denied := d.deniedClusters[clusterID]
+	d.Unlock()
+	if denied {
+		return false
+	}
+
+	clusterCRDName, err := util.GetClusterCRDName(&types.SubmarinerCluster{ID: clusterID, Spec: submarinerv1.ClusterSpec{ClusterID: clusterID}})
+	if err != nil {
+		klog.Errorf("Error converting the Cluster CRD name of cluster %s: %v", clusterID, err)
+		return false
+	}
+
+	crdCluster, err := d.submarinerClusterInformer.Lister().Clusters(d.objectNamespace).Get(clusterCRDName)
+	if err == nil {
+		return d.isPeeringAllowed(&crdCluster.Spec)
+	} else if !apierrors.IsNotFound(err) {
+		klog.Errorf("Error retrieving the Cluster CRD of cluster %s: %v", clusterID, err)
+		return false
+	}
+
+	spec := submarinerv1.ClusterSpec{ClusterID: clusterID}
+	cluster, err := d.datastore.GetCluster(clusterID)
+	if err == nil {
+		spec = cluster.Spec
+	} else if !datastore.IsNotFound(err) {
+		klog.Errorf("Error retrieving cluster %s from the central datastore: %v", clusterID, err)
+		return false
+	}
+	return d.isPeeringAllowed(&spec)
+}
+
+// denyPeering removes the Cluster and Endpoint CRDs of a remote cluster which the peering policies deny
+func (d *DatastoreSyncer) denyPeering(clusterID, clusterCRDName string) error {
+	d.Lock()
+	if !d.deniedClusters[clusterID] {
+		klog.Infof("The peering policies deny cluster %s, disconnecting it", clusterID)
+	}
+	d.deniedClusters[clusterID] = true
+	d.Unlock()
+
+	d.setOverlappingClusters(clusterID, nil)
+	return d.removeClusterCRDs(clusterID, clusterCRDName)
+}
+
+// removeClusterCRDs removes the local Cluster and Endpoint CRDs of a remote cluster
+func (d *DatastoreSyncer) removeClusterCRDs(clusterID, clusterCRDName string) error {
+	err := d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Delete(clusterCRDName, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("Error deleting Cluster CRD %s from the local datastore: %v", clusterCRDName, err)
+	}
+
+	endpoints, err := d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("Error listing the local Endpoint CRDs: %v", err)
+	}
+
+	for _, endpoint := range endpoints.Items {
+		if endpoint.Spec.ClusterID != clusterID {
+			continue
+		}
+
+		err = d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).Delete(endpoint.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("Error deleting Endpoint CRD %s from the local datastore: %v", endpoint.Name, err)
+		}
+	}
+	return nil
+}
+
+// allowPeering forgets that the remote cluster was denied, returning true if it was
+func (d *DatastoreSyncer) allowPeering(clusterID string) bool {
+	d.Lock()
+	defer d.Unlock()
+	denied := d.deniedClusters[clusterID]
+	delete(d.deniedClusters, clusterID)
+	return denied
+}
+
+// syncRemoteEndpoints reconciles the Endpoint CRDs of a remote cluster with its endpoints in the central datastore
+func (d *DatastoreSyncer) syncRemoteEndpoints(clusterID string) {
+	endpoints, err := d.datastore.GetEndpoints(clusterID)
+	if err != nil {
+		klog.Errorf("Error retrieving the endpoints of cluster %s: %v", clusterID, err)
+		return
+	}
+
+	for i := range endpoints {
+		if err = d.reconcileEndpointCRD(&endpoints[i], false); err != nil {
+			klog.Errorf("Error reconciling the Endpoint CRDs of %s: %v", clusterID, err)
+		}
+	}
+}
+
 func (d *DatastoreSyncer) reconcileClusterCRD(localCluster *types.SubmarinerCluster, delete bool) error {
 	clusterCRDName, err := util.GetClusterCRDName(localCluster)
 	if err != nil {
 		return fmt.Errorf("Error converting the Cluster CRD name: %v", err)
 	}
+
+	if localCluster.ID != d.thisClusterID {
+		if delete {
+			d.setOverlappingClusters(localCluster.ID, nil)
+			d.allowPeering(localCluster.ID)
+		} else {
+			if !d.isPeeringAllowed(&localCluster.Spec) {
+				return d.denyPeering(localCluster.ID, clusterCRDName)
+			}
+
+			// The endpoints of a cluster that was denied until now were never synced
+			if d.allowPeering(localCluster.ID) {
+				klog.Infof("The peering policies allow cluster %s again, connecting it", localCluster.ID)
+				defer d.syncRemoteEndpoints(localCluster.ID)
+			}
+
+			overlapping, err := d.findOverlappingClusters(localCluster.ID, util.GetClusterRoutableCIDRs(localCluster.Spec))
+			if err != nil {
+				return err
+			}
+
+			d.setOverlappingClusters(localCluster.ID, overlapping)
+			if len(overlapping) > 0 {
+				// The cluster may have been synced before its CIDRs, or the ones of the local cluster, changed
+				klog.Warningf("The CIDRs of cluster %s overlap with clusters %v, removing its Cluster and Endpoint CRDs",
+					localCluster.ID, overlapping)
+				return d.removeClusterCRDs(localCluster.ID, clusterCRDName)
+			}
+		}
+	}
+
 	var found bool
 	cluster, err := d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Get(clusterCRDName, metav1.GetOptions{})
-	if err != nil {
-		klog.V(4).Infof("There was an error retrieving the local Cluster CRD for %s, assuming it does not exist and creating a new one. The error was: %v",
-			clusterCRDName, err)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("The local Cluster CRD for %s does not exist", clusterCRDName)
 		found = false
+	} else if err != nil {
+		return fmt.Errorf("Error retrieving the local Cluster CRD %s: %v", clusterCRDName, err)
 	} else {
 		found = true
 	}
@@ -330,63 +793,466 @@ func (d *DatastoreSyncer) reconcileClusterCRD(localCluster *types.SubmarinerClus
 	return nil
 }
 
+// ensureDeregistrationFinalizer adds the deregistration finalizer to the local Cluster CRD if it's missing
+func (d *DatastoreSyncer) ensureDeregistrationFinalizer() error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster, err := d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Get(d.thisClusterID, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if cluster.DeletionTimestamp != nil || containsString(cluster.Finalizers, DeregistrationFinalizer) {
+			return nil
+		}
+
+		cluster.Finalizers = append(cluster.Finalizers, DeregistrationFinalizer)
+		_, err = d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Update(cluster)
+		return err
+	})
+}
+
+// deregister stops this syncer from publishing the local cluster and endpoint, and then removes them from the
+// central datastore.
+func (d *DatastoreSyncer) deregister() error {
+	d.Lock()
+	d.deregistered = true
+	d.Unlock()
+
+	return Deregister(d.thisClusterID, d.objectNamespace, d.submarinerClientset, d.datastore)
+}
+
+func (d *DatastoreSyncer) isDeregistered() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.deregistered
+}
+
+// Deregister removes the cluster and its endpoints from the central datastore, and then removes the
+// deregistration finalizer from the local Cluster CRD so that its deletion can complete. The other clusters
+// remove the cluster from their local datastores when they see it deleted from the central datastore.
+func Deregister(clusterID, objectNamespace string, submarinerClientset submarinerClientset.Interface, ds datastore.Datastore) error {
+	if err := ds.RemoveCluster(clusterID); err != nil {
+		return datastore.Wrapf(err, "Error removing cluster %s from the central datastore", clusterID)
+	}
+	klog.Infof("Removed cluster %s from the central datastore", clusterID)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster, err := submarinerClientset.SubmarinerV1().Clusters(objectNamespace).Get(clusterID, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !containsString(cluster.Finalizers, DeregistrationFinalizer) {
+			return nil
+		}
+
+		cluster.Finalizers = removeString(cluster.Finalizers, DeregistrationFinalizer)
+		_, err = submarinerClientset.SubmarinerV1().Clusters(objectNamespace).Update(cluster)
+		return err
+	})
+}
+
+// renewLease renews the liveness lease of the local endpoint in the central datastore. If the endpoint isn't
+// found, it may have been reaped while we were unable to reach the broker, so it is set again.
+func (d *DatastoreSyncer) renewLease() {
+	if d.isDeregistered() {
+		return
+	}
+
+	localEndpoint := d.getLocalEndpoint()
+	err := d.datastore.RenewEndpointLease(localEndpoint.Spec.ClusterID, localEndpoint.Spec.CableName, time.Now())
+	if err == nil {
+		klog.V(8).Infof("Renewed the lease of endpoint %s", localEndpoint.Spec.CableName)
+		return
+	}
+
+	if !datastore.IsNotFound(err) {
+		klog.Errorf("Error renewing the lease of endpoint %s: %v", localEndpoint.Spec.CableName, err)
+		return
+	}
+
+	klog.Warningf("Endpoint %s was not found while renewing its lease, setting it again in the central datastore",
+		localEndpoint.Spec.CableName)
+	if err = d.datastore.SetEndpoint(&localEndpoint); err != nil {
+		klog.Errorf("Error setting endpoint %s in the central datastore: %v", localEndpoint.Spec.CableName, err)
+		return
+	}
+
+	if err = d.datastore.RenewEndpointLease(localEndpoint.Spec.ClusterID, localEndpoint.Spec.CableName, time.Now()); err != nil {
+		klog.Errorf("Error renewing the lease of endpoint %s: %v", localEndpoint.Spec.CableName, err)
+	}
+}
+
+// runReaper removes endpoints whose lease has expired from the central datastore, and removes the local
+// Cluster and Endpoint CRDs of remote clusters that are no longer present in the central datastore.
 func (d *DatastoreSyncer) runReaper() {
-	var wg sync.WaitGroup
-	klog.V(4).Infof("Starting reaper")
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			clusters, err := d.datastore.GetClusters(d.colorCodes)
+	klog.V(6).Infof("Running the reaper")
+
+	// The local CRDs are listed before querying the central datastore so that objects which are created
+	// locally while the reaper runs can't be mistaken for stale ones
+	crdClusters, err := d.submarinerClusterInformer.Lister().Clusters(d.objectNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Error retrieving local Clusters: %v", err)
+		return
+	}
+
+	crdEndpoints, err := d.submarinerEndpointInformer.Lister().Endpoints(d.objectNamespace).List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Error retrieving local Endpoints: %v", err)
+		return
+	}
+
+	clusters, err := d.datastore.GetClusters(d.getColorCodes())
+	if err != nil {
+		klog.Errorf("Error retrieving remote Clusters: %v", err)
+		return
+	}
+
+	now := time.Now()
+	seenLeases := map[string]bool{}
+	retrievedEndpoints := map[string][]types.SubmarinerEndpoint{}
+	for _, cluster := range clusters {
+		endpoints, err := d.datastore.GetEndpoints(cluster.ID)
+		if err != nil {
+			klog.Errorf("Error retrieving remote Endpoints for cluster %s: %v", cluster.ID, err)
+			continue
+		}
+
+		var liveEndpoints []types.SubmarinerEndpoint
+		for _, endpoint := range endpoints {
+			leaseKey := endpoint.Spec.ClusterID + "/" + endpoint.Spec.CableName
+			seenLeases[leaseKey] = true
+			if d.reapIfExpired(leaseKey, endpoint, now) {
+				continue
+			}
+			liveEndpoints = append(liveEndpoints, endpoint)
+		}
+		retrievedEndpoints[cluster.ID] = liveEndpoints
+	}
+
+	for leaseKey := range d.observedLeases {
+		if !seenLeases[leaseKey] {
+			delete(d.observedLeases, leaseKey)
+		}
+	}
+
+	d.recheckOverlappingClusters(clusters, retrievedEndpoints)
+
+	for _, crdc := range crdClusters {
+		if crdc.Spec.ClusterID == d.thisClusterID || searchClusters(clusters, crdc.Spec.ClusterID) {
+			continue
+		}
+
+		klog.V(4).Infof("Removing the Cluster CRD %s because it was not found in the central datastore", crdc.Name)
+		err = d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Delete(crdc.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			klog.Errorf("Error deleting local Cluster CRD %s: %v", crdc.Name, err)
+			continue
+		}
+
+		// The cluster is gone, so are all its endpoints
+		retrievedEndpoints[crdc.Spec.ClusterID] = nil
+	}
+
+	for _, crde := range crdEndpoints {
+		if crde.Spec.ClusterID == d.thisClusterID {
+			continue
+		}
+
+		endpoints, ok := retrievedEndpoints[crde.Spec.ClusterID]
+		if !ok {
+			klog.V(6).Infof("Endpoints for cluster %s were not retrieved, not reaping CRD %s", crde.Spec.ClusterID, crde.Name)
+			continue
+		}
+
+		if searchEndpoints(endpoints, crde.Spec.CableName, crde.Spec.ClusterID) {
+			klog.V(6).Infof("Found CRD %s in the central datastore list of endpoints, not doing anything", crde.Name)
+			continue
+		}
+
+		klog.V(4).Infof("Removing the CRD %s because it was not found in the central datastore", crde.Name)
+		err = d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).Delete(crde.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			klog.Errorf("Error deleting local CRDE %s: %v", crde.Name, err)
+		}
+	}
+}
+
+// reapIfExpired removes the endpoint from the central datastore if its lease hasn't been renewed within the
+// lease timeout, returning true if it was removed or is already gone. Endpoints that never renewed a lease are
+// left alone.
+func (d *DatastoreSyncer) reapIfExpired(leaseKey string, endpoint types.SubmarinerEndpoint, now time.Time) bool {
+	if util.CompareEndpointSpec(endpoint.Spec, d.getLocalEndpoint().Spec) {
+		return false
+	}
+
+	renewTime, err := d.datastore.GetEndpointLeaseRenewTime(endpoint.Spec.ClusterID, endpoint.Spec.CableName)
+	if datastore.IsNotFound(err) {
+		klog.V(6).Infof("Endpoint %s was removed before its lease could be checked", endpoint.Spec.CableName)
+		return true
+	} else if err != nil {
+		klog.Errorf("Error retrieving the lease of endpoint %s: %v", endpoint.Spec.CableName, err)
+		return false
+	}
+
+	if renewTime.IsZero() {
+		klog.V(6).Infof("Endpoint %s has no lease, not reaping it", endpoint.Spec.CableName)
+		return false
+	}
+
+	observed, ok := d.observedLeases[leaseKey]
+	if !ok || !observed.renewTime.Equal(renewTime) {
+		d.observedLeases[leaseKey] = observedLease{renewTime: renewTime, observedAt: now}
+		return false
+	}
+
+	if now.Sub(observed.observedAt) < d.leaseConfig.LeaseTimeout {
+		return false
+	}
+
+	klog.Infof("The lease of endpoint %s was last renewed at %v and has expired, removing it from the central datastore",
+		endpoint.Spec.CableName, renewTime)
+	err = d.datastore.RemoveEndpoint(endpoint.Spec.ClusterID, endpoint.Spec.CableName)
+	if err != nil && !datastore.IsNotFound(err) {
+		klog.Errorf("Error removing expired endpoint %s from the central datastore: %v", endpoint.Spec.CableName, err)
+		return false
+	}
+
+	delete(d.observedLeases, leaseKey)
+	return true
+}
+
+// findOverlappingClusters returns the IDs of the clusters whose CIDRs overlap the given CIDRs of a remote cluster:
+// the local cluster, whose node network holds the private IP of the local endpoint, and the remote clusters that have
+// a local Cluster CRD. Remote clusters that were already refused aren't considered, even while the informer cache
+// still holds their removed CRD, so the first cluster to be synced wins.
+func (d *DatastoreSyncer) findOverlappingClusters(clusterID string, cidrs []string) ([]string, error) {
+	clusters, err := d.submarinerClusterInformer.Lister().Clusters(d.objectNamespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the local Cluster CRDs: %v", err)
+	}
+
+	localCIDRs := util.GetClusterRoutableCIDRs(d.getLocalCluster().Spec)
+	if privateIP := d.getLocalEndpoint().Spec.PrivateIP.To4(); privateIP != nil {
+		// A remote cluster, typically through its node CIDR, may not claim the network of the local gateway
+		localCIDRs = append(localCIDRs, privateIP.String()+"/32")
+	}
+
+	existing := map[string][]string{
+		d.thisClusterID: localCIDRs,
+	}
+	for _, cluster := range clusters {
+		if cluster.Spec.ClusterID != d.thisClusterID && !d.isOverlapping(cluster.Spec.ClusterID) {
+			existing[cluster.Spec.ClusterID] = util.GetClusterRoutableCIDRs(cluster.Spec)
+		}
+	}
+
+	var overlapping []string
+	for existingID, existingCIDRs := range existing {
+		if existingID == clusterID {
+			continue
+		}
+
+		for _, cidr := range cidrs {
+			overlaps, err := netutil.IsOverlappingCIDR(existingCIDRs, cidr)
 			if err != nil {
-				klog.Errorf("Error retrieving remote Clusters: %v", err)
-				return
+				klog.Errorf("Error checking CIDR %s of cluster %s for overlaps: %v", cidr, clusterID, err)
+				continue
 			}
-			for _, cluster := range clusters {
-				endpoints, err := d.datastore.GetEndpoints(cluster.ID)
-				if err != nil {
-					klog.Errorf("Error retrieving remote Endpoints for cluster %s: %v", cluster.ID, err)
-					continue
-				}
 
-				crdEndpoints, err := d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).List(metav1.ListOptions{})
-				if err != nil {
-					klog.Errorf("Error retrieving local Endpoints: %v", err)
-					continue
-				}
+			if overlaps {
+				overlapping = append(overlapping, existingID)
+				break
+			}
+		}
+	}
 
-				for _, crde := range crdEndpoints.Items {
-					if util.CompareEndpointSpec(crde.Spec, d.localEndpoint.Spec) {
-						klog.V(4).Infof("Not going to delete self from kubernetes")
-					} else {
-						if searchEndpoints(endpoints, crde.Spec.CableName, crde.Spec.ClusterID) {
-							klog.V(4).Infof("Found CRD %s in the API server list of endpoints, not doing anything", crde.Name)
-						} else {
-							// remove the crde
-							if cluster.ID == crde.Spec.ClusterID {
-								if reflect.DeepEqual(crde.Spec, d.localEndpoint.Spec) {
-									klog.V(4).Infof("Not reaping own endpoint %s", crde.Name)
-								} else {
-									klog.V(4).Infof("Removing the CRD %s because it was not found in the API server list", crde.Name)
-									err = d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).Delete(crde.Name, &metav1.DeleteOptions{})
-									if err != nil {
-										klog.Errorf("Error deleting local CRDE %s: %v", crde.Name, err)
-									}
-								}
-							} else {
-								klog.V(4).Infof("CRDE wasn't found in list but did not match the cluster we're searching for right now")
-							}
-						}
-					}
-				}
+	sort.Strings(overlapping)
+	return overlapping, nil
+}
+
+// setOverlappingClusters records the clusters that the given remote cluster overlaps with. When they change, an
+// event is recorded and the CIDROverlap condition of the local Cluster CRD is updated.
+func (d *DatastoreSyncer) setOverlappingClusters(clusterID string, overlapping []string) {
+	d.Lock()
+	previous := d.overlappingClusters[clusterID]
+	if reflect.DeepEqual(previous, overlapping) {
+		d.Unlock()
+		return
+	}
+
+	if len(overlapping) == 0 {
+		delete(d.overlappingClusters, clusterID)
+	} else {
+		d.overlappingClusters[clusterID] = overlapping
+	}
+	message := d.overlapMessage()
+	d.Unlock()
+
+	cluster, err := d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Get(d.thisClusterID, metav1.GetOptions{})
+	if err != nil {
+		klog.Errorf("Error retrieving the local Cluster CRD %s: %v", d.thisClusterID, err)
+		return
+	}
+
+	if len(overlapping) > 0 {
+		d.recorder.Eventf(cluster, corev1.EventTypeWarning, reasonCIDROverlap,
+			"The CIDRs of cluster %s overlap with clusters %s, cluster %s is not connected",
+			clusterID, strings.Join(overlapping, ", "), clusterID)
+	} else if previous != nil {
+		d.recorder.Eventf(cluster, corev1.EventTypeNormal, reasonCIDROverlap,
+			"The CIDRs of cluster %s no longer overlap with other clusters", clusterID)
+	}
+
+	if err = d.updateOverlapCondition(message); err != nil {
+		klog.Errorf("Error updating the %s condition of the local Cluster CRD: %v", submarinerv1.ClusterConditionCIDROverlap, err)
+	}
+}
+
+func (d *DatastoreSyncer) isOverlapping(clusterID string) bool {
+	d.Lock()
+	defer d.Unlock()
+	_, ok := d.overlappingClusters[clusterID]
+	return ok
+}
+
+// overlapMessage describes the overlapping clusters, it must be called with the lock held
+func (d *DatastoreSyncer) overlapMessage() string {
+	var descriptions []string
+	for clusterID, overlapping := range d.overlappingClusters {
+		descriptions = append(descriptions, fmt.Sprintf("cluster %s overlaps with %s", clusterID, strings.Join(overlapping, ", ")))
+	}
+	sort.Strings(descriptions)
+	return strings.Join(descriptions, "; ")
+}
+
+// updateOverlapCondition sets the CIDROverlap condition of the local Cluster CRD, it's true when the message
+// describing the overlapping clusters isn't empty
+func (d *DatastoreSyncer) updateOverlapCondition(message string) error {
+	condition := submarinerv1.ClusterCondition{
+		Type:    submarinerv1.ClusterConditionCIDROverlap,
+		Status:  corev1.ConditionFalse,
+		Reason:  "NoOverlap",
+		Message: "The CIDRs of the remote clusters don't overlap",
+	}
+	if message != "" {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = reasonCIDROverlap
+		condition.Message = message
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster, err := d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Get(d.thisClusterID, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if !setClusterCondition(&cluster.Status, condition) {
+			return nil
+		}
+
+		_, err = d.submarinerClientset.SubmarinerV1().Clusters(d.objectNamespace).Update(cluster)
+		return err
+	})
+}
+
+// setClusterCondition adds or replaces the condition with the same type, returning false if it was already set.
+// The transition time is only updated when the status of the condition changes.
+func setClusterCondition(status *submarinerv1.ClusterStatus, condition submarinerv1.ClusterCondition) bool {
+	condition.LastTransitionTime = metav1.Now()
+	for i, existing := range status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+
+		if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+			return false
+		}
+
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		}
+		status.Conditions[i] = condition
+		return true
+	}
+
+	status.Conditions = append(status.Conditions, condition)
+	return true
+}
+
+// recheckOverlappingClusters reconciles the remote clusters which were refused because of overlapping CIDRs
+// again, so that they are synced once the overlap is resolved. Clusters that left the central datastore are
+// forgotten. The synced clusters that now overlap, e.g. after the CIDRs of the local cluster changed, are
+// reconciled too so that their CRDs are removed.
+func (d *DatastoreSyncer) recheckOverlappingClusters(clusters []types.SubmarinerCluster,
+	retrievedEndpoints map[string][]types.SubmarinerEndpoint) {
+	d.Lock()
+	var overlapping []string
+	for clusterID := range d.overlappingClusters {
+		overlapping = append(overlapping, clusterID)
+	}
+	d.Unlock()
+
+	for i := range clusters {
+		cluster := &clusters[i]
+		if cluster.ID == d.thisClusterID || d.isOverlapping(cluster.ID) {
+			continue
+		}
 
+		found, err := d.findOverlappingClusters(cluster.ID, util.GetClusterRoutableCIDRs(cluster.Spec))
+		if err != nil {
+			klog.Errorf("Error checking cluster %s for overlapping CIDRs: %v", cluster.ID, err)
+			continue
+		}
+
+		if len(found) > 0 {
+			if err = d.reconcileClusterCRD(cluster, false); err != nil {
+				klog.Errorf("Error reconciling the Cluster CRD of %s: %v", cluster.ID, err)
 			}
-			klog.V(4).Infof("Sleeping for 15 seconds")
-			time.Sleep(15 * time.Second)
 		}
-	}()
-	wg.Wait()
-	klog.Fatalf("reaper exited")
+	}
+
+	for _, clusterID := range overlapping {
+		var cluster *types.SubmarinerCluster
+		for i := range clusters {
+			if clusters[i].ID == clusterID {
+				cluster = &clusters[i]
+			}
+		}
+
+		if cluster == nil {
+			d.setOverlappingClusters(clusterID, nil)
+			continue
+		}
+
+		if err := d.reconcileClusterCRD(cluster, false); err != nil {
+			klog.Errorf("Error reconciling the Cluster CRD of %s: %v", clusterID, err)
+			continue
+		}
+
+		for i := range retrievedEndpoints[clusterID] {
+			if err := d.reconcileEndpointCRD(&retrievedEndpoints[clusterID][i], false); err != nil {
+				klog.Errorf("Error reconciling the Endpoint CRDs of %s: %v", clusterID, err)
+			}
+		}
+	}
+}
+
+// returns true if the cluster was found in the passed in list
+func searchClusters(clusters []types.SubmarinerCluster, clusterID string) bool {
+	for _, cluster := range clusters {
+		if cluster.ID == clusterID {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetriable returns true if the central datastore error is transient and the operation should be retried
+func isRetriable(err error) bool {
+	return datastore.IsUnavailable(err) || datastore.IsConflict(err)
 }
 
 // basic brute force search for now
@@ -406,12 +1272,39 @@ func (d *DatastoreSyncer) reconcileEndpointCRD(rawEndpoint *types.SubmarinerEndp
 		return fmt.Errorf("Error converting the Enndpoint CRD name: %v", err)
 	}
 
+	if !delete && rawEndpoint.Spec.ClusterID != d.thisClusterID {
+		if !d.isClusterPeeringAllowed(rawEndpoint.Spec.ClusterID) {
+			klog.V(4).Infof("The peering policies deny cluster %s, not syncing Endpoint CRD %s",
+				rawEndpoint.Spec.ClusterID, endpointName)
+			return nil
+		}
+
+		if d.isOverlapping(rawEndpoint.Spec.ClusterID) {
+			klog.V(4).Infof("The CIDRs of cluster %s overlap with other clusters, not syncing Endpoint CRD %s",
+				rawEndpoint.Spec.ClusterID, endpointName)
+			return nil
+		}
+
+		overlapping, err := d.findOverlappingClusters(rawEndpoint.Spec.ClusterID, rawEndpoint.Spec.Subnets)
+		if err != nil {
+			return err
+		}
+
+		if len(overlapping) > 0 {
+			klog.Warningf("The subnets %v of endpoint %s overlap with clusters %v, not syncing its Endpoint CRD",
+				rawEndpoint.Spec.Subnets, endpointName, overlapping)
+			d.setOverlappingClusters(rawEndpoint.Spec.ClusterID, overlapping)
+			return nil
+		}
+	}
+
 	var found bool
 	endpoint, err := d.submarinerClientset.SubmarinerV1().Endpoints(d.objectNamespace).Get(endpointName, metav1.GetOptions{})
-	if err != nil {
-		klog.V(4).Infof("There was an error retrieving the local Endpoint CRD for %s, assuming it does not exist and creating a new one. The error was: %v",
-			endpointName, err)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("The local Endpoint CRD for %s does not exist", endpointName)
 		found = false
+	} else if err != nil {
+		return fmt.Errorf("Error retrieving the local Endpoint CRD %s: %v", endpointName, err)
 	} else {
 		found = true
 	}
@@ -459,3 +1352,22 @@ func (d *DatastoreSyncer) reconcileEndpointCRD(rawEndpoint *types.SubmarinerEndp
 	}
 	return nil
 }
+
+func containsString(c []string, s string) bool {
+	for _, v := range c {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(c []string, s string) []string {
+	var result []string
+	for _, v := range c {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
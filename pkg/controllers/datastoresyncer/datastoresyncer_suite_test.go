@@ -0,0 +1,13 @@
+package datastoresyncer
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestDatastoreSyncer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DatastoreSyncer Suite")
+}
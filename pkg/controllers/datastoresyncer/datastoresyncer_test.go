@@ -0,0 +1,631 @@
+package datastoresyncer
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	gomegatypes "github.com/onsi/gomega/types"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/client/clientset/versioned/fake"
+	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/datastore/inmemory"
+	"github.com/rancher/submariner/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const namespace = "submariner"
+
+var testLeaseConfig = LeaseConfig{
+	HeartbeatInterval: 50 * time.Millisecond,
+	LeaseTimeout:      300 * time.Millisecond,
+	ReaperInterval:    50 * time.Millisecond,
+}
+
+// testCluster is a cluster simulated in-process: its own fake submariner clientset and a datastore
+// syncer connected to the shared in-memory broker.
+type testCluster struct {
+	id        string
+	clientset *fake.Clientset
+	recorder  *record.FakeRecorder
+	stopCh    chan struct{}
+	stopped   chan struct{}
+}
+
+var _ = Describe("DatastoreSyncer", func() {
+	var broker *inmemory.Datastore
+	var clusters []*testCluster
+
+	BeforeEach(func() {
+		broker = inmemory.NewDatastore()
+		clusters = nil
+	})
+
+	AfterEach(func() {
+		for _, c := range clusters {
+			c.stop()
+		}
+	})
+
+	startCluster := func(id string, subnet string, colorCodes ...string) *testCluster {
+		c := newTestCluster(broker, id, subnet, colorCodes...)
+		clusters = append(clusters, c)
+		return c
+	}
+
+	startClusterWith := func(ds datastore.Datastore, id string, subnet string, colorCodes ...string) *testCluster {
+		c := newTestCluster(ds, id, subnet, colorCodes...)
+		clusters = append(clusters, c)
+		return c
+	}
+
+	When("clusters join the broker", func() {
+		It("should register them in the broker and sync the remote clusters and endpoints locally", func() {
+			east := startCluster("east", "10.0.0.0/16", "blue")
+			west := startCluster("west", "10.1.0.0/16", "blue")
+
+			Eventually(func() ([]types.SubmarinerCluster, error) {
+				return broker.GetClusters([]string{"blue"})
+			}, 5).Should(HaveLen(2))
+
+			Eventually(east.clusterNames, 5).Should(ConsistOf("east", "west"))
+			Eventually(west.clusterNames, 5).Should(ConsistOf("east", "west"))
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+			Eventually(west.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+		})
+
+		It("should not sync clusters that don't share a color code", func() {
+			east := startCluster("east", "10.0.0.0/16", "blue")
+			startCluster("west", "10.1.0.0/16", "blue")
+			startCluster("north", "10.2.0.0/16", "red")
+
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+			Consistently(east.endpointClusterIDs, 0.5).Should(ConsistOf("east", "west"))
+			Expect(east.clusterNames()).To(ConsistOf("east", "west"))
+		})
+	})
+
+	When("a cluster stops renewing its lease", func() {
+		It("should be reaped from the broker and removed from the other clusters", func() {
+			east := startCluster("east", "10.0.0.0/16", "blue")
+			west := startCluster("west", "10.1.0.0/16", "blue")
+
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+
+			west.stop()
+
+			Eventually(func() ([]types.SubmarinerEndpoint, error) {
+				return broker.GetEndpoints("west")
+			}, 5).Should(BeEmpty())
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east"))
+		})
+	})
+
+	When("a cluster is deregistered", func() {
+		var east, west *testCluster
+
+		BeforeEach(func() {
+			east = startCluster("east", "10.0.0.0/16", "blue")
+			west = startCluster("west", "10.1.0.0/16", "blue")
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+		})
+
+		assertDeregistered := func() {
+			Eventually(func() bool {
+				_, err := broker.GetCluster("west")
+				return datastore.IsNotFound(err)
+			}, 5).Should(BeTrue())
+			Eventually(func() ([]types.SubmarinerEndpoint, error) {
+				return broker.GetEndpoints("west")
+			}, 5).Should(BeEmpty())
+
+			Eventually(east.clusterNames, 5).Should(ConsistOf("east"))
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east"))
+			Eventually(west.finalizers, 5).ShouldNot(ContainElement(DeregistrationFinalizer))
+		}
+
+		It("should add the deregistration finalizer to the local Cluster CRD", func() {
+			Eventually(west.finalizers, 5).Should(ContainElement(DeregistrationFinalizer))
+		})
+
+		Context("by deleting its local Cluster CRD", func() {
+			It("should remove it from the broker and from the other clusters", func() {
+				Eventually(west.finalizers, 5).Should(ContainElement(DeregistrationFinalizer))
+
+				cluster, err := west.clientset.SubmarinerV1().Clusters(namespace).Get("west", metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				now := metav1.Now()
+				cluster.DeletionTimestamp = &now
+				_, err = west.clientset.SubmarinerV1().Clusters(namespace).Update(cluster)
+				Expect(err).ToNot(HaveOccurred())
+
+				assertDeregistered()
+				Consistently(func() ([]types.SubmarinerEndpoint, error) {
+					return broker.GetEndpoints("west")
+				}, 0.5).Should(BeEmpty())
+			})
+		})
+
+		Context("by an explicit uninstall", func() {
+			It("should remove it from the broker and from the other clusters", func() {
+				Eventually(west.finalizers, 5).Should(ContainElement(DeregistrationFinalizer))
+				west.stop()
+
+				Expect(Deregister("west", namespace, west.clientset, broker)).To(Succeed())
+				assertDeregistered()
+			})
+		})
+	})
+
+	When("the broker is temporarily unavailable", func() {
+		It("should retry registering the cluster", func() {
+			startClusterWith(&unavailableDatastore{Datastore: broker, failures: 3}, "east", "10.0.0.0/16", "blue")
+
+			Eventually(func() error {
+				_, err := broker.GetCluster("east")
+				return err
+			}, 5).Should(Succeed())
+		})
+	})
+
+	When("the CIDRs of a remote cluster overlap", func() {
+		var east, north *testCluster
+
+		BeforeEach(func() {
+			east = startCluster("east", "10.0.0.0/16", "blue")
+			north = startCluster("north", "10.2.0.0/16", "blue")
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "north"))
+			startCluster("west", "10.0.128.0/17", "blue")
+		})
+
+		It("should not sync the overlapping cluster and should report the collision", func() {
+			Eventually(east.overlapCondition, 5).Should(haveConditionStatus(corev1.ConditionTrue))
+			condition, err := east.overlapCondition()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(condition.Message).To(ContainSubstring("cluster west overlaps with east"))
+			Eventually(east.recorder.Events, 5).Should(Receive(And(ContainSubstring("CIDROverlap"),
+				ContainSubstring("west"))))
+
+			Consistently(east.clusterNames, 0.5).Should(ConsistOf("east", "north"))
+			Expect(east.endpointClusterIDs()).To(ConsistOf("east", "north"))
+			Expect(north.clusterNames()).To(ConsistOf("east", "north"))
+			Expect(north.endpointClusterIDs()).To(ConsistOf("east", "north"))
+		})
+
+		It("should clear the condition once the overlapping cluster leaves", func() {
+			Eventually(east.overlapCondition, 5).Should(haveConditionStatus(corev1.ConditionTrue))
+
+			Expect(Deregister("west", namespace, fake.NewSimpleClientset(), broker)).To(Succeed())
+
+			Eventually(east.overlapCondition, 5).Should(haveConditionStatus(corev1.ConditionFalse))
+		})
+	})
+
+	When("the CIDRs of a synced remote cluster start to overlap", func() {
+		var east, west *testCluster
+
+		BeforeEach(func() {
+			east = startCluster("east", "10.0.0.0/16", "blue")
+			west = startCluster("west", "10.1.0.0/16", "blue")
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+		})
+
+		It("should remove the CRDs of a remote cluster whose CIDRs changed", func() {
+			west.updateClusterSpec(func(spec *submarinerv1.ClusterSpec) {
+				spec.ClusterCIDR = []string{"10.0.128.0/17"}
+			})
+
+			Eventually(east.overlapCondition, 5).Should(haveConditionStatus(corev1.ConditionTrue))
+			Eventually(east.clusterNames, 5).Should(ConsistOf("east"))
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east"))
+		})
+
+		It("should remove the CRDs of a remote cluster whose node CIDR holds the local gateway", func() {
+			west.updateClusterSpec(func(spec *submarinerv1.ClusterSpec) {
+				spec.NodeCIDR = []string{"192.168.0.0/24"}
+			})
+
+			Eventually(east.overlapCondition, 5).Should(haveConditionStatus(corev1.ConditionTrue))
+			Eventually(east.clusterNames, 5).Should(ConsistOf("east"))
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east"))
+		})
+
+		It("should remove the CRDs of the remote clusters once the local CIDRs changed", func() {
+			east.updateClusterSpec(func(spec *submarinerv1.ClusterSpec) {
+				spec.ClusterCIDR = []string{"10.0.0.0/15"}
+			})
+
+			Eventually(east.overlapCondition, 5).Should(haveConditionStatus(corev1.ConditionTrue))
+			Eventually(east.clusterNames, 5).Should(ConsistOf("east"))
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east"))
+		})
+	})
+
+	When("the local Cluster CRD is edited", func() {
+		var east, west, north *testCluster
+
+		BeforeEach(func() {
+			east = startCluster("east", "10.0.0.0/16", "blue")
+			west = startCluster("west", "10.1.0.0/16", "blue")
+			north = startCluster("north", "10.2.0.0/16", "red")
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+		})
+
+		It("should publish the new CIDRs and update the subnets of the local endpoint", func() {
+			west.updateClusterSpec(func(spec *submarinerv1.ClusterSpec) {
+				spec.ClusterCIDR = []string{"10.3.0.0/16"}
+			})
+
+			Eventually(func() ([]string, error) {
+				cluster, err := broker.GetCluster("west")
+				return cluster.Spec.ClusterCIDR, err
+			}, 5).Should(Equal([]string{"10.3.0.0/16"}))
+			Eventually(func() ([]string, error) {
+				endpoints, err := broker.GetEndpoints("west")
+				if err != nil || len(endpoints) != 1 {
+					return nil, err
+				}
+				return endpoints[0].Spec.Subnets, nil
+			}, 5).Should(Equal([]string{"10.3.0.0/16"}))
+			Eventually(func() ([]string, error) {
+				endpoint, err := east.clientset.SubmarinerV1().Endpoints(namespace).Get("west-submariner-cable-west", metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				return endpoint.Spec.Subnets, nil
+			}, 5).Should(Equal([]string{"10.3.0.0/16"}))
+		})
+
+		It("should sync the clusters sharing the new color codes", func() {
+			east.updateClusterSpec(func(spec *submarinerv1.ClusterSpec) {
+				spec.ColorCodes = []string{"red"}
+			})
+
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "north"))
+			Eventually(east.clusterNames, 5).Should(ConsistOf("east", "north"))
+			Eventually(north.endpointClusterIDs, 5).Should(ConsistOf("east", "north"))
+		})
+	})
+
+	When("peering policies are set", func() {
+		var production *testCluster
+
+		BeforeEach(func() {
+			production = startCluster("production", "10.0.0.0/16", "blue")
+			startCluster("partner", "10.1.0.0/16", "blue")
+			startCluster("staging", "10.2.0.0/16", "blue")
+		})
+
+		It("should not sync the denied clusters", func() {
+			production.createPeeringPolicy("deny-partner", submarinerv1.PeeringPolicyDeny, "partner")
+
+			Eventually(production.endpointClusterIDs, 5).Should(ConsistOf("production", "staging"))
+			Consistently(production.clusterNames, 0.5).Should(ConsistOf("production", "staging"))
+		})
+
+		It("should sync the denied clusters again once the policy is removed", func() {
+			Eventually(production.endpointClusterIDs, 5).Should(ConsistOf("production", "partner", "staging"))
+
+			production.createPeeringPolicy("allow-staging", submarinerv1.PeeringPolicyAllow, "staging")
+			Eventually(production.endpointClusterIDs, 5).Should(ConsistOf("production", "staging"))
+			Eventually(production.clusterNames, 5).Should(ConsistOf("production", "staging"))
+
+			Expect(production.clientset.SubmarinerV1().PeeringPolicies(namespace).Delete("allow-staging",
+				&metav1.DeleteOptions{})).To(Succeed())
+			Eventually(production.endpointClusterIDs, 5).Should(ConsistOf("production", "partner", "staging"))
+			Eventually(production.clusterNames, 5).Should(ConsistOf("production", "partner", "staging"))
+		})
+	})
+
+	Describe("Function LeaseConfig.Validate", func() {
+		It("should accept the default intervals", func() {
+			Expect(LeaseConfig{HeartbeatInterval: 10 * time.Second, LeaseTimeout: time.Minute,
+				ReaperInterval: 30 * time.Second}.Validate()).To(Succeed())
+		})
+
+		It("should reject intervals that aren't positive", func() {
+			Expect(LeaseConfig{HeartbeatInterval: 0, LeaseTimeout: time.Minute,
+				ReaperInterval: 30 * time.Second}.Validate()).ToNot(Succeed())
+			Expect(LeaseConfig{HeartbeatInterval: 10 * time.Second, LeaseTimeout: time.Minute,
+				ReaperInterval: -time.Second}.Validate()).ToNot(Succeed())
+		})
+
+		It("should reject a lease timeout that doesn't outlive the heartbeats and the reaper interval", func() {
+			Expect(LeaseConfig{HeartbeatInterval: time.Minute, LeaseTimeout: time.Minute,
+				ReaperInterval: 30 * time.Second}.Validate()).ToNot(Succeed())
+			Expect(LeaseConfig{HeartbeatInterval: 10 * time.Second, LeaseTimeout: time.Minute,
+				ReaperInterval: 2 * time.Minute}.Validate()).ToNot(Succeed())
+		})
+	})
+
+	Describe("Function renewLease", func() {
+		var syncer *DatastoreSyncer
+		var localEndpoint types.SubmarinerEndpoint
+
+		BeforeEach(func() {
+			localEndpoint = newTestEndpoint("east")
+			syncer = &DatastoreSyncer{datastore: broker, localEndpoint: localEndpoint, leaseConfig: testLeaseConfig}
+		})
+
+		It("should advance the renew time of the local endpoint", func() {
+			Expect(broker.SetEndpoint(&localEndpoint)).To(Succeed())
+			syncer.renewLease()
+			first, err := broker.GetEndpointLeaseRenewTime("east", localEndpoint.Spec.CableName)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(first.IsZero()).To(BeFalse())
+
+			time.Sleep(10 * time.Millisecond)
+			syncer.renewLease()
+			Expect(broker.GetEndpointLeaseRenewTime("east", localEndpoint.Spec.CableName)).To(BeTemporally(">", first))
+		})
+
+		It("should set the local endpoint again when it was removed from the broker", func() {
+			syncer.renewLease()
+			Expect(broker.GetEndpoints("east")).To(HaveLen(1))
+			renewTime, err := broker.GetEndpointLeaseRenewTime("east", localEndpoint.Spec.CableName)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(renewTime.IsZero()).To(BeFalse())
+		})
+	})
+
+	Describe("Function reapIfExpired", func() {
+		var syncer *DatastoreSyncer
+		var remoteEndpoint types.SubmarinerEndpoint
+		leaseKey := "west/submariner-cable-west"
+
+		BeforeEach(func() {
+			remoteEndpoint = newTestEndpoint("west")
+			Expect(broker.SetEndpoint(&remoteEndpoint)).To(Succeed())
+			syncer = &DatastoreSyncer{datastore: broker, localEndpoint: newTestEndpoint("east"),
+				leaseConfig: testLeaseConfig, observedLeases: map[string]observedLease{}}
+		})
+
+		It("should leave the endpoints without a lease alone", func() {
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, time.Now().Add(time.Hour))).To(BeFalse())
+			Expect(broker.GetEndpoints("west")).To(HaveLen(1))
+		})
+
+		It("should reap an endpoint once its lease went unrenewed for the lease timeout", func() {
+			now := time.Now()
+			Expect(broker.RenewEndpointLease("west", remoteEndpoint.Spec.CableName, now)).To(Succeed())
+
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, now)).To(BeFalse())
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, now.Add(testLeaseConfig.LeaseTimeout/2))).To(BeFalse())
+			Expect(broker.GetEndpoints("west")).To(HaveLen(1))
+
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, now.Add(testLeaseConfig.LeaseTimeout))).To(BeTrue())
+			Expect(broker.GetEndpoints("west")).To(BeEmpty())
+			Expect(syncer.observedLeases).To(BeEmpty())
+		})
+
+		It("should restart the timeout when the lease is renewed", func() {
+			now := time.Now()
+			Expect(broker.RenewEndpointLease("west", remoteEndpoint.Spec.CableName, now)).To(Succeed())
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, now)).To(BeFalse())
+
+			renewed := now.Add(testLeaseConfig.LeaseTimeout / 2)
+			Expect(broker.RenewEndpointLease("west", remoteEndpoint.Spec.CableName, renewed)).To(Succeed())
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, renewed)).To(BeFalse())
+			Expect(syncer.reapIfExpired(leaseKey, remoteEndpoint, now.Add(testLeaseConfig.LeaseTimeout))).To(BeFalse())
+			Expect(broker.GetEndpoints("west")).To(HaveLen(1))
+		})
+
+		It("should never reap the local endpoint", func() {
+			localEndpoint := newTestEndpoint("east")
+			Expect(broker.SetEndpoint(&localEndpoint)).To(Succeed())
+			Expect(broker.RenewEndpointLease("east", localEndpoint.Spec.CableName, time.Now())).To(Succeed())
+			Expect(syncer.reapIfExpired("east/submariner-cable-east", localEndpoint, time.Now())).To(BeFalse())
+			Expect(syncer.reapIfExpired("east/submariner-cable-east", localEndpoint, time.Now().Add(time.Hour))).To(BeFalse())
+			Expect(broker.GetEndpoints("east")).To(HaveLen(1))
+		})
+	})
+
+	Describe("Function LocalClusterFromCRD", func() {
+		localCluster := types.SubmarinerCluster{
+			ID: "east",
+			Spec: submarinerv1.ClusterSpec{
+				ClusterID:   "east",
+				ColorCodes:  []string{"blue"},
+				ClusterCIDR: []string{"10.0.0.0/16"},
+				ServiceCIDR: []string{"10.96.0.0/16"},
+			},
+		}
+
+		It("should return the configured cluster when there's no Cluster CRD", func() {
+			Expect(LocalClusterFromCRD(fake.NewSimpleClientset(), namespace, localCluster)).To(Equal(localCluster))
+		})
+
+		It("should return the CIDRs, color codes and role set in the Cluster CRD", func() {
+			clientset := fake.NewSimpleClientset(&submarinerv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "east", Namespace: namespace},
+				Spec: submarinerv1.ClusterSpec{
+					ClusterID:   "east",
+					ColorCodes:  []string{"red"},
+					ClusterCIDR: []string{"10.1.0.0/16"},
+					Role:        submarinerv1.ClusterRoleHub,
+				},
+			})
+
+			expected := localCluster
+			expected.Spec.ColorCodes = []string{"red"}
+			expected.Spec.ClusterCIDR = []string{"10.1.0.0/16"}
+			expected.Spec.Role = submarinerv1.ClusterRoleHub
+			Expect(LocalClusterFromCRD(clientset, namespace, localCluster)).To(Equal(expected))
+		})
+	})
+
+	When("a cluster rejoins after leaving", func() {
+		It("should be synced to the other clusters again", func() {
+			east := startCluster("east", "10.0.0.0/16", "blue")
+			west := startCluster("west", "10.1.0.0/16", "blue")
+
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+			west.stop()
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east"))
+
+			startCluster("west", "10.1.0.0/16", "blue")
+			Eventually(east.endpointClusterIDs, 5).Should(ConsistOf("east", "west"))
+		})
+	})
+})
+
+func newTestCluster(broker datastore.Datastore, id string, subnet string, colorCodes ...string) *testCluster {
+	c := &testCluster{
+		id:        id,
+		clientset: fake.NewSimpleClientset(),
+		recorder:  record.NewFakeRecorder(100),
+		stopCh:    make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+
+	localCluster := types.SubmarinerCluster{
+		ID: id,
+		Spec: submarinerv1.ClusterSpec{
+			ClusterID:   id,
+			ColorCodes:  colorCodes,
+			ClusterCIDR: []string{subnet},
+		},
+	}
+
+	localEndpoint := newTestEndpoint(id, subnet)
+
+	informerFactory := submarinerInformers.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+		submarinerInformers.WithNamespace(namespace))
+	syncer := NewDatastoreSyncer(id, namespace, nil, c.clientset, informerFactory.Submariner().V1().Clusters(),
+		informerFactory.Submariner().V1().Endpoints(), informerFactory.Submariner().V1().PeeringPolicies(), broker, colorCodes, localCluster, localEndpoint, c.recorder, testLeaseConfig)
+
+	informerFactory.Start(c.stopCh)
+	go func() {
+		defer GinkgoRecover()
+		defer close(c.stopped)
+		Expect(syncer.Run(c.stopCh)).To(Succeed())
+	}()
+
+	return c
+}
+
+func newTestEndpoint(id string, subnets ...string) types.SubmarinerEndpoint {
+	return types.SubmarinerEndpoint{
+		Spec: submarinerv1.EndpointSpec{
+			ClusterID: id,
+			CableName: "submariner-cable-" + id,
+			Hostname:  id + "-gateway",
+			PrivateIP: net.ParseIP("192.168.0.1"),
+			Subnets:   subnets,
+			Backend:   "ipsec",
+		},
+	}
+}
+
+func (c *testCluster) stop() {
+	select {
+	case <-c.stopCh:
+	default:
+		close(c.stopCh)
+	}
+	<-c.stopped
+}
+
+func (c *testCluster) clusterNames() ([]string, error) {
+	list, err := c.clientset.SubmarinerV1().Clusters(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, cluster := range list.Items {
+		names = append(names, cluster.Name)
+	}
+	return names, nil
+}
+
+func (c *testCluster) updateClusterSpec(update func(spec *submarinerv1.ClusterSpec)) {
+	Eventually(func() error {
+		cluster, err := c.clientset.SubmarinerV1().Clusters(namespace).Get(c.id, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		update(&cluster.Spec)
+		_, err = c.clientset.SubmarinerV1().Clusters(namespace).Update(cluster)
+		return err
+	}, 5).Should(Succeed())
+}
+
+func (c *testCluster) createPeeringPolicy(name string, action submarinerv1.PeeringPolicyAction, clusterIDs ...string) {
+	_, err := c.clientset.SubmarinerV1().PeeringPolicies(namespace).Create(&submarinerv1.PeeringPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: submarinerv1.PeeringPolicySpec{
+			Action:     action,
+			ClusterIDs: clusterIDs,
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func (c *testCluster) finalizers() ([]string, error) {
+	cluster, err := c.clientset.SubmarinerV1().Clusters(namespace).Get(c.id, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return cluster.Finalizers, nil
+}
+
+func (c *testCluster) overlapCondition() (*submarinerv1.ClusterCondition, error) {
+	cluster, err := c.clientset.SubmarinerV1().Clusters(namespace).Get(c.id, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cluster.Status.Conditions {
+		if cluster.Status.Conditions[i].Type == submarinerv1.ClusterConditionCIDROverlap {
+			return &cluster.Status.Conditions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func haveConditionStatus(status corev1.ConditionStatus) gomegatypes.GomegaMatcher {
+	return WithTransform(func(condition *submarinerv1.ClusterCondition) corev1.ConditionStatus {
+		if condition == nil {
+			return ""
+		}
+		return condition.Status
+	}, Equal(status))
+}
+
+func (c *testCluster) endpointClusterIDs() ([]string, error) {
+	list, err := c.clientset.SubmarinerV1().Endpoints(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, endpoint := range list.Items {
+		ids = append(ids, endpoint.Spec.ClusterID)
+	}
+	return ids, nil
+}
+
+// unavailableDatastore fails the first SetCluster calls as if the broker couldn't be reached
+type unavailableDatastore struct {
+	datastore.Datastore
+	sync.Mutex
+	failures int
+}
+
+func (u *unavailableDatastore) SetCluster(cluster *types.SubmarinerCluster) error {
+	u.Lock()
+	defer u.Unlock()
+	if u.failures > 0 {
+		u.failures--
+		return datastore.NewUnavailableError("the broker is unavailable")
+	}
+	return u.Datastore.SetCluster(cluster)
+}
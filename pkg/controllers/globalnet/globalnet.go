@@ -0,0 +1,504 @@
+package globalnet
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/submariner/pkg/ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+/*
+ * The globalnet controller lets clusters with overlapping pod and service CIDRs connect by giving each exported
+ * service and each pod an address from the global CIDR of the cluster. It runs on the gateway, where it DNATs
+ * the traffic to the global IPs of pods to the pods, sends the traffic to the global IPs of services to the
+ * kube-proxy chains of the services, and SNATs the traffic of the pods to the other clusters to their global IPs.
+ */
+
+const (
+	// Services with this annotation set to "true" are given a global IP
+	ExportAnnotation = "submariner.io/globalnet-export"
+	// The global IP assigned to an exported service or to a pod
+	GlobalIPAnnotation = "submariner.io/globalIp"
+
+	ingressChain = "SUBMARINER-GN-INGRESS"
+	egressChain  = "SUBMARINER-GN-EGRESS"
+)
+
+// IPTables is the subset of the go-iptables API used by the controller
+type IPTables interface {
+	ClearChain(table, chain string) error
+	Exists(table, chain string, rulespec ...string) (bool, error)
+	Insert(table, chain string, pos int, rulespec ...string) error
+	AppendUnique(table, chain string, rulespec ...string) error
+	Delete(table, chain string, rulespec ...string) error
+}
+
+// globalIPRules describes the NAT rules programmed for a service or a pod
+type globalIPRules struct {
+	globalIP string
+	// The traffic to the global IP of a pod is DNATed to its IP
+	targetIP string
+	// The traffic to the global IP of a service is sent to the kube-proxy chains of its ports. The ingress chain
+	// is jumped to before kube-proxy's KUBE-SERVICES chain, so DNATing it to the cluster IP would bypass kube-proxy.
+	servicePorts []servicePort
+	// Pods also have their traffic to the other clusters SNATed to their global IP
+	egress bool
+}
+
+type servicePort struct {
+	protocol string
+	port     int32
+	chain    string
+}
+
+type Controller struct {
+	sync.Mutex
+	kubeClientSet  kubernetes.Interface
+	serviceLister  corelisters.ServiceLister
+	podLister      corelisters.PodLister
+	servicesSynced cache.InformerSynced
+	podsSynced     cache.InformerSynced
+	ipt            IPTables
+	pool           *ipam.IPPool
+	// The globalnet CIDR range holding the global CIDRs of all the clusters
+	globalnetCIDRRange string
+
+	// The global IPs and the rules of the services and pods, keyed by kind and namespace/name
+	allocations map[string]string
+	rules       map[string]globalIPRules
+
+	serviceWorkqueue workqueue.RateLimitingInterface
+	podWorkqueue     workqueue.RateLimitingInterface
+}
+
+func NewController(globalCIDR string, globalnetCIDRRange string, ipt IPTables, kubeClientSet kubernetes.Interface,
+	serviceInformer coreinformers.ServiceInformer, podInformer coreinformers.PodInformer) (*Controller, error) {
+	pool, err := ipam.NewIPPool(globalCIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	controller := &Controller{
+		kubeClientSet:      kubeClientSet,
+		serviceLister:      serviceInformer.Lister(),
+		podLister:          podInformer.Lister(),
+		servicesSynced:     serviceInformer.Informer().HasSynced,
+		podsSynced:         podInformer.Informer().HasSynced,
+		ipt:                ipt,
+		pool:               pool,
+		globalnetCIDRRange: globalnetCIDRRange,
+		allocations:        map[string]string{},
+		rules:              map[string]globalIPRules{},
+		serviceWorkqueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Services"),
+		podWorkqueue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Pods"),
+	}
+
+	serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueService,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueueService(new)
+		},
+		DeleteFunc: controller.enqueueService,
+	})
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueuePod,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueuePod(new)
+		},
+		DeleteFunc: controller.enqueuePod,
+	})
+
+	return controller, nil
+}
+
+func (c *Controller) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.serviceWorkqueue.ShutDown()
+	defer c.podWorkqueue.ShutDown()
+
+	klog.Info("Starting the globalnet controller")
+
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.servicesSynced, c.podsSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	if err := c.ensureChains(); err != nil {
+		return err
+	}
+
+	if err := c.reserveExistingGlobalIPs(); err != nil {
+		return err
+	}
+
+	klog.Info("Starting workers")
+	go wait.Until(c.runServiceWorker, time.Second, stopCh)
+	go wait.Until(c.runPodWorker, time.Second, stopCh)
+
+	<-stopCh
+	klog.Info("Shutting down workers")
+	return nil
+}
+
+// ensureChains creates the globalnet chains, flushing any rules left over from a previous run since the rules
+// are reprogrammed for every service and pod, and makes sure the NAT chains jump to them
+func (c *Controller) ensureChains() error {
+	for _, chain := range []string{ingressChain, egressChain} {
+		if err := c.ipt.ClearChain("nat", chain); err != nil {
+			return fmt.Errorf("Error creating the iptables chain %s: %v", chain, err)
+		}
+	}
+
+	if err := c.insertUnique("nat", "PREROUTING", "-j", ingressChain); err != nil {
+		return err
+	}
+
+	// Only the traffic to the other clusters is SNATed, so that pods keep using their own IP within the cluster
+	return c.insertUnique("nat", "POSTROUTING", "-d", c.globalnetCIDRRange, "-j", egressChain)
+}
+
+func (c *Controller) insertUnique(table, chain string, ruleSpec ...string) error {
+	exists, err := c.ipt.Exists(table, chain, ruleSpec...)
+	if err != nil {
+		return fmt.Errorf("Error checking for the iptables rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	if err = c.ipt.Insert(table, chain, 1, ruleSpec...); err != nil {
+		return fmt.Errorf("Error inserting the iptables rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+	}
+	return nil
+}
+
+// reserveExistingGlobalIPs reserves the global IPs assigned before a restart, so that they aren't handed out
+// again before the services and pods holding them are processed
+func (c *Controller) reserveExistingGlobalIPs() error {
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("Error listing the services: %v", err)
+	}
+
+	for _, service := range services {
+		c.reserveGlobalIP(serviceKey(service.Namespace, service.Name), service.Annotations[GlobalIPAnnotation])
+	}
+
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("Error listing the pods: %v", err)
+	}
+
+	for _, pod := range pods {
+		c.reserveGlobalIP(podKey(pod.Namespace, pod.Name), pod.Annotations[GlobalIPAnnotation])
+	}
+	return nil
+}
+
+func (c *Controller) reserveGlobalIP(key, globalIP string) {
+	if globalIP == "" {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if err := c.pool.Reserve(globalIP); err != nil {
+		klog.Warningf("Not keeping the global IP of %s: %v", key, err)
+		return
+	}
+	c.allocations[key] = globalIP
+}
+
+func (c *Controller) enqueueService(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	klog.V(8).Infof("Enqueueing service %s", key)
+	c.serviceWorkqueue.Add(key)
+}
+
+func (c *Controller) enqueuePod(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	klog.V(8).Infof("Enqueueing pod %s", key)
+	c.podWorkqueue.Add(key)
+}
+
+func (c *Controller) runServiceWorker() {
+	for c.processNextItem(c.serviceWorkqueue, c.processService) {
+	}
+}
+
+func (c *Controller) runPodWorker() {
+	for c.processNextItem(c.podWorkqueue, c.processPod) {
+	}
+}
+
+func (c *Controller) processNextItem(queue workqueue.RateLimitingInterface, process func(namespace, name string) error) bool {
+	obj, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(obj)
+
+	key := obj.(string)
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error splitting meta namespace key %s: %v", key, err))
+		queue.Forget(obj)
+		return true
+	}
+
+	if err = process(namespace, name); err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error processing %s, requeuing: %v", key, err))
+		queue.AddRateLimited(obj)
+		return true
+	}
+
+	queue.Forget(obj)
+	return true
+}
+
+func (c *Controller) processService(namespace, name string) error {
+	key := serviceKey(namespace, name)
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.release(key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if service.Annotations[ExportAnnotation] != "true" || service.Spec.ClusterIP == "" ||
+		service.Spec.ClusterIP == corev1.ClusterIPNone {
+		c.release(key)
+		if _, ok := service.Annotations[GlobalIPAnnotation]; ok {
+			service = service.DeepCopy()
+			delete(service.Annotations, GlobalIPAnnotation)
+			_, err = c.kubeClientSet.CoreV1().Services(namespace).Update(service)
+			return err
+		}
+		return nil
+	}
+
+	globalIP, err := c.ensureGlobalIP(key, service.Annotations[GlobalIPAnnotation])
+	if err != nil {
+		return err
+	}
+
+	if service.Annotations[GlobalIPAnnotation] != globalIP {
+		service = service.DeepCopy()
+		setGlobalIPAnnotation(&service.ObjectMeta.Annotations, globalIP)
+		if _, err = c.kubeClientSet.CoreV1().Services(namespace).Update(service); err != nil {
+			return fmt.Errorf("Error annotating the service with its global IP: %v", err)
+		}
+		klog.V(4).Infof("Assigned the global IP %s to service %s/%s", globalIP, namespace, name)
+	}
+
+	rules := globalIPRules{globalIP: globalIP}
+	for _, port := range service.Spec.Ports {
+		protocol := strings.ToLower(string(port.Protocol))
+		rules.servicePorts = append(rules.servicePorts, servicePort{
+			protocol: protocol,
+			port:     port.Port,
+			chain:    kubeProxyServiceChain(namespace, name, port.Name, protocol),
+		})
+	}
+
+	return c.programRules(key, rules)
+}
+
+// kubeProxyServiceChain returns the name of the nat chain which kube-proxy, in iptables mode, programs for a port
+// of a service to load balance its traffic to the endpoints of the service
+func kubeProxyServiceChain(namespace, name, portName, protocol string) string {
+	servicePortName := namespace + "/" + name
+	if portName != "" {
+		servicePortName += ":" + portName
+	}
+
+	hash := sha256.Sum256([]byte(servicePortName + protocol))
+	return "KUBE-SVC-" + base32.StdEncoding.EncodeToString(hash[:])[:16]
+}
+
+func (c *Controller) processPod(namespace, name string) error {
+	key := podKey(namespace, name)
+	pod, err := c.podLister.Pods(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.release(key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		c.release(key)
+		return nil
+	}
+
+	if pod.Status.PodIP == "" {
+		klog.V(6).Infof("Pod %s has no IP yet", key)
+		return nil
+	}
+
+	globalIP, err := c.ensureGlobalIP(key, pod.Annotations[GlobalIPAnnotation])
+	if err != nil {
+		return err
+	}
+
+	if pod.Annotations[GlobalIPAnnotation] != globalIP {
+		pod = pod.DeepCopy()
+		setGlobalIPAnnotation(&pod.ObjectMeta.Annotations, globalIP)
+		if _, err = c.kubeClientSet.CoreV1().Pods(namespace).Update(pod); err != nil {
+			return fmt.Errorf("Error annotating the pod with its global IP: %v", err)
+		}
+		klog.V(4).Infof("Assigned the global IP %s to pod %s/%s", globalIP, namespace, name)
+	}
+
+	return c.programRules(key, globalIPRules{globalIP: globalIP, targetIP: pod.Status.PodIP, egress: true})
+}
+
+// ensureGlobalIP returns the global IP of the service or pod, keeping the annotated one if it can be reserved
+func (c *Controller) ensureGlobalIP(key, annotatedIP string) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if globalIP, ok := c.allocations[key]; ok {
+		return globalIP, nil
+	}
+
+	if annotatedIP != "" {
+		if err := c.pool.Reserve(annotatedIP); err == nil {
+			c.allocations[key] = annotatedIP
+			return annotatedIP, nil
+		}
+	}
+
+	globalIP, err := c.pool.Allocate()
+	if err != nil {
+		return "", err
+	}
+	c.allocations[key] = globalIP
+	return globalIP, nil
+}
+
+// programRules replaces the NAT rules of the service or pod
+func (c *Controller) programRules(key string, rules globalIPRules) error {
+	c.Lock()
+	defer c.Unlock()
+
+	existing, ok := c.rules[key]
+	if ok && reflect.DeepEqual(existing, rules) {
+		return nil
+	}
+
+	if ok {
+		c.deleteRules(existing)
+		delete(c.rules, key)
+	}
+
+	for _, ruleSpec := range ingressRuleSpecs(rules) {
+		klog.V(6).Infof("Installing iptables rule: %s", strings.Join(ruleSpec, " "))
+		if err := c.ipt.AppendUnique("nat", ingressChain, ruleSpec...); err != nil {
+			return fmt.Errorf("Error appending iptables rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+		}
+	}
+
+	if rules.egress {
+		ruleSpec := egressRuleSpec(rules)
+		klog.V(6).Infof("Installing iptables rule: %s", strings.Join(ruleSpec, " "))
+		if err := c.ipt.AppendUnique("nat", egressChain, ruleSpec...); err != nil {
+			return fmt.Errorf("Error appending iptables rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+		}
+	}
+
+	c.rules[key] = rules
+	return nil
+}
+
+// release removes the NAT rules of the service or pod and returns its global IP to the pool
+func (c *Controller) release(key string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if rules, ok := c.rules[key]; ok {
+		c.deleteRules(rules)
+		delete(c.rules, key)
+	}
+
+	if globalIP, ok := c.allocations[key]; ok {
+		klog.V(4).Infof("Releasing the global IP %s of %s", globalIP, key)
+		c.pool.Release(globalIP)
+		delete(c.allocations, key)
+	}
+}
+
+// deleteRules must be called with the lock held
+func (c *Controller) deleteRules(rules globalIPRules) {
+	for _, ruleSpec := range ingressRuleSpecs(rules) {
+		if err := c.ipt.Delete("nat", ingressChain, ruleSpec...); err != nil {
+			klog.Errorf("Error deleting iptables rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+		}
+	}
+
+	if rules.egress {
+		ruleSpec := egressRuleSpec(rules)
+		if err := c.ipt.Delete("nat", egressChain, ruleSpec...); err != nil {
+			klog.Errorf("Error deleting iptables rule \"%s\": %v", strings.Join(ruleSpec, " "), err)
+		}
+	}
+}
+
+func ingressRuleSpecs(rules globalIPRules) [][]string {
+	if rules.targetIP != "" {
+		return [][]string{{"-d", rules.globalIP, "-j", "DNAT", "--to-destination", rules.targetIP}}
+	}
+
+	var ruleSpecs [][]string
+	for _, port := range rules.servicePorts {
+		ruleSpecs = append(ruleSpecs, []string{"-d", rules.globalIP, "-p", port.protocol, "--dport",
+			fmt.Sprintf("%d", port.port), "-j", port.chain})
+	}
+	return ruleSpecs
+}
+
+func egressRuleSpec(rules globalIPRules) []string {
+	return []string{"-s", rules.targetIP, "-j", "SNAT", "--to-source", rules.globalIP}
+}
+
+func setGlobalIPAnnotation(annotations *map[string]string, globalIP string) {
+	if *annotations == nil {
+		*annotations = map[string]string{}
+	}
+	(*annotations)[GlobalIPAnnotation] = globalIP
+}
+
+func serviceKey(namespace, name string) string {
+	return "service/" + namespace + "/" + name
+}
+
+func podKey(namespace, name string) string {
+	return "pod/" + namespace + "/" + name
+}
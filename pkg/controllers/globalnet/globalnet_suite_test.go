@@ -0,0 +1,13 @@
+package globalnet
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGlobalnet(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Globalnet Suite")
+}
@@ -0,0 +1,282 @@
+package globalnet
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/rancher/submariner/pkg/ipam"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The chain kube-proxy programs for the http port of the default/nginx service
+const nginxServiceChain = "KUBE-SVC-P4Q3KNUAWJVP4ILH"
+
+var _ = Describe("Globalnet controller", func() {
+	var controller *Controller
+	var ipt *fakeIPTables
+
+	BeforeEach(func() {
+		pool, err := ipam.NewIPPool("169.254.0.0/24")
+		Expect(err).ToNot(HaveOccurred())
+		ipt = newFakeIPTables()
+		controller = &Controller{
+			ipt:                ipt,
+			pool:               pool,
+			globalnetCIDRRange: "169.254.0.0/16",
+			allocations:        map[string]string{},
+			rules:              map[string]globalIPRules{},
+		}
+	})
+
+	Describe("Function ensureChains", func() {
+		It("Should hook the globalnet chains into the NAT chains once", func() {
+			Expect(controller.ensureChains()).To(Succeed())
+			Expect(controller.ensureChains()).To(Succeed())
+			Expect(ipt.rules("nat", "PREROUTING")).To(ConsistOf("-j " + ingressChain))
+			Expect(ipt.rules("nat", "POSTROUTING")).To(ConsistOf("-d 169.254.0.0/16 -j " + egressChain))
+		})
+
+		It("Should flush the rules left over from a previous run", func() {
+			Expect(ipt.AppendUnique("nat", ingressChain, "-d", "169.254.0.1", "-j", "DNAT", "--to-destination", "10.0.0.1")).To(Succeed())
+			Expect(controller.ensureChains()).To(Succeed())
+			Expect(ipt.rules("nat", ingressChain)).To(BeEmpty())
+		})
+	})
+
+	Describe("Function ensureGlobalIP", func() {
+		Context("When the object has no global IP", func() {
+			It("Should allocate one and keep it", func() {
+				globalIP, err := controller.ensureGlobalIP(podKey("default", "client"), "")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(globalIP).To(Equal("169.254.0.1"))
+				Expect(controller.ensureGlobalIP(podKey("default", "client"), "")).To(Equal(globalIP))
+			})
+		})
+
+		Context("When the object is annotated with a free global IP", func() {
+			It("Should keep it", func() {
+				Expect(controller.ensureGlobalIP(podKey("default", "client"), "169.254.0.7")).To(Equal("169.254.0.7"))
+			})
+		})
+
+		Context("When the object is annotated with a global IP in use", func() {
+			It("Should allocate another one", func() {
+				Expect(controller.ensureGlobalIP(podKey("default", "first"), "169.254.0.1")).To(Equal("169.254.0.1"))
+				Expect(controller.ensureGlobalIP(podKey("default", "second"), "169.254.0.1")).To(Equal("169.254.0.2"))
+			})
+		})
+	})
+
+	Describe("Function programRules", func() {
+		It("Should send the traffic to the global IP of a service to the kube-proxy chains of its ports", func() {
+			Expect(controller.programRules(serviceKey("default", "nginx"), globalIPRules{globalIP: "169.254.0.1",
+				servicePorts: []servicePort{{protocol: "tcp", port: 80, chain: nginxServiceChain}}})).To(Succeed())
+			Expect(ipt.rules("nat", ingressChain)).To(ConsistOf("-d 169.254.0.1 -p tcp --dport 80 -j " + nginxServiceChain))
+			Expect(ipt.rules("nat", egressChain)).To(BeEmpty())
+		})
+
+		It("Should DNAT the global IP of a pod to its IP and SNAT its traffic to its global IP", func() {
+			Expect(controller.programRules(podKey("default", "client"),
+				globalIPRules{globalIP: "169.254.0.1", targetIP: "10.0.0.5", egress: true})).To(Succeed())
+			Expect(ipt.rules("nat", ingressChain)).To(ConsistOf("-d 169.254.0.1 -j DNAT --to-destination 10.0.0.5"))
+			Expect(ipt.rules("nat", egressChain)).To(ConsistOf("-s 10.0.0.5 -j SNAT --to-source 169.254.0.1"))
+		})
+
+		It("Should replace the rules when the target IP changes", func() {
+			key := podKey("default", "client")
+			Expect(controller.programRules(key, globalIPRules{globalIP: "169.254.0.1", targetIP: "10.0.0.5", egress: true})).To(Succeed())
+			Expect(controller.programRules(key, globalIPRules{globalIP: "169.254.0.1", targetIP: "10.0.0.6", egress: true})).To(Succeed())
+			Expect(ipt.rules("nat", ingressChain)).To(ConsistOf("-d 169.254.0.1 -j DNAT --to-destination 10.0.0.6"))
+			Expect(ipt.rules("nat", egressChain)).To(ConsistOf("-s 10.0.0.6 -j SNAT --to-source 169.254.0.1"))
+		})
+	})
+
+	Describe("Function kubeProxyServiceChain", func() {
+		It("Should return the chain name used by kube-proxy", func() {
+			Expect(kubeProxyServiceChain("default", "nginx", "http", "tcp")).To(Equal(nginxServiceChain))
+		})
+	})
+
+	Describe("Function processService", func() {
+		var services cache.Indexer
+
+		BeforeEach(func() {
+			services = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			controller.serviceLister = corelisters.NewServiceLister(services)
+
+			// The rules kube-proxy programs for the service, it's hooked into PREROUTING before globalnet starts
+			Expect(ipt.AppendUnique("nat", "PREROUTING", "-j", "KUBE-SERVICES")).To(Succeed())
+			Expect(ipt.AppendUnique("nat", "KUBE-SERVICES", "-d", "10.96.0.10", "-p", "tcp", "--dport", "80", "-j", nginxServiceChain)).To(Succeed())
+			Expect(ipt.AppendUnique("nat", nginxServiceChain, "-j", "KUBE-SEP-NGINX")).To(Succeed())
+			Expect(ipt.AppendUnique("nat", "KUBE-SEP-NGINX", "-p", "tcp", "-j", "DNAT", "--to-destination", "10.0.0.20:8080")).To(Succeed())
+			Expect(controller.ensureChains()).To(Succeed())
+		})
+
+		Context("When the service is exported", func() {
+			It("Should deliver the traffic to its global IP to its endpoints", func() {
+				Expect(services.Add(&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default", Annotations: map[string]string{
+						ExportAnnotation:   "true",
+						GlobalIPAnnotation: "169.254.0.1",
+					}},
+					Spec: corev1.ServiceSpec{
+						ClusterIP: "10.96.0.10",
+						Ports:     []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+					},
+				})).To(Succeed())
+
+				Expect(controller.processService("default", "nginx")).To(Succeed())
+				Expect(ipt.traverse("nat", "PREROUTING", packet{dst: "169.254.0.1", protocol: "tcp", port: "80"})).To(
+					Equal(packet{dst: "10.0.0.20", protocol: "tcp", port: "8080"}))
+			})
+		})
+	})
+
+	Describe("Function release", func() {
+		It("Should remove the rules and return the global IP to the pool", func() {
+			key := podKey("default", "client")
+			globalIP, err := controller.ensureGlobalIP(key, "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(controller.programRules(key, globalIPRules{globalIP: globalIP, targetIP: "10.0.0.5", egress: true})).To(Succeed())
+
+			controller.release(key)
+
+			Expect(ipt.rules("nat", ingressChain)).To(BeEmpty())
+			Expect(ipt.rules("nat", egressChain)).To(BeEmpty())
+			Expect(controller.ensureGlobalIP(podKey("default", "other"), globalIP)).To(Equal(globalIP))
+		})
+	})
+})
+
+// fakeIPTables keeps the rules of each chain in memory
+type fakeIPTables struct {
+	sync.Mutex
+	chains map[string][]string
+}
+
+func newFakeIPTables() *fakeIPTables {
+	return &fakeIPTables{chains: map[string][]string{}}
+}
+
+func (f *fakeIPTables) rules(table, chain string) []string {
+	f.Lock()
+	defer f.Unlock()
+	return append([]string{}, f.chains[table+"/"+chain]...)
+}
+
+func (f *fakeIPTables) ClearChain(table, chain string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.chains[table+"/"+chain] = nil
+	return nil
+}
+
+func (f *fakeIPTables) Exists(table, chain string, rulespec ...string) (bool, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.indexOf(table, chain, rulespec) >= 0, nil
+}
+
+func (f *fakeIPTables) Insert(table, chain string, pos int, rulespec ...string) error {
+	f.Lock()
+	defer f.Unlock()
+	key := table + "/" + chain
+	rules := f.chains[key]
+	f.chains[key] = append(rules[:pos-1], append([]string{strings.Join(rulespec, " ")}, rules[pos-1:]...)...)
+	return nil
+}
+
+func (f *fakeIPTables) AppendUnique(table, chain string, rulespec ...string) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.indexOf(table, chain, rulespec) < 0 {
+		f.chains[table+"/"+chain] = append(f.chains[table+"/"+chain], strings.Join(rulespec, " "))
+	}
+	return nil
+}
+
+func (f *fakeIPTables) Delete(table, chain string, rulespec ...string) error {
+	f.Lock()
+	defer f.Unlock()
+	i := f.indexOf(table, chain, rulespec)
+	if i < 0 {
+		return fmt.Errorf("rule %v doesn't exist in %s/%s", rulespec, table, chain)
+	}
+	key := table + "/" + chain
+	f.chains[key] = append(f.chains[key][:i], f.chains[key][i+1:]...)
+	return nil
+}
+
+// packet holds the fields of a packet that the nat rules of the tests match and rewrite
+type packet struct {
+	dst      string
+	protocol string
+	port     string
+}
+
+// traverse passes the packet through the rules of a nat chain like the kernel does, following the jumps to other
+// chains until a DNAT rule rewrites its destination
+func (f *fakeIPTables) traverse(table, chain string, p packet) packet {
+	result, _ := f.traverseChain(table, chain, p)
+	return result
+}
+
+func (f *fakeIPTables) traverseChain(table, chain string, p packet) (packet, bool) {
+	for _, rule := range f.rules(table, chain) {
+		args := strings.Fields(rule)
+		matches := true
+		target, toDestination := "", ""
+		for i := 0; i+1 < len(args); i += 2 {
+			switch args[i] {
+			case "-d":
+				matches = matches && args[i+1] == p.dst
+			case "-p":
+				matches = matches && args[i+1] == p.protocol
+			case "--dport":
+				matches = matches && args[i+1] == p.port
+			case "-j":
+				target = args[i+1]
+			case "--to-destination":
+				toDestination = args[i+1]
+			}
+		}
+
+		if !matches {
+			continue
+		}
+
+		switch target {
+		case "DNAT":
+			parts := strings.SplitN(toDestination, ":", 2)
+			p.dst = parts[0]
+			if len(parts) == 2 {
+				p.port = parts[1]
+			}
+			return p, true
+		case "RETURN":
+			return p, false
+		default:
+			if result, done := f.traverseChain(table, target, p); done {
+				return result, true
+			}
+		}
+	}
+	return p, false
+}
+
+func (f *fakeIPTables) indexOf(table, chain string, rulespec []string) int {
+	rule := strings.Join(rulespec, " ")
+	for i, r := range f.chains[table+"/"+chain] {
+		if r == rule {
+			return i
+		}
+	}
+	return -1
+}
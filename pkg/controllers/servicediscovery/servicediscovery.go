@@ -0,0 +1,425 @@
+package servicediscovery
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
+	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions/submariner.io/v1"
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/controllers/globalnet"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/types"
+	"github.com/rancher/submariner/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+/*
+ * The service discovery controller publishes the services that have a ServiceExport of the same name to the
+ * other clusters through the central datastore, and mirrors the services exported by the other clusters into
+ * local ServiceImport CRDs, which the DNS responder answers <service>.<namespace>.svc.clusterset.local queries
+ * from.
+ */
+
+// globalIPRequestedAnnotation marks the services whose globalnet export annotation was added by this controller,
+// so that it's only removed again from those when their ServiceExport is deleted
+const globalIPRequestedAnnotation = "submariner.io/service-discovery-export"
+
+type Controller struct {
+	thisClusterID   string
+	objectNamespace string
+	colorCodes      []string
+	// With globalnet the services are exported with their global IP instead of their ClusterIP
+	globalnetEnabled bool
+
+	kubeClientSet        kubernetes.Interface
+	submarinerClientSet  submarinerClientset.Interface
+	serviceLister        corelisters.ServiceLister
+	serviceExportLister  submarinerListers.ServiceExportLister
+	servicesSynced       cache.InformerSynced
+	serviceExportsSynced cache.InformerSynced
+	datastore            datastore.Datastore
+
+	exportWorkqueue workqueue.RateLimitingInterface
+
+	// The keys of the services this cluster exported to the central datastore, only those are withdrawn from it
+	sync.Mutex
+	exportedServices map[string]bool
+}
+
+func NewController(thisClusterID string, objectNamespace string, colorCodes []string, globalnetEnabled bool,
+	kubeClientSet kubernetes.Interface, submarinerClientSet submarinerClientset.Interface,
+	serviceInformer coreinformers.ServiceInformer, serviceExportInformer submarinerInformers.ServiceExportInformer,
+	datastore datastore.Datastore) *Controller {
+	controller := &Controller{
+		thisClusterID:        thisClusterID,
+		objectNamespace:      objectNamespace,
+		colorCodes:           colorCodes,
+		globalnetEnabled:     globalnetEnabled,
+		kubeClientSet:        kubeClientSet,
+		submarinerClientSet:  submarinerClientSet,
+		serviceLister:        serviceInformer.Lister(),
+		serviceExportLister:  serviceExportInformer.Lister(),
+		servicesSynced:       serviceInformer.Informer().HasSynced,
+		serviceExportsSynced: serviceExportInformer.Informer().HasSynced,
+		datastore:            datastore,
+		exportWorkqueue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "ServiceExports"),
+		exportedServices:     map[string]bool{},
+	}
+
+	// A service and its ServiceExport share the same key, so either of them changing reconciles the export
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueExport,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueueExport(new)
+		},
+		DeleteFunc: controller.enqueueExport,
+	}
+	serviceInformer.Informer().AddEventHandler(handler)
+	serviceExportInformer.Informer().AddEventHandler(handler)
+
+	return controller
+}
+
+func (c *Controller) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.exportWorkqueue.ShutDown()
+
+	klog.Info("Starting the service discovery controller")
+
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.servicesSynced, c.serviceExportsSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	c.enqueueExportedServices()
+	c.removeStaleServiceImportCRDs()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := c.datastore.WatchServiceImports(ctx, c.thisClusterID, c.colorCodes, c.reconcileServiceImportCRD); err != nil {
+		return fmt.Errorf("Error watching service imports in the central datastore: %v", err)
+	}
+
+	klog.Info("Starting workers")
+	go wait.Until(c.runExportWorker, time.Second, stopCh)
+
+	<-stopCh
+	klog.Info("Shutting down workers")
+	return nil
+}
+
+// enqueueExportedServices enqueues the services this cluster exported before a restart, so that the ones whose
+// ServiceExport was deleted in the meantime are withdrawn from the central datastore
+func (c *Controller) enqueueExportedServices() {
+	serviceImports, err := c.datastore.GetServiceImports(c.thisClusterID)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error retrieving the services exported by cluster %s: %v", c.thisClusterID, err))
+		return
+	}
+
+	for _, serviceImport := range serviceImports {
+		key := serviceImport.Spec.Namespace + "/" + serviceImport.Spec.ServiceName
+		c.setExported(key, true)
+		c.exportWorkqueue.Add(key)
+	}
+}
+
+// removeStaleServiceImportCRDs deletes the local ServiceImport CRDs of services that were withdrawn while we
+// weren't watching the central datastore
+func (c *Controller) removeStaleServiceImportCRDs() {
+	localImports, err := c.submarinerClientSet.SubmarinerV1().ServiceImports(c.objectNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error listing the local ServiceImport CRDs: %v", err))
+		return
+	}
+
+	exported := map[string][]types.SubmarinerServiceImport{}
+	for _, localImport := range localImports.Items {
+		clusterID := localImport.Spec.ClusterID
+		if _, ok := exported[clusterID]; !ok {
+			serviceImports, err := c.datastore.GetServiceImports(clusterID)
+			if err != nil {
+				utilruntime.HandleError(fmt.Errorf("Error retrieving the services exported by cluster %s: %v", clusterID, err))
+				continue
+			}
+			exported[clusterID] = serviceImports
+		}
+
+		if !searchServiceImports(exported[clusterID], localImport.Spec.Namespace, localImport.Spec.ServiceName) {
+			klog.Infof("Service %s/%s is no longer exported by cluster %s, deleting its ServiceImport CRD",
+				localImport.Spec.Namespace, localImport.Spec.ServiceName, clusterID)
+			utilruntime.HandleError(c.reconcileServiceImportCRD(&types.SubmarinerServiceImport{Spec: localImport.Spec}, true))
+		}
+	}
+}
+
+func (c *Controller) enqueueExport(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	klog.V(8).Infof("Enqueueing service export %s", key)
+	c.exportWorkqueue.Add(key)
+}
+
+func (c *Controller) runExportWorker() {
+	for c.processNextExport() {
+	}
+}
+
+func (c *Controller) processNextExport() bool {
+	obj, shutdown := c.exportWorkqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.exportWorkqueue.Done(obj)
+
+	key := obj.(string)
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error splitting meta namespace key %s: %v", key, err))
+		c.exportWorkqueue.Forget(obj)
+		return true
+	}
+
+	if err = c.reconcileExport(namespace, name); err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error exporting service %s: %v", key, err))
+		c.exportWorkqueue.AddRateLimited(obj)
+		return true
+	}
+
+	c.exportWorkqueue.Forget(obj)
+	return true
+}
+
+// reconcileExport publishes the service to the central datastore if it has a ServiceExport, and withdraws it
+// otherwise
+func (c *Controller) reconcileExport(namespace, name string) error {
+	_, err := c.serviceExportLister.ServiceExports(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		if err := c.releaseGlobalIP(namespace, name); err != nil {
+			return err
+		}
+		return c.withdraw(namespace, name)
+	} else if err != nil {
+		return err
+	}
+
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("Service %s/%s is exported but doesn't exist", namespace, name)
+		return c.withdraw(namespace, name)
+	} else if err != nil {
+		return err
+	}
+
+	if service.Spec.Type == corev1.ServiceTypeExternalName || service.Spec.ClusterIP == "" ||
+		service.Spec.ClusterIP == corev1.ClusterIPNone {
+		klog.Warningf("Service %s/%s has no ClusterIP, only services with a ClusterIP can be exported", namespace, name)
+		return c.withdraw(namespace, name)
+	}
+
+	ip := service.Spec.ClusterIP
+	if c.globalnetEnabled {
+		if service.Annotations[globalnet.ExportAnnotation] != "true" {
+			return c.requestGlobalIP(service)
+		}
+
+		ip = service.Annotations[globalnet.GlobalIPAnnotation]
+		if ip == "" {
+			klog.V(4).Infof("Service %s/%s has no global IP yet", namespace, name)
+			return nil
+		}
+	}
+
+	serviceImport := &types.SubmarinerServiceImport{
+		Spec: submarinerv1.ServiceImportSpec{
+			ClusterID:   c.thisClusterID,
+			Namespace:   namespace,
+			ServiceName: name,
+			IPs:         []string{ip},
+		},
+	}
+
+	for _, port := range service.Spec.Ports {
+		serviceImport.Spec.Ports = append(serviceImport.Spec.Ports, submarinerv1.ServicePort{
+			Name:     port.Name,
+			Protocol: port.Protocol,
+			Port:     port.Port,
+		})
+	}
+
+	klog.V(4).Infof("Exporting service %s/%s with IP %s", namespace, name, ip)
+	if err := c.datastore.SetServiceImport(serviceImport); err != nil {
+		return err
+	}
+	c.setExported(namespace+"/"+name, true)
+	return nil
+}
+
+// requestGlobalIP annotates the service so that the globalnet controller gives it a global IP, the service is
+// exported once it's annotated with it
+func (c *Controller) requestGlobalIP(service *corev1.Service) error {
+	service = service.DeepCopy()
+	if service.Annotations == nil {
+		service.Annotations = map[string]string{}
+	}
+	service.Annotations[globalnet.ExportAnnotation] = "true"
+	service.Annotations[globalIPRequestedAnnotation] = "true"
+
+	if _, err := c.kubeClientSet.CoreV1().Services(service.Namespace).Update(service); err != nil {
+		return fmt.Errorf("Error requesting a global IP for service %s/%s: %v", service.Namespace, service.Name, err)
+	}
+	klog.V(4).Infof("Requested a global IP for service %s/%s", service.Namespace, service.Name)
+	return nil
+}
+
+// releaseGlobalIP removes the globalnet export annotation requestGlobalIP added to the service, so that the
+// globalnet controller releases its global IP. Annotations the service was given by someone else are kept.
+func (c *Controller) releaseGlobalIP(namespace, name string) error {
+	if !c.globalnetEnabled {
+		return nil
+	}
+
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if service.Annotations[globalIPRequestedAnnotation] != "true" {
+		return nil
+	}
+
+	service = service.DeepCopy()
+	delete(service.Annotations, globalnet.ExportAnnotation)
+	delete(service.Annotations, globalIPRequestedAnnotation)
+
+	if _, err := c.kubeClientSet.CoreV1().Services(namespace).Update(service); err != nil {
+		return fmt.Errorf("Error releasing the global IP of service %s/%s: %v", namespace, name, err)
+	}
+	klog.V(4).Infof("Released the global IP of service %s/%s", namespace, name)
+	return nil
+}
+
+// withdraw removes the service from the central datastore if this cluster exported it, the services that were
+// never exported are skipped so that their resyncs don't reach the central datastore
+func (c *Controller) withdraw(namespace, name string) error {
+	key := namespace + "/" + name
+	if !c.isExported(key) {
+		return nil
+	}
+
+	err := c.datastore.RemoveServiceImport(c.thisClusterID, namespace, name)
+	if err != nil && !datastore.IsNotFound(err) {
+		return err
+	}
+
+	klog.V(4).Infof("Withdrew service %s", key)
+	c.setExported(key, false)
+	return nil
+}
+
+func (c *Controller) isExported(key string) bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.exportedServices[key]
+}
+
+func (c *Controller) setExported(key string, exported bool) {
+	c.Lock()
+	defer c.Unlock()
+	if exported {
+		c.exportedServices[key] = true
+	} else {
+		delete(c.exportedServices, key)
+	}
+}
+
+// reconcileServiceImportCRD mirrors a service exported by another cluster into the local ServiceImport CRDs
+func (c *Controller) reconcileServiceImportCRD(rawServiceImport *types.SubmarinerServiceImport, delete bool) error {
+	serviceImportName, err := util.GetServiceImportCRDName(rawServiceImport)
+	if err != nil {
+		return fmt.Errorf("Error converting the ServiceImport CRD name: %v", err)
+	}
+
+	client := c.submarinerClientSet.SubmarinerV1().ServiceImports(c.objectNamespace)
+	serviceImport, err := client.Get(serviceImportName, metav1.GetOptions{})
+	found := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("Error retrieving the local ServiceImport CRD %s: %v", serviceImportName, err)
+	}
+
+	if delete {
+		if !found {
+			klog.V(6).Infof("ServiceImport CRD %s was not found for deletion", serviceImportName)
+			return nil
+		}
+
+		klog.V(6).Infof("Attempting to delete ServiceImport CRD %s from local datastore", serviceImportName)
+		if err = client.Delete(serviceImportName, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("Error deleting ServiceImport CRD %s from the local datastore: %v", serviceImportName, err)
+		}
+		return nil
+	}
+
+	if !found {
+		serviceImport = &submarinerv1.ServiceImport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: serviceImportName,
+			},
+			Spec: rawServiceImport.Spec,
+		}
+		if _, err = client.Create(serviceImport); err != nil {
+			return fmt.Errorf("Error creating ServiceImport CRD %s in the local datastore: %v", serviceImportName, err)
+		}
+		return nil
+	}
+
+	if reflect.DeepEqual(serviceImport.Spec, rawServiceImport.Spec) {
+		klog.V(4).Infof("ServiceImport CRD matched what we received from datastore, not reconciling")
+		return nil
+	}
+
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		result, getErr := client.Get(serviceImportName, metav1.GetOptions{})
+		if getErr != nil {
+			return fmt.Errorf("Error retrieving latest version of ServiceImport %s: %v", serviceImportName, getErr)
+		}
+		result.Spec = rawServiceImport.Spec
+		_, updateErr := client.Update(result)
+		return updateErr
+	})
+	if retryErr != nil {
+		return fmt.Errorf("Error updating ServiceImport CRD %s: %v", serviceImportName, retryErr)
+	}
+	return nil
+}
+
+func searchServiceImports(serviceImports []types.SubmarinerServiceImport, namespace, serviceName string) bool {
+	for _, serviceImport := range serviceImports {
+		if serviceImport.Spec.Namespace == namespace && serviceImport.Spec.ServiceName == serviceName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package servicediscovery
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	fakeClientset "github.com/rancher/submariner/pkg/client/clientset/versioned/fake"
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/controllers/globalnet"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/datastore/inmemory"
+	"github.com/rancher/submariner/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	thisClusterID   = "east"
+	objectNamespace = "submariner"
+)
+
+var _ = Describe("Service discovery controller", func() {
+	var controller *Controller
+	var ds *countingDatastore
+	var services cache.Indexer
+	var serviceExports cache.Indexer
+
+	BeforeEach(func() {
+		ds = &countingDatastore{Datastore: inmemory.NewDatastore()}
+		services = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		serviceExports = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		controller = &Controller{
+			thisClusterID:       thisClusterID,
+			objectNamespace:     objectNamespace,
+			submarinerClientSet: fakeClientset.NewSimpleClientset(),
+			serviceLister:       corelisters.NewServiceLister(services),
+			serviceExportLister: submarinerListers.NewServiceExportLister(serviceExports),
+			datastore:           ds,
+			exportWorkqueue:     workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+			exportedServices:    map[string]bool{},
+		}
+	})
+
+	exported := func() []types.SubmarinerServiceImport {
+		serviceImports, err := ds.GetServiceImports(thisClusterID)
+		Expect(err).ToNot(HaveOccurred())
+		return serviceImports
+	}
+
+	Describe("Function reconcileExport", func() {
+		Context("When a service has a ServiceExport", func() {
+			It("Should export its ClusterIP and ports", func() {
+				Expect(services.Add(newService("default", "nginx", "10.96.0.10"))).To(Succeed())
+				Expect(serviceExports.Add(newServiceExport("default", "nginx"))).To(Succeed())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(HaveLen(1))
+				Expect(exported()[0].Spec).To(Equal(submarinerv1.ServiceImportSpec{
+					ClusterID:   thisClusterID,
+					Namespace:   "default",
+					ServiceName: "nginx",
+					IPs:         []string{"10.96.0.10"},
+					Ports:       []submarinerv1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+				}))
+			})
+		})
+
+		Context("When a service has no ServiceExport", func() {
+			It("Should not export it", func() {
+				Expect(services.Add(newService("default", "nginx", "10.96.0.10"))).To(Succeed())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(BeEmpty())
+			})
+
+			It("Should not remove it from the central datastore", func() {
+				Expect(services.Add(newService("default", "nginx", "10.96.0.10"))).To(Succeed())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(ds.removedServiceImports).To(Equal(0))
+			})
+		})
+
+		Context("When the ServiceExport of a service exported before a restart was deleted", func() {
+			It("Should withdraw the service", func() {
+				Expect(ds.SetServiceImport(newServiceImport(thisClusterID, "default", "nginx", "10.96.0.10"))).To(Succeed())
+				Expect(services.Add(newService("default", "nginx", "10.96.0.10"))).To(Succeed())
+
+				controller.enqueueExportedServices()
+				Expect(controller.exportWorkqueue.Len()).To(Equal(1))
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(BeEmpty())
+				Expect(ds.removedServiceImports).To(Equal(1))
+			})
+		})
+
+		Context("When the ServiceExport of an exported service is deleted", func() {
+			It("Should withdraw the service", func() {
+				Expect(services.Add(newService("default", "nginx", "10.96.0.10"))).To(Succeed())
+				export := newServiceExport("default", "nginx")
+				Expect(serviceExports.Add(export)).To(Succeed())
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(HaveLen(1))
+
+				Expect(serviceExports.Delete(export)).To(Succeed())
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(BeEmpty())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(ds.removedServiceImports).To(Equal(1))
+			})
+		})
+
+		Context("When an exported service is headless", func() {
+			It("Should not export it", func() {
+				Expect(services.Add(newService("default", "nginx", corev1.ClusterIPNone))).To(Succeed())
+				Expect(serviceExports.Add(newServiceExport("default", "nginx"))).To(Succeed())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(BeEmpty())
+			})
+		})
+
+		Context("When globalnet is enabled", func() {
+			BeforeEach(func() {
+				controller.globalnetEnabled = true
+			})
+
+			It("Should export the global IP of the service", func() {
+				service := newService("default", "nginx", "10.96.0.10")
+				service.Annotations = map[string]string{
+					globalnet.ExportAnnotation:   "true",
+					globalnet.GlobalIPAnnotation: "169.254.0.5",
+				}
+				Expect(services.Add(service)).To(Succeed())
+				Expect(serviceExports.Add(newServiceExport("default", "nginx"))).To(Succeed())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(HaveLen(1))
+				Expect(exported()[0].Spec.IPs).To(Equal([]string{"169.254.0.5"}))
+			})
+
+			It("Should wait for the service to be given a global IP", func() {
+				service := newService("default", "nginx", "10.96.0.10")
+				service.Annotations = map[string]string{globalnet.ExportAnnotation: "true"}
+				Expect(services.Add(service)).To(Succeed())
+				Expect(serviceExports.Add(newServiceExport("default", "nginx"))).To(Succeed())
+
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+				Expect(exported()).To(BeEmpty())
+			})
+
+			It("Should keep the export annotation of a service it didn't request a global IP for", func() {
+				service := newService("default", "nginx", "10.96.0.10")
+				service.Annotations = map[string]string{
+					globalnet.ExportAnnotation:   "true",
+					globalnet.GlobalIPAnnotation: "169.254.0.5",
+				}
+				Expect(services.Add(service)).To(Succeed())
+
+				// The controller has no kube clientset, so this would fail if it tried to update the service
+				Expect(controller.reconcileExport("default", "nginx")).To(Succeed())
+			})
+		})
+	})
+
+	Describe("Function reconcileServiceImportCRD", func() {
+		localImport := func(name string) (*submarinerv1.ServiceImport, error) {
+			return controller.submarinerClientSet.SubmarinerV1().ServiceImports(objectNamespace).Get(name, metav1.GetOptions{})
+		}
+
+		It("Should create, update and delete the local ServiceImport CRD", func() {
+			west := newServiceImport("west", "default", "nginx", "10.96.0.10")
+			Expect(controller.reconcileServiceImportCRD(west, false)).To(Succeed())
+			serviceImport, err := localImport("west-default-nginx")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serviceImport.Spec).To(Equal(west.Spec))
+
+			west.Spec.IPs = []string{"10.96.0.20"}
+			Expect(controller.reconcileServiceImportCRD(west, false)).To(Succeed())
+			serviceImport, err = localImport("west-default-nginx")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serviceImport.Spec).To(Equal(west.Spec))
+
+			Expect(controller.reconcileServiceImportCRD(west, true)).To(Succeed())
+			_, err = localImport("west-default-nginx")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Function removeStaleServiceImportCRDs", func() {
+		It("Should only delete the CRDs of services that are no longer exported", func() {
+			Expect(ds.SetCluster(&types.SubmarinerCluster{ID: "west"})).To(Succeed())
+			kept := newServiceImport("west", "default", "nginx", "10.96.0.10")
+			Expect(ds.SetServiceImport(kept)).To(Succeed())
+			Expect(controller.reconcileServiceImportCRD(kept, false)).To(Succeed())
+			Expect(controller.reconcileServiceImportCRD(newServiceImport("west", "default", "httpd", "10.96.0.20"), false)).To(Succeed())
+
+			controller.removeStaleServiceImportCRDs()
+
+			serviceImports, err := controller.submarinerClientSet.SubmarinerV1().ServiceImports(objectNamespace).List(metav1.ListOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serviceImports.Items).To(HaveLen(1))
+			Expect(serviceImports.Items[0].Name).To(Equal("west-default-nginx"))
+		})
+	})
+})
+
+func newService(namespace, name, clusterIP string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeClusterIP,
+			ClusterIP: clusterIP,
+			Ports:     []corev1.ServicePort{{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80}},
+		},
+	}
+}
+
+func newServiceExport(namespace, name string) *submarinerv1.ServiceExport {
+	return &submarinerv1.ServiceExport{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+}
+
+func newServiceImport(clusterID, namespace, serviceName, ip string) *types.SubmarinerServiceImport {
+	return &types.SubmarinerServiceImport{
+		Spec: submarinerv1.ServiceImportSpec{
+			ClusterID:   clusterID,
+			Namespace:   namespace,
+			ServiceName: serviceName,
+			IPs:         []string{ip},
+		},
+	}
+}
+
+// countingDatastore counts the service imports removed from the central datastore
+type countingDatastore struct {
+	*inmemory.Datastore
+	removedServiceImports int
+}
+
+func (d *countingDatastore) RemoveServiceImport(clusterID, namespace, serviceName string) error {
+	d.removedServiceImports++
+	return d.Datastore.RemoveServiceImport(clusterID, namespace, serviceName)
+}
+
+var _ datastore.Datastore = &countingDatastore{}
@@ -0,0 +1,534 @@
+package trafficfilter
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions/submariner.io/v1"
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+/*
+ * The traffic filter controller enforces the TrafficPolicies on the gateway, through which all the traffic from
+ * the remote clusters enters the cluster. Without policies the traffic isn't filtered. Once a policy exists, the
+ * traffic from the CIDRs of the remote clusters is dropped unless a policy allows it, and the drops are counted
+ * per remote cluster.
+ *
+ * The rules are programmed alternately in two chains, and the FORWARD chain is switched over to the chain holding
+ * the new rules, so that the traffic is never let through while the rules are replaced. kube-proxy or the CNI may
+ * later insert their own ACCEPT rules above the jump, which would let the traffic bypass the policies, so the
+ * position of the jump is checked periodically and the rules are programmed again in the other chain if needed.
+ *
+ * The FORWARD chain sees the traffic after kube-proxy translated the service IPs in PREROUTING, so the destination
+ * CIDRs of the policies match the pods backing the services rather than their ClusterIPs.
+ */
+
+const (
+	reasonTrafficDropped = "TrafficDropped"
+
+	// The single work item which reprograms the rules, whichever object changed
+	rulesKey = "rules"
+
+	// How often the drop counters are read and reported
+	dropsReportInterval = 30 * time.Second
+
+	// The comment of the DROP rules, followed by the ID of the remote cluster
+	dropCommentPrefix = "submariner-cluster "
+)
+
+var chains = [2]string{"SUBMARINER-TRAFFIC-A", "SUBMARINER-TRAFFIC-B"}
+
+// IPTables is the subset of the go-iptables API used by the controller
+type IPTables interface {
+	ClearChain(table, chain string) error
+	Exists(table, chain string, rulespec ...string) (bool, error)
+	List(table, chain string) ([]string, error)
+	Insert(table, chain string, pos int, rulespec ...string) error
+	Append(table, chain string, rulespec ...string) error
+	Delete(table, chain string, rulespec ...string) error
+	Stats(table, chain string) ([][]string, error)
+}
+
+type Controller struct {
+	sync.Mutex
+	clusterID       string
+	objectNamespace string
+	ipt             IPTables
+	recorder        record.EventRecorder
+
+	policyLister  submarinerListers.TrafficPolicyLister
+	clusterLister submarinerListers.ClusterLister
+	podLister     corelisters.PodLister
+	cachesSynced  []cache.InformerSynced
+
+	// The index of the chain the FORWARD chain jumps to, -1 when the traffic isn't filtered
+	activeChain int
+	// The rules of the active chain, which are only compared once they've been programmed
+	rules      [][]string
+	programmed bool
+	// The packets dropped from each remote cluster by the active chain when they were last reported
+	drops map[string]uint64
+
+	workqueue workqueue.RateLimitingInterface
+}
+
+func NewController(clusterID string, objectNamespace string, ipt IPTables, recorder record.EventRecorder,
+	policyInformer submarinerInformers.TrafficPolicyInformer, clusterInformer submarinerInformers.ClusterInformer,
+	podInformer coreinformers.PodInformer) *Controller {
+	controller := &Controller{
+		clusterID:       clusterID,
+		objectNamespace: objectNamespace,
+		ipt:             ipt,
+		recorder:        recorder,
+		policyLister:    policyInformer.Lister(),
+		clusterLister:   clusterInformer.Lister(),
+		podLister:       podInformer.Lister(),
+		cachesSynced: []cache.InformerSynced{policyInformer.Informer().HasSynced, clusterInformer.Informer().HasSynced,
+			podInformer.Informer().HasSynced},
+		activeChain: -1,
+		drops:       map[string]uint64{},
+		workqueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "TrafficPolicies"),
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueue,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueue(new)
+		},
+		DeleteFunc: controller.enqueue,
+	}
+	policyInformer.Informer().AddEventHandler(handler)
+	clusterInformer.Informer().AddEventHandler(handler)
+	podInformer.Informer().AddEventHandler(handler)
+
+	return controller
+}
+
+func (c *Controller) Run(stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	klog.Info("Starting the traffic filter controller")
+
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, c.cachesSynced...); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	if err := c.findActiveChain(); err != nil {
+		return err
+	}
+
+	c.workqueue.Add(rulesKey)
+
+	klog.Info("Starting workers")
+	go wait.Until(c.runWorker, time.Second, stopCh)
+	go wait.Until(c.reportDrops, dropsReportInterval, stopCh)
+
+	<-stopCh
+	klog.Info("Shutting down workers")
+	return nil
+}
+
+// findActiveChain finds the chain the FORWARD chain jumps to after a restart, so that its rules stay in place
+// until they're replaced
+func (c *Controller) findActiveChain() error {
+	c.Lock()
+	defer c.Unlock()
+
+	for i, chain := range chains {
+		exists, err := c.ipt.Exists("filter", "FORWARD", "-j", chain)
+		if err != nil {
+			return fmt.Errorf("Error checking for the jump to the iptables chain %s: %v", chain, err)
+		}
+
+		if exists {
+			c.activeChain = i
+		}
+	}
+	return nil
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	klog.V(8).Infof("Enqueueing the traffic rules after a change of %v", obj)
+	c.workqueue.Add(rulesKey)
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	if err := c.syncRules(); err != nil {
+		utilruntime.HandleError(fmt.Errorf("Error programming the traffic rules, requeuing: %v", err))
+		c.workqueue.AddRateLimited(obj)
+		return true
+	}
+
+	c.workqueue.Forget(obj)
+	return true
+}
+
+func (c *Controller) syncRules() error {
+	policies, err := c.policyLister.TrafficPolicies(c.objectNamespace).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("Error listing the TrafficPolicies: %v", err)
+	}
+
+	clusters, err := c.clusterLister.Clusters(c.objectNamespace).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("Error listing the Clusters: %v", err)
+	}
+
+	pods := map[string][]*corev1.Pod{}
+	for _, policy := range policies {
+		for _, destination := range policy.Spec.To {
+			if destination.Namespace == "" {
+				continue
+			}
+
+			if pods[destination.Namespace], err = c.podLister.Pods(destination.Namespace).List(labels.Everything()); err != nil {
+				return fmt.Errorf("Error listing the pods of namespace %s: %v", destination.Namespace, err)
+			}
+		}
+	}
+
+	return c.programRules(renderRules(policies, clusters, c.clusterID, pods))
+}
+
+// renderRules returns the rules enforcing the policies, or nil when there are no policies. The rules let through
+// the replies to the connections opened from the local cluster and the allowed traffic, and drop the rest of the
+// traffic from the remote clusters.
+func renderRules(policies []*submarinerv1.TrafficPolicy, clusters []*submarinerv1.Cluster, localClusterID string,
+	pods map[string][]*corev1.Pod) [][]string {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	remoteCIDRs := map[string][]string{}
+	var remoteClusterIDs []string
+	for _, cluster := range clusters {
+		if cluster.Spec.ClusterID == localClusterID {
+			continue
+		}
+		remoteCIDRs[cluster.Spec.ClusterID] = util.GetClusterRoutableCIDRs(cluster.Spec)
+		remoteClusterIDs = append(remoteClusterIDs, cluster.Spec.ClusterID)
+	}
+	sort.Strings(remoteClusterIDs)
+
+	sortedPolicies := append([]*submarinerv1.TrafficPolicy{}, policies...)
+	sort.Slice(sortedPolicies, func(i, j int) bool {
+		return sortedPolicies[i].Name < sortedPolicies[j].Name
+	})
+
+	rules := [][]string{{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "RETURN"}}
+	rendered := map[string]bool{}
+	for _, policy := range sortedPolicies {
+		for _, source := range policySources(policy, remoteCIDRs) {
+			for _, destination := range policyDestinations(policy, pods) {
+				for _, port := range policyPorts(policy) {
+					var rule []string
+					if source != "" {
+						rule = append(rule, "-s", source)
+					}
+					if destination != "" {
+						rule = append(rule, "-d", destination)
+					}
+					rule = append(append(rule, port...), "-j", "RETURN")
+
+					if key := strings.Join(rule, " "); !rendered[key] {
+						rendered[key] = true
+						rules = append(rules, rule)
+					}
+				}
+			}
+		}
+	}
+
+	for _, clusterID := range remoteClusterIDs {
+		for _, cidr := range remoteCIDRs[clusterID] {
+			rules = append(rules, []string{"-s", cidr, "-m", "comment", "--comment", dropCommentPrefix + clusterID, "-j", "DROP"})
+		}
+	}
+	return rules
+}
+
+// policySources returns the source CIDRs of the policy, an empty string stands for all the remote clusters
+func policySources(policy *submarinerv1.TrafficPolicy, remoteCIDRs map[string][]string) []string {
+	if len(policy.Spec.From) == 0 {
+		return []string{""}
+	}
+
+	var sources []string
+	for _, source := range policy.Spec.From {
+		if len(source.CIDRs) > 0 {
+			sources = append(sources, validCIDRs(policy, source.CIDRs)...)
+		} else if cidrs, ok := remoteCIDRs[source.ClusterID]; ok {
+			sources = append(sources, cidrs...)
+		} else {
+			klog.Warningf("TrafficPolicy %s refers to the unknown cluster %q", policy.Name, source.ClusterID)
+		}
+	}
+	return sources
+}
+
+// policyDestinations returns the destination CIDRs of the policy, an empty string stands for the whole local cluster
+func policyDestinations(policy *submarinerv1.TrafficPolicy, pods map[string][]*corev1.Pod) []string {
+	if len(policy.Spec.To) == 0 {
+		return []string{""}
+	}
+
+	var destinations []string
+	for _, destination := range policy.Spec.To {
+		if destination.Namespace != "" {
+			// The pods are listed in no particular order, the rules are only reprogrammed when they change
+			var podIPs []string
+			for _, pod := range pods[destination.Namespace] {
+				if pod.Status.PodIP != "" && !pod.Spec.HostNetwork {
+					podIPs = append(podIPs, pod.Status.PodIP+"/32")
+				}
+			}
+			sort.Strings(podIPs)
+			destinations = append(destinations, podIPs...)
+		}
+		destinations = append(destinations, validCIDRs(policy, destination.CIDRs)...)
+	}
+	return destinations
+}
+
+// policyPorts returns the protocol and port matches of the policy, a nil match stands for all the ports
+func policyPorts(policy *submarinerv1.TrafficPolicy) [][]string {
+	if len(policy.Spec.Ports) == 0 {
+		return [][]string{nil}
+	}
+
+	var ports [][]string
+	for _, port := range policy.Spec.Ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+
+		match := []string{"-p", strings.ToLower(string(protocol))}
+		if port.Port != 0 {
+			match = append(match, "--dport", strconv.Itoa(int(port.Port)))
+		}
+		ports = append(ports, match)
+	}
+	return ports
+}
+
+func validCIDRs(policy *submarinerv1.TrafficPolicy, cidrs []string) []string {
+	var valid []string
+	for _, cidr := range cidrs {
+		if _, ipNet, err := net.ParseCIDR(cidr); err != nil {
+			klog.Warningf("Ignoring the invalid CIDR %q of TrafficPolicy %s: %v", cidr, policy.Name, err)
+		} else {
+			valid = append(valid, ipNet.String())
+		}
+	}
+	return valid
+}
+
+// programRules programs the rules in the inactive chain and switches the FORWARD chain over to it, or removes
+// the jump to the active chain when there are no rules
+func (c *Controller) programRules(rules [][]string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.programmed && reflect.DeepEqual(rules, c.rules) {
+		return nil
+	}
+
+	previous := c.activeChain
+	if previous >= 0 {
+		c.collectDrops()
+	}
+
+	if rules == nil {
+		if previous >= 0 {
+			klog.Info("There are no TrafficPolicies anymore, the traffic from the remote clusters isn't filtered")
+			if err := c.removeChain(previous); err != nil {
+				return err
+			}
+		}
+		c.activeChain = -1
+		c.rules = nil
+		c.programmed = true
+		c.drops = map[string]uint64{}
+		return nil
+	}
+
+	next := (previous + 1) % len(chains)
+	if previous < 0 {
+		next = 0
+	}
+
+	if err := c.ipt.ClearChain("filter", chains[next]); err != nil {
+		return fmt.Errorf("Error creating the iptables chain %s: %v", chains[next], err)
+	}
+
+	for _, rule := range rules {
+		klog.V(8).Infof("Installing iptables rule in %s: %s", chains[next], strings.Join(rule, " "))
+		if err := c.ipt.Append("filter", chains[next], rule...); err != nil {
+			return fmt.Errorf("Error appending iptables rule \"%s\": %v", strings.Join(rule, " "), err)
+		}
+	}
+
+	if err := c.ipt.Insert("filter", "FORWARD", 1, "-j", chains[next]); err != nil {
+		return fmt.Errorf("Error inserting the jump to the iptables chain %s: %v", chains[next], err)
+	}
+
+	c.activeChain = next
+	c.rules = rules
+	c.programmed = true
+	c.drops = map[string]uint64{}
+
+	if previous >= 0 {
+		if err := c.removeChain(previous); err != nil {
+			return err
+		}
+	}
+
+	klog.V(4).Infof("Programmed %d traffic rules in %s", len(rules), chains[next])
+	return nil
+}
+
+// removeChain removes the jump to the chain and flushes it, it must be called with the lock held
+func (c *Controller) removeChain(index int) error {
+	if err := c.ipt.Delete("filter", "FORWARD", "-j", chains[index]); err != nil {
+		return fmt.Errorf("Error deleting the jump to the iptables chain %s: %v", chains[index], err)
+	}
+
+	if err := c.ipt.ClearChain("filter", chains[index]); err != nil {
+		return fmt.Errorf("Error flushing the iptables chain %s: %v", chains[index], err)
+	}
+	return nil
+}
+
+func (c *Controller) reportDrops() {
+	c.Lock()
+	defer c.Unlock()
+	c.collectDrops()
+	c.checkJump()
+}
+
+// checkJump programs the rules again when the jump to the active chain isn't the first rule of the FORWARD chain
+// anymore, it must be called with the lock held
+func (c *Controller) checkJump() {
+	if c.activeChain < 0 || !c.programmed {
+		return
+	}
+
+	rules, err := c.ipt.List("filter", "FORWARD")
+	if err != nil {
+		klog.Errorf("Error listing the rules of the FORWARD chain: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if strings.HasPrefix(rule, "-A FORWARD ") {
+			if rule == "-A FORWARD -j "+chains[c.activeChain] {
+				return
+			}
+			break
+		}
+	}
+
+	klog.Warningf("The jump to the iptables chain %s isn't the first rule of the FORWARD chain anymore, "+
+		"programming the traffic rules again", chains[c.activeChain])
+	c.programmed = false
+	c.workqueue.Add(rulesKey)
+}
+
+// collectDrops reads the drop counters of the active chain and reports the packets dropped from each remote
+// cluster since the last report, it must be called with the lock held
+func (c *Controller) collectDrops() {
+	if c.activeChain < 0 {
+		return
+	}
+
+	rows, err := c.ipt.Stats("filter", chains[c.activeChain])
+	if err != nil {
+		klog.Errorf("Error reading the counters of the iptables chain %s: %v", chains[c.activeChain], err)
+		return
+	}
+
+	counts := map[string]uint64{}
+	for _, row := range rows {
+		// The fields are the packets, the bytes, the target, ... and the options holding the comment
+		if len(row) < 10 || row[2] != "DROP" {
+			continue
+		}
+
+		clusterID := clusterIDFromComment(row[9])
+		if clusterID == "" {
+			continue
+		}
+
+		packets, err := strconv.ParseUint(row[0], 10, 64)
+		if err != nil {
+			klog.Errorf("Error parsing the packet counter %q of the iptables chain %s: %v", row[0], chains[c.activeChain], err)
+			continue
+		}
+		counts[clusterID] += packets
+	}
+
+	for clusterID, count := range counts {
+		if count <= c.drops[clusterID] {
+			continue
+		}
+
+		dropped := count - c.drops[clusterID]
+		klog.Warningf("The traffic policies dropped %d packets from cluster %s", dropped, clusterID)
+		cluster, err := c.clusterLister.Clusters(c.objectNamespace).Get(clusterID)
+		if err != nil {
+			klog.Errorf("Error retrieving the Cluster %s to report the dropped packets: %v", clusterID, err)
+			continue
+		}
+
+		c.recorder.Eventf(cluster, corev1.EventTypeWarning, reasonTrafficDropped,
+			"The traffic policies dropped %d packets from cluster %s", dropped, clusterID)
+	}
+	c.drops = counts
+}
+
+// clusterIDFromComment returns the ID of the cluster in the comment of a DROP rule, as listed with its counters
+func clusterIDFromComment(options string) string {
+	start := strings.Index(options, "/* "+dropCommentPrefix)
+	if start < 0 {
+		return ""
+	}
+
+	comment := options[start+len("/* "+dropCommentPrefix):]
+	end := strings.Index(comment, " */")
+	if end < 0 {
+		return ""
+	}
+	return comment[:end]
+}
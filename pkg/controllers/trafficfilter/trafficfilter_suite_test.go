@@ -0,0 +1,13 @@
+package trafficfilter
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestTrafficFilter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Traffic Filter Suite")
+}
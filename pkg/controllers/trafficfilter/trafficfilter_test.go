@@ -0,0 +1,329 @@
+package trafficfilter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const namespace = "submariner"
+
+var _ = Describe("Traffic filter controller", func() {
+	clusters := []*submarinerv1.Cluster{
+		newCluster("east", "10.0.0.0/16"),
+		newCluster("west", "10.1.0.0/16"),
+		newCluster("north", "10.2.0.0/16"),
+	}
+
+	dropWest := []string{"-s", "10.1.0.0/16", "-m", "comment", "--comment", "submariner-cluster west", "-j", "DROP"}
+	dropNorth := []string{"-s", "10.2.0.0/16", "-m", "comment", "--comment", "submariner-cluster north", "-j", "DROP"}
+	established := []string{"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED", "-j", "RETURN"}
+
+	Describe("Function renderRules", func() {
+		It("Should not filter the traffic without policies", func() {
+			Expect(renderRules(nil, clusters, "east", nil)).To(BeNil())
+		})
+
+		It("Should allow the selected traffic and drop the rest of the traffic from the remote clusters", func() {
+			policy := newPolicy("web", submarinerv1.TrafficPolicySpec{
+				From:  []submarinerv1.TrafficPolicySource{{ClusterID: "west"}, {CIDRs: []string{"10.2.1.0/24"}}},
+				To:    []submarinerv1.TrafficPolicyDestination{{Namespace: "web"}, {CIDRs: []string{"10.96.0.10/32"}}},
+				Ports: []submarinerv1.TrafficPolicyPort{{Port: 443}, {Protocol: corev1.ProtocolUDP, Port: 53}},
+			})
+			pods := map[string][]*corev1.Pod{"web": {
+				newPod("nginx-2", "10.0.0.6", false),
+				newPod("nginx-1", "10.0.0.5", false),
+				newPod("host", "192.168.0.1", true),
+			}}
+
+			var expected [][]string
+			expected = append(expected, established)
+			for _, source := range []string{"10.1.0.0/16", "10.2.1.0/24"} {
+				for _, destination := range []string{"10.0.0.5/32", "10.0.0.6/32", "10.96.0.10/32"} {
+					expected = append(expected,
+						[]string{"-s", source, "-d", destination, "-p", "tcp", "--dport", "443", "-j", "RETURN"},
+						[]string{"-s", source, "-d", destination, "-p", "udp", "--dport", "53", "-j", "RETURN"})
+				}
+			}
+			expected = append(expected, dropNorth, dropWest)
+
+			Expect(renderRules([]*submarinerv1.TrafficPolicy{policy}, clusters, "east", pods)).To(Equal(expected))
+		})
+
+		It("Should allow all the traffic matching the criteria a policy leaves empty", func() {
+			policy := newPolicy("from-west", submarinerv1.TrafficPolicySpec{
+				From: []submarinerv1.TrafficPolicySource{{ClusterID: "west"}},
+			})
+			Expect(renderRules([]*submarinerv1.TrafficPolicy{policy}, clusters, "east", nil)).To(Equal([][]string{
+				established,
+				{"-s", "10.1.0.0/16", "-j", "RETURN"},
+				dropNorth,
+				dropWest,
+			}))
+		})
+
+		It("Should allow nothing for unknown clusters and invalid CIDRs", func() {
+			policy := newPolicy("bogus", submarinerv1.TrafficPolicySpec{
+				From: []submarinerv1.TrafficPolicySource{{ClusterID: "south"}, {CIDRs: []string{"10.1.0.0/33"}}},
+			})
+			Expect(renderRules([]*submarinerv1.TrafficPolicy{policy}, clusters, "east", nil)).To(Equal([][]string{
+				established,
+				dropNorth,
+				dropWest,
+			}))
+		})
+	})
+
+	Describe("Function programRules", func() {
+		var controller *Controller
+		var ipt *fakeIPTables
+
+		BeforeEach(func() {
+			ipt = newFakeIPTables()
+			controller = &Controller{ipt: ipt, activeChain: -1, drops: map[string]uint64{}}
+		})
+
+		It("Should switch the FORWARD chain over to the chain holding the new rules", func() {
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+			Expect(ipt.rules("filter", "FORWARD")).To(Equal([]string{"-j " + chains[0]}))
+			Expect(ipt.rules("filter", chains[0])).To(HaveLen(2))
+
+			Expect(controller.programRules([][]string{established, dropNorth, dropWest})).To(Succeed())
+			Expect(ipt.rules("filter", "FORWARD")).To(Equal([]string{"-j " + chains[1]}))
+			Expect(ipt.rules("filter", chains[1])).To(HaveLen(3))
+			Expect(ipt.rules("filter", chains[0])).To(BeEmpty())
+		})
+
+		It("Should not reprogram unchanged rules", func() {
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+			Expect(ipt.rules("filter", "FORWARD")).To(Equal([]string{"-j " + chains[0]}))
+		})
+
+		It("Should stop filtering once the policies are removed", func() {
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+			Expect(controller.programRules(nil)).To(Succeed())
+			Expect(ipt.rules("filter", "FORWARD")).To(BeEmpty())
+			Expect(ipt.rules("filter", chains[0])).To(BeEmpty())
+		})
+
+		It("Should replace the rules left over from a previous run", func() {
+			Expect(ipt.Insert("filter", "FORWARD", 1, "-j", chains[1])).To(Succeed())
+			Expect(controller.findActiveChain()).To(Succeed())
+
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+			Expect(ipt.rules("filter", "FORWARD")).To(Equal([]string{"-j " + chains[0]}))
+		})
+	})
+
+	Describe("Function checkJump", func() {
+		var controller *Controller
+		var ipt *fakeIPTables
+
+		BeforeEach(func() {
+			ipt = newFakeIPTables()
+			controller = &Controller{ipt: ipt, activeChain: -1, drops: map[string]uint64{},
+				workqueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())}
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			controller.workqueue.ShutDown()
+		})
+
+		It("Should leave the rules alone while the jump is the first FORWARD rule", func() {
+			Expect(ipt.Append("filter", "FORWARD", "-j", "ACCEPT")).To(Succeed())
+			controller.checkJump()
+			Expect(controller.workqueue.Len()).To(BeZero())
+		})
+
+		It("Should move the jump back to the top of the FORWARD chain once a rule was inserted above it", func() {
+			Expect(ipt.Insert("filter", "FORWARD", 1, "-j", "KUBE-FORWARD")).To(Succeed())
+			controller.checkJump()
+			Expect(controller.workqueue.Len()).To(Equal(1))
+
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+			Expect(ipt.rules("filter", "FORWARD")).To(Equal([]string{"-j " + chains[1], "-j KUBE-FORWARD"}))
+			Expect(ipt.rules("filter", chains[1])).To(HaveLen(2))
+			Expect(ipt.rules("filter", chains[0])).To(BeEmpty())
+		})
+	})
+
+	Describe("Function collectDrops", func() {
+		It("Should report the packets dropped from each cluster since the last report", func() {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			Expect(indexer.Add(clusters[1])).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			ipt := newFakeIPTables()
+			controller := &Controller{
+				objectNamespace: namespace,
+				ipt:             ipt,
+				recorder:        recorder,
+				clusterLister:   submarinerListers.NewClusterLister(indexer),
+				activeChain:     -1,
+				drops:           map[string]uint64{},
+			}
+			Expect(controller.programRules([][]string{established, dropWest})).To(Succeed())
+
+			ipt.setPackets(chains[0], 1, 5)
+			controller.collectDrops()
+			Expect(recorder.Events).To(Receive(And(ContainSubstring(reasonTrafficDropped), ContainSubstring("dropped 5 packets from cluster west"))))
+
+			controller.collectDrops()
+			Expect(recorder.Events).ToNot(Receive())
+
+			ipt.setPackets(chains[0], 1, 8)
+			controller.collectDrops()
+			Expect(recorder.Events).To(Receive(ContainSubstring("dropped 3 packets from cluster west")))
+		})
+	})
+
+	Describe("Function clusterIDFromComment", func() {
+		It("Should return the cluster ID of a DROP rule comment", func() {
+			Expect(clusterIDFromComment("/* submariner-cluster west */")).To(Equal("west"))
+			Expect(clusterIDFromComment("ctstate RELATED,ESTABLISHED")).To(BeEmpty())
+		})
+	})
+})
+
+func newCluster(id, cidr string) *submarinerv1.Cluster {
+	return &submarinerv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: id, Namespace: namespace},
+		Spec:       submarinerv1.ClusterSpec{ClusterID: id, ClusterCIDR: []string{cidr}},
+	}
+}
+
+func newPolicy(name string, spec submarinerv1.TrafficPolicySpec) *submarinerv1.TrafficPolicy {
+	return &submarinerv1.TrafficPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       spec,
+	}
+}
+
+func newPod(name, ip string, hostNetwork bool) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "web"},
+		Spec:       corev1.PodSpec{HostNetwork: hostNetwork},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+// fakeIPTables keeps the rules of each chain in memory, with a packet counter for each rule
+type fakeIPTables struct {
+	sync.Mutex
+	chains  map[string][]string
+	packets map[string]map[int]uint64
+}
+
+func newFakeIPTables() *fakeIPTables {
+	return &fakeIPTables{chains: map[string][]string{}, packets: map[string]map[int]uint64{}}
+}
+
+func (f *fakeIPTables) rules(table, chain string) []string {
+	f.Lock()
+	defer f.Unlock()
+	return append([]string{}, f.chains[table+"/"+chain]...)
+}
+
+func (f *fakeIPTables) setPackets(chain string, index int, packets uint64) {
+	f.Lock()
+	defer f.Unlock()
+	if f.packets[chain] == nil {
+		f.packets[chain] = map[int]uint64{}
+	}
+	f.packets[chain][index] = packets
+}
+
+func (f *fakeIPTables) ClearChain(table, chain string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.chains[table+"/"+chain] = nil
+	delete(f.packets, chain)
+	return nil
+}
+
+func (f *fakeIPTables) Exists(table, chain string, rulespec ...string) (bool, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.indexOf(table, chain, rulespec) >= 0, nil
+}
+
+// List renders the rules like iptables -S
+func (f *fakeIPTables) List(table, chain string) ([]string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	rules := []string{"-P " + chain + " ACCEPT"}
+	for _, rule := range f.chains[table+"/"+chain] {
+		rules = append(rules, "-A "+chain+" "+rule)
+	}
+	return rules, nil
+}
+
+func (f *fakeIPTables) Insert(table, chain string, pos int, rulespec ...string) error {
+	f.Lock()
+	defer f.Unlock()
+	key := table + "/" + chain
+	rules := f.chains[key]
+	f.chains[key] = append(rules[:pos-1], append([]string{strings.Join(rulespec, " ")}, rules[pos-1:]...)...)
+	return nil
+}
+
+func (f *fakeIPTables) Append(table, chain string, rulespec ...string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.chains[table+"/"+chain] = append(f.chains[table+"/"+chain], strings.Join(rulespec, " "))
+	return nil
+}
+
+func (f *fakeIPTables) Delete(table, chain string, rulespec ...string) error {
+	f.Lock()
+	defer f.Unlock()
+	i := f.indexOf(table, chain, rulespec)
+	if i < 0 {
+		return fmt.Errorf("rule %v doesn't exist in %s/%s", rulespec, table, chain)
+	}
+	key := table + "/" + chain
+	f.chains[key] = append(f.chains[key][:i], f.chains[key][i+1:]...)
+	return nil
+}
+
+// Stats renders the rules like iptables -L -n -v -x, only the packets, the target and the comment are filled in
+func (f *fakeIPTables) Stats(table, chain string) ([][]string, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	var rows [][]string
+	for i, rule := range f.chains[table+"/"+chain] {
+		fields := strings.Fields(rule)
+		target := fields[len(fields)-1]
+		options := ""
+		if j := strings.Index(rule, "--comment "); j >= 0 {
+			options = "/* " + strings.TrimSuffix(rule[j+len("--comment "):], " -j "+target) + " */"
+		}
+		rows = append(rows, []string{fmt.Sprint(f.packets[chain][i]), "0", target, "all", "--", "*", "*",
+			"0.0.0.0/0", "0.0.0.0/0", options})
+	}
+	return rows, nil
+}
+
+func (f *fakeIPTables) indexOf(table, chain string, rulespec []string) int {
+	rule := strings.Join(rulespec, " ")
+	for i, r := range f.chains[table+"/"+chain] {
+		if r == rule {
+			return i
+		}
+	}
+	return -1
+}
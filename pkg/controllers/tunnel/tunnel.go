@@ -2,14 +2,18 @@ package tunnel
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
 	"github.com/rancher/submariner/pkg/cableengine"
-	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
 	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions/submariner.io/v1"
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/peering"
+	"github.com/rancher/submariner/pkg/topology"
 	"github.com/rancher/submariner/pkg/types"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
@@ -19,24 +23,42 @@ import (
 )
 
 type Controller struct {
+	sync.Mutex
+
+	clusterID           string
 	ce                  cableengine.Engine
 	kubeClientSet       kubernetes.Interface
-	submarinerClientSet submarinerClientset.Interface
+	clusterLister       submarinerListers.ClusterLister
+	clustersSynced      cache.InformerSynced
 	endpointsSynced     cache.InformerSynced
+	endpointLister      submarinerListers.EndpointLister
+	peeringPolicyLister submarinerListers.PeeringPolicyLister
+	peeringSynced       cache.InformerSynced
 
 	objectNamespace string
 
+	// The names of the cables installed by the controller, which are removed when the topology changes
+	installedCables map[string]bool
+
+	workers           int
 	endpointWorkqueue workqueue.RateLimitingInterface
 }
 
-func NewController(objectNamespace string, ce cableengine.Engine, kubeClientSet kubernetes.Interface, submarinerClientSet submarinerClientset.Interface, endpointInformer submarinerInformers.EndpointInformer) *Controller {
+func NewController(clusterID string, objectNamespace string, ce cableengine.Engine, kubeClientSet kubernetes.Interface, workers int, clusterInformer submarinerInformers.ClusterInformer, endpointInformer submarinerInformers.EndpointInformer, peeringPolicyInformer submarinerInformers.PeeringPolicyInformer) *Controller {
 	tunnelController := &Controller{
+		clusterID:           clusterID,
 		ce:                  ce,
 		kubeClientSet:       kubeClientSet,
-		submarinerClientSet: submarinerClientSet,
+		clusterLister:       clusterInformer.Lister(),
+		clustersSynced:      clusterInformer.Informer().HasSynced,
 		endpointsSynced:     endpointInformer.Informer().HasSynced,
+		endpointLister:      endpointInformer.Lister(),
+		peeringPolicyLister: peeringPolicyInformer.Lister(),
+		peeringSynced:       peeringPolicyInformer.Informer().HasSynced,
+		workers:             workers,
 		endpointWorkqueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Endpoints"),
 		objectNamespace:     objectNamespace,
+		installedCables:     map[string]bool{},
 	}
 	klog.Info("Setting up event handlers")
 	endpointInformer.Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
@@ -47,6 +69,24 @@ func NewController(objectNamespace string, ce cableengine.Engine, kubeClientSet
 		DeleteFunc: tunnelController.handleRemovedEndpoint,
 	}, 60*time.Second)
 
+	// The endpoints of the clusters which a changed policy allows are installed
+	peeringPolicyInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: tunnelController.enqueueAllEndpoints,
+		UpdateFunc: func(old, new interface{}) {
+			tunnelController.enqueueAllEndpoints(new)
+		},
+		DeleteFunc: tunnelController.enqueueAllEndpoints,
+	})
+
+	// The roles and CIDRs of the clusters determine which cables are installed and the subnets the hubs forward
+	clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: tunnelController.enqueueAllEndpoints,
+		UpdateFunc: func(old, new interface{}) {
+			tunnelController.enqueueAllEndpoints(new)
+		},
+		DeleteFunc: tunnelController.enqueueAllEndpoints,
+	})
+
 	return tunnelController
 }
 
@@ -58,12 +98,14 @@ func (t *Controller) Run(stopCh <-chan struct{}) error {
 
 	// Wait for the caches to be synced before starting workers
 	klog.Info("Waiting for informer caches to sync")
-	if ok := cache.WaitForCacheSync(stopCh, t.endpointsSynced); !ok {
+	if ok := cache.WaitForCacheSync(stopCh, t.clustersSynced, t.endpointsSynced, t.peeringSynced); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
 
-	klog.Info("Starting workers")
-	go wait.Until(t.runWorker, time.Second, stopCh)
+	klog.Infof("Starting %d workers", t.workers)
+	for i := 0; i < t.workers; i++ {
+		go wait.Until(t.runWorker, time.Second, stopCh)
+	}
 
 	klog.Info("Started workers")
 	<-stopCh
@@ -90,19 +132,59 @@ func (t *Controller) processNextEndpoint() bool {
 		if err != nil {
 			return fmt.Errorf("error splitting meta namespace key for endpoint %s: %v", obj, err)
 		}
-		endpoint, err := t.submarinerClientSet.SubmarinerV1().Endpoints(ns).Get(key, metav1.GetOptions{})
-		if err != nil {
+		endpoint, err := t.endpointLister.Endpoints(ns).Get(key)
+		if apierrors.IsNotFound(err) {
+			// The cable of a removed endpoint is removed by handleRemovedEndpoint
 			t.endpointWorkqueue.Forget(obj)
+			return nil
+		} else if err != nil {
+			t.endpointWorkqueue.AddRateLimited(obj)
 			return fmt.Errorf("error retrieving submariner endpoint key %s: %v", key, err)
 		}
 		myEndpoint := types.SubmarinerEndpoint{
 			Spec: endpoint.Spec,
 		}
+		// The local endpoint is the source of truth for the local side of the cables, edits apply in place
+		if endpoint.Spec.ClusterID == t.clusterID {
+			if err = t.ce.UpdateLocalEndpoint(myEndpoint); err != nil {
+				t.endpointWorkqueue.AddRateLimited(obj)
+				return fmt.Errorf("error updating the local endpoint %#v, %v", myEndpoint, err)
+			}
+			t.endpointWorkqueue.Forget(obj)
+			return nil
+		}
+		allowed, err := t.isPeeringAllowed(ns, endpoint.Spec.ClusterID)
+		if err != nil {
+			t.endpointWorkqueue.AddRateLimited(obj)
+			return err
+		}
+		// The cables which are already installed are removed when the datastore syncer deletes the Endpoint
+		// objects of the denied cluster
+		if !allowed {
+			klog.V(4).Infof("The peering policies deny cluster %s, not installing cable %s", endpoint.Spec.ClusterID,
+				endpoint.Spec.CableName)
+			t.endpointWorkqueue.Forget(obj)
+			return nil
+		}
+		connected, err := t.applyTopology(&myEndpoint)
+		if err != nil {
+			t.endpointWorkqueue.AddRateLimited(obj)
+			return err
+		}
+		if !connected {
+			if err = t.removeCable(endpoint.Spec.CableName); err != nil {
+				t.endpointWorkqueue.AddRateLimited(obj)
+				return err
+			}
+			t.endpointWorkqueue.Forget(obj)
+			return nil
+		}
 		err = t.ce.InstallCable(myEndpoint)
 		if err != nil {
 			t.endpointWorkqueue.AddRateLimited(obj)
 			return fmt.Errorf("error installing cable for endpoint %#v, %v", myEndpoint, err)
 		}
+		t.setCableInstalled(endpoint.Spec.CableName, true)
 		t.endpointWorkqueue.Forget(obj)
 		klog.V(4).Infof("endpoint processed by tunnel controller")
 		return nil
@@ -115,6 +197,113 @@ func (t *Controller) processNextEndpoint() bool {
 	return true
 }
 
+// isPeeringAllowed evaluates the peering policies for the remote cluster, which is selected by its ID only when
+// its Cluster object isn't synced yet
+func (t *Controller) isPeeringAllowed(namespace, clusterID string) (bool, error) {
+	policies, err := t.peeringPolicyLister.PeeringPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		return false, fmt.Errorf("error listing the peering policies: %v", err)
+	}
+
+	if len(policies) == 0 {
+		return true, nil
+	}
+
+	spec := v1.ClusterSpec{ClusterID: clusterID}
+	cluster, err := t.clusterLister.Clusters(namespace).Get(clusterID)
+	if err == nil {
+		spec = cluster.Spec
+	} else if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("error retrieving cluster %s: %v", clusterID, err)
+	}
+
+	allowed, err := peering.IsAllowed(policies, &spec)
+	if err != nil {
+		klog.Errorf("Error evaluating the peering policies for cluster %s, not peering with it: %v", clusterID, err)
+		return false, nil
+	}
+	return allowed, nil
+}
+
+// applyTopology returns whether the local cluster is connected to the cluster of the endpoint, and sets the subnets
+// of the other clusters which a hub forwards through the cable. The endpoint isn't connected until the Cluster
+// objects of both clusters are synced, the endpoint is processed again when they are.
+func (t *Controller) applyTopology(endpoint *types.SubmarinerEndpoint) (bool, error) {
+	clusters, err := t.clusterLister.Clusters(t.objectNamespace).List(labels.Everything())
+	if err != nil {
+		return false, fmt.Errorf("error listing the clusters: %v", err)
+	}
+
+	var local, remote *v1.ClusterSpec
+	specs := make([]*v1.ClusterSpec, 0, len(clusters))
+	for _, cluster := range clusters {
+		spec := &cluster.Spec
+		specs = append(specs, spec)
+		switch spec.ClusterID {
+		case t.clusterID:
+			local = spec
+		case endpoint.Spec.ClusterID:
+			remote = spec
+		}
+	}
+
+	if local == nil || remote == nil {
+		klog.V(4).Infof("Waiting for the Cluster objects of clusters %s and %s before installing cable %s", t.clusterID,
+			endpoint.Spec.ClusterID, endpoint.Spec.CableName)
+		return false, nil
+	}
+
+	if !topology.IsConnected(local, remote) {
+		klog.V(4).Infof("Cluster %s (%q) isn't connected to cluster %s (%q) in the topology", local.ClusterID,
+			local.Role, remote.ClusterID, remote.Role)
+		return false, nil
+	}
+
+	endpoint.LocalTransitSubnets, endpoint.RemoteTransitSubnets = topology.TransitSubnets(local, remote, specs)
+	return true, nil
+}
+
+// removeCable removes the cable if the controller installed it
+func (t *Controller) removeCable(cableName string) error {
+	t.Lock()
+	installed := t.installedCables[cableName]
+	t.Unlock()
+
+	if !installed {
+		return nil
+	}
+
+	klog.Infof("Removing cable %s, which isn't part of the topology anymore", cableName)
+	if err := t.ce.RemoveCable(cableName); err != nil {
+		return fmt.Errorf("error removing cable %s: %v", cableName, err)
+	}
+	t.setCableInstalled(cableName, false)
+	return nil
+}
+
+func (t *Controller) setCableInstalled(cableName string, installed bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	if installed {
+		t.installedCables[cableName] = true
+	} else {
+		delete(t.installedCables, cableName)
+	}
+}
+
+func (t *Controller) enqueueAllEndpoints(obj interface{}) {
+	endpoints, err := t.endpointLister.Endpoints(t.objectNamespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("error listing the endpoints: %v", err))
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		t.enqueueEndpoint(endpoint)
+	}
+}
+
 func (t *Controller) enqueueEndpoint(obj interface{}) {
 	var key string
 	var err error
@@ -151,5 +340,6 @@ func (t *Controller) handleRemovedEndpoint(obj interface{}) {
 		return
 	}
 
+	t.setCableInstalled(object.Spec.CableName, false)
 	klog.V(4).Infof("Removed endpoint cable %s from engine", object.Spec.CableName)
 }
@@ -0,0 +1,724 @@
+package conformance
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	subv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/types"
+)
+
+/*
+ * The conformance specs verify that a datastore implementation honours the contract of the datastore
+ * interface. A backend runs them from its own ginkgo suite by calling DescribeDatastore with a function that
+ * returns a new, empty datastore for each spec.
+ */
+
+const (
+	selfClusterID = "east"
+	eventTimeout  = 10 * time.Second
+)
+
+// DescribeDatastore declares the conformance specs for the datastore returned by newDatastore
+func DescribeDatastore(newDatastore func() datastore.Datastore) {
+	var ds datastore.Datastore
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		ds = newDatastore()
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	Describe("Cluster functions", func() {
+		Context("after a cluster is set", func() {
+			It("should return it from GetCluster", func() {
+				west := newCluster("west", "blue")
+				Expect(ds.SetCluster(west)).To(Succeed())
+
+				cluster, err := ds.GetCluster("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cluster.ID).To(Equal("west"))
+				Expect(cluster.Spec).To(Equal(west.Spec))
+			})
+		})
+
+		Context("after a cluster is set again with a different spec", func() {
+			It("should return the updated spec from GetCluster", func() {
+				west := newCluster("west", "blue")
+				Expect(ds.SetCluster(west)).To(Succeed())
+
+				west.Spec.ServiceCIDR = []string{"10.250.0.0/16"}
+				Expect(ds.SetCluster(west)).To(Succeed())
+
+				cluster, err := ds.GetCluster("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cluster.Spec).To(Equal(west.Spec))
+			})
+		})
+
+		Context("with a cluster that was never set", func() {
+			It("should return a NotFound error from GetCluster", func() {
+				_, err := ds.GetCluster("nowhere")
+				Expect(err).To(HaveOccurred())
+				Expect(datastore.IsNotFound(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+
+		Context("with clusters using different color codes", func() {
+			It("should only return the clusters sharing a color code from GetClusters", func() {
+				Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+				Expect(ds.SetCluster(newCluster("north", "red", "green"))).To(Succeed())
+				Expect(ds.SetCluster(newCluster("south", "yellow"))).To(Succeed())
+
+				clusters, err := ds.GetClusters([]string{"blue", "green"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(clusterIDs(clusters)).To(ConsistOf("west", "north"))
+			})
+		})
+
+		Context("after a cluster is removed", func() {
+			It("should no longer return it or its endpoints", func() {
+				Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+				Expect(ds.SetCluster(newCluster("north", "blue"))).To(Succeed())
+				Expect(ds.SetEndpoint(newEndpoint("west", "10.0.0.1"))).To(Succeed())
+				north := newEndpoint("north", "10.1.0.1")
+				Expect(ds.SetEndpoint(north)).To(Succeed())
+
+				Expect(ds.RemoveCluster("west")).To(Succeed())
+
+				_, err := ds.GetCluster("west")
+				Expect(datastore.IsNotFound(err)).To(BeTrue(), "Unexpected error: %v", err)
+
+				clusters, err := ds.GetClusters([]string{"blue"})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(clusterIDs(clusters)).To(ConsistOf("north"))
+
+				endpoints, err := ds.GetEndpoints("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoints).To(BeEmpty())
+
+				endpoints, err = ds.GetEndpoints("north")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cableNames(endpoints)).To(ConsistOf(north.Spec.CableName))
+			})
+		})
+
+		Context("with a cluster that was never set", func() {
+			It("should succeed in RemoveCluster", func() {
+				Expect(ds.RemoveCluster("nowhere")).To(Succeed())
+			})
+		})
+	})
+
+	Describe("Endpoint functions", func() {
+		BeforeEach(func() {
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+			Expect(ds.SetCluster(newCluster("north", "blue"))).To(Succeed())
+		})
+
+		Context("after an endpoint is set", func() {
+			It("should return it from GetEndpoint", func() {
+				endpoint := newEndpoint("west", "10.0.0.1")
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+
+				retrieved, err := ds.GetEndpoint("west", endpoint.Spec.CableName)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(retrieved.Spec).To(Equal(endpoint.Spec))
+			})
+		})
+
+		Context("after an endpoint is set again with a different spec", func() {
+			It("should return the updated spec from GetEndpoint", func() {
+				endpoint := newEndpoint("west", "10.0.0.1")
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+
+				endpoint.Spec.Subnets = []string{"10.250.0.0/16"}
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+
+				retrieved, err := ds.GetEndpoint("west", endpoint.Spec.CableName)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(retrieved.Spec).To(Equal(endpoint.Spec))
+			})
+		})
+
+		Context("with an endpoint that was never set", func() {
+			It("should return a NotFound error from GetEndpoint", func() {
+				_, err := ds.GetEndpoint("west", "submariner-cable-west-10-0-0-9")
+				Expect(err).To(HaveOccurred())
+				Expect(datastore.IsNotFound(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+
+			It("should return a NotFound error from RenewEndpointLease", func() {
+				err := ds.RenewEndpointLease("west", "submariner-cable-west-10-0-0-9", time.Now())
+				Expect(err).To(HaveOccurred())
+				Expect(datastore.IsNotFound(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+
+			It("should return a NotFound error from GetEndpointLeaseRenewTime", func() {
+				_, err := ds.GetEndpointLeaseRenewTime("west", "submariner-cable-west-10-0-0-9")
+				Expect(err).To(HaveOccurred())
+				Expect(datastore.IsNotFound(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+
+		Context("with endpoints belonging to different clusters", func() {
+			It("should only return the endpoints of the requested cluster from GetEndpoints", func() {
+				west1 := newEndpoint("west", "10.0.0.1")
+				west2 := newEndpoint("west", "10.0.0.2")
+				Expect(ds.SetEndpoint(west1)).To(Succeed())
+				Expect(ds.SetEndpoint(west2)).To(Succeed())
+				Expect(ds.SetEndpoint(newEndpoint("north", "10.1.0.1"))).To(Succeed())
+
+				endpoints, err := ds.GetEndpoints("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cableNames(endpoints)).To(ConsistOf(west1.Spec.CableName, west2.Spec.CableName))
+			})
+		})
+
+		Context("after an endpoint is removed", func() {
+			It("should no longer be returned", func() {
+				endpoint := newEndpoint("west", "10.0.0.1")
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+				Expect(ds.RemoveEndpoint("west", endpoint.Spec.CableName)).To(Succeed())
+
+				endpoints, err := ds.GetEndpoints("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(endpoints).To(BeEmpty())
+
+				_, err = ds.GetEndpoint("west", endpoint.Spec.CableName)
+				Expect(datastore.IsNotFound(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+
+		Context("with an endpoint that never renewed its lease", func() {
+			It("should return the zero time as its lease renew time", func() {
+				endpoint := newEndpoint("west", "10.0.0.1")
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+
+				renewTime, err := ds.GetEndpointLeaseRenewTime("west", endpoint.Spec.CableName)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(renewTime.IsZero()).To(BeTrue())
+			})
+		})
+
+		Context("after an endpoint renewed its lease", func() {
+			It("should return the renew time", func() {
+				endpoint := newEndpoint("west", "10.0.0.1")
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+
+				now := time.Now().Truncate(time.Second)
+				Expect(ds.RenewEndpointLease("west", endpoint.Spec.CableName, now)).To(Succeed())
+
+				renewTime, err := ds.GetEndpointLeaseRenewTime("west", endpoint.Spec.CableName)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(renewTime.Equal(now)).To(BeTrue(), "expected %v to equal %v", renewTime, now)
+			})
+
+			It("should forget the lease once the endpoint is removed", func() {
+				endpoint := newEndpoint("west", "10.0.0.1")
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+				Expect(ds.RenewEndpointLease("west", endpoint.Spec.CableName, time.Now())).To(Succeed())
+				Expect(ds.RemoveEndpoint("west", endpoint.Spec.CableName)).To(Succeed())
+				Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+
+				renewTime, err := ds.GetEndpointLeaseRenewTime("west", endpoint.Spec.CableName)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(renewTime.IsZero()).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("WatchClusters", func() {
+		var recorder *eventRecorder
+
+		BeforeEach(func() {
+			recorder = newEventRecorder()
+		})
+
+		It("should deliver existing clusters and clusters added after the watch started", func() {
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+			Expect(ds.WatchClusters(ctx, selfClusterID, []string{"blue"}, recorder.onClusterChange)).To(Succeed())
+			Expect(ds.SetCluster(newCluster("north", "blue"))).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west"))
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("north"))
+		})
+
+		It("should deliver cluster updates", func() {
+			west := newCluster("west", "blue")
+			Expect(ds.SetCluster(west)).To(Succeed())
+			Expect(ds.WatchClusters(ctx, selfClusterID, []string{"blue"}, recorder.onClusterChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west"))
+
+			west.Spec.ServiceCIDR = []string{"10.250.0.0/16"}
+			Expect(ds.SetCluster(west)).To(Succeed())
+
+			Eventually(func() interface{} {
+				return recorder.current()["west"]
+			}, eventTimeout).Should(Equal(west.Spec))
+		})
+
+		It("should deliver cluster removals", func() {
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+			Expect(ds.WatchClusters(ctx, selfClusterID, []string{"blue"}, recorder.onClusterChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west"))
+
+			Expect(ds.RemoveCluster("west")).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).ShouldNot(HaveKey("west"))
+			Expect(recorder.deleted()).To(ContainElement("west"))
+		})
+
+		It("should not deliver our own cluster", func() {
+			Expect(ds.SetCluster(newCluster(selfClusterID, "blue"))).To(Succeed())
+			Expect(ds.WatchClusters(ctx, selfClusterID, []string{"blue"}, recorder.onClusterChange)).To(Succeed())
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west"))
+			Consistently(recorder.seen).ShouldNot(HaveKey(selfClusterID))
+		})
+
+		It("should not deliver clusters that don't share a color code", func() {
+			Expect(ds.SetCluster(newCluster("south", "yellow"))).To(Succeed())
+			Expect(ds.WatchClusters(ctx, selfClusterID, []string{"blue"}, recorder.onClusterChange)).To(Succeed())
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west"))
+			Consistently(recorder.seen).ShouldNot(HaveKey("south"))
+		})
+	})
+
+	Describe("WatchEndpoints", func() {
+		var recorder *eventRecorder
+
+		BeforeEach(func() {
+			recorder = newEventRecorder()
+			Expect(ds.SetCluster(newCluster(selfClusterID, "blue"))).To(Succeed())
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+			Expect(ds.SetCluster(newCluster("north", "blue"))).To(Succeed())
+		})
+
+		It("should deliver existing endpoints and endpoints added after the watch started", func() {
+			west := newEndpoint("west", "10.0.0.1")
+			north := newEndpoint("north", "10.1.0.1")
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+			Expect(ds.WatchEndpoints(ctx, selfClusterID, []string{"blue"}, recorder.onEndpointChange)).To(Succeed())
+			Expect(ds.SetEndpoint(north)).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(west.Spec.CableName))
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(north.Spec.CableName))
+		})
+
+		It("should deliver endpoint updates", func() {
+			west := newEndpoint("west", "10.0.0.1")
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+			Expect(ds.WatchEndpoints(ctx, selfClusterID, []string{"blue"}, recorder.onEndpointChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(west.Spec.CableName))
+
+			west.Spec.Subnets = []string{"10.250.0.0/16"}
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+
+			Eventually(func() interface{} {
+				return recorder.current()[west.Spec.CableName]
+			}, eventTimeout).Should(Equal(west.Spec))
+		})
+
+		It("should deliver endpoint removals", func() {
+			west := newEndpoint("west", "10.0.0.1")
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+			Expect(ds.WatchEndpoints(ctx, selfClusterID, []string{"blue"}, recorder.onEndpointChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(west.Spec.CableName))
+
+			Expect(ds.RemoveEndpoint("west", west.Spec.CableName)).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).ShouldNot(HaveKey(west.Spec.CableName))
+			Expect(recorder.deleted()).To(ContainElement(west.Spec.CableName))
+		})
+
+		It("should deliver the removal of the endpoints of a removed cluster", func() {
+			west := newEndpoint("west", "10.0.0.1")
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+			Expect(ds.WatchEndpoints(ctx, selfClusterID, []string{"blue"}, recorder.onEndpointChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(west.Spec.CableName))
+
+			Expect(ds.RemoveCluster("west")).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).ShouldNot(HaveKey(west.Spec.CableName))
+			Expect(recorder.deleted()).To(ContainElement(west.Spec.CableName))
+		})
+
+		It("should not deliver our own endpoints", func() {
+			self := newEndpoint(selfClusterID, "10.2.0.1")
+			west := newEndpoint("west", "10.0.0.1")
+			Expect(ds.SetEndpoint(self)).To(Succeed())
+			Expect(ds.WatchEndpoints(ctx, selfClusterID, []string{"blue"}, recorder.onEndpointChange)).To(Succeed())
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(west.Spec.CableName))
+			Consistently(recorder.seen).ShouldNot(HaveKey(self.Spec.CableName))
+		})
+
+		It("should not deliver endpoints of clusters that don't share a color code", func() {
+			Expect(ds.SetCluster(newCluster("south", "yellow"))).To(Succeed())
+			south := newEndpoint("south", "10.3.0.1")
+			west := newEndpoint("west", "10.0.0.1")
+			Expect(ds.SetEndpoint(south)).To(Succeed())
+			Expect(ds.WatchEndpoints(ctx, selfClusterID, []string{"blue"}, recorder.onEndpointChange)).To(Succeed())
+			Expect(ds.SetEndpoint(west)).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey(west.Spec.CableName))
+			Consistently(recorder.seen).ShouldNot(HaveKey(south.Spec.CableName))
+		})
+	})
+
+	Describe("Service import functions", func() {
+		BeforeEach(func() {
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+			Expect(ds.SetCluster(newCluster("north", "blue"))).To(Succeed())
+		})
+
+		Context("with service imports belonging to different clusters", func() {
+			It("should only return the service imports of the requested cluster from GetServiceImports", func() {
+				west := newServiceImport("west", "default", "nginx", "10.96.0.10")
+				Expect(ds.SetServiceImport(west)).To(Succeed())
+				Expect(ds.SetServiceImport(newServiceImport("north", "default", "nginx", "10.97.0.10"))).To(Succeed())
+
+				serviceImports, err := ds.GetServiceImports("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(serviceImports).To(HaveLen(1))
+				Expect(serviceImports[0].Spec).To(Equal(west.Spec))
+			})
+		})
+
+		Context("after a service import is set again with a different spec", func() {
+			It("should return the updated spec from GetServiceImports", func() {
+				west := newServiceImport("west", "default", "nginx", "10.96.0.10")
+				Expect(ds.SetServiceImport(west)).To(Succeed())
+
+				west.Spec.IPs = []string{"10.96.0.20"}
+				Expect(ds.SetServiceImport(west)).To(Succeed())
+
+				serviceImports, err := ds.GetServiceImports("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(serviceImports).To(HaveLen(1))
+				Expect(serviceImports[0].Spec).To(Equal(west.Spec))
+			})
+		})
+
+		Context("after a service import is removed", func() {
+			It("should no longer be returned", func() {
+				Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+				Expect(ds.RemoveServiceImport("west", "default", "nginx")).To(Succeed())
+
+				serviceImports, err := ds.GetServiceImports("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(serviceImports).To(BeEmpty())
+			})
+		})
+
+		Context("after a cluster is removed", func() {
+			It("should no longer return its service imports", func() {
+				Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+				Expect(ds.RemoveCluster("west")).To(Succeed())
+
+				serviceImports, err := ds.GetServiceImports("west")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(serviceImports).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("CIDR reservation functions", func() {
+		Context("with CIDR blocks reserved in different pools", func() {
+			It("should only return the reservations of the requested pool from GetCIDRReservations", func() {
+				west := newCIDRReservation("west", "cluster", "10.0.0.0/16")
+				Expect(ds.ReserveCIDR(west)).To(Succeed())
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "service", "10.1.0.0/16"))).To(Succeed())
+
+				reservations, err := ds.GetCIDRReservations("cluster")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reservations).To(HaveLen(1))
+				Expect(reservations[0].Spec).To(Equal(west.Spec))
+			})
+		})
+
+		Context("when the block is already reserved by another cluster", func() {
+			It("should return a Conflict error from ReserveCIDR", func() {
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "cluster", "10.0.0.0/16"))).To(Succeed())
+
+				err := ds.ReserveCIDR(newCIDRReservation("north", "cluster", "10.0.0.0/16"))
+				Expect(datastore.IsConflict(err)).To(BeTrue(), "unexpected error %v", err)
+			})
+		})
+
+		Context("when an overlapping block of another size is already reserved", func() {
+			It("should return a Conflict error from ReserveCIDR", func() {
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "cluster", "10.0.0.0/16"))).To(Succeed())
+
+				err := ds.ReserveCIDR(newCIDRReservation("north", "cluster", "10.0.1.0/24"))
+				Expect(datastore.IsConflict(err)).To(BeTrue(), "unexpected error %v", err)
+
+				err = ds.ReserveCIDR(newCIDRReservation("north", "cluster", "10.0.0.0/8"))
+				Expect(datastore.IsConflict(err)).To(BeTrue(), "unexpected error %v", err)
+
+				reservations, err := ds.GetCIDRReservations("cluster")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reservations).To(HaveLen(1))
+				Expect(reservations[0].Spec.ClusterID).To(Equal("west"))
+			})
+
+			It("should accept overlapping blocks of other pools", func() {
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "cluster", "10.0.0.0/16"))).To(Succeed())
+				Expect(ds.ReserveCIDR(newCIDRReservation("north", "global", "10.0.1.0/24"))).To(Succeed())
+			})
+		})
+
+		Context("when the block is already reserved by the same cluster", func() {
+			It("should succeed in ReserveCIDR", func() {
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "cluster", "10.0.0.0/16"))).To(Succeed())
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "cluster", "10.0.0.0/16"))).To(Succeed())
+
+				reservations, err := ds.GetCIDRReservations("cluster")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reservations).To(HaveLen(1))
+			})
+		})
+
+		Context("after a cluster is removed", func() {
+			It("should release its reservations", func() {
+				Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+				Expect(ds.ReserveCIDR(newCIDRReservation("west", "cluster", "10.0.0.0/16"))).To(Succeed())
+				Expect(ds.ReserveCIDR(newCIDRReservation("north", "cluster", "10.1.0.0/16"))).To(Succeed())
+				Expect(ds.RemoveCluster("west")).To(Succeed())
+
+				reservations, err := ds.GetCIDRReservations("cluster")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reservations).To(HaveLen(1))
+				Expect(reservations[0].Spec.ClusterID).To(Equal("north"))
+			})
+		})
+	})
+
+	Describe("WatchServiceImports", func() {
+		var recorder *eventRecorder
+
+		BeforeEach(func() {
+			recorder = newEventRecorder()
+			Expect(ds.SetCluster(newCluster(selfClusterID, "blue"))).To(Succeed())
+			Expect(ds.SetCluster(newCluster("west", "blue"))).To(Succeed())
+			Expect(ds.SetCluster(newCluster("north", "blue"))).To(Succeed())
+		})
+
+		It("should deliver existing service imports and service imports added after the watch started", func() {
+			Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+			Expect(ds.WatchServiceImports(ctx, selfClusterID, []string{"blue"}, recorder.onServiceImportChange)).To(Succeed())
+			Expect(ds.SetServiceImport(newServiceImport("north", "default", "nginx", "10.97.0.10"))).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west/default/nginx"))
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("north/default/nginx"))
+		})
+
+		It("should deliver service import updates", func() {
+			west := newServiceImport("west", "default", "nginx", "10.96.0.10")
+			Expect(ds.SetServiceImport(west)).To(Succeed())
+			Expect(ds.WatchServiceImports(ctx, selfClusterID, []string{"blue"}, recorder.onServiceImportChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west/default/nginx"))
+
+			west.Spec.IPs = []string{"10.96.0.20"}
+			Expect(ds.SetServiceImport(west)).To(Succeed())
+
+			Eventually(func() interface{} {
+				return recorder.current()["west/default/nginx"]
+			}, eventTimeout).Should(Equal(west.Spec))
+		})
+
+		It("should deliver service import removals", func() {
+			Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+			Expect(ds.WatchServiceImports(ctx, selfClusterID, []string{"blue"}, recorder.onServiceImportChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west/default/nginx"))
+
+			Expect(ds.RemoveServiceImport("west", "default", "nginx")).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).ShouldNot(HaveKey("west/default/nginx"))
+			Expect(recorder.deleted()).To(ContainElement("west/default/nginx"))
+		})
+
+		It("should deliver the removal of the service imports of a removed cluster", func() {
+			Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+			Expect(ds.WatchServiceImports(ctx, selfClusterID, []string{"blue"}, recorder.onServiceImportChange)).To(Succeed())
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west/default/nginx"))
+
+			Expect(ds.RemoveCluster("west")).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).ShouldNot(HaveKey("west/default/nginx"))
+			Expect(recorder.deleted()).To(ContainElement("west/default/nginx"))
+		})
+
+		It("should not deliver our own service imports", func() {
+			Expect(ds.SetServiceImport(newServiceImport(selfClusterID, "default", "nginx", "10.98.0.10"))).To(Succeed())
+			Expect(ds.WatchServiceImports(ctx, selfClusterID, []string{"blue"}, recorder.onServiceImportChange)).To(Succeed())
+			Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west/default/nginx"))
+			Consistently(recorder.seen).ShouldNot(HaveKey(selfClusterID + "/default/nginx"))
+		})
+
+		It("should not deliver service imports of clusters that don't share a color code", func() {
+			Expect(ds.SetCluster(newCluster("south", "yellow"))).To(Succeed())
+			Expect(ds.SetServiceImport(newServiceImport("south", "default", "nginx", "10.99.0.10"))).To(Succeed())
+			Expect(ds.WatchServiceImports(ctx, selfClusterID, []string{"blue"}, recorder.onServiceImportChange)).To(Succeed())
+			Expect(ds.SetServiceImport(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+
+			Eventually(recorder.current, eventTimeout).Should(HaveKey("west/default/nginx"))
+			Consistently(recorder.seen).ShouldNot(HaveKey("south/default/nginx"))
+		})
+	})
+}
+
+func newCluster(clusterID string, colorCodes ...string) *types.SubmarinerCluster {
+	return &types.SubmarinerCluster{
+		ID: clusterID,
+		Spec: subv1.ClusterSpec{
+			ClusterID:   clusterID,
+			ColorCodes:  colorCodes,
+			ClusterCIDR: []string{"10.244.0.0/16"},
+			ServiceCIDR: []string{"10.96.0.0/16"},
+		},
+	}
+}
+
+func newEndpoint(clusterID, privateIP string) *types.SubmarinerEndpoint {
+	return &types.SubmarinerEndpoint{
+		Spec: subv1.EndpointSpec{
+			ClusterID: clusterID,
+			CableName: "submariner-cable-" + clusterID + "-" + strings.Replace(privateIP, ".", "-", -1),
+			Hostname:  "gateway-" + clusterID,
+			Subnets:   []string{"10.244.0.0/16", "10.96.0.0/16"},
+			PrivateIP: net.ParseIP(privateIP),
+			PublicIP:  net.ParseIP(privateIP),
+			Backend:   "ipsec",
+		},
+	}
+}
+
+func newServiceImport(clusterID, namespace, serviceName, ip string) *types.SubmarinerServiceImport {
+	return &types.SubmarinerServiceImport{
+		Spec: subv1.ServiceImportSpec{
+			ClusterID:   clusterID,
+			Namespace:   namespace,
+			ServiceName: serviceName,
+			IPs:         []string{ip},
+			Ports:       []subv1.ServicePort{{Name: "http", Protocol: "TCP", Port: 80}},
+		},
+	}
+}
+
+func newCIDRReservation(clusterID, pool, cidr string) *types.SubmarinerCIDRReservation {
+	return &types.SubmarinerCIDRReservation{
+		Spec: subv1.CIDRReservationSpec{
+			ClusterID: clusterID,
+			Pool:      pool,
+			CIDR:      cidr,
+		},
+	}
+}
+
+func clusterIDs(clusters []types.SubmarinerCluster) []string {
+	ids := []string{}
+	for _, cluster := range clusters {
+		ids = append(ids, cluster.ID)
+	}
+	return ids
+}
+
+func cableNames(endpoints []types.SubmarinerEndpoint) []string {
+	names := []string{}
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.Spec.CableName)
+	}
+	return names
+}
+
+// eventRecorder keeps track of the objects delivered by a watch, keyed by cluster ID, cable name or
+// cluster ID/namespace/service name
+type eventRecorder struct {
+	sync.Mutex
+	objects  map[string]interface{}
+	seenKeys map[string]bool
+	removed  []string
+}
+
+func newEventRecorder() *eventRecorder {
+	return &eventRecorder{
+		objects:  map[string]interface{}{},
+		seenKeys: map[string]bool{},
+	}
+}
+
+func (r *eventRecorder) onClusterChange(cluster *types.SubmarinerCluster, deleted bool) error {
+	r.record(cluster.ID, cluster.Spec, deleted)
+	return nil
+}
+
+func (r *eventRecorder) onEndpointChange(endpoint *types.SubmarinerEndpoint, deleted bool) error {
+	r.record(endpoint.Spec.CableName, endpoint.Spec, deleted)
+	return nil
+}
+
+func (r *eventRecorder) onServiceImportChange(serviceImport *types.SubmarinerServiceImport, deleted bool) error {
+	r.record(serviceImport.Spec.ClusterID+"/"+serviceImport.Spec.Namespace+"/"+serviceImport.Spec.ServiceName,
+		serviceImport.Spec, deleted)
+	return nil
+}
+
+func (r *eventRecorder) record(key string, spec interface{}, deleted bool) {
+	r.Lock()
+	defer r.Unlock()
+	r.seenKeys[key] = true
+	if deleted {
+		delete(r.objects, key)
+		r.removed = append(r.removed, key)
+	} else {
+		r.objects[key] = spec
+	}
+}
+
+// current returns the objects that were delivered and not deleted since
+func (r *eventRecorder) current() map[string]interface{} {
+	r.Lock()
+	defer r.Unlock()
+	result := map[string]interface{}{}
+	for k, v := range r.objects {
+		result[k] = v
+	}
+	return result
+}
+
+// seen returns the keys of all the objects that were ever delivered
+func (r *eventRecorder) seen() map[string]bool {
+	r.Lock()
+	defer r.Unlock()
+	result := map[string]bool{}
+	for k := range r.seenKeys {
+		result[k] = true
+	}
+	return result
+}
+
+func (r *eventRecorder) deleted() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.removed...)
+}
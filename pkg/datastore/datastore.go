@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"context"
+	"time"
 
 	"github.com/rancher/submariner/pkg/types"
 )
@@ -9,6 +10,12 @@ import (
 /*
  * The datastore interface is used to implement central broker datastores so that
  * the datastoresyncer can facilitate the management of local CRDs
+ *
+ * Watches must not block: they start watching in the background and stop when the passed in context is
+ * cancelled. They deliver the objects that already exist as well as subsequent changes and deletions,
+ * skip the objects belonging to selfClusterID and only deliver the objects of clusters that share at
+ * least one of the given color codes. The conformance package contains the specs every implementation
+ * is expected to pass.
  */
 
 type Datastore interface {
@@ -41,6 +48,34 @@ type Datastore interface {
 	// This should be called to remove an endpoint from use
 	RemoveEndpoint(clusterID, cableName string) error
 
-	// This should be called to remove a cluster from use
+	// This should be called periodically to renew the liveness lease of the local endpoint
+	RenewEndpointLease(clusterID, cableName string, renewTime time.Time) error
+
+	// This gets the last renew time of an endpoint's liveness lease, or the zero time if it never renewed one
+	GetEndpointLeaseRenewTime(clusterID, cableName string) (time.Time, error)
+
+	// This should be called to remove a cluster from use, along with its endpoints, service imports and CIDR
+	// reservations
 	RemoveCluster(clusterID string) error
+
+	// This gets all the services exported by the given cluster ID
+	GetServiceImports(clusterID string) ([]types.SubmarinerServiceImport, error)
+
+	// Watches the services exported by the other clusters and calls the passed in function on change
+	WatchServiceImports(ctx context.Context, selfClusterID string, colorCodes []string,
+		onServiceImportChange func(serviceImport *types.SubmarinerServiceImport, deleted bool) error) error
+
+	// This should only ever be called to export a service of the local cluster
+	SetServiceImport(serviceImport *types.SubmarinerServiceImport) error
+
+	// This should be called to stop exporting a service
+	RemoveServiceImport(clusterID, namespace, serviceName string) error
+
+	// This gets the CIDR blocks reserved by all clusters in the given IPAM pool
+	GetCIDRReservations(pool string) ([]types.SubmarinerCIDRReservation, error)
+
+	// This reserves a CIDR block of an IPAM pool for a cluster. It returns a Conflict error if another cluster
+	// already reserved the block or if another block of the pool overlaps it, and succeeds if the same cluster
+	// reserved the block.
+	ReserveCIDR(reservation *types.SubmarinerCIDRReservation) error
 }
@@ -0,0 +1,85 @@
+package datastore
+
+import (
+	"fmt"
+)
+
+// Reason classifies a datastore error so that callers can react to it without parsing error strings
+type Reason string
+
+const (
+	// The reason of errors that don't fall into any of the other categories
+	ReasonUnknown Reason = ""
+	// The requested object doesn't exist in the central datastore
+	ReasonNotFound Reason = "NotFound"
+	// The object was modified concurrently or already exists
+	ReasonConflict Reason = "Conflict"
+	// The central datastore couldn't be reached or failed to process the request; retrying may succeed
+	ReasonUnavailable Reason = "Unavailable"
+	// The central datastore rejected our credentials or didn't allow the request
+	ReasonUnauthorized Reason = "Unauthorized"
+)
+
+// Error is returned by datastore implementations for the failures listed in Reason
+type Error struct {
+	Reason  Reason
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(reason Reason, format string, a ...interface{}) error {
+	return &Error{Reason: reason, Message: fmt.Sprintf(format, a...)}
+}
+
+func NewNotFoundError(format string, a ...interface{}) error {
+	return newError(ReasonNotFound, format, a...)
+}
+
+func NewConflictError(format string, a ...interface{}) error {
+	return newError(ReasonConflict, format, a...)
+}
+
+func NewUnavailableError(format string, a ...interface{}) error {
+	return newError(ReasonUnavailable, format, a...)
+}
+
+func NewUnauthorizedError(format string, a ...interface{}) error {
+	return newError(ReasonUnauthorized, format, a...)
+}
+
+// Wrapf prefixes the message of err while keeping its reason, so that context can be added to an error
+// on its way up without callers losing the ability to classify it
+func Wrapf(err error, format string, a ...interface{}) error {
+	message := fmt.Sprintf(format, a...)
+	if e, ok := err.(*Error); ok {
+		return &Error{Reason: e.Reason, Message: message + ": " + e.Message}
+	}
+	return fmt.Errorf("%s: %v", message, err)
+}
+
+// ReasonForError returns the reason of a datastore error, or ReasonUnknown for any other error
+func ReasonForError(err error) Reason {
+	if e, ok := err.(*Error); ok {
+		return e.Reason
+	}
+	return ReasonUnknown
+}
+
+func IsNotFound(err error) bool {
+	return ReasonForError(err) == ReasonNotFound
+}
+
+func IsConflict(err error) bool {
+	return ReasonForError(err) == ReasonConflict
+}
+
+func IsUnavailable(err error) bool {
+	return ReasonForError(err) == ReasonUnavailable
+}
+
+func IsUnauthorized(err error) bool {
+	return ReasonForError(err) == ReasonUnauthorized
+}
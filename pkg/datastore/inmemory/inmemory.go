@@ -0,0 +1,557 @@
+package inmemory
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/netutil"
+	"github.com/rancher/submariner/pkg/types"
+	"k8s.io/klog"
+)
+
+/*
+ * The in-memory datastore keeps the broker state in the current process. It is meant for tests and for
+ * simulations where several datastore syncers, each acting as a different cluster, share a single
+ * Datastore instance. Every watch gets its own queue and goroutine, so a slow handler never blocks the
+ * writers or the other watches, and each watch sees the changes in the order they were made.
+ */
+
+type Datastore struct {
+	sync.Mutex
+	clusters   map[string]types.SubmarinerCluster
+	endpoints  map[string]map[string]types.SubmarinerEndpoint
+	renewTimes map[string]time.Time
+	// The service imports of each cluster, keyed by namespace/name
+	serviceImports map[string]map[string]types.SubmarinerServiceImport
+	// The CIDR reservations, keyed by pool/CIDR
+	cidrReservations map[string]types.SubmarinerCIDRReservation
+	watches          []*watch
+}
+
+type event struct {
+	cluster       *types.SubmarinerCluster
+	endpoint      *types.SubmarinerEndpoint
+	serviceImport *types.SubmarinerServiceImport
+	deleted       bool
+}
+
+type watch struct {
+	sync.Mutex
+	ctx                   context.Context
+	selfClusterID         string
+	colorCodes            []string
+	onClusterChange       func(cluster *types.SubmarinerCluster, deleted bool) error
+	onEndpointChange      func(endpoint *types.SubmarinerEndpoint, deleted bool) error
+	onServiceImportChange func(serviceImport *types.SubmarinerServiceImport, deleted bool) error
+	queue                 []event
+	wakeUp                chan struct{}
+}
+
+func NewDatastore() *Datastore {
+	return &Datastore{
+		clusters:         map[string]types.SubmarinerCluster{},
+		endpoints:        map[string]map[string]types.SubmarinerEndpoint{},
+		renewTimes:       map[string]time.Time{},
+		serviceImports:   map[string]map[string]types.SubmarinerServiceImport{},
+		cidrReservations: map[string]types.SubmarinerCIDRReservation{},
+	}
+}
+
+func (d *Datastore) GetClusters(colorCodes []string) ([]types.SubmarinerCluster, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	clusters := []types.SubmarinerCluster{}
+	for _, id := range d.sortedClusterIDs() {
+		cluster := d.clusters[id]
+		if stringSliceOverlaps(cluster.Spec.ColorCodes, colorCodes) {
+			clusters = append(clusters, copyCluster(&cluster))
+		}
+	}
+	return clusters, nil
+}
+
+func (d *Datastore) GetCluster(clusterID string) (*types.SubmarinerCluster, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	cluster, ok := d.clusters[clusterID]
+	if !ok {
+		return nil, datastore.NewNotFoundError("cluster %s wasn't found", clusterID)
+	}
+
+	result := copyCluster(&cluster)
+	return &result, nil
+}
+
+func (d *Datastore) GetEndpoints(clusterID string) ([]types.SubmarinerEndpoint, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	endpoints := []types.SubmarinerEndpoint{}
+	for _, cableName := range d.sortedCableNames(clusterID) {
+		endpoint := d.endpoints[clusterID][cableName]
+		endpoints = append(endpoints, copyEndpoint(&endpoint))
+	}
+	return endpoints, nil
+}
+
+func (d *Datastore) GetEndpoint(clusterID string, cableName string) (*types.SubmarinerEndpoint, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	endpoint, ok := d.endpoints[clusterID][cableName]
+	if !ok {
+		return nil, datastore.NewNotFoundError("endpoint %s wasn't found", cableName)
+	}
+
+	result := copyEndpoint(&endpoint)
+	return &result, nil
+}
+
+func (d *Datastore) WatchClusters(ctx context.Context, selfClusterID string, colorCodes []string,
+	onClusterChange func(cluster *types.SubmarinerCluster, deleted bool) error) error {
+	d.Lock()
+	defer d.Unlock()
+
+	w := d.newWatch(ctx, selfClusterID, colorCodes)
+	w.onClusterChange = onClusterChange
+	for _, id := range d.sortedClusterIDs() {
+		cluster := d.clusters[id]
+		d.notifyWatch(w, event{cluster: &cluster})
+	}
+	return nil
+}
+
+func (d *Datastore) WatchEndpoints(ctx context.Context, selfClusterID string, colorCodes []string,
+	onEndpointChange func(endpoint *types.SubmarinerEndpoint, deleted bool) error) error {
+	d.Lock()
+	defer d.Unlock()
+
+	w := d.newWatch(ctx, selfClusterID, colorCodes)
+	w.onEndpointChange = onEndpointChange
+	for _, id := range d.sortedClusterIDs() {
+		for _, cableName := range d.sortedCableNames(id) {
+			endpoint := d.endpoints[id][cableName]
+			d.notifyWatch(w, event{endpoint: &endpoint})
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) SetCluster(cluster *types.SubmarinerCluster) error {
+	d.Lock()
+	defer d.Unlock()
+
+	stored := copyCluster(cluster)
+	existing, existed := d.clusters[stored.ID]
+	if existed && reflect.DeepEqual(existing, stored) {
+		klog.V(8).Infof("Cluster %s is unchanged", stored.ID)
+		return nil
+	}
+
+	d.clusters[stored.ID] = stored
+	d.notify(event{cluster: &stored})
+
+	// Endpoints and service imports may be set before their cluster, or the cluster may only now share a color
+	// code with a watch; either way those watches haven't seen the cluster's endpoints and service imports yet.
+	for _, w := range d.watches {
+		if existed && stringSliceOverlaps(existing.Spec.ColorCodes, w.colorCodes) {
+			continue
+		}
+
+		if w.onEndpointChange != nil {
+			for _, cableName := range d.sortedCableNames(stored.ID) {
+				endpoint := d.endpoints[stored.ID][cableName]
+				d.notifyWatch(w, event{endpoint: &endpoint})
+			}
+		}
+
+		if w.onServiceImportChange != nil {
+			for _, key := range d.sortedServiceImportKeys(stored.ID) {
+				serviceImport := d.serviceImports[stored.ID][key]
+				d.notifyWatch(w, event{serviceImport: &serviceImport})
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) SetEndpoint(endpoint *types.SubmarinerEndpoint) error {
+	d.Lock()
+	defer d.Unlock()
+
+	stored := copyEndpoint(endpoint)
+	clusterID := stored.Spec.ClusterID
+	if existing, ok := d.endpoints[clusterID][stored.Spec.CableName]; ok && reflect.DeepEqual(existing, stored) {
+		klog.V(8).Infof("Endpoint %s is unchanged", stored.Spec.CableName)
+		return nil
+	}
+
+	if d.endpoints[clusterID] == nil {
+		d.endpoints[clusterID] = map[string]types.SubmarinerEndpoint{}
+	}
+	d.endpoints[clusterID][stored.Spec.CableName] = stored
+	d.notify(event{endpoint: &stored})
+	return nil
+}
+
+func (d *Datastore) RemoveEndpoint(clusterID, cableName string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.removeEndpoint(clusterID, cableName)
+	return nil
+}
+
+func (d *Datastore) RenewEndpointLease(clusterID, cableName string, renewTime time.Time) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if _, ok := d.endpoints[clusterID][cableName]; !ok {
+		return datastore.NewNotFoundError("endpoint %s wasn't found", cableName)
+	}
+
+	d.renewTimes[clusterID+"/"+cableName] = renewTime
+	return nil
+}
+
+func (d *Datastore) GetEndpointLeaseRenewTime(clusterID, cableName string) (time.Time, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if _, ok := d.endpoints[clusterID][cableName]; !ok {
+		return time.Time{}, datastore.NewNotFoundError("endpoint %s wasn't found", cableName)
+	}
+
+	return d.renewTimes[clusterID+"/"+cableName], nil
+}
+
+func (d *Datastore) RemoveCluster(clusterID string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	for _, cableName := range d.sortedCableNames(clusterID) {
+		d.removeEndpoint(clusterID, cableName)
+	}
+
+	for _, key := range d.sortedServiceImportKeys(clusterID) {
+		d.removeServiceImport(clusterID, key)
+	}
+
+	for key, reservation := range d.cidrReservations {
+		if reservation.Spec.ClusterID == clusterID {
+			delete(d.cidrReservations, key)
+		}
+	}
+
+	cluster, ok := d.clusters[clusterID]
+	if !ok {
+		return nil
+	}
+
+	delete(d.clusters, clusterID)
+	d.notify(event{cluster: &cluster, deleted: true})
+	return nil
+}
+
+func (d *Datastore) GetServiceImports(clusterID string) ([]types.SubmarinerServiceImport, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	serviceImports := []types.SubmarinerServiceImport{}
+	for _, key := range d.sortedServiceImportKeys(clusterID) {
+		serviceImport := d.serviceImports[clusterID][key]
+		serviceImports = append(serviceImports, copyServiceImport(&serviceImport))
+	}
+	return serviceImports, nil
+}
+
+func (d *Datastore) WatchServiceImports(ctx context.Context, selfClusterID string, colorCodes []string,
+	onServiceImportChange func(serviceImport *types.SubmarinerServiceImport, deleted bool) error) error {
+	d.Lock()
+	defer d.Unlock()
+
+	w := d.newWatch(ctx, selfClusterID, colorCodes)
+	w.onServiceImportChange = onServiceImportChange
+	for _, id := range d.sortedClusterIDs() {
+		for _, key := range d.sortedServiceImportKeys(id) {
+			serviceImport := d.serviceImports[id][key]
+			d.notifyWatch(w, event{serviceImport: &serviceImport})
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) SetServiceImport(serviceImport *types.SubmarinerServiceImport) error {
+	d.Lock()
+	defer d.Unlock()
+
+	stored := copyServiceImport(serviceImport)
+	clusterID := stored.Spec.ClusterID
+	key := stored.Spec.Namespace + "/" + stored.Spec.ServiceName
+	if existing, ok := d.serviceImports[clusterID][key]; ok && reflect.DeepEqual(existing, stored) {
+		klog.V(8).Infof("Service import %s is unchanged", key)
+		return nil
+	}
+
+	if d.serviceImports[clusterID] == nil {
+		d.serviceImports[clusterID] = map[string]types.SubmarinerServiceImport{}
+	}
+	d.serviceImports[clusterID][key] = stored
+	d.notify(event{serviceImport: &stored})
+	return nil
+}
+
+func (d *Datastore) RemoveServiceImport(clusterID, namespace, serviceName string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	d.removeServiceImport(clusterID, namespace+"/"+serviceName)
+	return nil
+}
+
+func (d *Datastore) GetCIDRReservations(pool string) ([]types.SubmarinerCIDRReservation, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	keys := []string{}
+	for key, reservation := range d.cidrReservations {
+		if reservation.Spec.Pool == pool {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	reservations := []types.SubmarinerCIDRReservation{}
+	for _, key := range keys {
+		reservations = append(reservations, d.cidrReservations[key])
+	}
+	return reservations, nil
+}
+
+func (d *Datastore) ReserveCIDR(reservation *types.SubmarinerCIDRReservation) error {
+	d.Lock()
+	defer d.Unlock()
+
+	key := reservation.Spec.Pool + "/" + reservation.Spec.CIDR
+	if existing, ok := d.cidrReservations[key]; ok {
+		if existing.Spec.ClusterID != reservation.Spec.ClusterID {
+			return datastore.NewConflictError("CIDR %s is already reserved by cluster %s", reservation.Spec.CIDR,
+				existing.Spec.ClusterID)
+		}
+		return nil
+	}
+
+	for _, existing := range d.cidrReservations {
+		if existing.Spec.Pool != reservation.Spec.Pool {
+			continue
+		}
+
+		overlaps, err := netutil.IsOverlappingCIDR([]string{existing.Spec.CIDR}, reservation.Spec.CIDR)
+		if err != nil {
+			return err
+		}
+		if overlaps {
+			return datastore.NewConflictError("CIDR %s overlaps CIDR %s reserved by cluster %s", reservation.Spec.CIDR,
+				existing.Spec.CIDR, existing.Spec.ClusterID)
+		}
+	}
+
+	d.cidrReservations[key] = types.SubmarinerCIDRReservation{Spec: reservation.Spec}
+	return nil
+}
+
+func (d *Datastore) removeServiceImport(clusterID, key string) {
+	serviceImport, ok := d.serviceImports[clusterID][key]
+	if !ok {
+		return
+	}
+
+	delete(d.serviceImports[clusterID], key)
+	d.notify(event{serviceImport: &serviceImport, deleted: true})
+}
+
+func (d *Datastore) removeEndpoint(clusterID, cableName string) {
+	endpoint, ok := d.endpoints[clusterID][cableName]
+	if !ok {
+		return
+	}
+
+	delete(d.endpoints[clusterID], cableName)
+	delete(d.renewTimes, clusterID+"/"+cableName)
+	d.notify(event{endpoint: &endpoint, deleted: true})
+}
+
+func (d *Datastore) newWatch(ctx context.Context, selfClusterID string, colorCodes []string) *watch {
+	w := &watch{
+		ctx:           ctx,
+		selfClusterID: selfClusterID,
+		colorCodes:    colorCodes,
+		wakeUp:        make(chan struct{}, 1),
+	}
+	d.watches = append(d.watches, w)
+	go w.run()
+	return w
+}
+
+// notify queues the event on every watch it is relevant to. It must be called with the lock held.
+func (d *Datastore) notify(e event) {
+	active := d.watches[:0]
+	for _, w := range d.watches {
+		if w.ctx.Err() != nil {
+			continue
+		}
+		active = append(active, w)
+		d.notifyWatch(w, e)
+	}
+	d.watches = active
+}
+
+func (d *Datastore) notifyWatch(w *watch, e event) {
+	var clusterID string
+	switch {
+	case e.cluster != nil:
+		if w.onClusterChange == nil {
+			return
+		}
+		clusterID = e.cluster.ID
+	case e.endpoint != nil:
+		if w.onEndpointChange == nil {
+			return
+		}
+		clusterID = e.endpoint.Spec.ClusterID
+	default:
+		if w.onServiceImportChange == nil {
+			return
+		}
+		clusterID = e.serviceImport.Spec.ClusterID
+	}
+
+	if clusterID == w.selfClusterID {
+		return
+	}
+
+	cluster, ok := d.clusters[clusterID]
+	if e.cluster != nil {
+		cluster, ok = *e.cluster, true
+	}
+
+	// Deletions of endpoints and service imports whose cluster is gone are always delivered
+	if ok && !stringSliceOverlaps(cluster.Spec.ColorCodes, w.colorCodes) || !ok && !e.deleted {
+		return
+	}
+
+	w.Lock()
+	w.queue = append(w.queue, e)
+	w.Unlock()
+
+	select {
+	case w.wakeUp <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watch) run() {
+	for {
+		select {
+		case <-w.ctx.Done():
+			klog.V(6).Infof("In-memory watch was cancelled")
+			return
+		case <-w.wakeUp:
+		}
+
+		w.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.Unlock()
+
+		for _, e := range queue {
+			if w.ctx.Err() != nil {
+				return
+			}
+
+			var err error
+			switch {
+			case e.cluster != nil:
+				cluster := copyCluster(e.cluster)
+				err = w.onClusterChange(&cluster, e.deleted)
+			case e.endpoint != nil:
+				endpoint := copyEndpoint(e.endpoint)
+				err = w.onEndpointChange(&endpoint, e.deleted)
+			default:
+				serviceImport := copyServiceImport(e.serviceImport)
+				err = w.onServiceImportChange(&serviceImport, e.deleted)
+			}
+
+			if err != nil {
+				klog.Errorf("Error handling in-memory watch event: %v", err)
+			}
+		}
+	}
+}
+
+func (d *Datastore) sortedClusterIDs() []string {
+	ids := make([]string, 0, len(d.clusters))
+	for id := range d.clusters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (d *Datastore) sortedCableNames(clusterID string) []string {
+	names := make([]string, 0, len(d.endpoints[clusterID]))
+	for name := range d.endpoints[clusterID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *Datastore) sortedServiceImportKeys(clusterID string) []string {
+	keys := make([]string, 0, len(d.serviceImports[clusterID]))
+	for key := range d.serviceImports[clusterID] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyCluster(cluster *types.SubmarinerCluster) types.SubmarinerCluster {
+	return types.SubmarinerCluster{
+		ID:   cluster.ID,
+		Spec: *cluster.Spec.DeepCopy(),
+	}
+}
+
+func copyEndpoint(endpoint *types.SubmarinerEndpoint) types.SubmarinerEndpoint {
+	return types.SubmarinerEndpoint{
+		Spec: *endpoint.Spec.DeepCopy(),
+	}
+}
+
+func copyServiceImport(serviceImport *types.SubmarinerServiceImport) types.SubmarinerServiceImport {
+	return types.SubmarinerServiceImport{
+		Spec: *serviceImport.Spec.DeepCopy(),
+	}
+}
+
+func stringSliceOverlaps(left []string, right []string) bool {
+	hash := make(map[string]bool)
+	for _, s := range left {
+		hash[s] = true
+	}
+
+	for _, s := range right {
+		if hash[s] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,13 @@
+package inmemory
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestInMemory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "In-memory Datastore Suite")
+}
@@ -0,0 +1,14 @@
+package inmemory
+
+import (
+	. "github.com/onsi/ginkgo"
+
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/datastore/conformance"
+)
+
+var _ = Describe("In-memory Datastore", func() {
+	conformance.DescribeDatastore(func() datastore.Datastore {
+		return NewDatastore()
+	})
+})
@@ -11,8 +11,11 @@ import (
 	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
 	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
 	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/netutil"
 	"github.com/rancher/submariner/pkg/types"
 	"github.com/rancher/submariner/pkg/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/rest"
@@ -22,7 +25,7 @@ import (
 )
 
 type Datastore struct {
-	client          *submarinerClientset.Clientset
+	client          submarinerClientset.Interface
 	informerFactory submarinerInformers.SharedInformerFactory
 
 	thisClusterID   string
@@ -72,14 +75,18 @@ func NewDatastore(thisClusterID string, stopCh <-chan struct{}) (*Datastore, err
 		return nil, fmt.Errorf("Error building submariner clientset: %v", err)
 	}
 
+	return newDatastore(submarinerClient, thisClusterID, k8sSpec.RemoteNamespace, stopCh), nil
+}
+
+func newDatastore(client submarinerClientset.Interface, thisClusterID, remoteNamespace string, stopCh <-chan struct{}) *Datastore {
 	return &Datastore{
-		client: submarinerClient,
-		informerFactory: submarinerInformers.NewSharedInformerFactoryWithOptions(submarinerClient, time.Second*30,
-			submarinerInformers.WithNamespace(k8sSpec.RemoteNamespace)),
+		client: client,
+		informerFactory: submarinerInformers.NewSharedInformerFactoryWithOptions(client, time.Second*30,
+			submarinerInformers.WithNamespace(remoteNamespace)),
 		thisClusterID:   thisClusterID,
-		remoteNamespace: k8sSpec.RemoteNamespace,
+		remoteNamespace: remoteNamespace,
 		stopCh:          stopCh,
-	}, nil
+	}
 }
 
 func stringSliceOverlaps(left []string, right []string) bool {
@@ -103,7 +110,7 @@ func (k *Datastore) GetClusters(colorCodes []string) ([]types.SubmarinerCluster,
 	k8sClusters, err := k.client.SubmarinerV1().Clusters(k.remoteNamespace).List(metav1.ListOptions{})
 
 	if err != nil {
-		return nil, err
+		return nil, datastoreError(err, "Error listing Clusters in the remote broker")
 	}
 
 	for _, cluster := range k8sClusters.Items {
@@ -121,7 +128,7 @@ func (k *Datastore) GetCluster(clusterID string) (*types.SubmarinerCluster, erro
 	k8sClusters, err := k.client.SubmarinerV1().Clusters(k.remoteNamespace).List(metav1.ListOptions{})
 
 	if err != nil {
-		return nil, err
+		return nil, datastoreError(err, "Error listing Clusters in the remote broker")
 	}
 
 	for _, cluster := range k8sClusters.Items {
@@ -133,7 +140,7 @@ func (k *Datastore) GetCluster(clusterID string) (*types.SubmarinerCluster, erro
 		}
 	}
 
-	return nil, fmt.Errorf("cluster wasn't found")
+	return nil, datastore.NewNotFoundError("cluster %s wasn't found", clusterID)
 }
 
 func (k *Datastore) GetEndpoints(clusterID string) ([]types.SubmarinerEndpoint, error) {
@@ -141,7 +148,7 @@ func (k *Datastore) GetEndpoints(clusterID string) ([]types.SubmarinerEndpoint,
 	k8sEndpoints, err := k.client.SubmarinerV1().Endpoints(k.remoteNamespace).List(metav1.ListOptions{})
 
 	if err != nil {
-		return nil, err
+		return nil, datastoreError(err, "Error listing Endpoints in the remote broker")
 	}
 
 	endpoints := []types.SubmarinerEndpoint{}
@@ -159,7 +166,7 @@ func (k *Datastore) GetEndpoint(clusterID string, cableName string) (*types.Subm
 	k8sEndpoints, err := k.client.SubmarinerV1().Endpoints(k.remoteNamespace).List(metav1.ListOptions{})
 
 	if err != nil {
-		return nil, err
+		return nil, datastoreError(err, "Error listing Endpoints in the remote broker")
 	}
 
 	for _, endpoint := range k8sEndpoints.Items {
@@ -167,80 +174,60 @@ func (k *Datastore) GetEndpoint(clusterID string, cableName string) (*types.Subm
 			return &types.SubmarinerEndpoint{Spec: endpoint.Spec}, nil
 		}
 	}
-	return nil, fmt.Errorf("endpoint wasn't found")
+	return nil, datastore.NewNotFoundError("endpoint %s wasn't found", cableName)
 }
 
 func (k *Datastore) WatchClusters(ctx context.Context, selfClusterID string, colorCodes []string, onClusterChange func(cluster *types.SubmarinerCluster, deleted bool) error) error {
+	handleCluster := func(obj interface{}, deleted bool) {
+		var object *submarinerv1.Cluster
+		var ok bool
+		if object, ok = obj.(*submarinerv1.Cluster); !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				klog.Errorf("Could not convert object %v to a Cluster", obj)
+				return
+			}
+			object, ok = tombstone.Obj.(*submarinerv1.Cluster)
+			if !ok {
+				klog.Errorf("Could not convert object tombstone %v to a Cluster", tombstone.Obj)
+				return
+			}
+			klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
+		}
+
+		if ctx.Err() != nil {
+			klog.V(8).Infof("Cluster watch was cancelled, ignoring event for %s", object.GetName())
+			return
+		}
+
+		if object.Spec.ClusterID == selfClusterID {
+			klog.V(8).Infof("Not delivering event for our own cluster %s", object.GetName())
+			return
+		}
+
+		if !stringSliceOverlaps(object.Spec.ColorCodes, colorCodes) {
+			klog.V(8).Infof("Cluster %s doesn't share any color codes with %v, not delivering event", object.GetName(), colorCodes)
+			return
+		}
+
+		utilruntime.HandleError(onClusterChange(&types.SubmarinerCluster{
+			ID:   object.Spec.ClusterID,
+			Spec: object.Spec,
+		}, deleted))
+	}
 
 	k.informerFactory.Submariner().V1().Clusters().Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			var object *submarinerv1.Cluster
-			var ok bool
 			klog.V(8).Infof("AddFunc in WatchClusters called")
-			if object, ok = obj.(*submarinerv1.Cluster); !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					klog.Errorf("Could not convert object %v to a Cluster", obj)
-					return
-				}
-				object, ok = tombstone.Obj.(*submarinerv1.Cluster)
-				if !ok {
-					klog.Errorf("Could not convert object tombstone %v to a Cluster", tombstone.Obj)
-					return
-				}
-				klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-			}
-
-			utilruntime.HandleError(onClusterChange(&types.SubmarinerCluster{
-				ID:   object.Spec.ClusterID,
-				Spec: object.Spec,
-			}, false))
+			handleCluster(obj, false)
 		},
 		UpdateFunc: func(old, obj interface{}) {
-			var object *submarinerv1.Cluster
-			var ok bool
 			klog.V(8).Infof("UpdateFunc in WatchClusters called")
-			if object, ok = obj.(*submarinerv1.Cluster); !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					klog.Errorf("Could not convert object %v to a Cluster", obj)
-					return
-				}
-				object, ok = tombstone.Obj.(*submarinerv1.Cluster)
-				if !ok {
-					klog.Errorf("Could not convert object tombstone %v to a Cluster", tombstone.Obj)
-					return
-				}
-				klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-			}
-
-			utilruntime.HandleError(onClusterChange(&types.SubmarinerCluster{
-				ID:   object.Spec.ClusterID,
-				Spec: object.Spec,
-			}, false))
+			handleCluster(obj, false)
 		},
 		DeleteFunc: func(obj interface{}) {
-			var object *submarinerv1.Cluster
-			var ok bool
 			klog.V(8).Infof("DeleteFunc in WatchClusters called")
-			if object, ok = obj.(*submarinerv1.Cluster); !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					klog.Errorf("Could not convert object %v to a Cluster", obj)
-					return
-				}
-				object, ok = tombstone.Obj.(*submarinerv1.Cluster)
-				if !ok {
-					klog.Errorf("Could not convert object tombstone %v to a Cluster", tombstone.Obj)
-					return
-				}
-				klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-			}
-
-			utilruntime.HandleError(onClusterChange(&types.SubmarinerCluster{
-				ID:   object.Spec.ClusterID,
-				Spec: object.Spec,
-			}, true))
+			handleCluster(obj, true)
 		},
 	}, time.Second*30)
 
@@ -249,73 +236,58 @@ func (k *Datastore) WatchClusters(ctx context.Context, selfClusterID string, col
 }
 
 func (k *Datastore) WatchEndpoints(ctx context.Context, selfClusterID string, colorCodes []string, onEndpointChange func(endpoint *types.SubmarinerEndpoint, deleted bool) error) error {
+	handleEndpoint := func(obj interface{}, deleted bool) {
+		var object *submarinerv1.Endpoint
+		var ok bool
+		if object, ok = obj.(*submarinerv1.Endpoint); !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				klog.Errorf("Could not convert object %v to an Endpoint", obj)
+				return
+			}
+			object, ok = tombstone.Obj.(*submarinerv1.Endpoint)
+			if !ok {
+				klog.Errorf("Could not convert object tombstone %v to an Endpoint", tombstone.Obj)
+				return
+			}
+			klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
+		}
 
+		if ctx.Err() != nil {
+			klog.V(8).Infof("Endpoint watch was cancelled, ignoring event for %s", object.GetName())
+			return
+		}
+
+		if object.Spec.ClusterID == selfClusterID {
+			klog.V(8).Infof("Not delivering event for our own endpoint %s", object.GetName())
+			return
+		}
+
+		if !k.clusterMatchesColorCodes(object.Spec.ClusterID, colorCodes, deleted) {
+			klog.V(8).Infof("Cluster of endpoint %s doesn't share any color codes with %v, not delivering event",
+				object.GetName(), colorCodes)
+			return
+		}
+
+		utilruntime.HandleError(onEndpointChange(&types.SubmarinerEndpoint{
+			Spec: object.Spec,
+		}, deleted))
+	}
+
+	// The cluster informer is used to check the color codes of the endpoints' clusters
+	k.informerFactory.Submariner().V1().Clusters().Informer()
 	k.informerFactory.Submariner().V1().Endpoints().Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			var object *submarinerv1.Endpoint
-			var ok bool
 			klog.V(8).Infof("AddFunc in WatchEndpoints called")
-			if object, ok = obj.(*submarinerv1.Endpoint); !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					klog.Errorf("Could not convert object %v to an Endpoint", obj)
-					return
-				}
-				object, ok = tombstone.Obj.(*submarinerv1.Endpoint)
-				if !ok {
-					klog.Errorf("Could not convert object tombstone %v to an Endpoint", tombstone.Obj)
-					return
-				}
-				klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-			}
-
-			utilruntime.HandleError(onEndpointChange(&types.SubmarinerEndpoint{
-				Spec: object.Spec,
-			}, false))
+			handleEndpoint(obj, false)
 		},
 		UpdateFunc: func(old, obj interface{}) {
-			var object *submarinerv1.Endpoint
-			var ok bool
 			klog.V(8).Infof("UpdateFunc in WatchEndpoints called")
-			if object, ok = obj.(*submarinerv1.Endpoint); !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					klog.Errorf("Could not convert object %v to an Endpoint", obj)
-					return
-				}
-				object, ok = tombstone.Obj.(*submarinerv1.Endpoint)
-				if !ok {
-					klog.Errorf("Could not convert object tombstone %v to an Endpoint", tombstone.Obj)
-					return
-				}
-				klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-			}
-
-			utilruntime.HandleError(onEndpointChange(&types.SubmarinerEndpoint{
-				Spec: object.Spec,
-			}, false))
+			handleEndpoint(obj, false)
 		},
 		DeleteFunc: func(obj interface{}) {
-			var object *submarinerv1.Endpoint
-			var ok bool
 			klog.V(8).Infof("DeleteFunc in WatchEndpoints called")
-			if object, ok = obj.(*submarinerv1.Endpoint); !ok {
-				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-				if !ok {
-					klog.Errorf("Could not convert object %v to an Endpoint", obj)
-					return
-				}
-				object, ok = tombstone.Obj.(*submarinerv1.Endpoint)
-				if !ok {
-					klog.Errorf("Could not convert object tombstone %v to an Endpoint", tombstone.Obj)
-					return
-				}
-				klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-			}
-
-			utilruntime.HandleError(onEndpointChange(&types.SubmarinerEndpoint{
-				Spec: object.Spec,
-			}, true))
+			handleEndpoint(obj, true)
 		},
 	}, time.Second*30)
 
@@ -323,6 +295,22 @@ func (k *Datastore) WatchEndpoints(ctx context.Context, selfClusterID string, co
 	return nil
 }
 
+// clusterMatchesColorCodes looks up the given cluster, falling back to the broker when the informer cache
+// hasn't caught up yet. Deletions of endpoints whose cluster is gone are always delivered.
+func (k *Datastore) clusterMatchesColorCodes(clusterID string, colorCodes []string, deleted bool) bool {
+	cluster, err := k.informerFactory.Submariner().V1().Clusters().Lister().Clusters(k.remoteNamespace).Get(clusterID)
+	if err != nil {
+		cluster, err = k.client.SubmarinerV1().Clusters(k.remoteNamespace).Get(clusterID, metav1.GetOptions{})
+	}
+
+	if err != nil {
+		klog.V(6).Infof("Error retrieving cluster %s: %v", clusterID, err)
+		return deleted
+	}
+
+	return stringSliceOverlaps(cluster.Spec.ColorCodes, colorCodes)
+}
+
 func (k *Datastore) SetCluster(cluster *types.SubmarinerCluster) error {
 	clusterCRDName, err := util.GetClusterCRDName(cluster)
 	if err != nil {
@@ -330,9 +318,8 @@ func (k *Datastore) SetCluster(cluster *types.SubmarinerCluster) error {
 	}
 
 	retrievedCluster, err := k.client.SubmarinerV1().Clusters(k.remoteNamespace).Get(clusterCRDName, metav1.GetOptions{})
-	if err != nil {
-		klog.V(4).Infof("There was an error retrieving the remote Cluster CRD for %s, assuming it does not exist and creating a new one. The error was: %v",
-			clusterCRDName, err)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("The remote Cluster CRD for %s does not exist, creating a new one", clusterCRDName)
 		newClusterObject := &submarinerv1.Cluster{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: clusterCRDName,
@@ -342,8 +329,10 @@ func (k *Datastore) SetCluster(cluster *types.SubmarinerCluster) error {
 
 		_, err = k.client.SubmarinerV1().Clusters(k.remoteNamespace).Create(newClusterObject)
 		if err != nil {
-			return fmt.Errorf("Error creating Cluster CRD %s in the remote broker: %v", clusterCRDName, err)
+			return datastoreError(err, "Error creating Cluster CRD %s in the remote broker", clusterCRDName)
 		}
+	} else if err != nil {
+		return datastoreError(err, "Error retrieving Cluster CRD %s from the remote broker", clusterCRDName)
 	} else {
 		if reflect.DeepEqual(cluster.Spec, retrievedCluster.Spec) {
 			klog.V(4).Infof("Cluster CRD matched what we received from k8s broker, not reconciling")
@@ -352,14 +341,14 @@ func (k *Datastore) SetCluster(cluster *types.SubmarinerCluster) error {
 		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			result, getErr := k.client.SubmarinerV1().Clusters(k.remoteNamespace).Get(clusterCRDName, metav1.GetOptions{})
 			if getErr != nil {
-				return fmt.Errorf("Error retrieving latest version of Cluster %s: %v", clusterCRDName, getErr)
+				return getErr
 			}
 			result.Spec = cluster.Spec
 			_, updateErr := k.client.SubmarinerV1().Clusters(k.remoteNamespace).Update(result)
 			return updateErr
 		})
 		if retryErr != nil {
-			return fmt.Errorf("Error updating Cluster CRD %s in remote broker: %v", clusterCRDName, retryErr)
+			return datastoreError(retryErr, "Error updating Cluster CRD %s in remote broker", clusterCRDName)
 		}
 	}
 	return nil
@@ -372,9 +361,8 @@ func (k *Datastore) SetEndpoint(endpoint *types.SubmarinerEndpoint) error {
 	}
 
 	retrievedEndpoint, err := k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Get(endpointCRDName, metav1.GetOptions{})
-	if err != nil {
-		klog.V(4).Infof("There was an error retrieving the local Endpoint CRD for %s, assuming it does not exist and creating a new one. The error was: %v",
-			endpointCRDName, err)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("The remote Endpoint CRD for %s does not exist, creating a new one", endpointCRDName)
 		newEndpointObject := &submarinerv1.Endpoint{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: endpointCRDName,
@@ -384,8 +372,10 @@ func (k *Datastore) SetEndpoint(endpoint *types.SubmarinerEndpoint) error {
 
 		_, err = k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Create(newEndpointObject)
 		if err != nil {
-			return fmt.Errorf("Error creating Endpoint CRD %s in the remote broker: %v", endpointCRDName, err)
+			return datastoreError(err, "Error creating Endpoint CRD %s in the remote broker", endpointCRDName)
 		}
+	} else if err != nil {
+		return datastoreError(err, "Error retrieving Endpoint CRD %s from the remote broker", endpointCRDName)
 	} else {
 		if reflect.DeepEqual(endpoint.Spec, retrievedEndpoint.Spec) {
 			klog.V(4).Infof("Endpoint CRD matched what we received from k8s broker, not reconciling")
@@ -394,14 +384,14 @@ func (k *Datastore) SetEndpoint(endpoint *types.SubmarinerEndpoint) error {
 		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			result, getErr := k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Get(endpointCRDName, metav1.GetOptions{})
 			if getErr != nil {
-				return fmt.Errorf("Error retrieving latest version of Endpoint %s: %v", endpointCRDName, getErr)
+				return getErr
 			}
 			result.Spec = endpoint.Spec
 			_, updateErr := k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Update(result)
 			return updateErr
 		})
 		if retryErr != nil {
-			return fmt.Errorf("Error updating endpoint CRD %s: %v", endpointCRDName, retryErr)
+			return datastoreError(retryErr, "Error updating Endpoint CRD %s in remote broker", endpointCRDName)
 		}
 	}
 	return nil
@@ -414,10 +404,384 @@ func (k *Datastore) RemoveEndpoint(clusterID, cableName string) error {
 		return fmt.Errorf("Error converting the Endpoint CRD name: %v", err)
 	}
 
-	return k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Delete(endpointName, &metav1.DeleteOptions{})
+	err = k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Delete(endpointName, &metav1.DeleteOptions{})
+	if err != nil {
+		return datastoreError(err, "Error deleting Endpoint %s from the remote broker", endpointName)
+	}
+
+	err = k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).Delete(endpointName, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return datastoreError(err, "Error deleting EndpointLease %s from the remote broker", endpointName)
+	}
+	return nil
 }
 
+func (k *Datastore) RenewEndpointLease(clusterID, cableName string, renewTime time.Time) error {
+	endpointName, err := util.GetEndpointCRDNameFromParams(clusterID, cableName)
+	if err != nil {
+		return fmt.Errorf("Error converting the Endpoint CRD name: %v", err)
+	}
+
+	_, err = k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Get(endpointName, metav1.GetOptions{})
+	if err != nil {
+		return datastoreError(err, "Error retrieving Endpoint %s from remote broker", endpointName)
+	}
+
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		result, getErr := k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).Get(endpointName, metav1.GetOptions{})
+		if apierrors.IsNotFound(getErr) {
+			_, createErr := k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).Create(&submarinerv1.EndpointLease{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: endpointName,
+				},
+				Spec: submarinerv1.EndpointLeaseSpec{
+					ClusterID: clusterID,
+					CableName: cableName,
+					RenewTime: metav1.NewTime(renewTime),
+				},
+			})
+			if apierrors.IsAlreadyExists(createErr) {
+				// Retry as an update
+				return apierrors.NewConflict(submarinerv1.Resource("endpointleases"), endpointName, createErr)
+			}
+			return createErr
+		} else if getErr != nil {
+			return getErr
+		}
+		result.Spec.RenewTime = metav1.NewTime(renewTime)
+		_, updateErr := k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).Update(result)
+		return updateErr
+	})
+	if retryErr != nil {
+		return datastoreError(retryErr, "Error renewing the lease of Endpoint %s in remote broker", endpointName)
+	}
+	return nil
+}
+
+func (k *Datastore) GetEndpointLeaseRenewTime(clusterID, cableName string) (time.Time, error) {
+	endpointName, err := util.GetEndpointCRDNameFromParams(clusterID, cableName)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error converting the Endpoint CRD name: %v", err)
+	}
+
+	_, err = k.client.SubmarinerV1().Endpoints(k.remoteNamespace).Get(endpointName, metav1.GetOptions{})
+	if err != nil {
+		return time.Time{}, datastoreError(err, "Error retrieving Endpoint %s from remote broker", endpointName)
+	}
+
+	lease, err := k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).Get(endpointName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, datastoreError(err, "Error retrieving EndpointLease %s from remote broker", endpointName)
+	}
+	return lease.Spec.RenewTime.Time, nil
+}
+
+// RemoveCluster removes the endpoints and their leases, the service imports and CIDR reservations of the cluster and
+// then the cluster itself from the broker. Objects that are already gone are ignored so that an interrupted removal can
+// simply be retried.
 func (k *Datastore) RemoveCluster(clusterID string) error {
-	// not implemented yet
+	endpoints, err := k.GetEndpoints(clusterID)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		err = k.RemoveEndpoint(clusterID, endpoint.Spec.CableName)
+		if err != nil && !datastore.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// The leases of the endpoints removed above are gone, unless an earlier removal was interrupted
+	leases, err := k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return datastoreError(err, "Error listing EndpointLeases in the remote broker")
+	}
+
+	for _, lease := range leases.Items {
+		if lease.Spec.ClusterID != clusterID {
+			continue
+		}
+
+		err = k.client.SubmarinerV1().EndpointLeases(k.remoteNamespace).Delete(lease.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return datastoreError(err, "Error deleting EndpointLease %s from the remote broker", lease.Name)
+		}
+	}
+
+	serviceImports, err := k.GetServiceImports(clusterID)
+	if err != nil {
+		return err
+	}
+
+	for _, serviceImport := range serviceImports {
+		err = k.RemoveServiceImport(clusterID, serviceImport.Spec.Namespace, serviceImport.Spec.ServiceName)
+		if err != nil && !datastore.IsNotFound(err) {
+			return err
+		}
+	}
+
+	cidrReservations, err := k.client.SubmarinerV1().CIDRReservations(k.remoteNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return datastoreError(err, "Error listing CIDRReservations in the remote broker")
+	}
+
+	for _, reservation := range cidrReservations.Items {
+		if reservation.Spec.ClusterID != clusterID {
+			continue
+		}
+
+		err = k.client.SubmarinerV1().CIDRReservations(k.remoteNamespace).Delete(reservation.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return datastoreError(err, "Error deleting CIDRReservation %s from the remote broker", reservation.Name)
+		}
+	}
+
+	err = k.client.SubmarinerV1().Clusters(k.remoteNamespace).Delete(clusterID, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return datastoreError(err, "Error deleting Cluster %s from the remote broker", clusterID)
+	}
+	return nil
+}
+
+func (k *Datastore) GetServiceImports(clusterID string) ([]types.SubmarinerServiceImport, error) {
+	k8sServiceImports, err := k.client.SubmarinerV1().ServiceImports(k.remoteNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, datastoreError(err, "Error listing ServiceImports in the remote broker")
+	}
+
+	serviceImports := []types.SubmarinerServiceImport{}
+	for _, serviceImport := range k8sServiceImports.Items {
+		if serviceImport.Spec.ClusterID == clusterID {
+			serviceImports = append(serviceImports, types.SubmarinerServiceImport{Spec: serviceImport.Spec})
+		}
+	}
+
+	return serviceImports, nil
+}
+
+func (k *Datastore) WatchServiceImports(ctx context.Context, selfClusterID string, colorCodes []string, onServiceImportChange func(serviceImport *types.SubmarinerServiceImport, deleted bool) error) error {
+	handleServiceImport := func(obj interface{}, deleted bool) {
+		var object *submarinerv1.ServiceImport
+		var ok bool
+		if object, ok = obj.(*submarinerv1.ServiceImport); !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				klog.Errorf("Could not convert object %v to a ServiceImport", obj)
+				return
+			}
+			object, ok = tombstone.Obj.(*submarinerv1.ServiceImport)
+			if !ok {
+				klog.Errorf("Could not convert object tombstone %v to a ServiceImport", tombstone.Obj)
+				return
+			}
+			klog.V(6).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
+		}
+
+		if ctx.Err() != nil {
+			klog.V(8).Infof("ServiceImport watch was cancelled, ignoring event for %s", object.GetName())
+			return
+		}
+
+		if object.Spec.ClusterID == selfClusterID {
+			klog.V(8).Infof("Not delivering event for our own service import %s", object.GetName())
+			return
+		}
+
+		if !k.clusterMatchesColorCodes(object.Spec.ClusterID, colorCodes, deleted) {
+			klog.V(8).Infof("Cluster of service import %s doesn't share any color codes with %v, not delivering event",
+				object.GetName(), colorCodes)
+			return
+		}
+
+		utilruntime.HandleError(onServiceImportChange(&types.SubmarinerServiceImport{
+			Spec: object.Spec,
+		}, deleted))
+	}
+
+	// The cluster informer is used to check the color codes of the service imports' clusters
+	k.informerFactory.Submariner().V1().Clusters().Informer()
+	k.informerFactory.Submariner().V1().ServiceImports().Informer().AddEventHandlerWithResyncPeriod(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			klog.V(8).Infof("AddFunc in WatchServiceImports called")
+			handleServiceImport(obj, false)
+		},
+		UpdateFunc: func(old, obj interface{}) {
+			klog.V(8).Infof("UpdateFunc in WatchServiceImports called")
+			handleServiceImport(obj, false)
+		},
+		DeleteFunc: func(obj interface{}) {
+			klog.V(8).Infof("DeleteFunc in WatchServiceImports called")
+			handleServiceImport(obj, true)
+		},
+	}, time.Second*30)
+
+	k.informerFactory.Start(k.stopCh)
+	return nil
+}
+
+func (k *Datastore) SetServiceImport(serviceImport *types.SubmarinerServiceImport) error {
+	serviceImportCRDName, err := util.GetServiceImportCRDName(serviceImport)
+	if err != nil {
+		return fmt.Errorf("Error converting the ServiceImport CRD name: %v", err)
+	}
+
+	retrievedServiceImport, err := k.client.SubmarinerV1().ServiceImports(k.remoteNamespace).Get(serviceImportCRDName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("The remote ServiceImport CRD for %s does not exist, creating a new one", serviceImportCRDName)
+		newServiceImportObject := &submarinerv1.ServiceImport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: serviceImportCRDName,
+			},
+			Spec: serviceImport.Spec,
+		}
+
+		_, err = k.client.SubmarinerV1().ServiceImports(k.remoteNamespace).Create(newServiceImportObject)
+		if err != nil {
+			return datastoreError(err, "Error creating ServiceImport CRD %s in the remote broker", serviceImportCRDName)
+		}
+	} else if err != nil {
+		return datastoreError(err, "Error retrieving ServiceImport CRD %s from the remote broker", serviceImportCRDName)
+	} else {
+		if reflect.DeepEqual(serviceImport.Spec, retrievedServiceImport.Spec) {
+			klog.V(4).Infof("ServiceImport CRD matched what we received from k8s broker, not reconciling")
+			return nil
+		}
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			result, getErr := k.client.SubmarinerV1().ServiceImports(k.remoteNamespace).Get(serviceImportCRDName, metav1.GetOptions{})
+			if getErr != nil {
+				return getErr
+			}
+			result.Spec = serviceImport.Spec
+			_, updateErr := k.client.SubmarinerV1().ServiceImports(k.remoteNamespace).Update(result)
+			return updateErr
+		})
+		if retryErr != nil {
+			return datastoreError(retryErr, "Error updating ServiceImport CRD %s in remote broker", serviceImportCRDName)
+		}
+	}
+	return nil
+}
+
+func (k *Datastore) RemoveServiceImport(clusterID, namespace, serviceName string) error {
+	serviceImportName, err := util.GetServiceImportCRDNameFromParams(clusterID, namespace, serviceName)
+	if err != nil {
+		return fmt.Errorf("Error converting the ServiceImport CRD name: %v", err)
+	}
+
+	err = k.client.SubmarinerV1().ServiceImports(k.remoteNamespace).Delete(serviceImportName, &metav1.DeleteOptions{})
+	if err != nil {
+		return datastoreError(err, "Error deleting ServiceImport %s from the remote broker", serviceImportName)
+	}
 	return nil
 }
+
+func (k *Datastore) GetCIDRReservations(pool string) ([]types.SubmarinerCIDRReservation, error) {
+	k8sReservations, err := k.client.SubmarinerV1().CIDRReservations(k.remoteNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, datastoreError(err, "Error listing CIDRReservations in the remote broker")
+	}
+
+	reservations := []types.SubmarinerCIDRReservation{}
+	for _, reservation := range k8sReservations.Items {
+		if reservation.Spec.Pool == pool {
+			reservations = append(reservations, types.SubmarinerCIDRReservation{Spec: reservation.Spec})
+		}
+	}
+	return reservations, nil
+}
+
+// ReserveCIDR relies on the CRD name being derived from the pool and the CIDR, so that the API server refuses
+// concurrent reservations of the same block. Overlapping blocks of other sizes are checked before and after the
+// reservation: when two clusters reserve overlapping blocks concurrently, at least the later one sees the other and
+// releases its block.
+func (k *Datastore) ReserveCIDR(reservation *types.SubmarinerCIDRReservation) error {
+	reservationCRDName, err := util.GetCIDRReservationCRDName(reservation)
+	if err != nil {
+		return fmt.Errorf("Error converting the CIDRReservation CRD name: %v", err)
+	}
+
+	if err = k.checkOverlappingReservations(reservation); err != nil {
+		return err
+	}
+
+	_, err = k.client.SubmarinerV1().CIDRReservations(k.remoteNamespace).Create(&submarinerv1.CIDRReservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: reservationCRDName,
+		},
+		Spec: reservation.Spec,
+	})
+	if err == nil {
+		if err = k.checkOverlappingReservations(reservation); err != nil {
+			klog.V(4).Infof("Releasing CIDRReservation CRD %s: %v", reservationCRDName, err)
+			deleteErr := k.client.SubmarinerV1().CIDRReservations(k.remoteNamespace).Delete(reservationCRDName,
+				&metav1.DeleteOptions{})
+			if deleteErr != nil && !apierrors.IsNotFound(deleteErr) {
+				klog.Errorf("Error deleting CIDRReservation CRD %s from the remote broker: %v", reservationCRDName,
+					deleteErr)
+			}
+		}
+		return err
+	} else if !apierrors.IsAlreadyExists(err) {
+		return datastoreError(err, "Error creating CIDRReservation CRD %s in the remote broker", reservationCRDName)
+	}
+
+	existing, err := k.client.SubmarinerV1().CIDRReservations(k.remoteNamespace).Get(reservationCRDName, metav1.GetOptions{})
+	if err != nil {
+		return datastoreError(err, "Error retrieving CIDRReservation CRD %s from the remote broker", reservationCRDName)
+	}
+
+	if existing.Spec.ClusterID != reservation.Spec.ClusterID {
+		return datastore.NewConflictError("CIDR %s of pool %s is already reserved by cluster %s", reservation.Spec.CIDR,
+			reservation.Spec.Pool, existing.Spec.ClusterID)
+	}
+	return nil
+}
+
+// checkOverlappingReservations returns a Conflict error if another block of the pool overlaps the reserved block
+func (k *Datastore) checkOverlappingReservations(reservation *types.SubmarinerCIDRReservation) error {
+	reservations, err := k.GetCIDRReservations(reservation.Spec.Pool)
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range reservations {
+		if existing.Spec.CIDR == reservation.Spec.CIDR {
+			continue
+		}
+
+		overlaps, err := netutil.IsOverlappingCIDR([]string{existing.Spec.CIDR}, reservation.Spec.CIDR)
+		if err != nil {
+			return fmt.Errorf("Error checking CIDR %s for overlaps: %v", reservation.Spec.CIDR, err)
+		}
+		if overlaps {
+			return datastore.NewConflictError("CIDR %s of pool %s overlaps CIDR %s reserved by cluster %s",
+				reservation.Spec.CIDR, reservation.Spec.Pool, existing.Spec.CIDR, existing.Spec.ClusterID)
+		}
+	}
+	return nil
+}
+
+// datastoreError converts an error returned by the broker API server to the matching datastore error
+func datastoreError(err error, format string, a ...interface{}) error {
+	message := fmt.Sprintf(format, a...)
+	switch {
+	case apierrors.IsNotFound(err):
+		return datastore.NewNotFoundError("%s: %v", message, err)
+	case apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err):
+		return datastore.NewConflictError("%s: %v", message, err)
+	case apierrors.IsUnauthorized(err) || apierrors.IsForbidden(err):
+		return datastore.NewUnauthorizedError("%s: %v", message, err)
+	case apierrors.IsServiceUnavailable(err) || apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err):
+		return datastore.NewUnavailableError("%s: %v", message, err)
+	}
+
+	if _, ok := err.(apierrors.APIStatus); !ok {
+		// Anything that isn't a status returned by the API server means we couldn't talk to it
+		return datastore.NewUnavailableError("%s: %v", message, err)
+	}
+	return fmt.Errorf("%s: %v", message, err)
+}
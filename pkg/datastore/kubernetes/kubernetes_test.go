@@ -0,0 +1,105 @@
+package kubernetes
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/client/clientset/versioned/fake"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/datastore/conformance"
+	"github.com/rancher/submariner/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	testing "k8s.io/client-go/testing"
+)
+
+var _ = Describe("Kubernetes Datastore", func() {
+	var stopCh chan struct{}
+
+	AfterEach(func() {
+		close(stopCh)
+	})
+
+	conformance.DescribeDatastore(func() datastore.Datastore {
+		stopCh = make(chan struct{})
+		return newDatastore(fake.NewSimpleClientset(), "east", "submariner-k8s-broker", stopCh)
+	})
+
+	Describe("RenewEndpointLease", func() {
+		var client *fake.Clientset
+		var ds *Datastore
+		var endpoint *types.SubmarinerEndpoint
+
+		BeforeEach(func() {
+			stopCh = make(chan struct{})
+			client = fake.NewSimpleClientset()
+			ds = newDatastore(client, "east", "submariner-k8s-broker", stopCh)
+
+			endpoint = &types.SubmarinerEndpoint{Spec: submarinerv1.EndpointSpec{
+				ClusterID: "east",
+				CableName: "submariner-cable-east-10-0-0-1",
+			}}
+			Expect(ds.SetEndpoint(endpoint)).To(Succeed())
+			client.ClearActions()
+		})
+
+		It("should only write the EndpointLease", func() {
+			Expect(ds.RenewEndpointLease("east", endpoint.Spec.CableName, time.Now())).To(Succeed())
+			Expect(ds.RenewEndpointLease("east", endpoint.Spec.CableName, time.Now().Add(time.Minute))).To(Succeed())
+
+			var writes []string
+			for _, action := range client.Actions() {
+				if action.GetVerb() != "get" {
+					writes = append(writes, action.GetVerb()+" "+action.GetResource().Resource)
+				}
+			}
+			Expect(writes).To(Equal([]string{"create endpointleases", "update endpointleases"}))
+		})
+	})
+
+	Describe("error reasons", func() {
+		var client *fake.Clientset
+		var ds *Datastore
+
+		BeforeEach(func() {
+			stopCh = make(chan struct{})
+			client = fake.NewSimpleClientset()
+			ds = newDatastore(client, "east", "submariner-k8s-broker", stopCh)
+		})
+
+		When("the API server is unavailable", func() {
+			BeforeEach(func() {
+				client.PrependReactor("*", "*", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewServiceUnavailable("the broker is down")
+				})
+			})
+
+			It("should return an Unavailable error", func() {
+				_, err := ds.GetClusters([]string{"blue"})
+				Expect(datastore.IsUnavailable(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+
+			It("should not try to create the cluster in SetCluster", func() {
+				err := ds.SetCluster(&types.SubmarinerCluster{ID: "west", Spec: submarinerv1.ClusterSpec{ClusterID: "west"}})
+				Expect(datastore.IsUnavailable(err)).To(BeTrue(), "Unexpected error: %v", err)
+				for _, action := range client.Actions() {
+					Expect(action.GetVerb()).ToNot(Equal("create"))
+				}
+			})
+		})
+
+		When("the API server forbids the request", func() {
+			It("should return an Unauthorized error", func() {
+				client.PrependReactor("list", "endpoints", func(action testing.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "endpoints"}, "", nil)
+				})
+				_, err := ds.GetEndpoints("west")
+				Expect(datastore.IsUnauthorized(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+	})
+})
@@ -7,10 +7,12 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/rancher/submariner/pkg/datastore"
 	"github.com/rancher/submariner/pkg/types"
 	"github.com/rancher/submariner/pkg/util"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -19,14 +21,16 @@ import (
 
 type PHPAPI struct {
 	sync.Mutex
-	Proto    string
-	Server   string
-	APIToken string
+	Proto        string
+	Server       string
+	APIToken     string
+	PollInterval time.Duration
 }
 
 type Specification struct {
-	Proto  string
-	Server string
+	Proto        string
+	Server       string
+	PollInterval time.Duration `default:"5s"`
 }
 
 func NewPHPAPI(apitoken string) (*PHPAPI, error) {
@@ -38,28 +42,73 @@ func NewPHPAPI(apitoken string) (*PHPAPI, error) {
 
 	klog.Infof("Instantiating PHPAPI Backend at %s://%s with APIToken %s", pais.Proto, pais.Server, apitoken)
 	return &PHPAPI{
-		Proto:    pais.Proto,
-		Server:   pais.Server,
-		APIToken: apitoken,
+		Proto:        pais.Proto,
+		Server:       pais.Server,
+		APIToken:     apitoken,
+		PollInterval: pais.PollInterval,
 	}, nil
 }
 
-func (p *PHPAPI) GetClusters(colorCodes []string) ([]types.SubmarinerCluster, error) {
-	colorCode := util.FlattenColors(colorCodes)
-	requestURL := fmt.Sprintf("%s://%s/clusters.php?plurality=true&identifier=%s&colorcode=%s", p.Proto, p.Server, p.APIToken, colorCode)
-	klog.V(8).Infof("request url: %s", requestURL)
-	clustersGetter, err := http.Get(requestURL)
+// get performs a GET request, returning the body of the response if it was successful
+func get(requestURL string) ([]byte, error) {
+	response, err := http.Get(requestURL)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving clusters from %s: %v", requestURL, err)
+		return nil, datastore.NewUnavailableError("error retrieving %s: %v", requestURL, err)
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, datastore.NewUnavailableError("error reading response from %s: %v", requestURL, err)
+	}
+
+	klog.V(8).Infof("response body: %v", string(body[:]))
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errorForStatus(response.StatusCode, "request to %s failed with status %s: %s", requestURL,
+			response.Status, string(body[:]))
 	}
+	return body, nil
+}
 
-	defer clustersGetter.Body.Close()
-	clustersRaw, err := ioutil.ReadAll(clustersGetter.Body)
+// postForm performs a POST request, returning an error if it was not successful
+func postForm(requestURL string, formVal url.Values) error {
+	response, err := http.PostForm(requestURL, formVal)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response from %s: %v", requestURL, err)
+		return datastore.NewUnavailableError("%v", err)
 	}
 
-	klog.V(8).Infof("Response body: %v", string(clustersRaw[:]))
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(response.Body)
+		return errorForStatus(response.StatusCode, "request failed with status %s: %s", response.Status, string(body[:]))
+	}
+	return nil
+}
+
+// errorForStatus returns the datastore error matching the HTTP status code returned by the broker
+func errorForStatus(statusCode int, format string, a ...interface{}) error {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return datastore.NewNotFoundError(format, a...)
+	case statusCode == http.StatusConflict:
+		return datastore.NewConflictError(format, a...)
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return datastore.NewUnauthorizedError(format, a...)
+	case statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError:
+		return datastore.NewUnavailableError(format, a...)
+	}
+	return fmt.Errorf(format, a...)
+}
+
+func (p *PHPAPI) GetClusters(colorCodes []string) ([]types.SubmarinerCluster, error) {
+	colorCode := util.FlattenColors(colorCodes)
+	requestURL := fmt.Sprintf("%s://%s/clusters.php?plurality=true&identifier=%s&colorcode=%s", p.Proto, p.Server, p.APIToken, colorCode)
+	klog.V(8).Infof("request url: %s", requestURL)
+	clustersRaw, err := get(requestURL)
+	if err != nil {
+		return nil, datastore.Wrapf(err, "error retrieving clusters")
+	}
 
 	// let's unmarshal into our type the cluster into
 	// need to actually make the API return json that works for this
@@ -73,19 +122,11 @@ func (p *PHPAPI) GetClusters(colorCodes []string) ([]types.SubmarinerCluster, er
 func (p *PHPAPI) GetCluster(clusterID string) (*types.SubmarinerCluster, error) {
 	requestURL := fmt.Sprintf("%s://%s/clusters.php?plurality=false&identifier=%s&cluster_id=%s", p.Proto, p.Server, p.APIToken, clusterID)
 	klog.V(8).Infof("request url: %s", requestURL)
-	clustersGetter, err := http.Get(requestURL)
-	if err != nil {
-		return nil, fmt.Errorf("error retrieving clusters from %s: %v", requestURL, err)
-	}
-
-	defer clustersGetter.Body.Close()
-	clustersRaw, err := ioutil.ReadAll(clustersGetter.Body)
+	clustersRaw, err := get(requestURL)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response from %s: %v", requestURL, err)
+		return nil, datastore.Wrapf(err, "error retrieving cluster %s", clusterID)
 	}
 
-	klog.V(8).Infof("response body: %v", string(clustersRaw[:]))
-
 	var cluster types.SubmarinerCluster
 	if err = json.Unmarshal(clustersRaw, &cluster); err != nil {
 		return nil, fmt.Errorf("error unmarshalling JSON %s: %v", string(clustersRaw[:]), err)
@@ -96,19 +137,11 @@ func (p *PHPAPI) GetCluster(clusterID string) (*types.SubmarinerCluster, error)
 
 func (p *PHPAPI) GetEndpoints(clusterID string) ([]types.SubmarinerEndpoint, error) {
 	requestURL := fmt.Sprintf("%s://%s/endpoints.php?plurality=true&identifier=%s&cluster_id=%s", p.Proto, p.Server, p.APIToken, clusterID)
-	endpointsGetter, err := http.Get(requestURL)
+	endpointsRaw, err := get(requestURL)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving endpoints from %s: %v", requestURL, err)
+		return nil, datastore.Wrapf(err, "error retrieving endpoints")
 	}
 
-	defer endpointsGetter.Body.Close()
-	endpointsRaw, err := ioutil.ReadAll(endpointsGetter.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response from %s: %v", requestURL, err)
-	}
-
-	klog.V(8).Infof("response body: %v", string(endpointsRaw[:]))
-
 	var endpoints []types.SubmarinerEndpoint
 	if err = json.Unmarshal(endpointsRaw, &endpoints); err != nil {
 		return nil, fmt.Errorf("error unmarshalling JSON %s: %v", string(endpointsRaw[:]), err)
@@ -117,76 +150,125 @@ func (p *PHPAPI) GetEndpoints(clusterID string) ([]types.SubmarinerEndpoint, err
 }
 
 func (p *PHPAPI) GetEndpoint(clusterID string, cableName string) (*types.SubmarinerEndpoint, error) {
-	return nil, fmt.Errorf("GetEndpoint is not implemented")
+	endpoints, err := p.GetEndpoints(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Spec.CableName == cableName {
+			return &endpoint, nil
+		}
+	}
+	return nil, datastore.NewNotFoundError("endpoint %s wasn't found", cableName)
 }
 
 func (p *PHPAPI) WatchClusters(ctx context.Context, selfClusterID string, colorCodes []string, onChange func(cluster *types.SubmarinerCluster, deleted bool) error) error {
 	colorCode := util.FlattenColors(colorCodes)
-	klog.Infof("Starting watch for colorCode %s", colorCode)
-	var wg sync.WaitGroup
-	wg.Add(1)
+	klog.Infof("Starting PHPAPI cluster watch for colorCode %s", colorCode)
 	go func() {
-		defer wg.Done()
-		for {
+		known := map[string]types.SubmarinerCluster{}
+		p.poll(ctx, func() {
 			clusters, err := p.GetClusters(colorCodes)
 			if err != nil {
 				utilruntime.HandleError(err)
-			} else {
-				klog.V(8).Infof("Got clusters from API: %#v", clusters)
-				for _, cluster := range clusters {
-					if selfClusterID != cluster.ID {
-						utilruntime.HandleError(onChange(&cluster, false))
-					}
+				return
+			}
+
+			klog.V(8).Infof("Got clusters from API: %#v", clusters)
+			current := map[string]types.SubmarinerCluster{}
+			for _, cluster := range clusters {
+				if selfClusterID == cluster.ID {
+					continue
+				}
+				current[cluster.ID] = cluster
+				if old, ok := known[cluster.ID]; !ok || !reflect.DeepEqual(old, cluster) {
+					utilruntime.HandleError(onChange(&cluster, false))
 				}
 			}
 
-			klog.V(8).Infof("Sleeping 5 seconds")
-			time.Sleep(5 * time.Second)
-		}
+			for id, cluster := range known {
+				if _, ok := current[id]; !ok {
+					utilruntime.HandleError(onChange(&cluster, true))
+				}
+			}
+			known = current
+		})
 	}()
-	wg.Wait()
-	klog.Errorf("I shouldn't have exited")
 	return nil
 }
 
 func (p *PHPAPI) WatchEndpoints(ctx context.Context, selfClusterID string, colorCodes []string, onChange func(endpoint *types.SubmarinerEndpoint, deleted bool) error) error {
 	colorCode := util.FlattenColors(colorCodes)
 	klog.Infof("Starting PHPAPI endpoint watch for colorCode %s", colorCode)
-	var wg sync.WaitGroup
-	wg.Add(1)
 	go func() {
-		defer wg.Done()
-		for {
+		// endpoints are tracked per cluster so a failure to retrieve one cluster's endpoints doesn't
+		// look like they were deleted
+		known := map[string]map[string]types.SubmarinerEndpoint{}
+		p.poll(ctx, func() {
 			clusters, err := p.GetClusters(colorCodes)
 			if err != nil {
 				utilruntime.HandleError(err)
-			} else {
-				klog.V(8).Infof("Got clusters from API: %#v", clusters)
-				for _, cluster := range clusters {
-					endpoints, err := p.GetEndpoints(cluster.ID)
-					if err != nil {
-						utilruntime.HandleError(err)
-						continue
+				return
+			}
+
+			klog.V(8).Infof("Got clusters from API: %#v", clusters)
+			current := map[string]map[string]types.SubmarinerEndpoint{}
+			for _, cluster := range clusters {
+				if selfClusterID == cluster.ID {
+					continue
+				}
+
+				endpoints, err := p.GetEndpoints(cluster.ID)
+				if err != nil {
+					utilruntime.HandleError(err)
+					if old, ok := known[cluster.ID]; ok {
+						current[cluster.ID] = old
 					}
+					continue
+				}
 
-					klog.Infof("Got endpoints from API: %#v", endpoints)
-					for _, endpoint := range endpoints {
-						if selfClusterID != endpoint.Spec.ClusterID {
-							utilruntime.HandleError(onChange(&endpoint, false))
-						}
+				klog.V(8).Infof("Got endpoints from API: %#v", endpoints)
+				current[cluster.ID] = map[string]types.SubmarinerEndpoint{}
+				for _, endpoint := range endpoints {
+					if selfClusterID == endpoint.Spec.ClusterID {
+						continue
+					}
+					current[cluster.ID][endpoint.Spec.CableName] = endpoint
+					if old, ok := known[cluster.ID][endpoint.Spec.CableName]; !ok || !reflect.DeepEqual(old, endpoint) {
+						utilruntime.HandleError(onChange(&endpoint, false))
 					}
 				}
 			}
 
-			klog.V(8).Infof("Sleeping 5 seconds")
-			time.Sleep(5 * time.Second)
-		}
+			for clusterID, endpoints := range known {
+				for cableName, endpoint := range endpoints {
+					if _, ok := current[clusterID][cableName]; !ok {
+						utilruntime.HandleError(onChange(&endpoint, true))
+					}
+				}
+			}
+			known = current
+		})
 	}()
-	wg.Wait()
-	klog.Errorf("I shouldn't have exited")
 	return nil
 }
 
+// poll calls the given function every PollInterval until the context is cancelled
+func (p *PHPAPI) poll(ctx context.Context, f func()) {
+	for {
+		f()
+
+		klog.V(8).Infof("Sleeping %v", p.PollInterval)
+		select {
+		case <-ctx.Done():
+			klog.V(4).Infof("PHPAPI watch was cancelled")
+			return
+		case <-time.After(p.PollInterval):
+		}
+	}
+}
+
 func (p *PHPAPI) SetCluster(cluster *types.SubmarinerCluster) error {
 	marshaledCluster, err := json.Marshal(cluster)
 	if err != nil {
@@ -199,11 +281,9 @@ func (p *PHPAPI) SetCluster(cluster *types.SubmarinerCluster) error {
 	requestURL := fmt.Sprintf("%s://%s/clusters.php?identifier=%s", p.Proto, p.Server, p.APIToken)
 
 	klog.V(8).Infof("Setting cluster %s via URL %s", string(marshaledCluster), requestURL)
-	poster, err := http.PostForm(requestURL, formVal)
-	if err != nil {
-		return fmt.Errorf("error setting cluster %s via URL %s: %v", string(marshaledCluster), requestURL, err)
+	if err = postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error setting cluster %s via URL %s", string(marshaledCluster), requestURL)
 	}
-	defer poster.Body.Close()
 	return nil
 }
 
@@ -220,11 +300,9 @@ func (p *PHPAPI) SetEndpoint(endpoint *types.SubmarinerEndpoint) error {
 		endpoint.Spec.ClusterID)
 
 	klog.V(8).Infof("Setting endpoint %s via URL %s", string(marshaledEndpoint), requestURL)
-	poster, err := http.PostForm(requestURL, formVal)
-	if err != nil {
-		return fmt.Errorf("error setting endpoint %s via URL %s: %v", string(marshaledEndpoint), requestURL, err)
+	if err = postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error setting endpoint %s via URL %s", string(marshaledEndpoint), requestURL)
 	}
-	defer poster.Body.Close()
 
 	return nil
 }
@@ -236,14 +314,185 @@ func (p *PHPAPI) RemoveEndpoint(clusterID, cableName string) error {
 	requestURL := fmt.Sprintf("%s://%s/endpoints.php?identifier=%s&cluster_id=%s", p.Proto, p.Server, p.APIToken, clusterID)
 
 	klog.V(8).Infof("Removing endpoint %s via URL %s", cableName, requestURL)
-	poster, err := http.PostForm(requestURL, formVal)
-	if err != nil {
-		return fmt.Errorf("error removing endpoint %s via URL %s: %v", cableName, requestURL, err)
+	if err := postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error removing endpoint %s via URL %s", cableName, requestURL)
+	}
+	return nil
+}
+
+func (p *PHPAPI) RenewEndpointLease(clusterID, cableName string, renewTime time.Time) error {
+	formVal := url.Values{}
+	formVal.Set("action", "heartbeat")
+	formVal.Add("cable_name", cableName)
+	formVal.Add("renew_time", renewTime.UTC().Format(time.RFC3339))
+	requestURL := fmt.Sprintf("%s://%s/endpoints.php?identifier=%s&cluster_id=%s", p.Proto, p.Server, p.APIToken, clusterID)
+
+	klog.V(8).Infof("Renewing lease of endpoint %s via URL %s", cableName, requestURL)
+	if err := postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error renewing lease of endpoint %s via URL %s", cableName, requestURL)
 	}
-	defer poster.Body.Close()
 	return nil
 }
 
+func (p *PHPAPI) GetEndpointLeaseRenewTime(clusterID, cableName string) (time.Time, error) {
+	requestURL := fmt.Sprintf("%s://%s/endpoints.php?lease=true&identifier=%s&cluster_id=%s&cable_name=%s", p.Proto, p.Server,
+		p.APIToken, clusterID, cableName)
+	leaseRaw, err := get(requestURL)
+	if err != nil {
+		return time.Time{}, datastore.Wrapf(err, "error retrieving lease of endpoint %s", cableName)
+	}
+
+	var lease struct {
+		RenewTime time.Time `json:"renew_time"`
+	}
+	if err = json.Unmarshal(leaseRaw, &lease); err != nil {
+		return time.Time{}, fmt.Errorf("error unmarshalling JSON %s: %v", string(leaseRaw[:]), err)
+	}
+	return lease.RenewTime, nil
+}
+
+// RemoveCluster removes the cluster from the broker, which also removes its endpoints, service imports and CIDR
+// reservations
 func (p *PHPAPI) RemoveCluster(clusterID string) error {
-	return fmt.Errorf("RemoveCluster is not implemented")
+	formVal := url.Values{}
+	formVal.Set("action", "delete")
+	formVal.Add("cluster_id", clusterID)
+	requestURL := fmt.Sprintf("%s://%s/clusters.php?identifier=%s", p.Proto, p.Server, p.APIToken)
+
+	klog.V(8).Infof("Removing cluster %s via URL %s", clusterID, requestURL)
+	if err := postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error removing cluster %s via URL %s", clusterID, requestURL)
+	}
+	return nil
+}
+
+func (p *PHPAPI) GetServiceImports(clusterID string) ([]types.SubmarinerServiceImport, error) {
+	requestURL := fmt.Sprintf("%s://%s/serviceimports.php?plurality=true&identifier=%s&cluster_id=%s", p.Proto, p.Server,
+		p.APIToken, clusterID)
+	serviceImportsRaw, err := get(requestURL)
+	if err != nil {
+		return nil, datastore.Wrapf(err, "error retrieving service imports")
+	}
+
+	var serviceImports []types.SubmarinerServiceImport
+	if err = json.Unmarshal(serviceImportsRaw, &serviceImports); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON %s: %v", string(serviceImportsRaw[:]), err)
+	}
+	return serviceImports, nil
+}
+
+func (p *PHPAPI) WatchServiceImports(ctx context.Context, selfClusterID string, colorCodes []string, onChange func(serviceImport *types.SubmarinerServiceImport, deleted bool) error) error {
+	colorCode := util.FlattenColors(colorCodes)
+	klog.Infof("Starting PHPAPI service import watch for colorCode %s", colorCode)
+	go func() {
+		// service imports are tracked per cluster, keyed by namespace/name, for the same reason as endpoints
+		known := map[string]map[string]types.SubmarinerServiceImport{}
+		p.poll(ctx, func() {
+			clusters, err := p.GetClusters(colorCodes)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return
+			}
+
+			current := map[string]map[string]types.SubmarinerServiceImport{}
+			for _, cluster := range clusters {
+				if selfClusterID == cluster.ID {
+					continue
+				}
+
+				serviceImports, err := p.GetServiceImports(cluster.ID)
+				if err != nil {
+					utilruntime.HandleError(err)
+					if old, ok := known[cluster.ID]; ok {
+						current[cluster.ID] = old
+					}
+					continue
+				}
+
+				klog.V(8).Infof("Got service imports from API: %#v", serviceImports)
+				current[cluster.ID] = map[string]types.SubmarinerServiceImport{}
+				for _, serviceImport := range serviceImports {
+					key := serviceImport.Spec.Namespace + "/" + serviceImport.Spec.ServiceName
+					current[cluster.ID][key] = serviceImport
+					if old, ok := known[cluster.ID][key]; !ok || !reflect.DeepEqual(old, serviceImport) {
+						utilruntime.HandleError(onChange(&serviceImport, false))
+					}
+				}
+			}
+
+			for clusterID, serviceImports := range known {
+				for key, serviceImport := range serviceImports {
+					if _, ok := current[clusterID][key]; !ok {
+						utilruntime.HandleError(onChange(&serviceImport, true))
+					}
+				}
+			}
+			known = current
+		})
+	}()
+	return nil
+}
+
+func (p *PHPAPI) SetServiceImport(serviceImport *types.SubmarinerServiceImport) error {
+	marshaledServiceImport, err := json.Marshal(serviceImport)
+	if err != nil {
+		return fmt.Errorf("error marshalling %#v: %v", serviceImport, err)
+	}
+
+	formVal := url.Values{}
+	formVal.Set("action", "reconcile")
+	formVal.Add("serviceimport", string(marshaledServiceImport))
+	requestURL := fmt.Sprintf("%s://%s/serviceimports.php?identifier=%s&cluster_id=%s", p.Proto, p.Server, p.APIToken,
+		serviceImport.Spec.ClusterID)
+
+	klog.V(8).Infof("Setting service import %s via URL %s", string(marshaledServiceImport), requestURL)
+	if err = postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error setting service import %s via URL %s", string(marshaledServiceImport), requestURL)
+	}
+	return nil
+}
+
+func (p *PHPAPI) RemoveServiceImport(clusterID, namespace, serviceName string) error {
+	formVal := url.Values{}
+	formVal.Set("action", "delete")
+	formVal.Add("namespace", namespace)
+	formVal.Add("service_name", serviceName)
+	requestURL := fmt.Sprintf("%s://%s/serviceimports.php?identifier=%s&cluster_id=%s", p.Proto, p.Server, p.APIToken, clusterID)
+
+	klog.V(8).Infof("Removing service import %s/%s via URL %s", namespace, serviceName, requestURL)
+	if err := postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error removing service import %s/%s via URL %s", namespace, serviceName, requestURL)
+	}
+	return nil
+}
+
+func (p *PHPAPI) GetCIDRReservations(pool string) ([]types.SubmarinerCIDRReservation, error) {
+	requestURL := fmt.Sprintf("%s://%s/cidrreservations.php?identifier=%s&pool=%s", p.Proto, p.Server, p.APIToken, pool)
+	reservationsRaw, err := get(requestURL)
+	if err != nil {
+		return nil, datastore.Wrapf(err, "error retrieving the CIDR reservations of pool %s", pool)
+	}
+
+	var reservations []types.SubmarinerCIDRReservation
+	if err = json.Unmarshal(reservationsRaw, &reservations); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON %s: %v", string(reservationsRaw[:]), err)
+	}
+	return reservations, nil
+}
+
+// ReserveCIDR relies on the broker answering with 409 Conflict when another cluster holds the block or another
+// block of the pool overlaps it
+func (p *PHPAPI) ReserveCIDR(reservation *types.SubmarinerCIDRReservation) error {
+	formVal := url.Values{}
+	formVal.Set("action", "reserve")
+	formVal.Add("cluster_id", reservation.Spec.ClusterID)
+	formVal.Add("cidr", reservation.Spec.CIDR)
+	requestURL := fmt.Sprintf("%s://%s/cidrreservations.php?identifier=%s&pool=%s", p.Proto, p.Server, p.APIToken,
+		reservation.Spec.Pool)
+
+	klog.V(8).Infof("Reserving CIDR %s for cluster %s via URL %s", reservation.Spec.CIDR, reservation.Spec.ClusterID, requestURL)
+	if err := postForm(requestURL, formVal); err != nil {
+		return datastore.Wrapf(err, "error reserving CIDR %s via URL %s", reservation.Spec.CIDR, requestURL)
+	}
+	return nil
 }
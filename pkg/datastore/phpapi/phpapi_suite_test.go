@@ -0,0 +1,13 @@
+package phpapi
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPHPAPI(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "PHPAPI Datastore Suite")
+}
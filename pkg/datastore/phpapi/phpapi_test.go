@@ -0,0 +1,316 @@
+package phpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	subv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/datastore/conformance"
+	"github.com/rancher/submariner/pkg/netutil"
+	"github.com/rancher/submariner/pkg/types"
+)
+
+const apiToken = "AValidAPIKeyWithLengthThirtyTwo!"
+
+var _ = Describe("PHPAPI Datastore", func() {
+	var server *httptest.Server
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	conformance.DescribeDatastore(func() datastore.Datastore {
+		server = httptest.NewServer(newFakeBroker())
+		serverURL, _ := url.Parse(server.URL)
+		return &PHPAPI{
+			Proto:        serverURL.Scheme,
+			Server:       serverURL.Host,
+			APIToken:     apiToken,
+			PollInterval: 50 * time.Millisecond,
+		}
+	})
+
+	Describe("error reasons", func() {
+		var phpapi *PHPAPI
+
+		BeforeEach(func() {
+			server = httptest.NewServer(newFakeBroker())
+			serverURL, _ := url.Parse(server.URL)
+			phpapi = &PHPAPI{
+				Proto:    serverURL.Scheme,
+				Server:   serverURL.Host,
+				APIToken: apiToken,
+			}
+		})
+
+		When("the API token is rejected", func() {
+			It("should return an Unauthorized error", func() {
+				phpapi.APIToken = "AnInvalidAPIKey"
+				_, err := phpapi.GetClusters([]string{"blue"})
+				Expect(datastore.IsUnauthorized(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+
+		When("the broker can't be reached", func() {
+			It("should return an Unavailable error", func() {
+				server.Close()
+				_, err := phpapi.GetClusters([]string{"blue"})
+				Expect(datastore.IsUnavailable(err)).To(BeTrue(), "Unexpected error: %v", err)
+
+				err = phpapi.SetCluster(&types.SubmarinerCluster{ID: "west"})
+				Expect(datastore.IsUnavailable(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+
+		When("the broker fails with a server error", func() {
+			It("should return an Unavailable error", func() {
+				server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "database is down", http.StatusInternalServerError)
+				})
+				_, err := phpapi.GetEndpoints("west")
+				Expect(datastore.IsUnavailable(err)).To(BeTrue(), "Unexpected error: %v", err)
+			})
+		})
+	})
+})
+
+// fakeBroker is an in-process stand-in for the PHP API broker
+type fakeBroker struct {
+	sync.Mutex
+	mux        *http.ServeMux
+	clusters   map[string]types.SubmarinerCluster
+	endpoints  map[string]map[string]types.SubmarinerEndpoint
+	renewTimes map[string]time.Time
+	// The service imports of each cluster, keyed by namespace/name
+	serviceImports map[string]map[string]types.SubmarinerServiceImport
+	// The CIDR reservations of each pool, keyed by CIDR
+	cidrReservations map[string]map[string]types.SubmarinerCIDRReservation
+}
+
+func newFakeBroker() *fakeBroker {
+	broker := &fakeBroker{
+		mux:              http.NewServeMux(),
+		clusters:         map[string]types.SubmarinerCluster{},
+		endpoints:        map[string]map[string]types.SubmarinerEndpoint{},
+		renewTimes:       map[string]time.Time{},
+		serviceImports:   map[string]map[string]types.SubmarinerServiceImport{},
+		cidrReservations: map[string]map[string]types.SubmarinerCIDRReservation{},
+	}
+	broker.mux.HandleFunc("/clusters.php", broker.handleClusters)
+	broker.mux.HandleFunc("/endpoints.php", broker.handleEndpoints)
+	broker.mux.HandleFunc("/serviceimports.php", broker.handleServiceImports)
+	broker.mux.HandleFunc("/cidrreservations.php", broker.handleCIDRReservations)
+	return broker
+}
+
+func (b *fakeBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Form.Get("identifier") != apiToken {
+		http.Error(w, "invalid identifier", http.StatusUnauthorized)
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	b.mux.ServeHTTP(w, r)
+}
+
+func (b *fakeBroker) handleClusters(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		switch r.Form.Get("action") {
+		case "reconcile":
+			var cluster types.SubmarinerCluster
+			if err := json.Unmarshal([]byte(r.Form.Get("cluster")), &cluster); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b.clusters[cluster.ID] = cluster
+		case "delete":
+			clusterID := r.Form.Get("cluster_id")
+			for cableName := range b.endpoints[clusterID] {
+				delete(b.renewTimes, clusterID+"/"+cableName)
+			}
+			delete(b.endpoints, clusterID)
+			delete(b.serviceImports, clusterID)
+			for _, reservations := range b.cidrReservations {
+				for cidr, reservation := range reservations {
+					if reservation.Spec.ClusterID == clusterID {
+						delete(reservations, cidr)
+					}
+				}
+			}
+			delete(b.clusters, clusterID)
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if r.Form.Get("plurality") == "true" {
+		colorCodes := strings.Split(r.Form.Get("colorcode"), ",")
+		clusters := []types.SubmarinerCluster{}
+		for _, cluster := range b.clusters {
+			if overlaps(cluster.Spec.ColorCodes, colorCodes) {
+				clusters = append(clusters, cluster)
+			}
+		}
+		writeJSON(w, clusters)
+		return
+	}
+
+	cluster, ok := b.clusters[r.Form.Get("cluster_id")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, cluster)
+}
+
+func (b *fakeBroker) handleEndpoints(w http.ResponseWriter, r *http.Request) {
+	clusterID := r.Form.Get("cluster_id")
+	if r.Method == http.MethodPost {
+		switch r.Form.Get("action") {
+		case "reconcile":
+			var endpoint types.SubmarinerEndpoint
+			if err := json.Unmarshal([]byte(r.Form.Get("endpoint")), &endpoint); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if b.endpoints[clusterID] == nil {
+				b.endpoints[clusterID] = map[string]types.SubmarinerEndpoint{}
+			}
+			b.endpoints[clusterID][endpoint.Spec.CableName] = endpoint
+		case "delete":
+			delete(b.endpoints[clusterID], r.Form.Get("cable_name"))
+			delete(b.renewTimes, clusterID+"/"+r.Form.Get("cable_name"))
+		case "heartbeat":
+			if _, ok := b.endpoints[clusterID][r.Form.Get("cable_name")]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+			renewTime, err := time.Parse(time.RFC3339, r.Form.Get("renew_time"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b.renewTimes[clusterID+"/"+r.Form.Get("cable_name")] = renewTime
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+		return
+	}
+
+	if r.Form.Get("lease") == "true" {
+		if _, ok := b.endpoints[clusterID][r.Form.Get("cable_name")]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]time.Time{"renew_time": b.renewTimes[clusterID+"/"+r.Form.Get("cable_name")]})
+		return
+	}
+
+	endpoints := []types.SubmarinerEndpoint{}
+	for _, endpoint := range b.endpoints[clusterID] {
+		endpoints = append(endpoints, endpoint)
+	}
+	writeJSON(w, endpoints)
+}
+
+func (b *fakeBroker) handleServiceImports(w http.ResponseWriter, r *http.Request) {
+	clusterID := r.Form.Get("cluster_id")
+	if r.Method == http.MethodPost {
+		switch r.Form.Get("action") {
+		case "reconcile":
+			var serviceImport types.SubmarinerServiceImport
+			if err := json.Unmarshal([]byte(r.Form.Get("serviceimport")), &serviceImport); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if b.serviceImports[clusterID] == nil {
+				b.serviceImports[clusterID] = map[string]types.SubmarinerServiceImport{}
+			}
+			b.serviceImports[clusterID][serviceImport.Spec.Namespace+"/"+serviceImport.Spec.ServiceName] = serviceImport
+		case "delete":
+			delete(b.serviceImports[clusterID], r.Form.Get("namespace")+"/"+r.Form.Get("service_name"))
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+		return
+	}
+
+	serviceImports := []types.SubmarinerServiceImport{}
+	for _, serviceImport := range b.serviceImports[clusterID] {
+		serviceImports = append(serviceImports, serviceImport)
+	}
+	writeJSON(w, serviceImports)
+}
+
+func (b *fakeBroker) handleCIDRReservations(w http.ResponseWriter, r *http.Request) {
+	pool := r.Form.Get("pool")
+	if r.Method == http.MethodPost {
+		if r.Form.Get("action") != "reserve" {
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+
+		cidr := r.Form.Get("cidr")
+		if existing, ok := b.cidrReservations[pool][cidr]; ok {
+			if existing.Spec.ClusterID != r.Form.Get("cluster_id") {
+				http.Error(w, "already reserved", http.StatusConflict)
+			}
+			return
+		}
+
+		for existing := range b.cidrReservations[pool] {
+			if overlaps, _ := netutil.IsOverlappingCIDR([]string{existing}, cidr); overlaps {
+				http.Error(w, "overlapping block already reserved", http.StatusConflict)
+				return
+			}
+		}
+
+		if b.cidrReservations[pool] == nil {
+			b.cidrReservations[pool] = map[string]types.SubmarinerCIDRReservation{}
+		}
+		b.cidrReservations[pool][cidr] = types.SubmarinerCIDRReservation{
+			Spec: subv1.CIDRReservationSpec{ClusterID: r.Form.Get("cluster_id"), Pool: pool, CIDR: cidr},
+		}
+		return
+	}
+
+	reservations := []types.SubmarinerCIDRReservation{}
+	for _, reservation := range b.cidrReservations[pool] {
+		reservations = append(reservations, reservation)
+	}
+	writeJSON(w, reservations)
+}
+
+func writeJSON(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func overlaps(left, right []string) bool {
+	for _, l := range left {
+		for _, r := range right {
+			if l == r {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/rancher/submariner/pkg/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+/*
+ * Discovery figures out the cluster (pod) and service CIDRs of the local cluster, so that they don't have to be
+ * configured by hand. The sources are tried from the most to the least authoritative:
+ *
+ * - the cluster CIDR comes from the --cluster-cidr argument of the kube-controller-manager or kube-proxy pods,
+ *   then from the enabled Calico IPPools, then from the Flannel ConfigMap and finally from the podCIDRs of the nodes
+ * - the service CIDR comes from the --service-cluster-ip-range argument of the kube-apiserver or
+ *   kube-controller-manager pods, and otherwise from the IP of the kubernetes Service
+ *
+ * A source that can't be read, e.g. because the CRD doesn't exist or access is denied, is skipped. The CIDRs derived
+ * from the nodes and the kubernetes Service are only guesses, which are used when no CIDR is set explicitly or
+ * reserved from a pool. Only IPv4 CIDRs are discovered.
+ */
+
+const (
+	systemNamespace = "kube-system"
+
+	clusterCIDRArg = "--cluster-cidr"
+	serviceCIDRArg = "--service-cluster-ip-range"
+
+	calicoIPPoolsPath  = "/apis/crd.projectcalico.org/v1/ippools"
+	flannelConfigMap   = "kube-flannel-cfg"
+	flannelNetConfFile = "net-conf.json"
+
+	// The labels of the pods whose arguments are inspected, as set by kubeadm and most other installers
+	apiServerSelector         = "component=kube-apiserver"
+	controllerManagerSelector = "component=kube-controller-manager"
+	kubeProxySelector         = "k8s-app=kube-proxy"
+
+	// The cluster CIDR guessed from the podCIDRs of the nodes leaves room for 256 times as many nodes, the
+	// kube-controller-manager default of a /16 cluster CIDR split into /24 node CIDRs
+	nodeCIDRGrowthBits = 8
+)
+
+// The default service CIDRs of common distributions, used when the kube-apiserver arguments aren't visible:
+// kube-up, kubeadm, k3s and RKE, OpenShift
+var defaultServiceCIDRs = []string{"10.0.0.0/24", "10.96.0.0/12", "10.43.0.0/16", "172.30.0.0/16"}
+
+// Discover returns the CIDRs of the local cluster, the ones that couldn't be discovered are left empty
+func Discover(kubeClient kubernetes.Interface) (types.ClusterNetwork, error) {
+	network := types.ClusterNetwork{}
+
+	apiServerPods, err := listPods(kubeClient, apiServerSelector)
+	if err != nil {
+		return network, err
+	}
+
+	controllerManagerPods, err := listPods(kubeClient, controllerManagerSelector)
+	if err != nil {
+		return network, err
+	}
+
+	kubeProxyPods, err := listPods(kubeClient, kubeProxySelector)
+	if err != nil {
+		return network, err
+	}
+
+	network.ClusterCIDRs = cidrsFromPodArgs(append(controllerManagerPods, kubeProxyPods...), clusterCIDRArg)
+	if len(network.ClusterCIDRs) > 0 {
+		klog.Infof("Discovered the cluster CIDRs %v from the %s argument", network.ClusterCIDRs, clusterCIDRArg)
+	} else if network.ClusterCIDRs, err = discoverCNIClusterCIDRs(kubeClient); err != nil {
+		return network, err
+	} else if len(network.ClusterCIDRs) == 0 {
+		nodes, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{})
+		if err != nil {
+			return network, fmt.Errorf("Error listing the nodes: %v", err)
+		}
+
+		network.GuessedClusterCIDRs = cidrsFromNodes(nodes.Items)
+		if len(network.GuessedClusterCIDRs) > 0 {
+			klog.Warningf("Guessing the cluster CIDRs %v from the podCIDRs of the nodes, set the cluster CIDR explicitly "+
+				"if nodes may get podCIDRs outside of them", network.GuessedClusterCIDRs)
+		}
+	}
+
+	network.ServiceCIDRs = cidrsFromPodArgs(append(apiServerPods, controllerManagerPods...), serviceCIDRArg)
+	if len(network.ServiceCIDRs) > 0 {
+		klog.Infof("Discovered the service CIDRs %v from the %s argument", network.ServiceCIDRs, serviceCIDRArg)
+		return network, nil
+	}
+
+	service, err := kubeClient.CoreV1().Services(v1.NamespaceDefault).Get("kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return network, fmt.Errorf("Error retrieving the kubernetes service: %v", err)
+	}
+
+	if cidr := serviceCIDRFromServiceIP(service.Spec.ClusterIP); cidr != "" {
+		klog.Warningf("Guessing the default service CIDR %s, which starts with the kubernetes service IP %s, set the "+
+			"service CIDR explicitly if it's wrong", cidr, service.Spec.ClusterIP)
+		network.GuessedServiceCIDRs = []string{cidr}
+	} else {
+		klog.Warningf("The kubernetes service IP %s isn't part of a known default service CIDR", service.Spec.ClusterIP)
+	}
+	return network, nil
+}
+
+func listPods(kubeClient kubernetes.Interface, selector string) ([]v1.Pod, error) {
+	pods, err := kubeClient.CoreV1().Pods(systemNamespace).List(metav1.ListOptions{LabelSelector: selector})
+	if apierrors.IsForbidden(err) {
+		klog.Warningf("Not allowed to list the %s pods: %v", selector, err)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error listing the %s pods: %v", selector, err)
+	}
+	return pods.Items, nil
+}
+
+// discoverCNIClusterCIDRs returns the CIDRs configured in the Calico IPPools or the Flannel ConfigMap
+func discoverCNIClusterCIDRs(kubeClient kubernetes.Interface) ([]string, error) {
+	raw, err := kubeClient.CoreV1().RESTClient().Get().AbsPath(calicoIPPoolsPath).DoRaw()
+	if err == nil {
+		cidrs, err := cidrsFromCalicoIPPools(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(cidrs) > 0 {
+			klog.Infof("Discovered the cluster CIDRs %v from the Calico IPPools", cidrs)
+			return cidrs, nil
+		}
+	} else if !apierrors.IsNotFound(err) && !apierrors.IsForbidden(err) {
+		return nil, fmt.Errorf("Error listing the Calico IPPools: %v", err)
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps(systemNamespace).Get(flannelConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Error retrieving the Flannel ConfigMap: %v", err)
+	}
+
+	cidrs, err := cidrsFromFlannelConfigMap(configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cidrs) > 0 {
+		klog.Infof("Discovered the cluster CIDRs %v from the Flannel ConfigMap", cidrs)
+	}
+	return cidrs, nil
+}
+
+// cidrsFromPodArgs returns the distinct values of the argument in the commands and arguments of the pods
+func cidrsFromPodArgs(pods []v1.Pod, arg string) []string {
+	var cidrs []string
+	for i := range pods {
+		for _, container := range pods[i].Spec.Containers {
+			for _, value := range argValues(append(append([]string{}, container.Command...), container.Args...), arg) {
+				// Dual-stack clusters pass a comma separated list
+				for _, cidr := range strings.Split(value, ",") {
+					cidrs = appendValidCIDR(cidrs, strings.TrimSpace(cidr))
+				}
+			}
+		}
+	}
+	return cidrs
+}
+
+// argValues returns the values of the argument, given either as "--arg=value" or as "--arg value"
+func argValues(args []string, arg string) []string {
+	var values []string
+	for i, a := range args {
+		if strings.HasPrefix(a, arg+"=") {
+			values = append(values, strings.TrimPrefix(a, arg+"="))
+		} else if a == arg && i+1 < len(args) {
+			values = append(values, args[i+1])
+		}
+	}
+	return values
+}
+
+// cidrsFromNodes returns the smallest CIDR holding the podCIDRs of all the nodes, widened by nodeCIDRGrowthBits so
+// that it also holds the podCIDRs of the nodes added later
+func cidrsFromNodes(nodes []v1.Node) []string {
+	var cidrs []string
+	for i := range nodes {
+		cidrs = appendValidCIDR(cidrs, nodes[i].Spec.PodCIDR)
+	}
+
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	_, supernet, _ := net.ParseCIDR(cidrs[0])
+	nodePrefix, _ := supernet.Mask.Size()
+	for _, cidr := range cidrs[1:] {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		for !contains(supernet, ipNet) {
+			ones, bits := supernet.Mask.Size()
+			supernet.Mask = net.CIDRMask(ones-1, bits)
+			supernet.IP = supernet.IP.Mask(supernet.Mask)
+		}
+		if ones, _ := ipNet.Mask.Size(); ones > nodePrefix {
+			nodePrefix = ones
+		}
+	}
+
+	ones, bits := supernet.Mask.Size()
+	if widened := nodePrefix - nodeCIDRGrowthBits; widened < ones {
+		if widened < 0 {
+			widened = 0
+		}
+		supernet.Mask = net.CIDRMask(widened, bits)
+		supernet.IP = supernet.IP.Mask(supernet.Mask)
+	}
+	return []string{supernet.String()}
+}
+
+// contains returns true if the outer network holds all the addresses of the inner one
+func contains(outer, inner *net.IPNet) bool {
+	outerOnes, _ := outer.Mask.Size()
+	innerOnes, _ := inner.Mask.Size()
+	return outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+// cidrsFromCalicoIPPools returns the CIDRs of the enabled pools in an IPPoolList
+func cidrsFromCalicoIPPools(raw []byte) ([]string, error) {
+	pools := struct {
+		Items []struct {
+			Spec struct {
+				CIDR     string `json:"cidr"`
+				Disabled bool   `json:"disabled"`
+			} `json:"spec"`
+		} `json:"items"`
+	}{}
+
+	if err := json.Unmarshal(raw, &pools); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling the Calico IPPools %s: %v", string(raw), err)
+	}
+
+	var cidrs []string
+	for _, pool := range pools.Items {
+		if !pool.Spec.Disabled {
+			cidrs = appendValidCIDR(cidrs, pool.Spec.CIDR)
+		}
+	}
+	return cidrs, nil
+}
+
+// cidrsFromFlannelConfigMap returns the network of the Flannel net-conf.json
+func cidrsFromFlannelConfigMap(configMap *v1.ConfigMap) ([]string, error) {
+	netConf, ok := configMap.Data[flannelNetConfFile]
+	if !ok {
+		return nil, nil
+	}
+
+	conf := struct {
+		Network string
+	}{}
+
+	if err := json.Unmarshal([]byte(netConf), &conf); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling the Flannel %s %s: %v", flannelNetConfFile, netConf, err)
+	}
+	return appendValidCIDR(nil, conf.Network), nil
+}
+
+// serviceCIDRFromServiceIP returns the default service CIDR holding the IP of the kubernetes Service, which is
+// always the first address of the service CIDR
+func serviceCIDRFromServiceIP(serviceIP string) string {
+	ip := net.ParseIP(serviceIP)
+	if ip == nil {
+		return ""
+	}
+
+	for _, cidr := range defaultServiceCIDRs {
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		if ipNet.Contains(ip) && ip.Equal(firstAddress(ipNet)) {
+			return cidr
+		}
+	}
+	return ""
+}
+
+func firstAddress(ipNet *net.IPNet) net.IP {
+	ip := make(net.IP, len(ipNet.IP))
+	copy(ip, ipNet.IP)
+	ip[len(ip)-1]++
+	return ip
+}
+
+// appendValidCIDR appends the CIDR, in its canonical form, unless it's invalid, IPv6 or already present
+func appendValidCIDR(cidrs []string, cidr string) []string {
+	if cidr == "" {
+		return cidrs
+	}
+
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		klog.Warningf("Ignoring the invalid CIDR %q: %v", cidr, err)
+		return cidrs
+	}
+
+	if ipNet.IP.To4() == nil {
+		klog.V(2).Infof("Ignoring the IPv6 CIDR %s, only IPv4 CIDRs are supported", cidr)
+		return cidrs
+	}
+
+	for _, existing := range cidrs {
+		if existing == ipNet.String() {
+			return cidrs
+		}
+	}
+	return append(cidrs, ipNet.String())
+}
@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Discovery", func() {
+	Describe("Function cidrsFromPodArgs", func() {
+		It("should return the values of the argument in both forms", func() {
+			pods := []v1.Pod{
+				newPod([]string{"kube-controller-manager", "--cluster-cidr=10.244.0.0/16", "--v=2"}, nil),
+				newPod([]string{"/usr/local/bin/kube-proxy"}, []string{"--cluster-cidr", "10.244.0.0/16"}),
+			}
+			Expect(cidrsFromPodArgs(pods, clusterCIDRArg)).To(Equal([]string{"10.244.0.0/16"}))
+		})
+
+		It("should split comma separated values and skip invalid and IPv6 CIDRs", func() {
+			pods := []v1.Pod{
+				newPod([]string{"kube-apiserver", "--service-cluster-ip-range=10.96.0.0/12,fd00::/108,bogus"}, nil),
+			}
+			Expect(cidrsFromPodArgs(pods, serviceCIDRArg)).To(Equal([]string{"10.96.0.0/12"}))
+		})
+
+		It("should return nothing when the argument isn't set", func() {
+			Expect(cidrsFromPodArgs([]v1.Pod{newPod([]string{"kube-proxy"}, nil)}, clusterCIDRArg)).To(BeEmpty())
+		})
+	})
+
+	Describe("Function cidrsFromNodes", func() {
+		It("should return a CIDR holding the podCIDRs of the current and future nodes", func() {
+			nodes := []v1.Node{
+				{Spec: v1.NodeSpec{PodCIDR: "10.244.0.0/24"}},
+				{Spec: v1.NodeSpec{PodCIDR: "10.244.1.0/24"}},
+				{Spec: v1.NodeSpec{PodCIDR: "10.244.1.0/24"}},
+				{},
+			}
+			Expect(cidrsFromNodes(nodes)).To(Equal([]string{"10.244.0.0/16"}))
+		})
+
+		It("should widen the CIDR to hold podCIDRs far apart", func() {
+			nodes := []v1.Node{
+				{Spec: v1.NodeSpec{PodCIDR: "10.244.0.0/24"}},
+				{Spec: v1.NodeSpec{PodCIDR: "10.246.3.0/24"}},
+			}
+			Expect(cidrsFromNodes(nodes)).To(Equal([]string{"10.244.0.0/14"}))
+		})
+
+		It("should return nothing without IPv4 podCIDRs", func() {
+			nodes := []v1.Node{{Spec: v1.NodeSpec{PodCIDR: "fd00:10:244::/64"}}, {}}
+			Expect(cidrsFromNodes(nodes)).To(BeEmpty())
+		})
+	})
+
+	Describe("Function cidrsFromCalicoIPPools", func() {
+		It("should return the CIDRs of the enabled pools", func() {
+			raw := []byte(`{"kind": "IPPoolList", "items": [
+				{"spec": {"cidr": "192.168.0.0/16"}},
+				{"spec": {"cidr": "172.16.0.0/16", "disabled": true}}]}`)
+			Expect(cidrsFromCalicoIPPools(raw)).To(Equal([]string{"192.168.0.0/16"}))
+		})
+
+		It("should return an error for malformed lists", func() {
+			_, err := cidrsFromCalicoIPPools([]byte("{"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Function cidrsFromFlannelConfigMap", func() {
+		It("should return the network of the configuration", func() {
+			configMap := &v1.ConfigMap{Data: map[string]string{
+				flannelNetConfFile: `{"Network": "10.244.0.0/16", "Backend": {"Type": "vxlan"}}`,
+			}}
+			Expect(cidrsFromFlannelConfigMap(configMap)).To(Equal([]string{"10.244.0.0/16"}))
+		})
+
+		It("should return nothing without a configuration", func() {
+			Expect(cidrsFromFlannelConfigMap(&v1.ConfigMap{})).To(BeEmpty())
+		})
+	})
+
+	Describe("Function serviceCIDRFromServiceIP", func() {
+		It("should return the default service CIDR starting with the IP", func() {
+			Expect(serviceCIDRFromServiceIP("10.96.0.1")).To(Equal("10.96.0.0/12"))
+			Expect(serviceCIDRFromServiceIP("10.43.0.1")).To(Equal("10.43.0.0/16"))
+			Expect(serviceCIDRFromServiceIP("172.30.0.1")).To(Equal("172.30.0.0/16"))
+		})
+
+		It("should return nothing for other IPs", func() {
+			Expect(serviceCIDRFromServiceIP("10.100.0.1")).To(BeEmpty())
+			Expect(serviceCIDRFromServiceIP("10.96.0.10")).To(BeEmpty())
+			Expect(serviceCIDRFromServiceIP("")).To(BeEmpty())
+		})
+	})
+})
+
+func newPod(command, args []string) v1.Pod {
+	return v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Command: command, Args: args}},
+		},
+	}
+}
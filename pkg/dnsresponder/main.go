@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/rancher/submariner/pkg/dnsresponder/responder"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	submarinerClientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
+	submarinerInformers "github.com/rancher/submariner/pkg/client/informers/externalversions"
+	"github.com/rancher/submariner/pkg/signals"
+)
+
+var (
+	masterURL  string
+	kubeconfig string
+)
+
+type SubmarinerDNSResponderSpecification struct {
+	// The submariner namespace holding the ServiceImport objects
+	Namespace string
+	Domain    string `default:"clusterset.local"`
+	Port      int    `default:"53"`
+	// The TTL of the answers, in seconds
+	TTL uint32 `default:"5"`
+}
+
+func main() {
+	klog.InitFlags(nil)
+	flag.Parse()
+	var sdrs SubmarinerDNSResponderSpecification
+
+	err := envconfig.Process("submariner_dns", &sdrs)
+	if err != nil {
+		klog.Fatal(err)
+	}
+
+	klog.V(2).Info("Starting submariner-dns-responder")
+	// set up signals so we handle the first shutdown signal gracefully
+	stopCh := signals.SetupSignalHandler()
+
+	cfg, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfig)
+	if err != nil {
+		klog.Fatalf("Error building kubeconfig: %s", err.Error())
+	}
+
+	submarinerClient, err := submarinerClientset.NewForConfig(cfg)
+	if err != nil {
+		klog.Fatalf("Error building submariner clientset: %s", err.Error())
+	}
+
+	submarinerInformerFactory := submarinerInformers.NewSharedInformerFactoryWithOptions(submarinerClient, time.Second*30,
+		submarinerInformers.WithNamespace(sdrs.Namespace))
+	serviceImportInformer := submarinerInformerFactory.Submariner().V1().ServiceImports()
+
+	r := responder.NewResponder(serviceImportInformer.Lister().ServiceImports(sdrs.Namespace), sdrs.Domain, sdrs.TTL)
+
+	submarinerInformerFactory.Start(stopCh)
+
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(stopCh, serviceImportInformer.Informer().HasSynced); !ok {
+		klog.Fatal("Failed to wait for caches to sync")
+	}
+
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", sdrs.Port))
+	if err != nil {
+		klog.Fatalf("Error listening on UDP port %d: %v", sdrs.Port, err)
+	}
+
+	go func() {
+		<-stopCh
+		klog.Info("Shutting down the DNS responder")
+		if err := conn.Close(); err != nil {
+			klog.Errorf("Error closing the DNS responder connection: %v", err)
+		}
+	}()
+
+	klog.Infof("Answering queries for %s on UDP port %d", sdrs.Domain, sdrs.Port)
+	err = r.Serve(conn)
+	select {
+	case <-stopCh:
+	default:
+		klog.Fatalf("Error serving DNS queries: %v", err)
+	}
+}
+
+func init() {
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig. Only required if out-of-cluster.")
+	flag.StringVar(&masterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+}
@@ -0,0 +1,174 @@
+package responder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+ * A minimal DNS message codec, covering what the responder needs: parsing queries with a single question and
+ * building responses with A records. Additional records in queries, such as the EDNS OPT record, are ignored.
+ */
+
+const (
+	typeA   uint16 = 1
+	typeANY uint16 = 255
+
+	classINET uint16 = 1
+	classANY  uint16 = 255
+
+	rcodeSuccess        = 0
+	rcodeFormatError    = 1
+	rcodeServerFailure  = 2
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+	rcodeRefused        = 5
+
+	flagResponse         = 1 << 15
+	flagAuthoritative    = 1 << 10
+	flagTruncated        = 1 << 9
+	flagRecursionDesired = 1 << 8
+
+	headerLength = 12
+	// The largest DNS message carried over UDP without EDNS
+	maxUDPMessageLength = 512
+	// The length of an A record using a compression pointer to the question name
+	aRecordLength = 16
+	maxNameLength = 255
+)
+
+type header struct {
+	id      uint16
+	flags   uint16
+	qdCount uint16
+	anCount uint16
+	nsCount uint16
+	arCount uint16
+}
+
+func (h header) opcode() int {
+	return int(h.flags>>11) & 0xF
+}
+
+type question struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+	// The question as it appeared in the query, which is echoed in the response
+	raw []byte
+}
+
+// parseQuery returns the header of the query and its question, if it has exactly one
+func parseQuery(msg []byte) (header, *question, error) {
+	if len(msg) < headerLength {
+		return header{}, nil, fmt.Errorf("the message is shorter than a DNS header")
+	}
+
+	h := header{
+		id:      binary.BigEndian.Uint16(msg[0:]),
+		flags:   binary.BigEndian.Uint16(msg[2:]),
+		qdCount: binary.BigEndian.Uint16(msg[4:]),
+		anCount: binary.BigEndian.Uint16(msg[6:]),
+		nsCount: binary.BigEndian.Uint16(msg[8:]),
+		arCount: binary.BigEndian.Uint16(msg[10:]),
+	}
+
+	if h.qdCount != 1 {
+		return h, nil, nil
+	}
+
+	name, offset, err := parseName(msg, headerLength)
+	if err != nil {
+		return h, nil, err
+	}
+
+	if len(msg) < offset+4 {
+		return h, nil, fmt.Errorf("the question of the message is truncated")
+	}
+
+	return h, &question{
+		name:   name,
+		qtype:  binary.BigEndian.Uint16(msg[offset:]),
+		qclass: binary.BigEndian.Uint16(msg[offset+2:]),
+		raw:    msg[headerLength : offset+4],
+	}, nil
+}
+
+// parseName returns the lower-cased, fully qualified name starting at offset and the offset following it.
+// Compression pointers are rejected since they aren't used in the question of queries.
+func parseName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	length := 0
+	for {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("the name at offset %d is truncated", offset)
+		}
+
+		labelLength := int(msg[offset])
+		offset++
+		if labelLength == 0 {
+			break
+		}
+
+		if labelLength > 63 {
+			return "", 0, fmt.Errorf("unsupported label type or length %d", labelLength)
+		}
+
+		if offset+labelLength > len(msg) {
+			return "", 0, fmt.Errorf("the label at offset %d is truncated", offset)
+		}
+
+		length += labelLength + 1
+		if length > maxNameLength {
+			return "", 0, fmt.Errorf("the name is longer than %d bytes", maxNameLength)
+		}
+
+		labels = append(labels, strings.ToLower(string(msg[offset:offset+labelLength])))
+		offset += labelLength
+	}
+
+	return strings.Join(labels, ".") + ".", offset, nil
+}
+
+// buildResponse answers the query with the given response code and A records, as many of the records as fit in a
+// UDP message are included. The response isn't flagged as truncated when some didn't fit, since the clients would
+// retry over TCP, which the responder doesn't serve.
+func buildResponse(query header, q *question, rcode int, ips []net.IP, ttl uint32) []byte {
+	flags := uint16(flagResponse|flagAuthoritative) | query.flags&(0xF<<11) | query.flags&flagRecursionDesired |
+		uint16(rcode)
+
+	var raw []byte
+	if q != nil {
+		raw = q.raw
+		if maxAnswers := (maxUDPMessageLength - headerLength - len(raw)) / aRecordLength; len(ips) > maxAnswers {
+			ips = ips[:maxAnswers]
+		}
+	} else {
+		ips = nil
+	}
+
+	msg := make([]byte, headerLength, headerLength+len(raw)+len(ips)*aRecordLength)
+	binary.BigEndian.PutUint16(msg[0:], query.id)
+	binary.BigEndian.PutUint16(msg[2:], flags)
+	if q != nil {
+		binary.BigEndian.PutUint16(msg[4:], 1)
+	}
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(ips)))
+	msg = append(msg, raw...)
+
+	for _, ip := range ips {
+		record := make([]byte, aRecordLength)
+		// A pointer to the question name, which always follows the header
+		binary.BigEndian.PutUint16(record[0:], 0xC000|headerLength)
+		binary.BigEndian.PutUint16(record[2:], typeA)
+		binary.BigEndian.PutUint16(record[4:], classINET)
+		binary.BigEndian.PutUint32(record[6:], ttl)
+		binary.BigEndian.PutUint16(record[10:], 4)
+		copy(record[12:], ip.To4())
+		msg = append(msg, record...)
+	}
+
+	return msg
+}
@@ -0,0 +1,148 @@
+package responder
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog"
+)
+
+/*
+ * The responder answers A queries for <service>.<namespace>.svc.<domain> with the IPs of the service in all the
+ * clusters exporting it, as recorded in the local ServiceImport CRDs maintained by the service discovery
+ * controller. It's meant to be the target of a stub domain or forward zone of the cluster DNS.
+ */
+
+type Responder struct {
+	lister submarinerListers.ServiceImportNamespaceLister
+	// The fully qualified domain the responder is authoritative for
+	domain string
+	ttl    uint32
+}
+
+func NewResponder(lister submarinerListers.ServiceImportNamespaceLister, domain string, ttl uint32) *Responder {
+	return &Responder{
+		lister: lister,
+		domain: strings.ToLower(strings.TrimSuffix(domain, ".")) + ".",
+		ttl:    ttl,
+	}
+}
+
+// Resolve returns the IPs exported for the service the name refers to, and whether any cluster exports it
+func (r *Responder) Resolve(name string) ([]net.IP, bool, error) {
+	namespace, serviceName, ok := r.parseServiceName(name)
+	if !ok {
+		return nil, false, nil
+	}
+
+	serviceImports, err := r.lister.List(labels.Everything())
+	if err != nil {
+		return nil, false, fmt.Errorf("Error listing the ServiceImports: %v", err)
+	}
+
+	// The imports are ordered by cluster so that the answers are stable
+	sort.Slice(serviceImports, func(i, j int) bool {
+		return serviceImports[i].Spec.ClusterID < serviceImports[j].Spec.ClusterID
+	})
+
+	var ips []net.IP
+	found := false
+	for _, serviceImport := range serviceImports {
+		if serviceImport.Spec.Namespace != namespace || serviceImport.Spec.ServiceName != serviceName {
+			continue
+		}
+
+		found = true
+		for _, ip := range serviceImport.Spec.IPs {
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				ips = append(ips, parsed)
+			}
+		}
+	}
+	return ips, found, nil
+}
+
+// parseServiceName splits a <service>.<namespace>.svc.<domain> name
+func (r *Responder) parseServiceName(name string) (string, string, bool) {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, "."+r.domain) {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.TrimSuffix(name, "."+r.domain), ".")
+	if len(parts) != 3 || parts[2] != "svc" || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[0], true
+}
+
+func (r *Responder) inDomain(name string) bool {
+	return name == r.domain || strings.HasSuffix(name, "."+r.domain)
+}
+
+// Respond returns the response to the query, or nil if the message is too malformed to be answered
+func (r *Responder) Respond(query []byte) []byte {
+	h, q, err := parseQuery(query)
+	if err != nil {
+		klog.V(4).Infof("Error parsing DNS query: %v", err)
+		if len(query) < headerLength {
+			return nil
+		}
+		return buildResponse(h, nil, rcodeFormatError, nil, 0)
+	}
+
+	if h.flags&flagResponse != 0 {
+		return nil
+	}
+
+	switch {
+	case h.opcode() != 0:
+		return buildResponse(h, q, rcodeNotImplemented, nil, 0)
+	case q == nil:
+		return buildResponse(h, nil, rcodeFormatError, nil, 0)
+	case !r.inDomain(q.name) || q.qclass != classINET && q.qclass != classANY:
+		return buildResponse(h, q, rcodeRefused, nil, 0)
+	}
+
+	ips, found, err := r.Resolve(q.name)
+	if err != nil {
+		klog.Errorf("Error resolving %s: %v", q.name, err)
+		return buildResponse(h, q, rcodeServerFailure, nil, 0)
+	}
+
+	klog.V(6).Infof("Resolved %s to %v", q.name, ips)
+	if !found {
+		return buildResponse(h, q, rcodeNameError, nil, 0)
+	}
+
+	// The name exists, so other types of queries get an empty answer rather than an error
+	if q.qtype != typeA && q.qtype != typeANY {
+		ips = nil
+	}
+	return buildResponse(h, q, rcodeSuccess, ips, r.ttl)
+}
+
+// Serve answers the queries received on the connection until it's closed
+func (r *Responder) Serve(conn net.PacketConn) error {
+	// Queries carrying EDNS records may be larger than the responses we send
+	buffer := make([]byte, 4096)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+
+		response := r.Respond(buffer[:n])
+		if response == nil {
+			continue
+		}
+
+		if _, err = conn.WriteTo(response, addr); err != nil {
+			klog.Errorf("Error sending DNS response to %s: %v", addr, err)
+		}
+	}
+}
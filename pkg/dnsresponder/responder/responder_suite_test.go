@@ -0,0 +1,13 @@
+package responder
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestResponder(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DNS Responder Suite")
+}
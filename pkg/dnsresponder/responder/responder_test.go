@@ -0,0 +1,198 @@
+package responder
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	submarinerListers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const namespace = "submariner"
+
+var _ = Describe("DNS responder", func() {
+	var responder *Responder
+	var serviceImports cache.Indexer
+
+	BeforeEach(func() {
+		serviceImports = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		Expect(serviceImports.Add(newServiceImport("west", "default", "nginx", "10.96.0.10"))).To(Succeed())
+		Expect(serviceImports.Add(newServiceImport("north", "default", "nginx", "10.97.0.10"))).To(Succeed())
+		Expect(serviceImports.Add(newServiceImport("west", "other", "nginx", "10.96.0.20"))).To(Succeed())
+		responder = NewResponder(submarinerListers.NewServiceImportLister(serviceImports).ServiceImports(namespace),
+			"clusterset.local", 5)
+	})
+
+	Describe("Function Resolve", func() {
+		It("Should return the IPs of the service in all the clusters exporting it", func() {
+			ips, found, err := responder.Resolve("nginx.default.svc.clusterset.local.")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(ips).To(Equal([]net.IP{net.ParseIP("10.97.0.10"), net.ParseIP("10.96.0.10")}))
+		})
+
+		It("Should not find services that aren't exported", func() {
+			_, found, err := responder.Resolve("httpd.default.svc.clusterset.local.")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+
+		It("Should not find names that don't refer to a service", func() {
+			_, found, err := responder.Resolve("default.svc.clusterset.local.")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("Function Respond", func() {
+		Context("With an A query for an exported service", func() {
+			It("Should answer with its IPs", func() {
+				response := responder.Respond(newQuery(42, "nginx.other.svc.clusterset.local.", typeA))
+				Expect(rcode(response)).To(Equal(rcodeSuccess))
+				Expect(binary.BigEndian.Uint16(response[0:])).To(Equal(uint16(42)))
+				Expect(answers(response)).To(Equal([]string{"10.96.0.20"}))
+			})
+
+			It("Should match the name regardless of its case", func() {
+				response := responder.Respond(newQuery(42, "NGINX.Default.svc.ClusterSet.local.", typeA))
+				Expect(rcode(response)).To(Equal(rcodeSuccess))
+				Expect(answers(response)).To(Equal([]string{"10.97.0.10", "10.96.0.10"}))
+			})
+		})
+
+		Context("With an A query for a service that isn't exported", func() {
+			It("Should answer NXDOMAIN", func() {
+				response := responder.Respond(newQuery(42, "httpd.default.svc.clusterset.local.", typeA))
+				Expect(rcode(response)).To(Equal(rcodeNameError))
+				Expect(answers(response)).To(BeEmpty())
+			})
+		})
+
+		Context("With a query of another type for an exported service", func() {
+			It("Should answer without records", func() {
+				response := responder.Respond(newQuery(42, "nginx.default.svc.clusterset.local.", 28))
+				Expect(rcode(response)).To(Equal(rcodeSuccess))
+				Expect(answers(response)).To(BeEmpty())
+			})
+		})
+
+		Context("With a query for a name outside the domain", func() {
+			It("Should refuse it", func() {
+				response := responder.Respond(newQuery(42, "nginx.default.svc.cluster.local.", typeA))
+				Expect(rcode(response)).To(Equal(rcodeRefused))
+			})
+		})
+
+		Context("With a truncated query", func() {
+			It("Should answer FORMERR", func() {
+				query := newQuery(42, "nginx.default.svc.clusterset.local.", typeA)
+				response := responder.Respond(query[:len(query)-6])
+				Expect(rcode(response)).To(Equal(rcodeFormatError))
+			})
+		})
+
+		Context("With a message shorter than a header", func() {
+			It("Should not answer", func() {
+				Expect(responder.Respond([]byte{0, 42})).To(BeNil())
+			})
+		})
+
+		Context("With more records than fit in a UDP message", func() {
+			It("Should answer with the records that fit without flagging the response as truncated", func() {
+				serviceImport := newServiceImport("south", "default", "nginx", "10.98.0.1")
+				for i := 2; i <= 40; i++ {
+					serviceImport.Spec.IPs = append(serviceImport.Spec.IPs, net.IPv4(10, 98, 0, byte(i)).String())
+				}
+				Expect(serviceImports.Add(serviceImport)).To(Succeed())
+
+				response := responder.Respond(newQuery(42, "nginx.default.svc.clusterset.local.", typeA))
+				Expect(rcode(response)).To(Equal(rcodeSuccess))
+				Expect(len(response)).To(BeNumerically("<=", maxUDPMessageLength))
+				Expect(binary.BigEndian.Uint16(response[2:]) & flagTruncated).To(BeZero())
+				Expect(len(answers(response))).To(BeNumerically("<", 42))
+			})
+		})
+	})
+
+	Describe("Function Serve", func() {
+		It("Should answer queries received over UDP", func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+			go func() {
+				defer GinkgoRecover()
+				_ = responder.Serve(conn)
+			}()
+
+			client, err := net.Dial("udp", conn.LocalAddr().String())
+			Expect(err).ToNot(HaveOccurred())
+			defer client.Close()
+
+			_, err = client.Write(newQuery(7, "nginx.other.svc.clusterset.local.", typeA))
+			Expect(err).ToNot(HaveOccurred())
+
+			response := make([]byte, maxUDPMessageLength)
+			n, err := client.Read(response)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(answers(response[:n])).To(Equal([]string{"10.96.0.20"}))
+		})
+	})
+})
+
+func newServiceImport(clusterID, serviceNamespace, serviceName, ip string) *submarinerv1.ServiceImport {
+	return &submarinerv1.ServiceImport{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      clusterID + "-" + serviceNamespace + "-" + serviceName,
+		},
+		Spec: submarinerv1.ServiceImportSpec{
+			ClusterID:   clusterID,
+			Namespace:   serviceNamespace,
+			ServiceName: serviceName,
+			IPs:         []string{ip},
+		},
+	}
+}
+
+func newQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, headerLength)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], flagRecursionDesired)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	binary.BigEndian.PutUint16(msg[len(msg)-2:], classINET)
+	return msg
+}
+
+func rcode(response []byte) int {
+	Expect(len(response)).To(BeNumerically(">=", headerLength))
+	return int(binary.BigEndian.Uint16(response[2:]) & 0xF)
+}
+
+// answers returns the addresses of the A records of the response
+func answers(response []byte) []string {
+	_, q, err := parseQuery(response)
+	Expect(err).ToNot(HaveOccurred())
+
+	ips := []string{}
+	offset := headerLength + len(q.raw)
+	for i := 0; i < int(binary.BigEndian.Uint16(response[6:])); i++ {
+		Expect(binary.BigEndian.Uint16(response[offset:])).To(Equal(uint16(0xC000 | headerLength)))
+		Expect(binary.BigEndian.Uint16(response[offset+2:])).To(Equal(typeA))
+		ips = append(ips, net.IP(response[offset+12:offset+16]).String())
+		offset += aRecordLength
+	}
+	Expect(offset).To(Equal(len(response)))
+	return ips
+}
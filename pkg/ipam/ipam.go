@@ -0,0 +1,266 @@
+package ipam
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"net"
+	"sync"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/netutil"
+	"github.com/rancher/submariner/pkg/types"
+	"k8s.io/klog"
+)
+
+// The IPAM pools from which the broker hands out CIDR blocks to the clusters
+const (
+	ClusterPool = "cluster"
+	ServicePool = "service"
+	GlobalPool  = "global"
+)
+
+// The number of times a reservation is attempted again after losing a race against another cluster
+const maxReserveAttempts = 5
+
+// NextAvailableCIDR returns the first block of size addresses in the pool CIDR that doesn't overlap any of the
+// allocated CIDRs. The size must be a power of two.
+func NextAvailableCIDR(pool string, size int, allocated []string) (string, error) {
+	_, poolNet, err := net.ParseCIDR(pool)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR range %q: %v", pool, err)
+	}
+
+	if poolNet.IP.To4() == nil {
+		return "", fmt.Errorf("the CIDR range %q is not an IPv4 CIDR", pool)
+	}
+
+	if size < 4 || size&(size-1) != 0 {
+		return "", fmt.Errorf("the block size %d is not a power of two of at least 4", size)
+	}
+
+	ones, _ := poolNet.Mask.Size()
+	prefix := 32 - (bits.Len(uint(size)) - 1)
+	if prefix < ones {
+		return "", fmt.Errorf("the block size %d is larger than the CIDR range %q", size, pool)
+	}
+
+	start := ipToUint32(poolNet.IP)
+	for i := 0; i < 1<<uint(prefix-ones); i++ {
+		cidr := fmt.Sprintf("%s/%d", uint32ToIP(start+uint32(i*size)), prefix)
+		overlaps, err := netutil.IsOverlappingCIDR(allocated, cidr)
+		if err != nil {
+			return "", fmt.Errorf("error checking %s against the allocated CIDRs %v: %v", cidr, allocated, err)
+		}
+
+		if !overlaps {
+			return cidr, nil
+		}
+	}
+
+	return "", fmt.Errorf("the CIDR range %q has no room left for a block of size %d", pool, size)
+}
+
+// ReserveCIDR returns the block of the pool reserved by the cluster in the central datastore. A cluster which
+// has no reservation yet gets the next block of poolCIDR that doesn't overlap the blocks reserved by the other
+// clusters, nor the CIDRs of the clusters it connects to. The cluster and service pools are checked against each
+// other since the pod and service CIDRs of all the clusters must be distinct.
+func ReserveCIDR(ds datastore.Datastore, clusterID string, colorCodes []string, pool, poolCIDR string, size int) (string, error) {
+	pools := []string{pool}
+	if pool == ClusterPool || pool == ServicePool {
+		pools = []string{ClusterPool, ServicePool}
+	}
+
+	for attempt := 0; ; attempt++ {
+		reservations, err := ds.GetCIDRReservations(pool)
+		if err != nil {
+			return "", datastore.Wrapf(err, "Error retrieving the reservations of the %s pool", pool)
+		}
+
+		for _, reservation := range reservations {
+			if reservation.Spec.ClusterID == clusterID {
+				klog.Infof("Using the %s CIDR %s already reserved by cluster %s", pool, reservation.Spec.CIDR, clusterID)
+				return reservation.Spec.CIDR, nil
+			}
+		}
+
+		allocated, err := allocatedCIDRs(ds, clusterID, colorCodes, pools)
+		if err != nil {
+			return "", err
+		}
+
+		cidr, err := NextAvailableCIDR(poolCIDR, size, allocated)
+		if err != nil {
+			return "", fmt.Errorf("Error allocating a block of the %s pool: %v", pool, err)
+		}
+
+		err = ds.ReserveCIDR(&types.SubmarinerCIDRReservation{
+			Spec: submarinerv1.CIDRReservationSpec{ClusterID: clusterID, Pool: pool, CIDR: cidr},
+		})
+		if err == nil {
+			klog.Infof("Reserved the %s CIDR %s for cluster %s", pool, cidr, clusterID)
+			return cidr, nil
+		}
+
+		if !datastore.IsConflict(err) || attempt >= maxReserveAttempts {
+			return "", datastore.Wrapf(err, "Error reserving the %s CIDR %s for cluster %s", pool, cidr, clusterID)
+		}
+
+		klog.V(4).Infof("The %s CIDR %s was reserved by another cluster meanwhile, retrying", pool, cidr)
+	}
+}
+
+// allocatedCIDRs returns the blocks of the given pools reserved by all the clusters, along with the matching CIDRs
+// of the other clusters sharing a color code, which may predate the reservations
+func allocatedCIDRs(ds datastore.Datastore, clusterID string, colorCodes, pools []string) ([]string, error) {
+	var allocated []string
+	for _, pool := range pools {
+		reservations, err := ds.GetCIDRReservations(pool)
+		if err != nil {
+			return nil, datastore.Wrapf(err, "Error retrieving the reservations of the %s pool", pool)
+		}
+
+		for _, reservation := range reservations {
+			allocated = append(allocated, reservation.Spec.CIDR)
+		}
+	}
+
+	clusters, err := ds.GetClusters(colorCodes)
+	if err != nil {
+		return nil, datastore.Wrapf(err, "Error retrieving the clusters from the central datastore")
+	}
+
+	for _, cluster := range clusters {
+		if cluster.ID == clusterID {
+			continue
+		}
+
+		for _, pool := range pools {
+			switch pool {
+			case ClusterPool:
+				allocated = append(allocated, cluster.Spec.ClusterCIDR...)
+			case ServicePool:
+				allocated = append(allocated, cluster.Spec.ServiceCIDR...)
+			case GlobalPool:
+				allocated = append(allocated, cluster.Spec.GlobalCIDR...)
+			}
+		}
+	}
+	return allocated, nil
+}
+
+// AllocateClusterGlobalCIDR returns the global CIDR of the cluster. A cluster which already registered a global
+// CIDR in the central datastore keeps it, otherwise one is reserved from the global pool.
+func AllocateClusterGlobalCIDR(ds datastore.Datastore, clusterID string, colorCodes []string, pool string, size int) (string, error) {
+	cluster, err := ds.GetCluster(clusterID)
+	if err == nil && len(cluster.Spec.GlobalCIDR) > 0 {
+		klog.Infof("Using the global CIDR %s already allocated to cluster %s", cluster.Spec.GlobalCIDR[0], clusterID)
+		return cluster.Spec.GlobalCIDR[0], nil
+	} else if err != nil && !datastore.IsNotFound(err) {
+		return "", datastore.Wrapf(err, "Error retrieving cluster %s from the central datastore", clusterID)
+	}
+
+	return ReserveCIDR(ds, clusterID, colorCodes, GlobalPool, pool, size)
+}
+
+// IPPool hands out the addresses of a global CIDR, excluding its network and broadcast addresses
+type IPPool struct {
+	sync.Mutex
+	cidr      *net.IPNet
+	first     uint32
+	size      uint32
+	allocated map[uint32]bool
+	next      uint32
+}
+
+func NewIPPool(cidr string) (*IPPool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid global CIDR %q: %v", cidr, err)
+	}
+
+	if ipNet.IP.To4() == nil {
+		return nil, fmt.Errorf("the global CIDR %q is not an IPv4 CIDR", cidr)
+	}
+
+	ones, total := ipNet.Mask.Size()
+	if total-ones < 2 {
+		return nil, fmt.Errorf("the global CIDR %q is too small", cidr)
+	}
+
+	return &IPPool{
+		cidr:      ipNet,
+		first:     ipToUint32(ipNet.IP) + 1,
+		size:      uint32(1)<<uint(total-ones) - 2,
+		allocated: map[uint32]bool{},
+	}, nil
+}
+
+// Allocate returns a free address from the pool
+func (p *IPPool) Allocate() (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i := uint32(0); i < p.size; i++ {
+		offset := (p.next + i) % p.size
+		if !p.allocated[offset] {
+			p.allocated[offset] = true
+			p.next = (offset + 1) % p.size
+			return uint32ToIP(p.first + offset).String(), nil
+		}
+	}
+	return "", fmt.Errorf("the global CIDR %s has no free addresses left", p.cidr)
+}
+
+// Reserve marks an address that was allocated earlier, e.g. before a restart, as used. It fails if the address
+// isn't part of the pool or is already in use.
+func (p *IPPool) Reserve(ip string) error {
+	offset, err := p.offset(ip)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if p.allocated[offset] {
+		return fmt.Errorf("the global IP %s is already in use", ip)
+	}
+	p.allocated[offset] = true
+	return nil
+}
+
+// Release returns an address to the pool, addresses that aren't part of the pool are ignored
+func (p *IPPool) Release(ip string) {
+	offset, err := p.offset(ip)
+	if err != nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	delete(p.allocated, offset)
+}
+
+func (p *IPPool) offset(ip string) (uint32, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.To4() == nil {
+		return 0, fmt.Errorf("invalid global IP %q", ip)
+	}
+
+	value := ipToUint32(parsed)
+	if !p.cidr.Contains(parsed) || value < p.first || value-p.first >= p.size {
+		return 0, fmt.Errorf("the global IP %s is not an address of %s", ip, p.cidr)
+	}
+	return value - p.first, nil
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(value uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, value)
+	return ip
+}
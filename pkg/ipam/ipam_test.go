@@ -0,0 +1,170 @@
+package ipam_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/datastore/inmemory"
+	"github.com/rancher/submariner/pkg/ipam"
+	"github.com/rancher/submariner/pkg/types"
+)
+
+var _ = Describe("IPAM", func() {
+	Describe("Function NextAvailableCIDR", func() {
+		Context("When no CIDR is allocated", func() {
+			It("Should return the first block of the pool", func() {
+				Expect(ipam.NextAvailableCIDR("169.254.0.0/16", 8192, nil)).To(Equal("169.254.0.0/19"))
+			})
+		})
+
+		Context("When CIDRs are allocated", func() {
+			It("Should return the first block that doesn't overlap them", func() {
+				Expect(ipam.NextAvailableCIDR("169.254.0.0/16", 8192, []string{"169.254.0.0/19", "169.254.40.0/24"})).
+					To(Equal("169.254.64.0/19"))
+			})
+		})
+
+		Context("When the pool is exhausted", func() {
+			It("Should return an error", func() {
+				_, err := ipam.NextAvailableCIDR("169.254.0.0/18", 8192, []string{"169.254.0.0/19", "169.254.32.0/19"})
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("When the size isn't a power of two", func() {
+			It("Should return an error", func() {
+				_, err := ipam.NextAvailableCIDR("169.254.0.0/16", 1000, nil)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("Function AllocateClusterGlobalCIDR", func() {
+		var broker *inmemory.Datastore
+
+		BeforeEach(func() {
+			broker = inmemory.NewDatastore()
+			Expect(broker.SetCluster(newCluster("east", "blue", "169.254.0.0/19"))).To(Succeed())
+			Expect(broker.SetCluster(newCluster("north", "red", "169.254.32.0/19"))).To(Succeed())
+		})
+
+		Context("When the cluster has no global CIDR yet", func() {
+			It("Should allocate one that isn't used by the clusters sharing its color codes", func() {
+				Expect(ipam.AllocateClusterGlobalCIDR(broker, "west", []string{"blue"}, "169.254.0.0/16", 8192)).
+					To(Equal("169.254.32.0/19"))
+			})
+		})
+
+		Context("When the cluster already registered a global CIDR", func() {
+			It("Should keep it", func() {
+				Expect(broker.SetCluster(newCluster("west", "blue", "169.254.96.0/19"))).To(Succeed())
+				Expect(ipam.AllocateClusterGlobalCIDR(broker, "west", []string{"blue"}, "169.254.0.0/16", 8192)).
+					To(Equal("169.254.96.0/19"))
+			})
+		})
+	})
+
+	Describe("Function ReserveCIDR", func() {
+		var broker *inmemory.Datastore
+
+		BeforeEach(func() {
+			broker = inmemory.NewDatastore()
+		})
+
+		Context("When no block is reserved", func() {
+			It("Should reserve the first block of the pool in the datastore", func() {
+				Expect(ipam.ReserveCIDR(broker, "west", nil, ipam.ClusterPool, "10.128.0.0/12", 65536)).
+					To(Equal("10.128.0.0/16"))
+
+				reservations, err := broker.GetCIDRReservations(ipam.ClusterPool)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reservations).To(HaveLen(1))
+				Expect(reservations[0].Spec.ClusterID).To(Equal("west"))
+			})
+		})
+
+		Context("When the cluster already reserved a block", func() {
+			It("Should keep it", func() {
+				Expect(ipam.ReserveCIDR(broker, "west", nil, ipam.ClusterPool, "10.128.0.0/12", 65536)).
+					To(Equal("10.128.0.0/16"))
+				Expect(ipam.ReserveCIDR(broker, "west", nil, ipam.ClusterPool, "10.128.0.0/12", 65536)).
+					To(Equal("10.128.0.0/16"))
+			})
+		})
+
+		Context("When other clusters reserved blocks of the cluster or service pools", func() {
+			It("Should reserve a block that overlaps neither", func() {
+				Expect(ipam.ReserveCIDR(broker, "east", nil, ipam.ClusterPool, "10.128.0.0/12", 65536)).
+					To(Equal("10.128.0.0/16"))
+				Expect(ipam.ReserveCIDR(broker, "east", nil, ipam.ServicePool, "10.128.0.0/12", 65536)).
+					To(Equal("10.129.0.0/16"))
+				Expect(ipam.ReserveCIDR(broker, "west", nil, ipam.ServicePool, "10.128.0.0/12", 65536)).
+					To(Equal("10.130.0.0/16"))
+			})
+		})
+
+		Context("When a connected cluster uses CIDRs that weren't reserved", func() {
+			It("Should reserve a block that doesn't overlap them", func() {
+				Expect(broker.SetCluster(&types.SubmarinerCluster{
+					ID: "east",
+					Spec: submarinerv1.ClusterSpec{
+						ClusterID:   "east",
+						ColorCodes:  []string{"blue"},
+						ClusterCIDR: []string{"10.128.0.0/16"},
+					},
+				})).To(Succeed())
+
+				Expect(ipam.ReserveCIDR(broker, "west", []string{"blue"}, ipam.ClusterPool, "10.128.0.0/12", 65536)).
+					To(Equal("10.129.0.0/16"))
+			})
+		})
+	})
+
+	Describe("IPPool", func() {
+		var pool *ipam.IPPool
+
+		BeforeEach(func() {
+			var err error
+			pool, err = ipam.NewIPPool("169.254.0.0/30")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Should hand out the host addresses of the CIDR", func() {
+			Expect(pool.Allocate()).To(Equal("169.254.0.1"))
+			Expect(pool.Allocate()).To(Equal("169.254.0.2"))
+			_, err := pool.Allocate()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Should hand out released addresses again", func() {
+			Expect(pool.Allocate()).To(Equal("169.254.0.1"))
+			Expect(pool.Allocate()).To(Equal("169.254.0.2"))
+			pool.Release("169.254.0.1")
+			Expect(pool.Allocate()).To(Equal("169.254.0.1"))
+		})
+
+		It("Should not hand out reserved addresses", func() {
+			Expect(pool.Reserve("169.254.0.1")).To(Succeed())
+			Expect(pool.Allocate()).To(Equal("169.254.0.2"))
+		})
+
+		It("Should refuse to reserve addresses in use or outside of the CIDR", func() {
+			Expect(pool.Reserve("169.254.0.1")).To(Succeed())
+			Expect(pool.Reserve("169.254.0.1")).ToNot(Succeed())
+			Expect(pool.Reserve("169.254.0.3")).ToNot(Succeed())
+			Expect(pool.Reserve("10.0.0.1")).ToNot(Succeed())
+		})
+	})
+})
+
+func newCluster(id, colorCode, globalCIDR string) *types.SubmarinerCluster {
+	return &types.SubmarinerCluster{
+		ID: id,
+		Spec: submarinerv1.ClusterSpec{
+			ClusterID:  id,
+			ColorCodes: []string{colorCode},
+			GlobalCIDR: []string{globalCIDR},
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package netutil
+
+import "net"
+
+// IsOverlappingCIDR returns true if the CIDR overlaps any of the CIDRs in the list
+func IsOverlappingCIDR(cidrList []string, cidr string) (bool, error) {
+	_, newNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range cidrList {
+		_, baseNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return false, err
+		}
+
+		if baseNet.Contains(newNet.IP) || newNet.Contains(baseNet.IP) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,13 @@
+package netutil_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestNetutil(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Netutil Suite")
+}
@@ -0,0 +1,43 @@
+package netutil_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/rancher/submariner/pkg/netutil"
+)
+
+var _ = Describe("Netutil", func() {
+	Describe("Function IsOverlappingCIDR", func() {
+		Context("with a CIDR contained in one of the list", func() {
+			It("should return true", func() {
+				overlaps, err := netutil.IsOverlappingCIDR([]string{"10.1.0.0/16", "10.10.0.0/16"}, "10.10.10.0/24")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(overlaps).To(BeTrue())
+			})
+		})
+
+		Context("with a CIDR containing one of the list", func() {
+			It("should return true", func() {
+				overlaps, err := netutil.IsOverlappingCIDR([]string{"10.10.10.0/24"}, "10.0.0.0/8")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(overlaps).To(BeTrue())
+			})
+		})
+
+		Context("with a CIDR disjoint from the list", func() {
+			It("should return false", func() {
+				overlaps, err := netutil.IsOverlappingCIDR([]string{"10.1.0.0/16", "10.2.0.0/16"}, "10.3.0.0/16")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(overlaps).To(BeFalse())
+			})
+		})
+
+		Context("with an invalid CIDR", func() {
+			It("should return an error", func() {
+				_, err := netutil.IsOverlappingCIDR([]string{"10.1.0.0/16"}, "10.3.0.0")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -0,0 +1,75 @@
+package peering
+
+import (
+	"fmt"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// IsAllowed returns whether the peering policies allow this cluster to peer with the remote cluster: it's denied
+// when a Deny policy selects it, or when Allow policies exist and none of them selects it. An invalid policy
+// returns an error, and the remote cluster must then be treated as denied.
+func IsAllowed(policies []*submarinerv1.PeeringPolicy, cluster *submarinerv1.ClusterSpec) (bool, error) {
+	hasAllowPolicies := false
+	allowed := false
+	for _, policy := range policies {
+		selected, err := selects(&policy.Spec, cluster)
+		if err != nil {
+			return false, fmt.Errorf("Error evaluating PeeringPolicy %s: %v", policy.Name, err)
+		}
+
+		switch policy.Spec.Action {
+		case submarinerv1.PeeringPolicyDeny:
+			if selected {
+				return false, nil
+			}
+		case submarinerv1.PeeringPolicyAllow:
+			hasAllowPolicies = true
+			allowed = allowed || selected
+		default:
+			return false, fmt.Errorf("PeeringPolicy %s has an unknown action %q", policy.Name, policy.Spec.Action)
+		}
+	}
+	return !hasAllowPolicies || allowed, nil
+}
+
+// selects returns whether the cluster matches all the criteria of the policy. The labels and color codes come from
+// the Cluster object the remote cluster writes itself, so it may change them to escape a Deny policy or to match an
+// Allow policy. Only the cluster ID, which the broker webhook keeps a cluster from impersonating, is authoritative.
+func selects(spec *submarinerv1.PeeringPolicySpec, cluster *submarinerv1.ClusterSpec) (bool, error) {
+	if len(spec.ClusterIDs) > 0 && !containsString(spec.ClusterIDs, cluster.ClusterID) {
+		return false, nil
+	}
+
+	if spec.ClusterSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.ClusterSelector)
+		if err != nil {
+			return false, fmt.Errorf("invalid cluster selector: %v", err)
+		}
+
+		if !selector.Matches(labels.Set(cluster.Labels)) {
+			return false, nil
+		}
+	}
+
+	if len(spec.ColorCodes) > 0 {
+		for _, colorCode := range cluster.ColorCodes {
+			if containsString(spec.ColorCodes, colorCode) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func containsString(c []string, s string) bool {
+	for _, v := range c {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package peering_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPeering(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Peering Policy Suite")
+}
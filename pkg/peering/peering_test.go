@@ -0,0 +1,106 @@
+package peering_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/peering"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("IsAllowed", func() {
+	partner := &submarinerv1.ClusterSpec{
+		ClusterID:  "partner",
+		ColorCodes: []string{"blue"},
+		Labels:     map[string]string{"env": "partner"},
+	}
+	staging := &submarinerv1.ClusterSpec{
+		ClusterID:  "staging",
+		ColorCodes: []string{"blue", "red"},
+		Labels:     map[string]string{"env": "staging"},
+	}
+
+	When("there are no policies", func() {
+		It("should allow every cluster", func() {
+			Expect(peering.IsAllowed(nil, partner)).To(BeTrue())
+		})
+	})
+
+	When("a Deny policy selects clusters by ID", func() {
+		policies := []*submarinerv1.PeeringPolicy{
+			newPolicy("deny-partner", submarinerv1.PeeringPolicySpec{
+				Action:     submarinerv1.PeeringPolicyDeny,
+				ClusterIDs: []string{"partner"},
+			}),
+		}
+
+		It("should deny the selected clusters only", func() {
+			Expect(peering.IsAllowed(policies, partner)).To(BeFalse())
+			Expect(peering.IsAllowed(policies, staging)).To(BeTrue())
+		})
+	})
+
+	When("Allow policies exist", func() {
+		policies := []*submarinerv1.PeeringPolicy{
+			newPolicy("allow-staging", submarinerv1.PeeringPolicySpec{
+				Action:          submarinerv1.PeeringPolicyAllow,
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "staging"}},
+			}),
+		}
+
+		It("should deny the clusters none of them selects", func() {
+			Expect(peering.IsAllowed(policies, staging)).To(BeTrue())
+			Expect(peering.IsAllowed(policies, partner)).To(BeFalse())
+		})
+
+		It("should let Deny policies take precedence", func() {
+			denyRed := newPolicy("deny-red", submarinerv1.PeeringPolicySpec{
+				Action:     submarinerv1.PeeringPolicyDeny,
+				ColorCodes: []string{"red"},
+			})
+			Expect(peering.IsAllowed(append(policies, denyRed), staging)).To(BeFalse())
+		})
+	})
+
+	When("a policy sets several criteria", func() {
+		policies := []*submarinerv1.PeeringPolicy{
+			newPolicy("deny-blue-partner", submarinerv1.PeeringPolicySpec{
+				Action:          submarinerv1.PeeringPolicyDeny,
+				ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "partner"}},
+				ColorCodes:      []string{"blue"},
+			}),
+		}
+
+		It("should select the clusters matching all of them", func() {
+			Expect(peering.IsAllowed(policies, partner)).To(BeFalse())
+			Expect(peering.IsAllowed(policies, staging)).To(BeTrue())
+		})
+	})
+
+	When("a policy is invalid", func() {
+		It("should return an error", func() {
+			_, err := peering.IsAllowed([]*submarinerv1.PeeringPolicy{
+				newPolicy("bogus", submarinerv1.PeeringPolicySpec{Action: "Maybe"}),
+			}, partner)
+			Expect(err).To(HaveOccurred())
+
+			_, err = peering.IsAllowed([]*submarinerv1.PeeringPolicy{
+				newPolicy("bad-selector", submarinerv1.PeeringPolicySpec{
+					Action: submarinerv1.PeeringPolicyDeny,
+					ClusterSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "env", Operator: "Near"},
+					}},
+				}),
+			}, partner)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
+
+func newPolicy(name string, spec submarinerv1.PeeringPolicySpec) *submarinerv1.PeeringPolicy {
+	return &submarinerv1.PeeringPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       spec,
+	}
+}
@@ -0,0 +1,174 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+/*
+ * The routes, rules and VXLAN interface may be removed behind the back of the route agent, by a CNI restart or an
+ * administrator. The route agent subscribes to the netlink route and link updates, and queues a repair whenever its
+ * routes are deleted or its links go down, up or away. The repair runs on the workers like the other
+ * reconciliations, and counts what it had to restore.
+ */
+
+const (
+	// The workqueue key which restores the routes, rules and VXLAN interface after an external change
+	routeRepairKey = "repair"
+	// The delay before resubscribing when the netlink updates stop
+	subscribeRetryPeriod = 5 * time.Second
+)
+
+// The drift counters of the route agent, served by MetricsHandler
+var driftCounters = newCounters()
+
+const driftMetricsName = "submariner_route_agent_drift"
+
+const (
+	driftRoutes = "routes_restored"
+	driftRules  = "rules_restored"
+	driftLinks  = "links_restored"
+)
+
+// watchNetlink queues a repair on the netlink updates which may have removed the routes, until stopCh is closed
+func (r *Controller) watchNetlink(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		done := make(chan struct{})
+		defer close(done)
+
+		routeUpdates := make(chan netlink.RouteUpdate)
+		if err := netlink.RouteSubscribe(routeUpdates, done); err != nil {
+			klog.Errorf("Error subscribing to the route updates: %v", err)
+			return
+		}
+
+		linkUpdates := make(chan netlink.LinkUpdate)
+		if err := netlink.LinkSubscribe(linkUpdates, done); err != nil {
+			klog.Errorf("Error subscribing to the link updates: %v", err)
+			return
+		}
+
+		// Anything may have changed while the updates weren't watched
+		r.workqueue.Add(routeRepairKey)
+
+		klog.Info("Watching the routes and links for external changes")
+		linkStates := map[string]bool{}
+		for {
+			select {
+			case update, ok := <-routeUpdates:
+				if !ok {
+					klog.Warning("The route updates stopped, resubscribing")
+					return
+				}
+				if isRouteDrift(update) {
+					klog.V(4).Infof("Route %s was removed, queuing a repair", update.Route.String())
+					r.workqueue.Add(routeRepairKey)
+				}
+			case update, ok := <-linkUpdates:
+				if !ok {
+					klog.Warning("The link updates stopped, resubscribing")
+					return
+				}
+				if isLinkDrift(update, []string{r.link.Name, vxlanInterface}, linkStates) {
+					klog.V(4).Infof("Link %s changed, queuing a repair", update.Attrs().Name)
+					r.workqueue.Add(routeRepairKey)
+				}
+			case <-stopCh:
+				return
+			}
+		}
+	}, subscribeRetryPeriod, stopCh)
+}
+
+// isRouteDrift returns whether the update removed a route of the submariner table
+func isRouteDrift(update netlink.RouteUpdate) bool {
+	return update.Type == syscall.RTM_DELROUTE && update.Table == routeTable
+}
+
+// isLinkDrift returns whether the update removed one of the given links or changed whether it's up, which flushes
+// the routes through it or allows restoring them, and records the state of the link
+func isLinkDrift(update netlink.LinkUpdate, names []string, linkStates map[string]bool) bool {
+	name := update.Attrs().Name
+	if !containsString(names, name) {
+		return false
+	}
+
+	if update.Header.Type == syscall.RTM_DELLINK {
+		delete(linkStates, name)
+		return true
+	}
+
+	up := update.Flags&syscall.IFF_UP != 0
+	previous, known := linkStates[name]
+	linkStates[name] = up
+	return !known || previous != up
+}
+
+// repairRoutes restores the VXLAN interface, its gateway and the routes and rules of the remote CIDR blocks, and
+// counts the ones which were missing
+func (r *Controller) repairRoutes() error {
+	r.Lock()
+	defer r.Unlock()
+
+	link, err := r.netLink.LinkByName(vxlanInterface)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		klog.Warningf("The %s interface was removed, restoring it", vxlanInterface)
+		driftCounters.Add(driftLinks, 1)
+	} else if err == nil && link.Attrs().Flags&net.FlagUp == 0 {
+		klog.Warningf("The %s interface is down, bringing it up", vxlanInterface)
+		driftCounters.Add(driftLinks, 1)
+	}
+
+	if err = r.ensureVxlanInterface(); err != nil {
+		return err
+	}
+
+	// The gateway may not be known yet, and the gateway itself has no FDB entry or routes to restore
+	if r.gw == nil {
+		return nil
+	}
+
+	if err = r.setVxlanGateway(r.vxlanGateway); err != nil {
+		return err
+	}
+
+	routes, rules, err := r.driftedCidrBlocks()
+	if err != nil {
+		return err
+	}
+
+	if len(routes) > 0 || len(rules) > 0 {
+		klog.Warningf("Restoring the routes to %v and the rules to %v, which were removed", routes, rules)
+		driftCounters.Add(driftRoutes, int64(len(routes)))
+		driftCounters.Add(driftRules, int64(len(rules)))
+	}
+	return r.reconcileRoutes()
+}
+
+// driftedCidrBlocks returns the remote CIDR blocks which are missing a route and those missing a rule
+func (r *Controller) driftedCidrBlocks() ([]string, []string, error) {
+	link, err := r.netLink.LinkByName(vxlanInterface)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error retrieving link by name %s: %v", vxlanInterface, err)
+	}
+
+	routes, err := r.listTableRoutes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error retrieving the routes of table %d: %v", routeTable, err)
+	}
+
+	rules, err := r.listTableRules()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error retrieving the rules of table %d: %v", routeTable, err)
+	}
+
+	_, missingRoutes := routesToReconcile(routes, r.subnets, r.gw, link.Attrs().Index)
+	_, missingRules := rulesToReconcile(rules, r.subnets)
+	return missingRoutes, missingRules, nil
+}
@@ -0,0 +1,114 @@
+package route
+
+import (
+	"strconv"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+var _ = Describe("Drift", func() {
+	Describe("Function isRouteDrift", func() {
+		It("Should select the removals of the routes of the submariner table", func() {
+			route := netlink.Route{Dst: parseCIDR("10.1.0.0/16"), Table: routeTable}
+			Expect(isRouteDrift(netlink.RouteUpdate{Type: syscall.RTM_DELROUTE, Route: route})).To(BeTrue())
+			Expect(isRouteDrift(netlink.RouteUpdate{Type: syscall.RTM_NEWROUTE, Route: route})).To(BeFalse())
+
+			route.Table = syscall.RT_TABLE_MAIN
+			Expect(isRouteDrift(netlink.RouteUpdate{Type: syscall.RTM_DELROUTE, Route: route})).To(BeFalse())
+		})
+	})
+
+	Describe("Function isLinkDrift", func() {
+		names := []string{"eth0", vxlanInterface}
+
+		newLinkUpdate := func(name string, msgType uint16, flags uint32) netlink.LinkUpdate {
+			update := netlink.LinkUpdate{IfInfomsg: nl.IfInfomsg{}, Link: &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{Name: name}}}
+			update.Header.Type = msgType
+			update.Flags = flags
+			return update
+		}
+
+		It("Should select the changes of the up state of the links", func() {
+			linkStates := map[string]bool{}
+			Expect(isLinkDrift(newLinkUpdate("eth0", syscall.RTM_NEWLINK, syscall.IFF_UP), names, linkStates)).To(BeTrue())
+			Expect(isLinkDrift(newLinkUpdate("eth0", syscall.RTM_NEWLINK, syscall.IFF_UP), names, linkStates)).To(BeFalse())
+			Expect(isLinkDrift(newLinkUpdate("eth0", syscall.RTM_NEWLINK, 0), names, linkStates)).To(BeTrue())
+			Expect(isLinkDrift(newLinkUpdate("eth0", syscall.RTM_NEWLINK, syscall.IFF_UP), names, linkStates)).To(BeTrue())
+		})
+
+		It("Should select the removals of the links", func() {
+			linkStates := map[string]bool{vxlanInterface: true}
+			Expect(isLinkDrift(newLinkUpdate(vxlanInterface, syscall.RTM_DELLINK, 0), names, linkStates)).To(BeTrue())
+			Expect(linkStates).To(BeEmpty())
+		})
+
+		It("Should ignore the other links", func() {
+			Expect(isLinkDrift(newLinkUpdate("eth1", syscall.RTM_DELLINK, 0), names, map[string]bool{})).To(BeFalse())
+		})
+	})
+
+	Describe("Function repairRoutes", func() {
+		useTestNetns()
+
+		var routeController *Controller
+
+		BeforeEach(func() {
+			routeController = newNetnsController()
+		})
+
+		It("Should restore the removed routes and rules and count them", func() {
+			routes, err := routeController.listTableRoutes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(routes).To(HaveLen(2))
+			rules, err := routeController.listTableRules()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rules).To(HaveLen(2))
+
+			restoredRoutes := driftCount(driftRoutes)
+			restoredRules := driftCount(driftRules)
+			routeController.deleteRoutes(routes[:1])
+			routeController.deleteRules(rules)
+
+			Expect(routeController.repairRoutes()).To(Succeed())
+
+			routes, err = routeController.listTableRoutes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(routes).To(HaveLen(2))
+			rules, err = routeController.listTableRules()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rules).To(HaveLen(2))
+			Expect(driftCount(driftRoutes)).To(Equal(restoredRoutes + 1))
+			Expect(driftCount(driftRules)).To(Equal(restoredRules + 2))
+		})
+
+		It("Should restore the removed VXLAN interface", func() {
+			link, err := netlink.LinkByName(vxlanInterface)
+			Expect(err).ToNot(HaveOccurred())
+			restoredLinks := driftCount(driftLinks)
+			Expect(netlink.LinkDel(link)).To(Succeed())
+
+			Expect(routeController.repairRoutes()).To(Succeed())
+
+			_, err = netlink.LinkByName(vxlanInterface)
+			Expect(err).ToNot(HaveOccurred())
+			routes, err := routeController.listTableRoutes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(routes).To(HaveLen(2))
+			Expect(driftCount(driftLinks)).To(Equal(restoredLinks + 1))
+		})
+	})
+})
+
+func driftCount(key string) int64 {
+	value := driftCounters.Get(key)
+	if value == nil {
+		return 0
+	}
+	count, _ := strconv.ParseInt(value.String(), 10, 64)
+	return count
+}
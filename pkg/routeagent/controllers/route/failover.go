@@ -0,0 +1,210 @@
+package route
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"time"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/vishvananda/netlink"
+	"k8s.io/klog"
+)
+
+/*
+ * When the active gateway moves to another node, the connections to the remote clusters keep their conntrack
+ * entries, which still hold the state of the previous path, and hang. The route agent flushes the conntrack entries
+ * of the remote CIDR blocks once its routes lead to the new gateway, and records how long the failover took.
+ *
+ * The routes may also be pre-installed through the standby gateways, with higher metrics than the route through the
+ * active gateway, so that the node still reaches the remote clusters while the active route is being replaced. The
+ * kernel only falls back to them once the active route is gone though: the VXLAN interface stays up when the active
+ * gateway fails, so its route isn't dropped. The route agent therefore removes the active routes as soon as the
+ * Endpoint of the active gateway is removed, rather than waiting for the Endpoint of the next gateway.
+ */
+
+const (
+	// The metric of the routes through the first standby gateway, the following standby gateways use the next ones
+	standbyRouteMetric = 100
+)
+
+// The failover counters of the route agent, served by MetricsHandler
+var failoverCounters = newCounters()
+
+const failoverMetricsName = "submariner_route_agent_failover"
+
+const (
+	failoverCount = "failovers"
+	// The time from the creation of the endpoint of the new gateway to the convergence of this node
+	failoverConvergenceMillis = "last_convergence_ms"
+	// The time this node took to update its routes and flush its conntrack entries
+	failoverUpdateMillis = "last_update_ms"
+	failoverFlushedFlows = "conntrack_flows_flushed"
+)
+
+// isFailover returns whether the gateway moved from a known host to another one
+func (r *Controller) isFailover(endpoint *v1.Endpoint) bool {
+	return r.gatewayHost != "" && r.gatewayHost != endpoint.Spec.Hostname
+}
+
+// completeFailover flushes the conntrack entries of the remote CIDR blocks once the routes lead to the new gateway,
+// and records the convergence time of the failover
+func (r *Controller) completeFailover(endpoint *v1.Endpoint, start time.Time) {
+	flushed, err := r.flushConntrack(r.subnets)
+	if err != nil {
+		klog.Errorf("Error flushing the conntrack entries of %v: %v", r.subnets, err)
+	}
+
+	updated := time.Since(start)
+	failoverCounters.Add(failoverCount, 1)
+	failoverCounters.Add(failoverFlushedFlows, int64(flushed))
+	setCounter(failoverUpdateMillis, int64(updated/time.Millisecond))
+
+	if created := endpoint.CreationTimestamp.Time; !created.IsZero() {
+		converged := time.Since(created)
+		setCounter(failoverConvergenceMillis, int64(converged/time.Millisecond))
+		klog.Infof("The gateway moved from %s to %s, converged %v after the new endpoint was created, "+
+			"%d conntrack entries flushed", r.gatewayHost, endpoint.Spec.Hostname, converged, flushed)
+	} else {
+		klog.Infof("The gateway moved from %s to %s, converged in %v, %d conntrack entries flushed",
+			r.gatewayHost, endpoint.Spec.Hostname, updated, flushed)
+	}
+}
+
+func setCounter(key string, value int64) {
+	counter := new(expvar.Int)
+	counter.Set(value)
+	failoverCounters.Set(key, counter)
+}
+
+// flushConntrack removes the conntrack entries of the connections from or to the given CIDR blocks
+func (r *Controller) flushConntrack(cidrBlocks []string) (uint, error) {
+	filter, err := newCidrConntrackFilter(cidrBlocks)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(filter.cidrBlocks) == 0 {
+		return 0, nil
+	}
+	return r.netLink.ConntrackDeleteFilter(netlink.ConntrackTable, netlink.FAMILY_V4, filter)
+}
+
+// cidrConntrackFilter matches the conntrack flows whose original source or destination is in one of its CIDR blocks
+type cidrConntrackFilter struct {
+	cidrBlocks []*net.IPNet
+}
+
+func newCidrConntrackFilter(cidrBlocks []string) (*cidrConntrackFilter, error) {
+	filter := &cidrConntrackFilter{}
+	for _, cidrBlock := range cidrBlocks {
+		_, ipNet, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing cidr block %s: %v", cidrBlock, err)
+		}
+		filter.cidrBlocks = append(filter.cidrBlocks, ipNet)
+	}
+	return filter, nil
+}
+
+func (f *cidrConntrackFilter) MatchConntrackFlow(flow *netlink.ConntrackFlow) bool {
+	for _, cidrBlock := range f.cidrBlocks {
+		if cidrBlock.Contains(flow.Forward.SrcIP) || cidrBlock.Contains(flow.Forward.DstIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// standbyGateways returns the IPs of the standby gateways while the given gateway is active, except this node
+func (r *Controller) standbyGateways(gatewayIP net.IP) []net.IP {
+	if gatewayIP == nil {
+		return nil
+	}
+
+	var standby []net.IP
+	for _, ip := range r.standbyGatewayIPs {
+		if ip.Equal(gatewayIP) || ip.Equal(r.nodeIP) {
+			continue
+		}
+
+		if r.overlayIPCollides(ip) {
+			klog.Errorf("The overlay IP %s of the standby gateway %s is the one of this node %s, skipping it",
+				vxlanIP(ip), ip, r.nodeIP)
+			continue
+		}
+		standby = append(standby, ip)
+	}
+	return standby
+}
+
+// withdrawActiveRoutes removes the routes through the active gateway, whose Endpoint was removed, so that the
+// standby routes take over until the next gateway is known
+func (r *Controller) withdrawActiveRoutes() {
+	routes, err := r.listTableRoutes()
+	if err != nil {
+		klog.Errorf("Error retrieving the routes of table %d: %v", routeTable, err)
+		return
+	}
+
+	var active []netlink.Route
+	for _, route := range routes {
+		if route.Priority < standbyRouteMetric && route.Gw.Equal(r.gw) {
+			active = append(active, route)
+		}
+	}
+	r.deleteRoutes(active)
+	r.gw = nil
+}
+
+// standbyRoutesToReconcile returns the standby routes which don't lead to one of the CIDR blocks through one of the
+// standby gateways with its metric, and the standby routes to add
+func standbyRoutesToReconcile(routes []netlink.Route, cidrBlocks []string, gws []net.IP, linkIndex int) ([]netlink.Route,
+	[]netlink.Route) {
+	var stale []netlink.Route
+	present := map[string]bool{}
+	for _, route := range routes {
+		if !isStandbyRoute(route) {
+			continue
+		}
+
+		index := route.Priority - standbyRouteMetric
+		if route.Dst != nil && containsString(cidrBlocks, route.Dst.String()) && index < len(gws) &&
+			route.Gw.Equal(gws[index]) && route.LinkIndex == linkIndex && !present[standbyRouteKey(route)] {
+			present[standbyRouteKey(route)] = true
+		} else {
+			stale = append(stale, route)
+		}
+	}
+
+	var missing []netlink.Route
+	for _, cidrBlock := range cidrBlocks {
+		_, dst, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			klog.Errorf("Error parsing cidr block %s: %v", cidrBlock, err)
+			continue
+		}
+
+		for i, gw := range gws {
+			route := netlink.Route{
+				Dst:       dst,
+				Gw:        gw,
+				LinkIndex: linkIndex,
+				Table:     routeTable,
+				Priority:  standbyRouteMetric + i,
+			}
+			if !present[standbyRouteKey(route)] {
+				missing = append(missing, route)
+			}
+		}
+	}
+	return stale, missing
+}
+
+func isStandbyRoute(route netlink.Route) bool {
+	return route.Priority >= standbyRouteMetric
+}
+
+func standbyRouteKey(route netlink.Route) string {
+	return fmt.Sprintf("%s/%d", route.Dst, route.Priority)
+}
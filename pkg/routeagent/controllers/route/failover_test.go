@@ -0,0 +1,142 @@
+package route
+
+import (
+	"net"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Failover", func() {
+	Describe("Function isFailover", func() {
+		endpoint := &v1.Endpoint{Spec: v1.EndpointSpec{Hostname: "node-2"}}
+
+		It("Should detect the gateway moving to another host", func() {
+			Expect((&Controller{gatewayHost: "node-1"}).isFailover(endpoint)).To(BeTrue())
+			Expect((&Controller{gatewayHost: "node-2"}).isFailover(endpoint)).To(BeFalse())
+		})
+
+		It("Should ignore the first gateway", func() {
+			Expect((&Controller{}).isFailover(endpoint)).To(BeFalse())
+		})
+	})
+
+	Describe("Function cidrConntrackFilter", func() {
+		filter, err := newCidrConntrackFilter([]string{"10.1.0.0/16", "10.97.0.0/16"})
+
+		It("Should match the flows from or to the CIDR blocks", func() {
+			Expect(err).ToNot(HaveOccurred())
+			Expect(filter.MatchConntrackFlow(newFlow("10.0.3.4", "10.97.0.10"))).To(BeTrue())
+			Expect(filter.MatchConntrackFlow(newFlow("10.1.8.9", "10.0.3.4"))).To(BeTrue())
+			Expect(filter.MatchConntrackFlow(newFlow("10.0.3.4", "10.96.0.10"))).To(BeFalse())
+		})
+
+		It("Should reject invalid CIDR blocks", func() {
+			_, err := newCidrConntrackFilter([]string{"10.1.0.0"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Function standbyGateways", func() {
+		routeController := &Controller{
+			nodeIP:            net.ParseIP("10.0.0.3"),
+			standbyGatewayIPs: []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3"), net.ParseIP("10.0.0.4")},
+		}
+
+		It("Should return the standby gateways other than the active one and this node", func() {
+			Expect(routeController.standbyGateways(net.ParseIP("10.0.0.2"))).To(Equal([]net.IP{net.ParseIP("10.0.0.4")}))
+		})
+
+		It("Should return nothing on the gateway", func() {
+			Expect(routeController.standbyGateways(nil)).To(BeEmpty())
+		})
+
+		It("Should skip the standby gateways whose overlay IP is the one of this node", func() {
+			colliding := &Controller{
+				nodeIP:            net.ParseIP("10.0.0.3"),
+				standbyGatewayIPs: []net.IP{net.ParseIP("11.0.0.3"), net.ParseIP("10.0.0.4")},
+			}
+			Expect(colliding.standbyGateways(net.ParseIP("10.0.0.2"))).To(Equal([]net.IP{net.ParseIP("10.0.0.4")}))
+		})
+	})
+
+	Describe("Function standbyRoutesToReconcile", func() {
+		gws := []net.IP{net.ParseIP("240.0.0.3"), net.ParseIP("240.0.0.4")}
+
+		It("Should return the standby routes to remove and to add", func() {
+			routes := []netlink.Route{
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: net.ParseIP("240.0.0.2"), LinkIndex: 2, Table: routeTable},
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: gws[0], LinkIndex: 2, Table: routeTable, Priority: standbyRouteMetric},
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: gws[0], LinkIndex: 2, Table: routeTable, Priority: standbyRouteMetric + 1},
+				{Dst: parseCIDR("10.2.0.0/16"), Gw: gws[0], LinkIndex: 2, Table: routeTable, Priority: standbyRouteMetric},
+			}
+			stale, missing := standbyRoutesToReconcile(routes, []string{"10.1.0.0/16"}, gws, 2)
+			Expect(stale).To(Equal([]netlink.Route{routes[2], routes[3]}))
+			Expect(missing).To(Equal([]netlink.Route{
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: gws[1], LinkIndex: 2, Table: routeTable, Priority: standbyRouteMetric + 1},
+			}))
+		})
+
+		It("Should remove all the standby routes without standby gateways", func() {
+			routes := []netlink.Route{
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: gws[0], LinkIndex: 2, Table: routeTable, Priority: standbyRouteMetric},
+			}
+			stale, missing := standbyRoutesToReconcile(routes, []string{"10.1.0.0/16"}, nil, 2)
+			Expect(stale).To(Equal(routes))
+			Expect(missing).To(BeEmpty())
+		})
+	})
+
+	Describe("Function withdrawActiveRoutes", func() {
+		It("Should only keep the standby routes", func() {
+			fake := newFakeNetlink()
+			gw := vxlanIP(net.ParseIP("10.0.0.2"))
+			standby := netlink.Route{Dst: parseCIDR("10.1.0.0/16"), Gw: vxlanIP(net.ParseIP("10.0.0.7")), LinkIndex: 2,
+				Table: routeTable, Priority: standbyRouteMetric}
+			fake.routes = []netlink.Route{
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: gw, LinkIndex: 2, Table: routeTable},
+				standby,
+			}
+			routeController := &Controller{netLink: fake, gw: gw}
+
+			routeController.withdrawActiveRoutes()
+			Expect(fake.routes).To(Equal([]netlink.Route{standby}))
+			Expect(routeController.gw).To(BeNil())
+		})
+	})
+
+	Describe("Function reconcileRoutes with standby gateways", func() {
+		useTestNetns()
+
+		It("Should install the standby routes with higher metrics than the active route", func() {
+			newNetnsController(net.ParseIP("10.0.0.2").To4(), net.ParseIP("10.0.0.7").To4())
+
+			routes, err := netlink.RouteListFiltered(syscall.AF_INET,
+				&netlink.Route{Table: routeTable, Dst: parseCIDR("10.1.0.0/16")}, netlink.RT_FILTER_TABLE|netlink.RT_FILTER_DST)
+			Expect(err).ToNot(HaveOccurred())
+
+			gateways := map[int]string{}
+			for _, route := range routes {
+				gateways[route.Priority] = route.Gw.String()
+			}
+			Expect(gateways).To(Equal(map[int]string{0: "240.0.0.2", standbyRouteMetric: "240.0.0.7"}))
+
+			link, err := netlink.LinkByName(vxlanInterface)
+			Expect(err).ToNot(HaveOccurred())
+			neighs, err := netlink.NeighList(link.Attrs().Index, syscall.AF_BRIDGE)
+			Expect(err).ToNot(HaveOccurred())
+
+			var vteps []string
+			for _, neigh := range neighs {
+				if isDefaultFdbEntry(neigh) {
+					vteps = append(vteps, neigh.IP.String())
+				}
+			}
+			Expect(vteps).To(ConsistOf("10.0.0.2", "10.0.0.7"))
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package route
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/netutil"
+	"github.com/rancher/submariner/pkg/util"
+	"k8s.io/klog"
+)
+
+/*
+ * The host network traffic of the nodes follows the routes to the remote clusters with the node IP as its source,
+ * which the gateway keeps when the node CIDR is set on the local Cluster: the node CIDR is then one of the subnets of
+ * the local endpoint, and the other clusters route it back through their gateway. The replies reach the nodes through
+ * the cluster network while the routes to the remote clusters go through the overlay, so the reverse path filter of
+ * the data interface must be loose as well.
+ *
+ * The node CIDR of a remote cluster which overlaps the network of the data interface isn't routed, since its routes
+ * and rules would take the traffic of the local nodes away from their own network.
+ */
+
+// The directory of the IPv4 settings of the interfaces, replaced by the tests
+var ipv4ConfDir = "/proc/sys/net/ipv4/conf"
+
+// setLooseRPFilter sets the reverse path filter of the interface to loose mode
+func setLooseRPFilter(name string) error {
+	rpFilter := filepath.Join(ipv4ConfDir, name, "rp_filter")
+	if err := ioutil.WriteFile(rpFilter, []byte("2"), 0644); err != nil {
+		return fmt.Errorf("Error setting %s: %v", rpFilter, err)
+	}
+	return nil
+}
+
+// routeHostNetwork prepares the data interface for the host network traffic to the remote clusters once the local
+// cluster has a node CIDR, globalnet doesn't route it
+func (r *Controller) routeHostNetwork(clusters []*v1.Cluster) error {
+	cluster := localCluster(clusters, r.clusterID)
+	if r.hostNetworkRouted || cluster == nil || len(cluster.Spec.NodeCIDR) == 0 || len(cluster.Spec.GlobalCIDR) > 0 {
+		return nil
+	}
+
+	if !isInCidrBlocks(r.nodeIP, cluster.Spec.NodeCIDR) {
+		klog.Warningf("The node IP %s isn't in the node CIDR %v, its host network traffic won't reach the remote "+
+			"clusters", r.nodeIP, cluster.Spec.NodeCIDR)
+	}
+
+	if err := setLooseRPFilter(r.link.Name); err != nil {
+		return err
+	}
+
+	klog.Infof("Routing the host network traffic of %v to the remote clusters", cluster.Spec.NodeCIDR)
+	r.hostNetworkRouted = true
+	return nil
+}
+
+// routableCidrBlocks returns the CIDR blocks of the remote cluster, except its node CIDR blocks which overlap the
+// addresses of the data interface
+func (r *Controller) routableCidrBlocks(cluster *v1.Cluster) []string {
+	cidrBlocks := util.GetClusterRoutableCIDRs(cluster.Spec)
+	if len(cluster.Spec.NodeCIDR) == 0 || len(cluster.Spec.GlobalCIDR) > 0 {
+		return cidrBlocks
+	}
+
+	addrs, err := r.link.Addrs()
+	if err != nil {
+		klog.Errorf("Error retrieving the addresses of %s, not routing the node CIDR %v of cluster %s: %v",
+			r.link.Name, cluster.Spec.NodeCIDR, cluster.Spec.ClusterID, err)
+		return removeStrings(cidrBlocks, cluster.Spec.NodeCIDR)
+	}
+
+	overlapping := overlappingCidrBlocks(cluster.Spec.NodeCIDR, addrs)
+	if len(overlapping) > 0 {
+		klog.Errorf("The node CIDR %v of cluster %s overlaps the addresses of %s, not routing it", overlapping,
+			cluster.Spec.ClusterID, r.link.Name)
+	}
+	return removeStrings(cidrBlocks, overlapping)
+}
+
+// overlappingCidrBlocks returns the CIDR blocks which overlap the network of one of the addresses
+func overlappingCidrBlocks(cidrBlocks []string, addrs []net.Addr) []string {
+	var networks []string
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			networks = append(networks, ipNet.String())
+		}
+	}
+
+	var overlapping []string
+	for _, cidrBlock := range cidrBlocks {
+		overlaps, err := netutil.IsOverlappingCIDR(networks, cidrBlock)
+		if err != nil {
+			klog.Errorf("Error checking cidr block %s for overlaps: %v", cidrBlock, err)
+			continue
+		}
+		if overlaps {
+			overlapping = append(overlapping, cidrBlock)
+		}
+	}
+	return overlapping
+}
+
+func removeStrings(values []string, removed []string) []string {
+	var kept []string
+	for _, value := range values {
+		if !containsString(removed, value) {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+func localCluster(clusters []*v1.Cluster, clusterID string) *v1.Cluster {
+	for _, cluster := range clusters {
+		if cluster.Spec.ClusterID == clusterID {
+			return cluster
+		}
+	}
+	return nil
+}
+
+// isInCidrBlocks returns whether the IP is in one of the CIDR blocks
+func isInCidrBlocks(ip net.IP, cidrBlocks []string) bool {
+	for _, cidrBlock := range cidrBlocks {
+		_, ipNet, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			klog.Errorf("Error parsing cidr block %s: %v", cidrBlock, err)
+			continue
+		}
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
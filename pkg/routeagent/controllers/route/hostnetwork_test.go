@@ -0,0 +1,91 @@
+package route
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+)
+
+var _ = Describe("Host network", func() {
+	Describe("Function routeHostNetwork", func() {
+		var confDir, previousConfDir string
+		var routeController *Controller
+		var clusters []*v1.Cluster
+
+		BeforeEach(func() {
+			var err error
+			confDir, err = ioutil.TempDir("", "ipv4-conf")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.Mkdir(filepath.Join(confDir, "eth0"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(confDir, "eth0", "rp_filter"), []byte("1"), 0644)).To(Succeed())
+			previousConfDir, ipv4ConfDir = ipv4ConfDir, confDir
+
+			routeController = &Controller{
+				clusterID: "local",
+				link:      &net.Interface{Name: "eth0"},
+				nodeIP:    net.ParseIP("192.168.10.5"),
+			}
+			local := newCluster("local", "10.0.0.0/16", "10.96.0.0/16")
+			local.Spec.NodeCIDR = []string{"192.168.10.0/24"}
+			clusters = []*v1.Cluster{local, newCluster("east", "10.1.0.0/16", "10.97.0.0/16")}
+		})
+
+		AfterEach(func() {
+			ipv4ConfDir = previousConfDir
+			Expect(os.RemoveAll(confDir)).To(Succeed())
+		})
+
+		rpFilter := func() string {
+			value, err := ioutil.ReadFile(filepath.Join(confDir, "eth0", "rp_filter"))
+			Expect(err).ToNot(HaveOccurred())
+			return string(value)
+		}
+
+		It("Should loosen the reverse path filter of the data interface when the cluster has a node CIDR", func() {
+			Expect(routeController.routeHostNetwork(clusters)).To(Succeed())
+			Expect(rpFilter()).To(Equal("2"))
+			Expect(routeController.hostNetworkRouted).To(BeTrue())
+		})
+
+		It("Should leave the data interface alone without a node CIDR", func() {
+			clusters[0].Spec.NodeCIDR = nil
+			Expect(routeController.routeHostNetwork(clusters)).To(Succeed())
+			Expect(rpFilter()).To(Equal("1"))
+		})
+
+		It("Should leave the data interface alone with globalnet", func() {
+			clusters[0].Spec.GlobalCIDR = []string{"169.254.0.0/19"}
+			Expect(routeController.routeHostNetwork(clusters)).To(Succeed())
+			Expect(rpFilter()).To(Equal("1"))
+		})
+	})
+
+	Describe("Function overlappingCidrBlocks", func() {
+		addrs := []net.Addr{
+			&net.IPNet{IP: net.ParseIP("192.168.10.5").To4(), Mask: net.CIDRMask(24, 32)},
+			&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+		}
+
+		It("Should return the CIDR blocks overlapping the networks of the addresses", func() {
+			Expect(overlappingCidrBlocks([]string{"192.168.0.0/16", "192.168.10.128/25", "192.168.20.0/24"}, addrs)).To(
+				Equal([]string{"192.168.0.0/16", "192.168.10.128/25"}))
+		})
+
+		It("Should return nothing without overlaps", func() {
+			Expect(overlappingCidrBlocks([]string{"192.168.20.0/24"}, addrs)).To(BeEmpty())
+		})
+	})
+
+	Describe("Function isInCidrBlocks", func() {
+		It("Should return whether the IP is in one of the CIDR blocks", func() {
+			Expect(isInCidrBlocks(net.ParseIP("192.168.10.5"), []string{"10.0.0.0/16", "192.168.10.0/24"})).To(BeTrue())
+			Expect(isInCidrBlocks(net.ParseIP("192.168.11.5"), []string{"10.0.0.0/16", "192.168.10.0/24"})).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package route
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+
+	"k8s.io/klog"
+)
+
+// MetricsHandler serves the drift and failover counters of the route agent in the JSON format of /debug/vars,
+// without the command line and memory statistics the expvar handler publishes as well
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err := fmt.Fprintf(w, "{\n%q: %s,\n%q: %s\n}\n", driftMetricsName, driftCounters.String(),
+			failoverMetricsName, failoverCounters.String())
+		if err != nil {
+			klog.Errorf("Error writing the metrics: %v", err)
+		}
+	})
+}
+
+// newCounters returns a map of counters which, unlike expvar.NewMap, isn't published to the expvar handler
+func newCounters() *expvar.Map {
+	return new(expvar.Map).Init()
+}
@@ -0,0 +1,26 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	Describe("Function MetricsHandler", func() {
+		It("Should only serve the drift and failover counters", func() {
+			recorder := httptest.NewRecorder()
+			MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			metrics := map[string]map[string]interface{}{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &metrics)).To(Succeed())
+			Expect(metrics).To(HaveLen(2))
+			Expect(metrics).To(HaveKey(driftMetricsName))
+			Expect(metrics).To(HaveKey(failoverMetricsName))
+		})
+	})
+})
@@ -0,0 +1,463 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/rancher/submariner/pkg/cableengine/ipsec"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("Netlink", func() {
+	remoteCidrBlocks := []string{"10.1.0.0/16", "10.97.0.0/16"}
+	nodeIP := net.ParseIP("10.0.0.5").To4()
+	gw := vxlanIP(net.ParseIP("10.0.0.2"))
+
+	var fake *fakeNetlink
+	var routeController *Controller
+	var vxlanIndex int
+
+	BeforeEach(func() {
+		fake = newFakeNetlink()
+		Expect(fake.LinkAdd(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}})).To(Succeed())
+		Expect(fake.LinkAdd(&netlink.Vxlan{LinkAttrs: netlink.LinkAttrs{Name: vxlanInterface}})).To(Succeed())
+		vxlanIndex = fake.links[vxlanInterface].Attrs().Index
+
+		routeController = &Controller{
+			netLink:      fake,
+			link:         &net.Interface{Name: "eth0", Index: fake.links["eth0"].Attrs().Index},
+			nodeIP:       nodeIP,
+			subnets:      remoteCidrBlocks,
+			vxlanGateway: net.ParseIP("10.0.0.2"),
+			gw:           gw,
+		}
+	})
+
+	Describe("Function reconcileRoutes", func() {
+		It("Should install the routes and rules of the remote CIDR blocks and remove the stale ones", func() {
+			fake.routes = []netlink.Route{
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: net.ParseIP("240.0.0.9"), LinkIndex: vxlanIndex, Table: routeTable},
+				{Dst: parseCIDR("10.2.0.0/16"), Gw: gw, LinkIndex: vxlanIndex, Table: routeTable},
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: net.ParseIP("10.0.0.1"), LinkIndex: 1, Table: syscall.RT_TABLE_MAIN},
+			}
+			fake.rules = []netlink.Rule{
+				*newRule("10.2.0.0/16", routeTable),
+				*newRule("192.168.0.0/16", 100),
+			}
+
+			Expect(routeController.reconcileRoutes()).To(Succeed())
+
+			Expect(fake.routeStrings()).To(Equal([]string{
+				"10.1.0.0/16 via 10.0.0.1 dev 1 table 254 metric 0",
+				"10.1.0.0/16 via 240.0.0.2 dev 2 table 150 metric 0 src 10.0.0.5",
+				"10.97.0.0/16 via 240.0.0.2 dev 2 table 150 metric 0 src 10.0.0.5",
+			}))
+			Expect(fake.ruleStrings()).To(Equal([]string{
+				"to 10.1.0.0/16 table 150 priority 150",
+				"to 10.97.0.0/16 table 150 priority 150",
+				"to 192.168.0.0/16 table 100 priority 150",
+			}))
+		})
+
+		It("Should leave the routes and rules in place when they're up to date", func() {
+			Expect(routeController.reconcileRoutes()).To(Succeed())
+			routes := fake.routeStrings()
+			rules := fake.ruleStrings()
+			fake.changes = 0
+
+			Expect(routeController.reconcileRoutes()).To(Succeed())
+			Expect(fake.routeStrings()).To(Equal(routes))
+			Expect(fake.ruleStrings()).To(Equal(rules))
+			Expect(fake.changes).To(BeZero())
+		})
+
+		It("Should fail without the VXLAN interface", func() {
+			delete(fake.links, vxlanInterface)
+			Expect(routeController.reconcileRoutes()).ToNot(Succeed())
+		})
+	})
+
+	Describe("Function cleanRoutes", func() {
+		It("Should remove the routes of the submariner table and its rules only", func() {
+			Expect(routeController.reconcileRoutes()).To(Succeed())
+			fake.routes = append(fake.routes, netlink.Route{Dst: parseCIDR("10.1.0.0/16"), Gw: net.ParseIP("10.0.0.1"),
+				LinkIndex: 1, Table: syscall.RT_TABLE_MAIN})
+			fake.rules = append(fake.rules, *newRule("192.168.0.0/16", 100))
+
+			routeController.cleanRoutes()
+
+			Expect(fake.routeStrings()).To(Equal([]string{"10.1.0.0/16 via 10.0.0.1 dev 1 table 254 metric 0"}))
+			Expect(fake.ruleStrings()).To(Equal([]string{"to 192.168.0.0/16 table 100 priority 150"}))
+		})
+	})
+
+	Describe("Function removeRoutes", func() {
+		It("Should remove the routes and rules of the given CIDR blocks only", func() {
+			Expect(routeController.reconcileRoutes()).To(Succeed())
+
+			routeController.removeRoutes([]string{"10.97.0.0/16"})
+
+			Expect(fake.routeStrings()).To(Equal([]string{"10.1.0.0/16 via 240.0.0.2 dev 2 table 150 metric 0 src 10.0.0.5"}))
+			Expect(fake.ruleStrings()).To(Equal([]string{"to 10.1.0.0/16 table 150 priority 150"}))
+		})
+	})
+
+	Describe("Function removeMainTableRoutes", func() {
+		It("Should remove the routes to the remote CIDR blocks through a gateway from the main table", func() {
+			fake.routes = []netlink.Route{
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: net.ParseIP("10.0.0.2"), LinkIndex: 1, Table: syscall.RT_TABLE_MAIN},
+				{Dst: parseCIDR("10.0.0.0/24"), LinkIndex: 1, Table: syscall.RT_TABLE_MAIN},
+				{Dst: parseCIDR("10.97.0.0/16"), LinkIndex: 1, Table: syscall.RT_TABLE_MAIN},
+			}
+
+			routeController.removeMainTableRoutes()
+
+			Expect(fake.routeStrings()).To(Equal([]string{
+				"10.0.0.0/24 via <nil> dev 1 table 254 metric 0",
+				"10.97.0.0/16 via <nil> dev 1 table 254 metric 0",
+			}))
+		})
+	})
+
+	Describe("Function cleanXfrmPolicies", func() {
+		It("Should remove the policies of the cables only", func() {
+			fake.policies = []netlink.XfrmPolicy{
+				*newXfrmPolicy("10.0.0.0/16", "10.1.0.0/16", ipsec.ReqIDBase),
+				*newXfrmPolicy("192.168.100.0/24", "10.1.0.0/16", 1),
+				*newXfrmPolicy("10.97.0.0/16", "10.0.0.0/16", ipsec.ReqIDBase+1),
+			}
+
+			routeController.cleanXfrmPolicies()
+
+			Expect(fake.policies).To(Equal([]netlink.XfrmPolicy{*newXfrmPolicy("192.168.100.0/24", "10.1.0.0/16", 1)}))
+		})
+	})
+
+	Describe("Function setVxlanGateway", func() {
+		It("Should point the default FDB entry at the gateway", func() {
+			Expect(routeController.setVxlanGateway(net.ParseIP("10.0.0.2"))).To(Succeed())
+			Expect(fake.neighs).To(HaveLen(1))
+			Expect(fake.neighs[0].IP.String()).To(Equal("10.0.0.2"))
+			Expect(fake.neighs[0].LinkIndex).To(Equal(vxlanIndex))
+
+			Expect(routeController.setVxlanGateway(net.ParseIP("10.0.0.3"))).To(Succeed())
+			Expect(fake.neighs).To(HaveLen(1))
+			Expect(fake.neighs[0].IP.String()).To(Equal("10.0.0.3"))
+
+			Expect(routeController.setVxlanGateway(nil)).To(Succeed())
+			Expect(fake.neighs).To(BeEmpty())
+		})
+	})
+
+	Describe("Function setGatewayOverlayIP", func() {
+		It("Should assign the overlay IP of an endpoint private IP which isn't the node IP", func() {
+			Expect(routeController.setGatewayOverlayIP(net.ParseIP("10.0.1.9"))).To(Succeed())
+			Expect(fake.addrs[vxlanInterface]).To(Equal([]string{"240.0.1.9/32"}))
+
+			Expect(routeController.setGatewayOverlayIP(nil)).To(Succeed())
+			Expect(fake.addrs[vxlanInterface]).To(BeEmpty())
+		})
+
+		It("Should not assign the overlay IP of the node again", func() {
+			Expect(routeController.setGatewayOverlayIP(nodeIP)).To(Succeed())
+			Expect(fake.addrs[vxlanInterface]).To(BeEmpty())
+		})
+	})
+
+	Describe("Function applyGateway", func() {
+		It("Should refuse a gateway whose overlay IP is the one of this node", func() {
+			routeController.hostname = "node"
+			err := routeController.applyGateway(newEndpoint("east", "gateway", "11.0.0.5"))
+			Expect(err).To(HaveOccurred())
+			Expect(fake.neighs).To(BeEmpty())
+		})
+	})
+
+	Describe("Function flushConntrack", func() {
+		It("Should remove the flows of the remote CIDR blocks only", func() {
+			fake.flows = []*netlink.ConntrackFlow{newFlow("10.0.3.4", "10.1.0.8"), newFlow("10.0.3.4", "10.96.0.10"),
+				newFlow("10.97.0.1", "10.0.3.4")}
+
+			flushed, err := routeController.flushConntrack(remoteCidrBlocks)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(flushed).To(Equal(uint(2)))
+			Expect(fake.flows).To(Equal([]*netlink.ConntrackFlow{newFlow("10.0.3.4", "10.96.0.10")}))
+		})
+	})
+
+	Describe("In a network namespace", func() {
+		useTestNetns()
+
+		It("Should install the exact routes and rules", func() {
+			routeController := newNetnsController()
+			link, err := netlink.LinkByName(vxlanInterface)
+			Expect(err).ToNot(HaveOccurred())
+
+			routes, err := routeController.listTableRoutes()
+			Expect(err).ToNot(HaveOccurred())
+			var routeStrings []string
+			for _, route := range routes {
+				Expect(route.LinkIndex).To(Equal(link.Attrs().Index))
+				routeStrings = append(routeStrings, fmt.Sprintf("%s via %s src %s", route.Dst, route.Gw, route.Src))
+			}
+			Expect(routeStrings).To(ConsistOf("10.1.0.0/16 via 240.0.0.2 src 10.0.0.5", "10.97.0.0/16 via 240.0.0.2 src 10.0.0.5"))
+
+			rules, err := routeController.listTableRules()
+			Expect(err).ToNot(HaveOccurred())
+			var ruleStrings []string
+			for _, rule := range rules {
+				ruleStrings = append(ruleStrings, fmt.Sprintf("to %s priority %d", rule.Dst, rule.Priority))
+			}
+			Expect(ruleStrings).To(ConsistOf("to 10.1.0.0/16 priority 150", "to 10.97.0.0/16 priority 150"))
+		})
+
+		It("Should leave the main table alone when cleaning the routes", func() {
+			routeController := newNetnsController()
+			mainRoutes, err := netlink.RouteList(nil, syscall.AF_INET)
+			Expect(err).ToNot(HaveOccurred())
+
+			routeController.cleanRoutes()
+
+			routes, err := routeController.listTableRoutes()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(routes).To(BeEmpty())
+			rules, err := routeController.listTableRules()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rules).To(BeEmpty())
+			Expect(netlink.RouteList(nil, syscall.AF_INET)).To(Equal(mainRoutes))
+		})
+	})
+})
+
+// fakeNetlink keeps the links, routes, rules, FDB entries, XFRM policies and conntrack flows in memory
+type fakeNetlink struct {
+	links map[string]netlink.Link
+	// The addresses of each link, keyed by its name
+	addrs    map[string][]string
+	routes   []netlink.Route
+	rules    []netlink.Rule
+	neighs   []netlink.Neigh
+	policies []netlink.XfrmPolicy
+	flows    []*netlink.ConntrackFlow
+	// The number of added or removed routes and rules
+	changes int
+}
+
+func newFakeNetlink() *fakeNetlink {
+	return &fakeNetlink{links: map[string]netlink.Link{}, addrs: map[string][]string{}}
+}
+
+func (f *fakeNetlink) LinkByName(name string) (netlink.Link, error) {
+	link, ok := f.links[name]
+	if !ok {
+		return nil, netlink.LinkNotFoundError{}
+	}
+	return link, nil
+}
+
+func (f *fakeNetlink) LinkAdd(link netlink.Link) error {
+	if _, ok := f.links[link.Attrs().Name]; ok {
+		return syscall.EEXIST
+	}
+	link.Attrs().Index = len(f.links) + 1
+	f.links[link.Attrs().Name] = link
+	return nil
+}
+
+func (f *fakeNetlink) LinkDel(link netlink.Link) error {
+	delete(f.links, link.Attrs().Name)
+	return nil
+}
+
+func (f *fakeNetlink) LinkSetUp(link netlink.Link) error {
+	link.Attrs().Flags |= net.FlagUp
+	return nil
+}
+
+func (f *fakeNetlink) AddrReplace(link netlink.Link, addr *netlink.Addr) error {
+	name := link.Attrs().Name
+	for _, existing := range f.addrs[name] {
+		if existing == addr.IPNet.String() {
+			return nil
+		}
+	}
+	f.addrs[name] = append(f.addrs[name], addr.IPNet.String())
+	return nil
+}
+
+func (f *fakeNetlink) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	name := link.Attrs().Name
+	for i, existing := range f.addrs[name] {
+		if existing == addr.IPNet.String() {
+			f.addrs[name] = append(f.addrs[name][:i], f.addrs[name][i+1:]...)
+			return nil
+		}
+	}
+	return syscall.EADDRNOTAVAIL
+}
+
+func (f *fakeNetlink) RouteList(link netlink.Link, family int) ([]netlink.Route, error) {
+	var routes []netlink.Route
+	for _, route := range f.routes {
+		if route.Table == syscall.RT_TABLE_MAIN && (link == nil || route.LinkIndex == link.Attrs().Index) {
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func (f *fakeNetlink) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	var routes []netlink.Route
+	for _, route := range f.routes {
+		if filterMask&netlink.RT_FILTER_TABLE != 0 && route.Table != filter.Table {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func (f *fakeNetlink) RouteAdd(route *netlink.Route) error {
+	for _, existing := range f.routes {
+		if existing.Dst.String() == route.Dst.String() && existing.Table == route.Table && existing.Priority == route.Priority {
+			return syscall.EEXIST
+		}
+	}
+	f.routes = append(f.routes, *route)
+	f.changes++
+	return nil
+}
+
+func (f *fakeNetlink) RouteDel(route *netlink.Route) error {
+	for i, existing := range f.routes {
+		if existing.Dst.String() == route.Dst.String() && existing.Table == route.Table &&
+			existing.Priority == route.Priority && existing.Gw.Equal(route.Gw) {
+			f.routes = append(f.routes[:i], f.routes[i+1:]...)
+			f.changes++
+			return nil
+		}
+	}
+	return syscall.ESRCH
+}
+
+func (f *fakeNetlink) RuleList(family int) ([]netlink.Rule, error) {
+	return append([]netlink.Rule{}, f.rules...), nil
+}
+
+func (f *fakeNetlink) RuleAdd(rule *netlink.Rule) error {
+	f.rules = append(f.rules, *rule)
+	f.changes++
+	return nil
+}
+
+func (f *fakeNetlink) RuleDel(rule *netlink.Rule) error {
+	for i, existing := range f.rules {
+		if existing.Dst.String() == rule.Dst.String() && existing.Table == rule.Table && existing.Priority == rule.Priority {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			f.changes++
+			return nil
+		}
+	}
+	return syscall.ENOENT
+}
+
+func (f *fakeNetlink) NeighList(linkIndex, family int) ([]netlink.Neigh, error) {
+	var neighs []netlink.Neigh
+	for _, neigh := range f.neighs {
+		if neigh.LinkIndex == linkIndex {
+			neighs = append(neighs, neigh)
+		}
+	}
+	return neighs, nil
+}
+
+func (f *fakeNetlink) NeighAppend(neigh *netlink.Neigh) error {
+	f.neighs = append(f.neighs, *neigh)
+	return nil
+}
+
+func (f *fakeNetlink) NeighDel(neigh *netlink.Neigh) error {
+	for i, existing := range f.neighs {
+		if existing.LinkIndex == neigh.LinkIndex && existing.IP.Equal(neigh.IP) &&
+			existing.HardwareAddr.String() == neigh.HardwareAddr.String() {
+			f.neighs = append(f.neighs[:i], f.neighs[i+1:]...)
+			return nil
+		}
+	}
+	return syscall.ENOENT
+}
+
+func (f *fakeNetlink) XfrmPolicyList(family int) ([]netlink.XfrmPolicy, error) {
+	return append([]netlink.XfrmPolicy{}, f.policies...), nil
+}
+
+func (f *fakeNetlink) XfrmPolicyDel(policy *netlink.XfrmPolicy) error {
+	for i, existing := range f.policies {
+		if existing.Src.String() == policy.Src.String() && existing.Dst.String() == policy.Dst.String() &&
+			existing.Dir == policy.Dir {
+			f.policies = append(f.policies[:i], f.policies[i+1:]...)
+			return nil
+		}
+	}
+	return syscall.ENOENT
+}
+
+func (f *fakeNetlink) ConntrackDeleteFilter(table netlink.ConntrackTableType, family netlink.InetFamily,
+	filter netlink.CustomConntrackFilter) (uint, error) {
+	var kept []*netlink.ConntrackFlow
+	var deleted uint
+	for _, flow := range f.flows {
+		if filter.MatchConntrackFlow(flow) {
+			deleted++
+		} else {
+			kept = append(kept, flow)
+		}
+	}
+	f.flows = kept
+	return deleted, nil
+}
+
+// routeStrings returns the routes sorted by their description
+func (f *fakeNetlink) routeStrings() []string {
+	var routes []string
+	for _, route := range f.routes {
+		description := fmt.Sprintf("%s via %s dev %d table %d metric %d", route.Dst, route.Gw, route.LinkIndex, route.Table,
+			route.Priority)
+		if route.Src != nil {
+			description += " src " + route.Src.String()
+		}
+		routes = append(routes, description)
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+// ruleStrings returns the rules sorted by their description
+func (f *fakeNetlink) ruleStrings() []string {
+	var rules []string
+	for _, rule := range f.rules {
+		rules = append(rules, fmt.Sprintf("to %s table %d priority %d", rule.Dst, rule.Table, rule.Priority))
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+func newRule(dst string, table int) *netlink.Rule {
+	rule := netlink.NewRule()
+	rule.Dst = parseCIDR(dst)
+	rule.Table = table
+	rule.Priority = rulePriority
+	return rule
+}
+
+func newFlow(src, dst string) *netlink.ConntrackFlow {
+	flow := &netlink.ConntrackFlow{}
+	flow.Forward.SrcIP = net.ParseIP(src)
+	flow.Forward.DstIP = net.ParseIP(dst)
+	return flow
+}
@@ -4,15 +4,17 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"syscall"
 	"time"
 
 	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
-	clientset "github.com/rancher/submariner/pkg/client/clientset/versioned"
+	"github.com/rancher/submariner/pkg/cableengine/ipsec"
 	informers "github.com/rancher/submariner/pkg/client/informers/externalversions/submariner.io/v1"
+	listers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
 	"github.com/vishvananda/netlink"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
@@ -20,63 +22,130 @@ import (
 	"k8s.io/klog"
 )
 
-type Controller struct {
-	clusterID       string
-	objectNamespace string
+/*
+ * The routes of the node are computed from the informer caches: the CIDR blocks of the remote clusters come from the
+ * Cluster objects, and the gateway from the Endpoint of the local cluster. Since every reconciliation recomputes all
+ * the routes of the node, the changes of the Cluster and Endpoint objects and the periodic resync all queue the same
+ * key, which coalesces them while a reconciliation runs. The workers serialize the reconciliations and repairs.
+ */
+
+const (
+	// The workqueue key which reconciles the routes after any change and periodically, see also routeRepairKey
+	reconcileKey = "reconcile"
+	resyncPeriod = 30 * time.Second
+
+	// The routes to the remote clusters are installed in a dedicated table, which the rules for the remote CIDR
+	// blocks select ahead of the main table. Charon uses table and priority 220 on the gateway.
+	routeTable   = 150
+	rulePriority = 150
+)
 
-	submarinerClientSet clientset.Interface
-	clustersSynced      cache.InformerSynced
-	endpointsSynced     cache.InformerSynced
+// Netlink is the subset of the netlink API used by the controller, which *netlink.Handle implements
+type Netlink interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	LinkSetUp(link netlink.Link) error
+	AddrReplace(link netlink.Link, addr *netlink.Addr) error
+	AddrDel(link netlink.Link, addr *netlink.Addr) error
+	RouteList(link netlink.Link, family int) ([]netlink.Route, error)
+	RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error)
+	RouteAdd(route *netlink.Route) error
+	RouteDel(route *netlink.Route) error
+	RuleList(family int) ([]netlink.Rule, error)
+	RuleAdd(rule *netlink.Rule) error
+	RuleDel(rule *netlink.Rule) error
+	NeighList(linkIndex, family int) ([]netlink.Neigh, error)
+	NeighAppend(neigh *netlink.Neigh) error
+	NeighDel(neigh *netlink.Neigh) error
+	XfrmPolicyList(family int) ([]netlink.XfrmPolicy, error)
+	XfrmPolicyDel(policy *netlink.XfrmPolicy) error
+	ConntrackDeleteFilter(table netlink.ConntrackTableType, family netlink.InetFamily,
+		filter netlink.CustomConntrackFilter) (uint, error)
+}
 
-	clusterWorkqueue  workqueue.RateLimitingInterface
-	endpointWorkqueue workqueue.RateLimitingInterface
+type Controller struct {
+	// Serializes the reconciliations and repairs of the workers
+	sync.Mutex
 
-	gw      net.IP
-	subnets []string
+	clusterID       string
+	objectNamespace string
+	netLink         Netlink
+	hostname        string
+
+	clusterLister   listers.ClusterLister
+	endpointLister  listers.EndpointLister
+	clustersSynced  cache.InformerSynced
+	endpointsSynced cache.InformerSynced
+
+	workers   int
+	workqueue workqueue.RateLimitingInterface
+
+	// The hostname of the active gateway, empty until it's known
+	gatewayHost string
+	// The IP of the gateway the VXLAN interface sends the traffic to, and its overlay IP, nil on the gateway itself
+	vxlanGateway net.IP
+	gw           net.IP
+	// The IPs of the gateway nodes which the standby routes go through, if any
+	standbyGatewayIPs []net.IP
+	subnets           []string
+	// The CIDR blocks of each remote cluster, keyed by the name of its Cluster object
+	clusterCidrBlocks map[string][]string
 
 	link *net.Interface
+	// The IP of this node on link, which is the source of the VXLAN overlay
+	nodeIP net.IP
+	// The overlay IP of the private IP of the local endpoint, when this node is the gateway and it isn't the overlay
+	// IP of nodeIP
+	gatewayOverlayIP net.IP
+	// Whether link is set up for the host network traffic to the remote clusters
+	hostNetworkRouted bool
 }
 
-func NewController(clusterID string, objectNamespace string, link *net.Interface, submarinerClientSet clientset.Interface,
-	clusterInformer informers.ClusterInformer, endpointInformer informers.EndpointInformer) *Controller {
+func NewController(clusterID string, objectNamespace string, netLink Netlink, link *net.Interface,
+	standbyGatewayIPs []net.IP, workers int, clusterInformer informers.ClusterInformer,
+	endpointInformer informers.EndpointInformer) *Controller {
 	controller := Controller{
-		clusterID:           clusterID,
-		objectNamespace:     objectNamespace,
-		submarinerClientSet: submarinerClientSet,
-		link:                link,
-		clustersSynced:      clusterInformer.Informer().HasSynced,
-		endpointsSynced:     endpointInformer.Informer().HasSynced,
-		clusterWorkqueue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Clusters"),
-		endpointWorkqueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Endpoints"),
-	}
-
-	clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: controller.enqueueCluster,
-		UpdateFunc: func(old, new interface{}) {
-			controller.enqueueCluster(new)
-		},
-		DeleteFunc: controller.handleRemovedCluster,
-	})
+		clusterID:         clusterID,
+		objectNamespace:   objectNamespace,
+		netLink:           netLink,
+		link:              link,
+		standbyGatewayIPs: standbyGatewayIPs,
+		clusterLister:     clusterInformer.Lister(),
+		endpointLister:    endpointInformer.Lister(),
+		clustersSynced:    clusterInformer.Informer().HasSynced,
+		endpointsSynced:   endpointInformer.Informer().HasSynced,
+		workers:           workers,
+		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Routes"),
+		clusterCidrBlocks: map[string][]string{},
+	}
 
-	endpointInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: controller.enqueueEndpoint,
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueue,
 		UpdateFunc: func(old, new interface{}) {
-			controller.enqueueEndpoint(new)
+			controller.enqueue(new)
 		},
-		DeleteFunc: controller.handleRemovedEndpoint,
-	})
+		DeleteFunc: controller.enqueue,
+	}
+	clusterInformer.Informer().AddEventHandler(handler)
+	endpointInformer.Informer().AddEventHandler(handler)
 
 	return &controller
 }
 
 func (r *Controller) Run(stopCh <-chan struct{}) error {
-	var wg sync.WaitGroup
-	wg.Add(1)
 	defer utilruntime.HandleCrash()
+	defer r.workqueue.ShutDown()
 
 	// Start the informer factories to begin populating the informer caches
 	klog.Info("Starting Route Controller")
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("unable to determine hostname: %v", err)
+	}
+	r.hostname = hostname
+
 	// Wait for the caches to be synced before starting workers
 	klog.Info("Waiting for endpoint informer caches to sync")
 	if ok := cache.WaitForCacheSync(stopCh, r.endpointsSynced, r.clustersSynced); !ok {
@@ -84,36 +153,32 @@ func (r *Controller) Run(stopCh <-chan struct{}) error {
 	}
 
 	// let's go ahead and pre-populate clusters
-
-	clusters, err := r.submarinerClientSet.SubmarinerV1().Clusters(r.objectNamespace).List(metav1.ListOptions{})
-
+	clusters, err := r.clusterLister.Clusters(r.objectNamespace).List(labels.Everything())
 	if err != nil {
-		klog.Fatalf("error while retrieving all clusters: %v", err)
+		return fmt.Errorf("error while retrieving all clusters: %v", err)
 	}
+	r.syncClusterCidrBlocks(clusters)
+	r.removeMainTableRoutes()
 
-	for _, cluster := range clusters.Items {
-		if cluster.Spec.ClusterID != r.clusterID {
-			r.populateCidrBlockList(append(cluster.Spec.ClusterCIDR, cluster.Spec.ServiceCIDR...))
-		}
+	if err = r.ensureVxlanInterface(); err != nil {
+		return err
 	}
 
-	klog.Info("Starting workers")
-	go wait.Until(r.runClusterWorker, time.Second, stopCh)
-	go wait.Until(r.runEndpointWorker, time.Second, stopCh)
-	wg.Wait()
+	klog.Infof("Starting %d workers", r.workers)
+	for i := 0; i < r.workers; i++ {
+		go wait.Until(r.runWorker, time.Second, stopCh)
+	}
+	go wait.Until(func() {
+		r.workqueue.Add(reconcileKey)
+	}, resyncPeriod, stopCh)
+	go r.watchNetlink(stopCh)
 	<-stopCh
 	klog.Info("Shutting down workers")
 	return nil
 }
 
-func (r *Controller) runClusterWorker() {
-	for r.processNextCluster() {
-
-	}
-}
-
-func (r *Controller) runEndpointWorker() {
-	for r.processNextEndpoint() {
+func (r *Controller) runWorker() {
+	for r.processNextItem() {
 
 	}
 }
@@ -126,189 +191,330 @@ func (r *Controller) populateCidrBlockList(inputCidrBlocks []string) {
 	}
 }
 
-func (r *Controller) processNextCluster() bool {
-	obj, shutdown := r.clusterWorkqueue.Get()
-	if shutdown {
-		return false
+// setClusterCidrBlocks records the CIDR blocks of a cluster, or forgets them when nil, and returns its previous
+// CIDR blocks that aren't used by any other cluster anymore
+func (r *Controller) setClusterCidrBlocks(name string, cidrBlocks []string) []string {
+	previous := r.clusterCidrBlocks[name]
+	if cidrBlocks == nil {
+		delete(r.clusterCidrBlocks, name)
+	} else {
+		r.clusterCidrBlocks[name] = cidrBlocks
 	}
-	err := func() error {
-		defer r.clusterWorkqueue.Done(obj)
-		klog.V(4).Infof("Processing cluster object: %v", obj)
-		key := obj.(string)
-		ns, name, err := cache.SplitMetaNamespaceKey(key)
-		if err != nil {
-			return fmt.Errorf("Error while splitting meta namespace key %s: %v", key, err)
+
+	var removed []string
+	for _, cidrBlock := range previous {
+		inUse := false
+		for _, otherCidrBlocks := range r.clusterCidrBlocks {
+			if containsString(otherCidrBlocks, cidrBlock) {
+				inUse = true
+				break
+			}
 		}
-		cluster, err := r.submarinerClientSet.SubmarinerV1().Clusters(ns).Get(name, metav1.GetOptions{})
-		if err != nil {
-			return fmt.Errorf("Error retrieving submariner cluster object %s: %v", name, err)
+		if !inUse {
+			removed = append(removed, cidrBlock)
 		}
+	}
+
+	r.removeCidrBlocks(removed)
+	r.populateCidrBlockList(cidrBlocks)
+	return removed
+}
 
+// syncClusterCidrBlocks records the CIDR blocks of the given remote clusters and forgets the clusters which aren't
+// part of them, and returns the CIDR blocks that aren't used by any cluster anymore
+func (r *Controller) syncClusterCidrBlocks(clusters []*v1.Cluster) []string {
+	names := map[string]bool{}
+	var removed []string
+	for _, cluster := range clusters {
 		if cluster.Spec.ClusterID == r.clusterID {
-			klog.V(6).Infof("cluster ID matched the cluster ID of this cluster, not adding it to the cidr list")
-			return nil
-			// no need to reconcile because this endpoint isn't ours
+			continue
 		}
 
-		r.populateCidrBlockList(append(cluster.Spec.ClusterCIDR, cluster.Spec.ServiceCIDR...))
+		names[cluster.Name] = true
+		removed = append(removed, r.setClusterCidrBlocks(cluster.Name, r.routableCidrBlocks(cluster))...)
+	}
 
-		r.clusterWorkqueue.Forget(obj)
-		klog.V(4).Infof("cluster processed by route controller")
-		return nil
-	}()
+	for name := range r.clusterCidrBlocks {
+		if !names[name] {
+			klog.Infof("Cluster %s doesn't exist anymore, forgetting its CIDR blocks", name)
+			removed = append(removed, r.setClusterCidrBlocks(name, nil)...)
+		}
+	}
 
-	if err != nil {
-		utilruntime.HandleError(err)
-		return true
+	// A CIDR block moved from one cluster to another is still in use
+	var unused []string
+	for _, cidrBlock := range removed {
+		if !containsString(r.subnets, cidrBlock) && !containsString(unused, cidrBlock) {
+			unused = append(unused, cidrBlock)
+		}
 	}
+	return unused
+}
 
-	return true
+func (r *Controller) removeCidrBlocks(cidrBlocks []string) {
+	var subnets []string
+	for _, subnet := range r.subnets {
+		if !containsString(cidrBlocks, subnet) {
+			subnets = append(subnets, subnet)
+		}
+	}
+	r.subnets = subnets
 }
 
-func (r *Controller) processNextEndpoint() bool {
-	obj, shutdown := r.endpointWorkqueue.Get()
+func (r *Controller) processNextItem() bool {
+	obj, shutdown := r.workqueue.Get()
 	if shutdown {
 		return false
 	}
 	err := func() error {
-		defer r.endpointWorkqueue.Done(obj)
-		klog.V(4).Infof("Handling object in handleEndpoint")
-		klog.V(4).Infof("Processing endpoint object: %v", obj)
-		key := obj.(string)
-		ns, name, err := cache.SplitMetaNamespaceKey(key)
-		if err != nil {
-			return fmt.Errorf("Error while splitting meta namespace key %s: %v", key, err)
-		}
-		endpoint, err := r.submarinerClientSet.SubmarinerV1().Endpoints(ns).Get(name, metav1.GetOptions{})
-		if err != nil {
-			return fmt.Errorf("Error retrieving submariner endpoint object %s: %v", name, err)
-		}
+		defer r.workqueue.Done(obj)
+		klog.V(4).Infof("Processing %v", obj)
 
-		if endpoint.Spec.ClusterID != r.clusterID {
-			klog.V(6).Infof("Endpoint didn't match the cluster ID of this cluster")
-			return nil
-			// no need to reconcile because this endpoint isn't ours
+		var err error
+		if obj.(string) == routeRepairKey {
+			err = r.repairRoutes()
+		} else {
+			err = r.reconcile()
 		}
 
-		hostname, err := os.Hostname()
 		if err != nil {
-			klog.Fatalf("unable to determine hostname: %v", err)
+			r.workqueue.AddRateLimited(obj)
+			return fmt.Errorf("Error while reconciling the routes for %v: %v", obj, err)
 		}
 
-		if endpoint.Spec.Hostname == hostname {
-			r.cleanRoutes()
-			klog.V(6).Infof("not reconciling routes because we appear to be the gateway host")
-			return nil
-		}
+		r.workqueue.Forget(obj)
+		klog.V(4).Infof("%v processed by route controller", obj)
+		return nil
+	}()
 
-		klog.V(6).Infof("Setting gateway to gw: %s", endpoint.Spec.PrivateIP.String())
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
 
-		r.gw = endpoint.Spec.PrivateIP
-		r.cleanXfrmPolicies()
-		err = r.reconcileRoutes()
+	return true
+}
 
-		if err != nil {
-			r.endpointWorkqueue.AddRateLimited(obj)
-			return fmt.Errorf("Error while reconciling routes %v", err)
-		}
+// reconcile computes the CIDR blocks of the remote clusters and the gateway from the informer caches, and updates
+// the routes, rules and VXLAN interface of the node to match them
+func (r *Controller) reconcile() error {
+	r.Lock()
+	defer r.Unlock()
+
+	clusters, err := r.clusterLister.Clusters(r.objectNamespace).List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("Error listing the clusters: %v", err)
+	}
+
+	// The CIDRs of a remote cluster may change while it's connected, the routes to the previous ones are removed
+	if removed := r.syncClusterCidrBlocks(clusters); len(removed) > 0 {
+		klog.Infof("Removing the routes to %v, which no cluster uses anymore", removed)
+		r.removeRoutes(removed)
+	}
 
-		r.endpointWorkqueue.Forget(obj)
-		klog.V(4).Infof("endpoint processed by route controller")
+	if err = r.routeHostNetwork(clusters); err != nil {
+		return err
+	}
+
+	endpoint, err := r.localEndpoint()
+	if err != nil {
+		return err
+	}
+
+	// The standby routes only take over once the routes through the previous gateway are gone
+	if endpoint == nil && r.gw != nil && len(r.standbyGateways(r.vxlanGateway)) > 0 {
+		klog.Infof("The endpoint of the gateway %s was removed, falling back to the standby routes", r.gatewayHost)
+		r.withdrawActiveRoutes()
 		return nil
-	}()
+	}
+
+	// The routes lead to the last gateway until another one takes over
+	if endpoint == nil {
+		klog.V(4).Infof("The cluster has no gateway, keeping the routes to %s", r.gatewayHost)
+		if r.gw != nil {
+			return r.reconcileRoutes()
+		}
+		return nil
+	}
+
+	return r.applyGateway(endpoint)
+}
 
+// localEndpoint returns the Endpoint of the local cluster from the cache, the most recent one while the previous
+// gateway's isn't removed yet, or nil when there is none
+func (r *Controller) localEndpoint() (*v1.Endpoint, error) {
+	endpoints, err := r.endpointLister.Endpoints(r.objectNamespace).List(labels.Everything())
 	if err != nil {
-		utilruntime.HandleError(err)
-		return true
+		return nil, fmt.Errorf("Error listing the endpoints: %v", err)
 	}
 
-	return true
+	var local []*v1.Endpoint
+	for _, endpoint := range endpoints {
+		if endpoint.Spec.ClusterID == r.clusterID {
+			local = append(local, endpoint)
+		}
+	}
+
+	if len(local) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(local, func(i, j int) bool {
+		if !local[i].CreationTimestamp.Equal(&local[j].CreationTimestamp) {
+			return local[j].CreationTimestamp.Before(&local[i].CreationTimestamp)
+		}
+		return local[i].Name < local[j].Name
+	})
+	return local[0], nil
 }
 
-func (r *Controller) enqueueCluster(obj interface{}) {
-	var key string
-	var err error
-	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
-		utilruntime.HandleError(err)
-		return
+// applyGateway points the VXLAN interface and the routes at the gateway of the endpoint, or removes the routes when
+// this node is the gateway
+func (r *Controller) applyGateway(endpoint *v1.Endpoint) error {
+	start := time.Now()
+	failover := r.isFailover(endpoint)
+
+	if endpoint.Spec.Hostname == r.hostname {
+		r.vxlanGateway = nil
+		r.gw = nil
+		r.cleanRoutes()
+		if err := r.setVxlanGateway(nil); err != nil {
+			return err
+		}
+		if err := r.setGatewayOverlayIP(endpoint.Spec.PrivateIP); err != nil {
+			return err
+		}
+		if failover {
+			r.completeFailover(endpoint, start)
+		}
+		r.gatewayHost = endpoint.Spec.Hostname
+		klog.V(6).Infof("not reconciling routes because we appear to be the gateway host")
+		return nil
+	}
+
+	klog.V(6).Infof("Setting gateway to gw: %s", endpoint.Spec.PrivateIP.String())
+
+	if r.overlayIPCollides(endpoint.Spec.PrivateIP) {
+		return fmt.Errorf("The overlay IP %s of the gateway %s is the one of this node %s, the lower 24 bits of the "+
+			"node IPs must differ", vxlanIP(endpoint.Spec.PrivateIP), endpoint.Spec.PrivateIP, r.nodeIP)
+	}
+
+	if err := r.setGatewayOverlayIP(nil); err != nil {
+		return err
+	}
+
+	if err := r.setVxlanGateway(endpoint.Spec.PrivateIP); err != nil {
+		return err
+	}
+	r.vxlanGateway = endpoint.Spec.PrivateIP
+	r.gw = vxlanIP(endpoint.Spec.PrivateIP)
+
+	// The policies charon installed while this node was the gateway are left behind when the gateway moves
+	if r.gatewayHost != endpoint.Spec.Hostname {
+		r.cleanXfrmPolicies()
 	}
-	klog.V(4).Infof("Enqueueing cluster for route controller %v", obj)
-	r.clusterWorkqueue.AddRateLimited(key)
+
+	if err := r.reconcileRoutes(); err != nil {
+		return fmt.Errorf("Error while reconciling routes %v", err)
+	}
+
+	if failover {
+		r.completeFailover(endpoint, start)
+	}
+	r.gatewayHost = endpoint.Spec.Hostname
+	return nil
 }
 
-func (r *Controller) enqueueEndpoint(obj interface{}) {
-	var key string
-	var err error
-	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+func (r *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
 		utilruntime.HandleError(err)
 		return
 	}
-	klog.V(4).Infof("Enqueueing endpoint for route controller %v", obj)
-	r.endpointWorkqueue.AddRateLimited(key)
+	klog.V(4).Infof("%s changed, enqueueing a reconciliation of the routes", key)
+	r.workqueue.Add(reconcileKey)
 }
 
-func (r *Controller) handleRemovedEndpoint(obj interface{}) {
-	// ideally we should attempt to remove all routes if the endpoint matches our cluster ID
-	var object *v1.Endpoint
-	var ok bool
-	klog.V(4).Infof("Handling object in handleEndpoint")
-	if object, ok = obj.(*v1.Endpoint); !ok {
-		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
-		if !ok {
-			klog.Errorf("Could not convert object %v to an Endpoint", obj)
-			return
-		}
-		object, ok = tombstone.Obj.(*v1.Endpoint)
-		if !ok {
-			klog.Errorf("Could not convert object tombstone %v to an Endpoint", tombstone.Obj)
-			return
-		}
-		klog.V(4).Infof("Recovered deleted object '%s' from tombstone", object.GetName())
-	}
-	klog.V(4).Infof("Informed of removed endpoint for route controller object: %v", object)
-	hostname, err := os.Hostname()
+// cleanRoutes removes all the routes of the submariner table and the rules which select it
+func (r *Controller) cleanRoutes() {
+	routes, err := r.listTableRoutes()
 	if err != nil {
-		klog.Fatalf("Could not retrieve hostname: %v", err)
+		klog.Errorf("Error retrieving the routes of table %d: %v", routeTable, err)
+	} else {
+		r.deleteRoutes(routes)
 	}
-	if object.Spec.Hostname == hostname {
-		r.cleanRoutes()
+
+	rules, err := r.listTableRules()
+	if err != nil {
+		klog.Errorf("Error retrieving the rules of table %d: %v", routeTable, err)
+	} else {
+		r.deleteRules(rules)
 	}
-	klog.V(4).Infof("Removed routes from host")
 }
 
-func (r *Controller) handleRemovedCluster(obj interface{}) {
-	// ideally we should attempt to remove all routes if the endpoint matches our cluster ID
+// removeRoutes removes the routes to the given CIDR blocks and their rules
+func (r *Controller) removeRoutes(cidrBlocks []string) {
+	if len(cidrBlocks) == 0 {
+		return
+	}
+
+	routes, err := r.listTableRoutes()
+	if err != nil {
+		klog.Errorf("Error retrieving the routes of table %d: %v", routeTable, err)
+		return
+	}
+
+	var removedRoutes []netlink.Route
+	for _, route := range routes {
+		if route.Dst != nil && containsString(cidrBlocks, route.Dst.String()) {
+			removedRoutes = append(removedRoutes, route)
+		}
+	}
+	r.deleteRoutes(removedRoutes)
+
+	rules, err := r.listTableRules()
+	if err != nil {
+		klog.Errorf("Error retrieving the rules of table %d: %v", routeTable, err)
+		return
+	}
+
+	var removedRules []netlink.Rule
+	for _, rule := range rules {
+		if rule.Dst != nil && containsString(cidrBlocks, rule.Dst.String()) {
+			removedRules = append(removedRules, rule)
+		}
+	}
+	r.deleteRules(removedRules)
 }
 
-func (r *Controller) cleanRoutes() {
-	link, err := netlink.LinkByName(r.link.Name)
+// removeMainTableRoutes removes the routes to the remote CIDR blocks through a gateway which previous versions
+// installed in the main table, the other routes of the main table are left alone
+func (r *Controller) removeMainTableRoutes() {
+	link, err := r.netLink.LinkByName(r.link.Name)
 	if err != nil {
 		klog.Errorf("Error retrieving link by name %s: %v", r.link.Name, err)
 		return
 	}
-	currentRouteList, err := netlink.RouteList(link, syscall.AF_INET)
+
+	routes, err := r.netLink.RouteList(link, syscall.AF_INET)
 	if err != nil {
-		klog.Errorf("Error retrieving routes: %v", err)
+		klog.Errorf("Error retrieving routes for link %s: %v", r.link.Name, err)
 		return
 	}
-	for _, route := range currentRouteList {
-		klog.V(6).Infof("Processing route %v", route)
-		if route.Dst == nil || route.Gw == nil {
-			klog.V(6).Infof("Found nil gw or dst")
-		} else {
-			if containsString(r.subnets, route.Dst.String()) {
-				klog.V(6).Infof("Removing route %s", route.String())
-				if err = netlink.RouteDel(&route); err != nil {
-					klog.Errorf("Error removing route %s: %v", route.String(), err)
-				}
-			}
+
+	var removed []netlink.Route
+	for _, route := range routes {
+		if route.Dst != nil && route.Gw != nil && containsString(r.subnets, route.Dst.String()) {
+			removed = append(removed, route)
 		}
 	}
+	r.deleteRoutes(removed)
 }
 
+// cleanXfrmPolicies removes the XFRM policies that charon installed for the cables while this node was the gateway,
+// the policies of other IPsec software are left alone
 func (r *Controller) cleanXfrmPolicies() {
 
-	currentXfrmPolicyList, err := netlink.XfrmPolicyList(syscall.AF_INET)
+	currentXfrmPolicyList, err := r.netLink.XfrmPolicyList(syscall.AF_INET)
 
 	if err != nil {
 		klog.Errorf("Error retrieving current xfrm policies: %v", err)
@@ -316,84 +522,218 @@ func (r *Controller) cleanXfrmPolicies() {
 	}
 
 	for _, xfrmPolicy := range currentXfrmPolicyList {
+		if !isSubmarinerXfrmPolicy(&xfrmPolicy, r.subnets) {
+			continue
+		}
+
 		klog.V(6).Infof("Deleting XFRM policy %s", xfrmPolicy.String())
-		if err = netlink.XfrmPolicyDel(&xfrmPolicy); err != nil {
+		if err = r.netLink.XfrmPolicyDel(&xfrmPolicy); err != nil {
 			klog.Errorf("Error Deleting XFRM policy %s: %v", xfrmPolicy.String(), err)
 		}
 	}
 }
 
-// Reconcile the routes installed on this device using rtnetlink
-func (r *Controller) reconcileRoutes() error {
-	link, err := netlink.LinkByName(r.link.Name)
-	if err != nil {
-		return fmt.Errorf("Error retrieving link by name %s: %v", r.link.Name, err)
+// isSubmarinerXfrmPolicy returns whether the policy belongs to a cable, i.e. its template has a reqid of the cables
+// and one of its selectors overlaps a remote CIDR block
+func isSubmarinerXfrmPolicy(policy *netlink.XfrmPolicy, cidrBlocks []string) bool {
+	ownReqID := false
+	for _, tmpl := range policy.Tmpls {
+		if ipsec.IsOwnReqID(tmpl.Reqid) {
+			ownReqID = true
+			break
+		}
 	}
 
-	currentRouteList, err := netlink.RouteList(link, syscall.AF_INET)
-
-	if err != nil {
-		return fmt.Errorf("Error retrieving routes for link %s: %v", r.link.Name, err)
+	if !ownReqID {
+		return false
 	}
 
-	// First lets delete all of the routes that don't match
-	for _, route := range currentRouteList {
-		// contains(endpoint destinations, route destination string, and the route gateway is our actual destination
-		klog.V(6).Infof("Processing route %v", route)
-		if route.Dst == nil || route.Gw == nil {
-			klog.V(6).Infof("Found nil gw or dst")
-		} else {
-			if containsString(r.subnets, route.Dst.String()) && route.Gw.Equal(r.gw) {
-				klog.V(6).Infof("Found route %s with gw %s already installed", route.String(), route.Gw.String())
-			} else {
-				klog.V(6).Infof("Removing route %s", route.String())
-				if err = netlink.RouteDel(&route); err != nil {
-					klog.Errorf("Error removing route %s: %v", route.String(), err)
-				}
-			}
+	for _, cidrBlock := range cidrBlocks {
+		_, ipNet, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			continue
+		}
+
+		if overlaps(ipNet, policy.Src) || overlaps(ipNet, policy.Dst) {
+			return true
 		}
 	}
+	return false
+}
 
-	currentRouteList, err = netlink.RouteList(link, syscall.AF_INET)
+func overlaps(a, b *net.IPNet) bool {
+	return a != nil && b != nil && (a.Contains(b.IP) || b.Contains(a.IP))
+}
+
+// Reconcile the routes of the submariner table through the VXLAN overlay, and the rules which select the table for
+// the remote CIDR blocks, using rtnetlink
+func (r *Controller) reconcileRoutes() error {
+	link, err := r.netLink.LinkByName(vxlanInterface)
+	if err != nil {
+		return fmt.Errorf("Error retrieving link by name %s: %v", vxlanInterface, err)
+	}
 
+	routes, err := r.listTableRoutes()
 	if err != nil {
-		return fmt.Errorf("Error retrieving routes for link %s: %v", r.link.Name, err)
+		return fmt.Errorf("Error retrieving the routes of table %d: %v", routeTable, err)
 	}
 
-	// let's now add the routes that are missing
-	for _, cidrBlock := range r.subnets {
+	stale, missing := routesToReconcile(routes, r.subnets, r.gw, link.Attrs().Index)
+	r.deleteRoutes(stale)
+
+	for _, cidrBlock := range missing {
 		_, dst, err := net.ParseCIDR(cidrBlock)
 		if err != nil {
 			klog.Errorf("Error parsing cidr block %s: %v", cidrBlock, err)
-			break
+			continue
 		}
+
+		// The node IP is kept as the source of the host network traffic, rather than its overlay IP
 		route := netlink.Route{
 			Dst:       dst,
 			Gw:        r.gw,
+			Src:       r.nodeIP,
 			LinkIndex: link.Attrs().Index,
+			Table:     routeTable,
 		}
-		found := false
-		for _, curRoute := range currentRouteList {
-			if curRoute.Gw == nil || curRoute.Dst == nil {
+		klog.V(6).Infof("Adding route %s", route.String())
+		if err = r.netLink.RouteAdd(&route); err != nil {
+			klog.Errorf("Error adding route %s: %v", route.String(), err)
+		}
+	}
 
-			} else {
-				if curRoute.Gw.Equal(route.Gw) && curRoute.Dst == route.Dst {
-					klog.V(6).Infof("Found equivalent route, not adding")
-					found = true
-				}
-			}
+	var standbyGws []net.IP
+	for _, ip := range r.standbyGateways(r.vxlanGateway) {
+		standbyGws = append(standbyGws, vxlanIP(ip))
+	}
+
+	stale, missingStandby := standbyRoutesToReconcile(routes, r.subnets, standbyGws, link.Attrs().Index)
+	r.deleteRoutes(stale)
+
+	for i := range missingStandby {
+		missingStandby[i].Src = r.nodeIP
+		klog.V(6).Infof("Adding standby route %s", missingStandby[i].String())
+		if err = r.netLink.RouteAdd(&missingStandby[i]); err != nil {
+			klog.Errorf("Error adding standby route %s: %v", missingStandby[i].String(), err)
 		}
+	}
 
-		if !found {
-			err = netlink.RouteAdd(&route)
-			if err != nil {
-				klog.Errorf("Error adding route %s: %v", route.String(), err)
-			}
+	return r.reconcileRules()
+}
+
+// reconcileRules ensures that the lookups of the remote CIDR blocks, and only them, use the submariner table
+func (r *Controller) reconcileRules() error {
+	rules, err := r.listTableRules()
+	if err != nil {
+		return fmt.Errorf("Error retrieving the rules of table %d: %v", routeTable, err)
+	}
+
+	stale, missing := rulesToReconcile(rules, r.subnets)
+	r.deleteRules(stale)
+
+	for _, cidrBlock := range missing {
+		_, dst, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			klog.Errorf("Error parsing cidr block %s: %v", cidrBlock, err)
+			continue
+		}
+
+		rule := netlink.NewRule()
+		rule.Dst = dst
+		rule.Table = routeTable
+		rule.Priority = rulePriority
+		klog.V(6).Infof("Adding the rule to %s", cidrBlock)
+		if err = r.netLink.RuleAdd(rule); err != nil {
+			klog.Errorf("Error adding the rule to %s: %v", cidrBlock, err)
 		}
 	}
 	return nil
 }
 
+// routesToReconcile returns the routes of the submariner table which don't lead to one of the CIDR blocks through the
+// gateway, and the CIDR blocks without a route. The standby routes are left to standbyRoutesToReconcile.
+func routesToReconcile(routes []netlink.Route, cidrBlocks []string, gw net.IP, linkIndex int) ([]netlink.Route, []string) {
+	var stale []netlink.Route
+	var present []string
+	for _, route := range routes {
+		if isStandbyRoute(route) {
+			continue
+		}
+
+		if route.Dst != nil && containsString(cidrBlocks, route.Dst.String()) && !containsString(present, route.Dst.String()) &&
+			route.Gw.Equal(gw) && route.LinkIndex == linkIndex {
+			klog.V(6).Infof("Found route %s with gw %s already installed", route.String(), route.Gw.String())
+			present = append(present, route.Dst.String())
+		} else {
+			stale = append(stale, route)
+		}
+	}
+	return stale, missingCidrBlocks(cidrBlocks, present)
+}
+
+// rulesToReconcile returns the rules selecting the submariner table which don't match one of the CIDR blocks, and the
+// CIDR blocks without a rule
+func rulesToReconcile(rules []netlink.Rule, cidrBlocks []string) ([]netlink.Rule, []string) {
+	var stale []netlink.Rule
+	var present []string
+	for _, rule := range rules {
+		if rule.Dst != nil && containsString(cidrBlocks, rule.Dst.String()) && !containsString(present, rule.Dst.String()) &&
+			rule.Priority == rulePriority {
+			present = append(present, rule.Dst.String())
+		} else {
+			stale = append(stale, rule)
+		}
+	}
+	return stale, missingCidrBlocks(cidrBlocks, present)
+}
+
+func missingCidrBlocks(cidrBlocks, present []string) []string {
+	var missing []string
+	for _, cidrBlock := range cidrBlocks {
+		if !containsString(present, cidrBlock) {
+			missing = append(missing, cidrBlock)
+		}
+	}
+	return missing
+}
+
+func (r *Controller) listTableRoutes() ([]netlink.Route, error) {
+	return r.netLink.RouteListFiltered(syscall.AF_INET, &netlink.Route{Table: routeTable}, netlink.RT_FILTER_TABLE)
+}
+
+func (r *Controller) listTableRules() ([]netlink.Rule, error) {
+	rules, err := r.netLink.RuleList(syscall.AF_INET)
+	if err != nil {
+		return nil, err
+	}
+
+	var tableRules []netlink.Rule
+	for _, rule := range rules {
+		if rule.Table == routeTable {
+			tableRules = append(tableRules, rule)
+		}
+	}
+	return tableRules, nil
+}
+
+func (r *Controller) deleteRoutes(routes []netlink.Route) {
+	for i := range routes {
+		klog.V(6).Infof("Removing route %s", routes[i].String())
+		if err := r.netLink.RouteDel(&routes[i]); err != nil {
+			klog.Errorf("Error removing route %s: %v", routes[i].String(), err)
+		}
+	}
+}
+
+func (r *Controller) deleteRules(rules []netlink.Rule) {
+	for i := range rules {
+		klog.V(6).Infof("Removing the rule to %s", rules[i].Dst)
+		if err := r.netLink.RuleDel(&rules[i]); err != nil {
+			klog.Errorf("Error removing the rule to %s: %v", rules[i].Dst, err)
+		}
+	}
+}
+
 func containsString(c []string, s string) bool {
 	for _, v := range c {
 		if v == s {
@@ -1,10 +1,22 @@
 package route
 
 import (
+	"fmt"
+	"net"
+	"runtime"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	v1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	listers "github.com/rancher/submariner/pkg/client/listers/submariner.io/v1"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
 var _ = Describe("Route", func() {
@@ -27,6 +39,229 @@ var _ = Describe("Route", func() {
 		})
 	})
 
+	Describe("Function removeCidrBlocks", func() {
+		Context("When input CIDR blocks are present in the existing subnets", func() {
+			It("Should remove them from subnets", func() {
+				routeController := Controller{subnets: []string{"10.10.10.0/24", "192.168.1.0/24", "172.16.0.0/16"}}
+				routeController.removeCidrBlocks([]string{"192.168.1.0/24", "10.20.0.0/16"})
+				want := []string{"10.10.10.0/24", "172.16.0.0/16"}
+				Expect(routeController.subnets).To(Equal(want))
+			})
+		})
+	})
+
+	Describe("Function enqueue", func() {
+		It("Should coalesce the changes onto the reconciliation key", func() {
+			routeController := &Controller{workqueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())}
+			defer routeController.workqueue.ShutDown()
+
+			routeController.enqueue(newCluster("east", "10.0.0.0/16", "10.96.0.0/16"))
+			routeController.enqueue(newCluster("west", "10.1.0.0/16", "10.97.0.0/16"))
+			Expect(routeController.workqueue.Len()).To(Equal(1))
+
+			key, _ := routeController.workqueue.Get()
+			Expect(key).To(Equal(reconcileKey))
+		})
+	})
+
+	Describe("Function setClusterCidrBlocks", func() {
+		var routeController *Controller
+
+		BeforeEach(func() {
+			routeController = &Controller{clusterCidrBlocks: map[string][]string{}}
+			routeController.setClusterCidrBlocks("east", []string{"10.0.0.0/16", "10.96.0.0/16"})
+			routeController.setClusterCidrBlocks("west", []string{"10.1.0.0/16", "10.96.0.0/16"})
+		})
+
+		Context("When the CIDR blocks of a cluster change", func() {
+			It("Should return the previous ones that aren't used by other clusters", func() {
+				removed := routeController.setClusterCidrBlocks("east", []string{"10.2.0.0/16"})
+				Expect(removed).To(Equal([]string{"10.0.0.0/16"}))
+				Expect(routeController.subnets).To(ConsistOf("10.1.0.0/16", "10.96.0.0/16", "10.2.0.0/16"))
+			})
+		})
+
+		Context("When a cluster is forgotten", func() {
+			It("Should remove all its CIDR blocks that aren't used by other clusters", func() {
+				removed := routeController.setClusterCidrBlocks("west", nil)
+				Expect(removed).To(Equal([]string{"10.1.0.0/16"}))
+				Expect(routeController.subnets).To(ConsistOf("10.0.0.0/16", "10.96.0.0/16"))
+				Expect(routeController.clusterCidrBlocks).ToNot(HaveKey("west"))
+			})
+		})
+	})
+
+	Describe("Function syncClusterCidrBlocks", func() {
+		var routeController *Controller
+
+		BeforeEach(func() {
+			routeController = &Controller{clusterID: "local", clusterCidrBlocks: map[string][]string{}}
+			routeController.setClusterCidrBlocks("east", []string{"10.0.0.0/16", "10.96.0.0/16"})
+			routeController.setClusterCidrBlocks("west", []string{"10.1.0.0/16", "10.97.0.0/16"})
+		})
+
+		Context("When a cluster is missing from the lister", func() {
+			It("Should forget it and return its CIDR blocks", func() {
+				removed := routeController.syncClusterCidrBlocks([]*v1.Cluster{
+					newCluster("local", "10.9.0.0/16", "10.99.0.0/16"),
+					newCluster("east", "10.0.0.0/16", "10.96.0.0/16"),
+				})
+				Expect(removed).To(ConsistOf("10.1.0.0/16", "10.97.0.0/16"))
+				Expect(routeController.subnets).To(ConsistOf("10.0.0.0/16", "10.96.0.0/16"))
+				Expect(routeController.clusterCidrBlocks).To(HaveLen(1))
+			})
+		})
+
+		Context("When the CIDR blocks of a cluster moved to another cluster", func() {
+			It("Should keep them", func() {
+				removed := routeController.syncClusterCidrBlocks([]*v1.Cluster{
+					newCluster("north", "10.1.0.0/16", "10.97.0.0/16"),
+					newCluster("east", "10.0.0.0/16", "10.96.0.0/16"),
+				})
+				Expect(removed).To(BeEmpty())
+				Expect(routeController.subnets).To(ConsistOf("10.0.0.0/16", "10.96.0.0/16", "10.1.0.0/16", "10.97.0.0/16"))
+				Expect(routeController.clusterCidrBlocks).To(HaveKey("north"))
+				Expect(routeController.clusterCidrBlocks).ToNot(HaveKey("west"))
+			})
+		})
+	})
+
+	Describe("Function reconcile", func() {
+		var fake *fakeNetlink
+		var clusters, endpoints cache.Indexer
+		var routeController *Controller
+
+		BeforeEach(func() {
+			fake = newFakeNetlink()
+			Expect(fake.LinkAdd(&netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}})).To(Succeed())
+			Expect(fake.LinkAdd(&netlink.Vxlan{LinkAttrs: netlink.LinkAttrs{Name: vxlanInterface}})).To(Succeed())
+
+			clusters = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			endpoints = cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			Expect(clusters.Add(newCluster("local", "10.0.0.0/16", "10.96.0.0/16"))).To(Succeed())
+			Expect(clusters.Add(newCluster("east", "10.1.0.0/16", "10.97.0.0/16"))).To(Succeed())
+
+			routeController = &Controller{
+				clusterID:         "local",
+				objectNamespace:   "submariner",
+				netLink:           fake,
+				hostname:          "node-1",
+				clusterLister:     listers.NewClusterLister(clusters),
+				endpointLister:    listers.NewEndpointLister(endpoints),
+				link:              &net.Interface{Name: "eth0", Index: fake.links["eth0"].Attrs().Index},
+				nodeIP:            net.ParseIP("10.0.0.5").To4(),
+				clusterCidrBlocks: map[string][]string{},
+			}
+		})
+
+		Context("When the gateway is another node", func() {
+			It("Should route the remote CIDR blocks through it", func() {
+				Expect(endpoints.Add(newEndpoint("local", "node-2", "10.0.0.2"))).To(Succeed())
+
+				Expect(routeController.reconcile()).To(Succeed())
+				Expect(fake.routeStrings()).To(Equal([]string{
+					"10.1.0.0/16 via 240.0.0.2 dev 2 table 150 metric 0 src 10.0.0.5",
+					"10.97.0.0/16 via 240.0.0.2 dev 2 table 150 metric 0 src 10.0.0.5",
+				}))
+				Expect(routeController.gatewayHost).To(Equal("node-2"))
+			})
+
+			It("Should follow the most recent endpoint of the cluster", func() {
+				previous := newEndpoint("local", "node-2", "10.0.0.2")
+				previous.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Minute))
+				Expect(endpoints.Add(previous)).To(Succeed())
+				current := newEndpoint("local", "node-3", "10.0.0.3")
+				current.CreationTimestamp = metav1.Now()
+				Expect(endpoints.Add(current)).To(Succeed())
+				Expect(endpoints.Add(newEndpoint("east", "node-9", "10.1.0.9"))).To(Succeed())
+
+				Expect(routeController.reconcile()).To(Succeed())
+				Expect(fake.routeStrings()).To(ContainElement("10.1.0.0/16 via 240.0.0.3 dev 2 table 150 metric 0 src 10.0.0.5"))
+				Expect(routeController.gatewayHost).To(Equal("node-3"))
+			})
+		})
+
+		Context("When this node is the gateway", func() {
+			It("Should remove the routes", func() {
+				Expect(endpoints.Add(newEndpoint("local", "node-2", "10.0.0.2"))).To(Succeed())
+				Expect(routeController.reconcile()).To(Succeed())
+
+				endpoints.Replace([]interface{}{newEndpoint("local", "node-1", "10.0.0.5")}, "")
+				Expect(routeController.reconcile()).To(Succeed())
+				Expect(fake.routeStrings()).To(BeEmpty())
+				Expect(fake.ruleStrings()).To(BeEmpty())
+				Expect(routeController.gw).To(BeNil())
+			})
+		})
+
+		Context("When a remote cluster is removed", func() {
+			It("Should remove its routes", func() {
+				Expect(endpoints.Add(newEndpoint("local", "node-2", "10.0.0.2"))).To(Succeed())
+				Expect(routeController.reconcile()).To(Succeed())
+
+				Expect(clusters.Delete(newCluster("east", "10.1.0.0/16", "10.97.0.0/16"))).To(Succeed())
+				Expect(routeController.reconcile()).To(Succeed())
+				Expect(fake.routeStrings()).To(BeEmpty())
+				Expect(routeController.subnets).To(BeEmpty())
+			})
+		})
+
+		Context("When the cluster has no endpoint", func() {
+			It("Should leave the routes alone", func() {
+				Expect(routeController.reconcile()).To(Succeed())
+				Expect(fake.routeStrings()).To(BeEmpty())
+				Expect(fake.changes).To(BeZero())
+				Expect(routeController.subnets).To(ConsistOf("10.1.0.0/16", "10.97.0.0/16"))
+			})
+		})
+	})
+
+	Describe("Function routesToReconcile", func() {
+		gw := net.ParseIP("192.168.0.1")
+
+		It("Should return the routes to remove and the CIDR blocks without a route", func() {
+			routes := []netlink.Route{
+				{Dst: parseCIDR("10.0.0.0/16"), Gw: gw, LinkIndex: 2, Table: routeTable},
+				{Dst: parseCIDR("10.1.0.0/16"), Gw: net.ParseIP("192.168.0.2"), LinkIndex: 2, Table: routeTable},
+				{Dst: parseCIDR("10.2.0.0/16"), Gw: gw, LinkIndex: 2, Table: routeTable},
+			}
+			stale, missing := routesToReconcile(routes, []string{"10.0.0.0/16", "10.1.0.0/16", "10.3.0.0/16"}, gw, 2)
+			Expect(stale).To(Equal([]netlink.Route{routes[1], routes[2]}))
+			Expect(missing).To(Equal([]string{"10.1.0.0/16", "10.3.0.0/16"}))
+		})
+
+		It("Should replace the routes through another link", func() {
+			routes := []netlink.Route{{Dst: parseCIDR("10.0.0.0/16"), Gw: gw, LinkIndex: 3, Table: routeTable}}
+			stale, missing := routesToReconcile(routes, []string{"10.0.0.0/16"}, gw, 2)
+			Expect(stale).To(Equal(routes))
+			Expect(missing).To(Equal([]string{"10.0.0.0/16"}))
+		})
+
+		It("Should leave the standby routes alone", func() {
+			routes := []netlink.Route{
+				{Dst: parseCIDR("10.0.0.0/16"), Gw: gw, LinkIndex: 2, Table: routeTable},
+				{Dst: parseCIDR("10.0.0.0/16"), Gw: net.ParseIP("192.168.0.2"), LinkIndex: 2, Table: routeTable, Priority: standbyRouteMetric},
+			}
+			stale, missing := routesToReconcile(routes, []string{"10.0.0.0/16"}, gw, 2)
+			Expect(stale).To(BeEmpty())
+			Expect(missing).To(BeEmpty())
+		})
+	})
+
+	Describe("Function rulesToReconcile", func() {
+		It("Should return the rules to remove and the CIDR blocks without a rule", func() {
+			rules := []netlink.Rule{
+				{Dst: parseCIDR("10.0.0.0/16"), Table: routeTable, Priority: rulePriority},
+				{Dst: parseCIDR("10.1.0.0/16"), Table: routeTable, Priority: rulePriority},
+				{Dst: parseCIDR("10.3.0.0/16"), Table: routeTable, Priority: 100},
+				{Table: routeTable, Priority: rulePriority},
+			}
+			stale, missing := rulesToReconcile(rules, []string{"10.0.0.0/16", "10.3.0.0/16"})
+			Expect(stale).To(Equal([]netlink.Rule{rules[1], rules[2], rules[3]}))
+			Expect(missing).To(Equal([]string{"10.3.0.0/16"}))
+		})
+	})
+
 	Describe("Function containsString", func() {
 		Context("When the given array of strings contains specified string", func() {
 			It("Should return true", func() {
@@ -42,6 +277,96 @@ var _ = Describe("Route", func() {
 	})
 })
 
+func newCluster(clusterID, clusterCIDR, serviceCIDR string) *v1.Cluster {
+	return &v1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterID, Namespace: "submariner"},
+		Spec: v1.ClusterSpec{
+			ClusterID:   clusterID,
+			ClusterCIDR: []string{clusterCIDR},
+			ServiceCIDR: []string{serviceCIDR},
+		},
+	}
+}
+
+func newEndpoint(clusterID, hostname, privateIP string) *v1.Endpoint {
+	return &v1.Endpoint{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterID + "-" + hostname, Namespace: "submariner"},
+		Spec: v1.EndpointSpec{
+			ClusterID: clusterID,
+			Hostname:  hostname,
+			PrivateIP: net.ParseIP(privateIP).To4(),
+		},
+	}
+}
+
+func parseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	Expect(err).ToNot(HaveOccurred())
+	return ipNet
+}
+
+// useTestNetns runs the specs of the container in a new network namespace, or skips them when it can't be created
+func useTestNetns() {
+	var origin, testNS netns.NsHandle
+
+	BeforeEach(func() {
+		// The network namespace belongs to the thread, which must not run other goroutines until it's restored
+		runtime.LockOSThread()
+
+		var err error
+		origin, err = netns.Get()
+		Expect(err).ToNot(HaveOccurred())
+
+		testNS, err = netns.New()
+		if err != nil {
+			origin.Close()
+			runtime.UnlockOSThread()
+			Skip(fmt.Sprintf("Unable to create a network namespace: %v", err))
+		}
+	})
+
+	AfterEach(func() {
+		if testNS.IsOpen() {
+			testNS.Close()
+			Expect(netns.Set(origin)).To(Succeed())
+			origin.Close()
+			runtime.UnlockOSThread()
+		}
+	})
+}
+
+// newNetnsController returns a controller whose routes lead to a gateway through a VXLAN interface on a veth link,
+// in the network namespace of useTestNetns
+func newNetnsController(standbyGatewayIPs ...net.IP) *Controller {
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: "eth-test", MTU: 1500}, PeerName: "eth-peer"}
+	if err := netlink.LinkAdd(veth); err != nil {
+		Skip(fmt.Sprintf("Unable to create a link: %v", err))
+	}
+	Expect(netlink.LinkSetUp(veth)).To(Succeed())
+	addr, err := netlink.ParseAddr("10.0.0.5/24")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(netlink.AddrAdd(veth, addr)).To(Succeed())
+
+	link, err := net.InterfaceByName(veth.Name)
+	Expect(err).ToNot(HaveOccurred())
+
+	gatewayIP := net.ParseIP("10.0.0.2").To4()
+	routeController := &Controller{
+		netLink:           &netlink.Handle{},
+		link:              link,
+		subnets:           []string{"10.1.0.0/16", "10.97.0.0/16"},
+		vxlanGateway:      gatewayIP,
+		gw:                vxlanIP(gatewayIP),
+		standbyGatewayIPs: standbyGatewayIPs,
+	}
+	if err = routeController.ensureVxlanInterface(); err != nil {
+		Skip(fmt.Sprintf("Unable to create the VXLAN interface: %v", err))
+	}
+	Expect(routeController.setVxlanGateway(gatewayIP)).To(Succeed())
+	Expect(routeController.reconcileRoutes()).To(Succeed())
+	return routeController
+}
+
 func TestRoute(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Route Suite")
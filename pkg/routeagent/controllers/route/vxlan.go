@@ -0,0 +1,272 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+	"k8s.io/klog"
+)
+
+/*
+ * The nodes reach the gateway through a VXLAN overlay, so that they don't have to be on the same L2 segment as the
+ * gateway. Each node has a VXLAN interface whose IP is its own IP moved into the overlay CIDR. On the nodes other
+ * than the gateway, the default FDB entry of the interface sends the frames to the gateway, and the routes to the
+ * remote clusters go through the overlay IP of the gateway. The standby gateways, if any, get a default FDB entry too,
+ * so that their standby routes resolve. The gateway learns the VTEPs of the nodes from the frames
+ * it receives, and sends the replies of the remote clusters to the pods through the cluster network as before.
+ *
+ * The nodes derive the overlay IP of the gateway from the private IP of its Endpoint, which may not be the IP of its
+ * data interface, so the gateway assigns that overlay IP to its VXLAN interface as well. Since the overlay IPs only
+ * keep the lower 24 bits of the node IPs, a node whose overlay IP is the one of a gateway but whose IP differs
+ * refuses to route through that gateway rather than sending the traffic to itself.
+ */
+
+const (
+	vxlanInterface = "vx-submariner"
+	vxlanVNI       = 100
+	vxlanPort      = 4800
+	// The VXLAN and outer headers
+	vxlanOverhead = 50
+)
+
+// The overlay CIDR, the overlay IP of a node keeps the lower 24 bits of its IP
+var vxlanOverlayCIDR = net.IPNet{IP: net.IPv4(240, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}
+
+// vxlanIP returns the overlay IP of the node with the given IP
+func vxlanIP(nodeIP net.IP) net.IP {
+	ip := nodeIP.To4()
+	if ip == nil {
+		return nil
+	}
+	return net.IPv4(vxlanOverlayCIDR.IP[0], ip[1], ip[2], ip[3]).To4()
+}
+
+// ensureVxlanInterface creates the VXLAN interface of the node, or recreates it when its settings changed, and
+// assigns the overlay IP of the node to it
+func (r *Controller) ensureVxlanInterface() error {
+	nodeIP, err := linkIPv4(r.link)
+	if err != nil {
+		return err
+	}
+
+	vxlan := &netlink.Vxlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name: vxlanInterface,
+			MTU:  r.link.MTU - vxlanOverhead,
+		},
+		VxlanId:      vxlanVNI,
+		VtepDevIndex: r.link.Index,
+		SrcAddr:      nodeIP,
+		Port:         vxlanPort,
+		Learning:     true,
+	}
+
+	existing, err := r.netLink.LinkByName(vxlanInterface)
+	if err == nil {
+		if isSameVxlan(existing, vxlan) {
+			vxlan = existing.(*netlink.Vxlan)
+		} else {
+			klog.Infof("The settings of the %s interface changed, recreating it", vxlanInterface)
+			if err = r.netLink.LinkDel(existing); err != nil {
+				return fmt.Errorf("Error deleting the %s interface: %v", vxlanInterface, err)
+			}
+			existing = nil
+		}
+	} else if _, ok := err.(netlink.LinkNotFoundError); !ok {
+		return fmt.Errorf("Error retrieving the %s interface: %v", vxlanInterface, err)
+	} else {
+		existing = nil
+	}
+
+	if existing == nil {
+		klog.Infof("Creating the %s interface with VNI %d on %s", vxlanInterface, vxlanVNI, r.link.Name)
+		if err = r.netLink.LinkAdd(vxlan); err != nil {
+			return fmt.Errorf("Error creating the %s interface: %v", vxlanInterface, err)
+		}
+	}
+
+	if err = r.netLink.LinkSetUp(vxlan); err != nil {
+		return fmt.Errorf("Error bringing up the %s interface: %v", vxlanInterface, err)
+	}
+
+	addr := &netlink.Addr{IPNet: &net.IPNet{IP: vxlanIP(nodeIP), Mask: vxlanOverlayCIDR.Mask}}
+	if err = r.netLink.AddrReplace(vxlan, addr); err != nil {
+		return fmt.Errorf("Error assigning %s to the %s interface: %v", addr.IPNet, vxlanInterface, err)
+	}
+
+	// A recreated interface lost the overlay IP of the endpoint of this gateway
+	if r.gatewayOverlayIP != nil {
+		addr = &netlink.Addr{IPNet: &net.IPNet{IP: r.gatewayOverlayIP, Mask: net.CIDRMask(32, 32)}}
+		if err = r.netLink.AddrReplace(vxlan, addr); err != nil {
+			return fmt.Errorf("Error assigning %s to the %s interface: %v", addr.IPNet, vxlanInterface, err)
+		}
+	}
+
+	// The traffic of the pods arrives on the gateway through the overlay while the routes back to the pods go
+	// through the cluster network, which a strict reverse path filter would drop
+	if err = setLooseRPFilter(vxlanInterface); err != nil {
+		return err
+	}
+
+	r.nodeIP = nodeIP
+	return nil
+}
+
+// setGatewayOverlayIP assigns the overlay IP which the other nodes derive from the private IP of the endpoint of
+// this gateway to the VXLAN interface, unless it's the overlay IP of the node already. It's removed again when
+// privateIP is nil because the gateway moved to another node.
+func (r *Controller) setGatewayOverlayIP(privateIP net.IP) error {
+	var ip net.IP
+	if privateIP != nil && !vxlanIP(privateIP).Equal(vxlanIP(r.nodeIP)) {
+		ip = vxlanIP(privateIP)
+	}
+
+	if ip.Equal(r.gatewayOverlayIP) {
+		return nil
+	}
+
+	link, err := r.netLink.LinkByName(vxlanInterface)
+	if err != nil {
+		return fmt.Errorf("Error retrieving the %s interface: %v", vxlanInterface, err)
+	}
+
+	if r.gatewayOverlayIP != nil {
+		addr := &netlink.Addr{IPNet: &net.IPNet{IP: r.gatewayOverlayIP, Mask: net.CIDRMask(32, 32)}}
+		klog.Infof("Removing the gateway overlay IP %s from the %s interface", r.gatewayOverlayIP, vxlanInterface)
+		if err = r.netLink.AddrDel(link, addr); err != nil && err != syscall.EADDRNOTAVAIL {
+			return fmt.Errorf("Error removing %s from the %s interface: %v", addr.IPNet, vxlanInterface, err)
+		}
+		r.gatewayOverlayIP = nil
+	}
+
+	if ip != nil {
+		addr := &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}}
+		klog.Infof("Assigning the overlay IP %s of the endpoint private IP %s to the %s interface", ip, privateIP,
+			vxlanInterface)
+		if err = r.netLink.AddrReplace(link, addr); err != nil {
+			return fmt.Errorf("Error assigning %s to the %s interface: %v", addr.IPNet, vxlanInterface, err)
+		}
+		r.gatewayOverlayIP = ip
+	}
+	return nil
+}
+
+// overlayIPCollides returns true if the overlay IP of the gateway with the given IP is the one of this node, which
+// is another node
+func (r *Controller) overlayIPCollides(gatewayIP net.IP) bool {
+	return r.nodeIP != nil && !gatewayIP.Equal(r.nodeIP) && vxlanIP(gatewayIP).Equal(vxlanIP(r.nodeIP))
+}
+
+// setVxlanGateway points the default FDB entries of the VXLAN interface at the gateway and the standby gateways, or
+// removes them when the gateway is nil because this node is the gateway
+func (r *Controller) setVxlanGateway(gatewayIP net.IP) error {
+	link, err := r.netLink.LinkByName(vxlanInterface)
+	if err != nil {
+		return fmt.Errorf("Error retrieving the %s interface: %v", vxlanInterface, err)
+	}
+
+	neighs, err := r.netLink.NeighList(link.Attrs().Index, syscall.AF_BRIDGE)
+	if err != nil {
+		return fmt.Errorf("Error listing the FDB entries of the %s interface: %v", vxlanInterface, err)
+	}
+
+	var vteps []net.IP
+	if gatewayIP != nil {
+		vteps = append([]net.IP{gatewayIP}, r.standbyGateways(gatewayIP)...)
+	}
+
+	stale, missing := fdbEntriesToReconcile(neighs, vteps)
+	for i := range stale {
+		klog.Infof("Removing the FDB entry to %s from the %s interface", stale[i].IP, vxlanInterface)
+		if err = r.netLink.NeighDel(&stale[i]); err != nil {
+			klog.Errorf("Error removing the FDB entry to %s: %v", stale[i].IP, err)
+		}
+	}
+
+	for _, vtep := range missing {
+		klog.Infof("Sending the traffic of the %s interface to the gateway %s", vxlanInterface, vtep)
+		err = r.netLink.NeighAppend(&netlink.Neigh{
+			LinkIndex:    link.Attrs().Index,
+			Family:       syscall.AF_BRIDGE,
+			State:        netlink.NUD_PERMANENT,
+			Flags:        netlink.NTF_SELF,
+			IP:           vtep,
+			HardwareAddr: make(net.HardwareAddr, 6),
+		})
+		if err != nil {
+			return fmt.Errorf("Error adding the FDB entry to %s: %v", vtep, err)
+		}
+	}
+	return nil
+}
+
+// fdbEntriesToReconcile returns the default FDB entries which don't lead to one of the VTEPs, and the VTEPs without
+// a default FDB entry
+func fdbEntriesToReconcile(neighs []netlink.Neigh, vteps []net.IP) ([]netlink.Neigh, []net.IP) {
+	var stale []netlink.Neigh
+	var present []net.IP
+	for _, neigh := range neighs {
+		if !isDefaultFdbEntry(neigh) {
+			continue
+		}
+
+		if containsIP(vteps, neigh.IP) && !containsIP(present, neigh.IP) {
+			present = append(present, neigh.IP)
+		} else {
+			stale = append(stale, neigh)
+		}
+	}
+
+	var missing []net.IP
+	for _, vtep := range vteps {
+		if !containsIP(present, vtep) {
+			missing = append(missing, vtep)
+		}
+	}
+	return stale, missing
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, i := range ips {
+		if i.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDefaultFdbEntry(neigh netlink.Neigh) bool {
+	if len(neigh.HardwareAddr) == 0 {
+		return false
+	}
+
+	for _, b := range neigh.HardwareAddr {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isSameVxlan(link netlink.Link, vxlan *netlink.Vxlan) bool {
+	existing, ok := link.(*netlink.Vxlan)
+	return ok && existing.VxlanId == vxlan.VxlanId && existing.VtepDevIndex == vxlan.VtepDevIndex &&
+		existing.SrcAddr.Equal(vxlan.SrcAddr) && existing.Port == vxlan.Port
+}
+
+// linkIPv4 returns the first IPv4 address of the interface
+func linkIPv4(link *net.Interface) (net.IP, error) {
+	addrs, err := link.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving the addresses of %s: %v", link.Name, err)
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no IPv4 address", link.Name)
+}
@@ -0,0 +1,78 @@
+package route
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("VXLAN", func() {
+	Describe("Function vxlanIP", func() {
+		It("Should keep the lower 24 bits of the node IP in the overlay CIDR", func() {
+			Expect(vxlanIP(net.ParseIP("10.20.30.40")).String()).To(Equal("240.20.30.40"))
+			Expect(vxlanOverlayCIDR.Contains(vxlanIP(net.ParseIP("192.168.1.5")))).To(BeTrue())
+		})
+
+		It("Should return nil for IPv6 addresses", func() {
+			Expect(vxlanIP(net.ParseIP("fd00::1"))).To(BeNil())
+		})
+	})
+
+	Describe("Function fdbEntriesToReconcile", func() {
+		zeroMAC := make(net.HardwareAddr, 6)
+		learned := netlink.Neigh{IP: net.ParseIP("10.0.0.7"), HardwareAddr: net.HardwareAddr{0x0a, 0, 0, 0, 0, 1}}
+		oldGateway := netlink.Neigh{IP: net.ParseIP("10.0.0.2"), HardwareAddr: zeroMAC}
+		gateway := netlink.Neigh{IP: net.ParseIP("10.0.0.3"), HardwareAddr: zeroMAC}
+
+		Context("When the gateway moved", func() {
+			It("Should return the default entry to the previous gateway", func() {
+				stale, missing := fdbEntriesToReconcile([]netlink.Neigh{learned, oldGateway}, []net.IP{gateway.IP})
+				Expect(stale).To(Equal([]netlink.Neigh{oldGateway}))
+				Expect(missing).To(Equal([]net.IP{gateway.IP}))
+			})
+		})
+
+		Context("When the default entry leads to the gateway", func() {
+			It("Should keep it", func() {
+				stale, missing := fdbEntriesToReconcile([]netlink.Neigh{learned, gateway}, []net.IP{gateway.IP})
+				Expect(stale).To(BeEmpty())
+				Expect(missing).To(BeEmpty())
+			})
+		})
+
+		Context("When this node is the gateway", func() {
+			It("Should return all the default entries", func() {
+				stale, missing := fdbEntriesToReconcile([]netlink.Neigh{learned, gateway}, nil)
+				Expect(stale).To(Equal([]netlink.Neigh{gateway}))
+				Expect(missing).To(BeEmpty())
+			})
+		})
+
+		Context("When there are standby gateways", func() {
+			It("Should keep a default entry to each of them", func() {
+				standby := net.ParseIP("10.0.0.4")
+				stale, missing := fdbEntriesToReconcile([]netlink.Neigh{gateway, oldGateway}, []net.IP{gateway.IP, standby})
+				Expect(stale).To(Equal([]netlink.Neigh{oldGateway}))
+				Expect(missing).To(Equal([]net.IP{standby}))
+			})
+		})
+	})
+
+	Describe("Function isSameVxlan", func() {
+		vxlan := &netlink.Vxlan{VxlanId: vxlanVNI, VtepDevIndex: 2, SrcAddr: net.ParseIP("10.0.0.5"), Port: vxlanPort}
+
+		It("Should compare the VNI, device, source address and port", func() {
+			same := *vxlan
+			Expect(isSameVxlan(&same, vxlan)).To(BeTrue())
+
+			moved := *vxlan
+			moved.SrcAddr = net.ParseIP("10.0.0.6")
+			Expect(isSameVxlan(&moved, vxlan)).To(BeFalse())
+
+			Expect(isSameVxlan(&netlink.Dummy{}, vxlan)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,78 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/rancher/submariner/pkg/cableengine/ipsec"
+	"github.com/vishvananda/netlink"
+)
+
+var _ = Describe("XFRM policies", func() {
+	remoteCidrBlocks := []string{"10.1.0.0/16", "10.97.0.0/16"}
+
+	submarinerPolicy := newXfrmPolicy("10.0.0.0/16", "10.1.0.0/16", ipsec.ReqIDBase)
+	// A host VPN whose selectors happen to cover a remote cluster
+	foreignPolicy := newXfrmPolicy("192.168.100.0/24", "10.1.0.0/16", 1)
+	// A policy with a reqid of the cables, e.g. from another charon, whose selectors aren't remote clusters
+	unrelatedPolicy := newXfrmPolicy("172.16.0.0/16", "172.17.0.0/16", ipsec.ReqIDBase+1)
+
+	Describe("Function isSubmarinerXfrmPolicy", func() {
+		It("Should select the policies of the cables only", func() {
+			Expect(isSubmarinerXfrmPolicy(submarinerPolicy, remoteCidrBlocks)).To(BeTrue())
+			Expect(isSubmarinerXfrmPolicy(foreignPolicy, remoteCidrBlocks)).To(BeFalse())
+			Expect(isSubmarinerXfrmPolicy(unrelatedPolicy, remoteCidrBlocks)).To(BeFalse())
+		})
+
+		It("Should select the policies of a remote CIDR block's subnets", func() {
+			policy := newXfrmPolicy("10.97.8.0/24", "10.0.0.0/16", ipsec.ReqIDBase)
+			policy.Dir = netlink.XFRM_DIR_IN
+			Expect(isSubmarinerXfrmPolicy(policy, remoteCidrBlocks)).To(BeTrue())
+		})
+	})
+
+	Describe("Function cleanXfrmPolicies", func() {
+		useTestNetns()
+
+		It("Should remove the policies of the cables and leave the foreign ones", func() {
+			for _, policy := range []*netlink.XfrmPolicy{submarinerPolicy, foreignPolicy, unrelatedPolicy} {
+				if err := netlink.XfrmPolicyAdd(policy); err != nil {
+					Skip(fmt.Sprintf("Unable to add XFRM policies: %v", err))
+				}
+			}
+
+			routeController := &Controller{netLink: &netlink.Handle{}, subnets: remoteCidrBlocks}
+			routeController.cleanXfrmPolicies()
+
+			policies, err := netlink.XfrmPolicyList(syscall.AF_INET)
+			Expect(err).ToNot(HaveOccurred())
+
+			var remaining []string
+			for _, policy := range policies {
+				remaining = append(remaining, policy.Src.String()+" > "+policy.Dst.String())
+			}
+			Expect(remaining).To(ConsistOf("192.168.100.0/24 > 10.1.0.0/16", "172.16.0.0/16 > 172.17.0.0/16"))
+		})
+	})
+})
+
+func newXfrmPolicy(src, dst string, reqID int) *netlink.XfrmPolicy {
+	_, srcNet, _ := net.ParseCIDR(src)
+	_, dstNet, _ := net.ParseCIDR(dst)
+	return &netlink.XfrmPolicy{
+		Src: srcNet,
+		Dst: dstNet,
+		Dir: netlink.XFRM_DIR_OUT,
+		Tmpls: []netlink.XfrmPolicyTmpl{{
+			Src:   net.ParseIP("192.168.0.1"),
+			Dst:   net.ParseIP("192.168.0.2"),
+			Proto: netlink.XFRM_PROTO_ESP,
+			Mode:  netlink.XFRM_MODE_TUNNEL,
+			Reqid: reqID,
+		}},
+	}
+}
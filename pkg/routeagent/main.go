@@ -2,12 +2,16 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"net"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/rancher/submariner/pkg/routeagent/controllers/route"
 	"github.com/rancher/submariner/pkg/util"
+	"github.com/vishvananda/netlink"
 
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
@@ -25,6 +29,17 @@ var (
 type SubmarinerRouteControllerSpecification struct {
 	ClusterID string
 	Namespace string
+	// The port of the drift and failover counters at /debug/vars, 0 disables them
+	MetricsPort int `default:"0"`
+	// The interface which carries the pod traffic, selected by name, by a CIDR which one of its addresses belongs to,
+	// or by the destination of a route through it. The interface of the default route is used when none is set.
+	Interface          string
+	InterfaceCIDR      string
+	InterfaceRouteToIP string
+	// The IPs of the gateway nodes which the standby routes go through, none are installed when empty
+	StandbyGateways []string
+	// The number of workers reconciling the routes
+	Workers int `default:"1"`
 }
 
 func main() {
@@ -53,11 +68,42 @@ func main() {
 
 	submarinerInformerFactory := submarinerInformers.NewSharedInformerFactoryWithOptions(submarinerClient, time.Second*30, submarinerInformers.WithNamespace(srcs.Namespace))
 
-	defLink, err := util.GetDefaultGatewayInterface()
-	routeController := route.NewController(srcs.ClusterID, srcs.Namespace, defLink, submarinerClient, submarinerInformerFactory.Submariner().V1().Clusters(), submarinerInformerFactory.Submariner().V1().Endpoints())
+	link, err := util.GetDataInterface(srcs.Interface, srcs.InterfaceCIDR, srcs.InterfaceRouteToIP)
+	if err != nil {
+		klog.Fatalf("Error selecting the data interface: %v", err)
+	}
+	klog.Infof("Using the %s interface for the pod traffic", link.Name)
+
+	if srcs.Workers < 1 {
+		klog.Fatalf("Invalid number of workers %d", srcs.Workers)
+	}
+
+	var standbyGatewayIPs []net.IP
+	for _, standbyGateway := range srcs.StandbyGateways {
+		ip := net.ParseIP(standbyGateway)
+		if ip == nil || ip.To4() == nil {
+			klog.Fatalf("Invalid standby gateway IP %q", standbyGateway)
+		}
+		standbyGatewayIPs = append(standbyGatewayIPs, ip.To4())
+	}
+
+	routeController := route.NewController(srcs.ClusterID, srcs.Namespace, &netlink.Handle{}, link, standbyGatewayIPs, srcs.Workers, submarinerInformerFactory.Submariner().V1().Clusters(), submarinerInformerFactory.Submariner().V1().Endpoints())
 
 	submarinerInformerFactory.Start(stopCh)
 
+	if srcs.MetricsPort != 0 {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", route.MetricsHandler())
+
+		go func() {
+			addr := fmt.Sprintf(":%d", srcs.MetricsPort)
+			klog.Infof("Serving the metrics on %s", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				klog.Errorf("Error serving the metrics: %v", err)
+			}
+		}()
+	}
+
 	var wg sync.WaitGroup
 
 	wg.Add(1)
@@ -0,0 +1,89 @@
+package topology
+
+import (
+	"sort"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/util"
+)
+
+/*
+ * In a hub-and-spoke topology the hubs are connected to every cluster, and the spokes to the hubs only, so that
+ * the number of cables grows with the number of spokes instead of its square. The clusters without a role are
+ * connected to each other and to the hubs. The traffic between two clusters which aren't connected is forwarded by
+ * the transit hub, the hub with the lowest cluster ID: the subnets of the clusters it forwards for are added to
+ * both sides of the cables to the transit hub, so that the traffic selectors of each side match.
+ */
+
+// IsValidRole returns whether the role is a known role, or no role
+func IsValidRole(role submarinerv1.ClusterRole) bool {
+	return role == "" || role == submarinerv1.ClusterRoleHub || role == submarinerv1.ClusterRoleSpoke
+}
+
+// IsConnected returns whether a cable connects the two clusters
+func IsConnected(a, b *submarinerv1.ClusterSpec) bool {
+	if a.Role == submarinerv1.ClusterRoleSpoke || b.Role == submarinerv1.ClusterRoleSpoke {
+		return a.Role == submarinerv1.ClusterRoleHub || b.Role == submarinerv1.ClusterRoleHub
+	}
+	return true
+}
+
+// TransitHub returns the ID of the hub which forwards the traffic between the clusters that aren't connected, or
+// "" when there are no hubs
+func TransitHub(clusters []*submarinerv1.ClusterSpec) string {
+	hub := ""
+	for _, cluster := range clusters {
+		if cluster.Role == submarinerv1.ClusterRoleHub && (hub == "" || cluster.ClusterID < hub) {
+			hub = cluster.ClusterID
+		}
+	}
+	return hub
+}
+
+// TransitSubnets returns the subnets of the other clusters whose traffic is forwarded through the cable between the
+// local and the remote cluster: on the local side when the local cluster is the transit hub, and on the remote side
+// when the remote cluster is. The clusters must include the local and the remote cluster.
+func TransitSubnets(local, remote *submarinerv1.ClusterSpec, clusters []*submarinerv1.ClusterSpec) (localSubnets, remoteSubnets []string) {
+	switch TransitHub(clusters) {
+	case "":
+		return nil, nil
+	case local.ClusterID:
+		return unconnectedSubnets(remote, local, clusters), nil
+	case remote.ClusterID:
+		return nil, unconnectedSubnets(local, remote, clusters)
+	}
+	return nil, nil
+}
+
+// unconnectedSubnets returns the routable CIDRs of the clusters, other than the hub, which aren't connected to the
+// given cluster
+func unconnectedSubnets(cluster, hub *submarinerv1.ClusterSpec, clusters []*submarinerv1.ClusterSpec) []string {
+	sorted := make([]*submarinerv1.ClusterSpec, len(clusters))
+	copy(sorted, clusters)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ClusterID < sorted[j].ClusterID
+	})
+
+	var subnets []string
+	for _, other := range sorted {
+		if other.ClusterID == cluster.ClusterID || other.ClusterID == hub.ClusterID || IsConnected(cluster, other) {
+			continue
+		}
+
+		for _, cidr := range util.GetClusterRoutableCIDRs(*other) {
+			if !containsString(subnets, cidr) {
+				subnets = append(subnets, cidr)
+			}
+		}
+	}
+	return subnets
+}
+
+func containsString(c []string, s string) bool {
+	for _, v := range c {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package topology_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	submarinerv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/topology"
+)
+
+var _ = Describe("Topology", func() {
+	hubA := newCluster("hub-a", submarinerv1.ClusterRoleHub, "10.0.0.0/16")
+	hubB := newCluster("hub-b", submarinerv1.ClusterRoleHub, "10.1.0.0/16")
+	edge1 := newCluster("edge-1", submarinerv1.ClusterRoleSpoke, "10.2.0.0/16")
+	edge2 := newCluster("edge-2", submarinerv1.ClusterRoleSpoke, "10.3.0.0/16")
+	mesh := newCluster("mesh", "", "10.4.0.0/16")
+
+	Describe("IsConnected", func() {
+		It("should connect the hubs to every cluster", func() {
+			Expect(topology.IsConnected(hubA, hubB)).To(BeTrue())
+			Expect(topology.IsConnected(hubA, edge1)).To(BeTrue())
+			Expect(topology.IsConnected(mesh, hubA)).To(BeTrue())
+		})
+
+		It("should connect the spokes to the hubs only", func() {
+			Expect(topology.IsConnected(edge1, edge2)).To(BeFalse())
+			Expect(topology.IsConnected(edge1, mesh)).To(BeFalse())
+		})
+
+		It("should connect the clusters without a role to each other", func() {
+			Expect(topology.IsConnected(mesh, newCluster("other", "", "10.5.0.0/16"))).To(BeTrue())
+		})
+	})
+
+	Describe("TransitHub", func() {
+		It("should return the hub with the lowest cluster ID", func() {
+			Expect(topology.TransitHub([]*submarinerv1.ClusterSpec{edge1, hubB, hubA})).To(Equal("hub-a"))
+		})
+
+		It("should return nothing without hubs", func() {
+			Expect(topology.TransitHub([]*submarinerv1.ClusterSpec{edge1, mesh})).To(BeEmpty())
+		})
+	})
+
+	Describe("TransitSubnets", func() {
+		clusters := []*submarinerv1.ClusterSpec{mesh, edge2, hubB, edge1, hubA}
+
+		When("the local cluster is the transit hub", func() {
+			It("should add the clusters the remote cluster isn't connected to on the local side", func() {
+				local, remote := topology.TransitSubnets(hubA, edge1, clusters)
+				Expect(local).To(Equal([]string{"10.3.0.0/16", "10.4.0.0/16"}))
+				Expect(remote).To(BeEmpty())
+
+				local, remote = topology.TransitSubnets(hubA, mesh, clusters)
+				Expect(local).To(Equal([]string{"10.2.0.0/16", "10.3.0.0/16"}))
+				Expect(remote).To(BeEmpty())
+			})
+
+			It("should add nothing for the other hubs", func() {
+				local, remote := topology.TransitSubnets(hubA, hubB, clusters)
+				Expect(local).To(BeEmpty())
+				Expect(remote).To(BeEmpty())
+			})
+		})
+
+		When("the remote cluster is the transit hub", func() {
+			It("should add the clusters the local cluster isn't connected to on the remote side", func() {
+				local, remote := topology.TransitSubnets(edge1, hubA, clusters)
+				Expect(local).To(BeEmpty())
+				Expect(remote).To(Equal([]string{"10.3.0.0/16", "10.4.0.0/16"}))
+			})
+		})
+
+		When("neither cluster is the transit hub", func() {
+			It("should add nothing", func() {
+				local, remote := topology.TransitSubnets(edge1, hubB, clusters)
+				Expect(local).To(BeEmpty())
+				Expect(remote).To(BeEmpty())
+			})
+		})
+
+		When("a cluster has a global CIDR", func() {
+			It("should add its global CIDR only", func() {
+				global := newCluster("edge-3", submarinerv1.ClusterRoleSpoke, "10.6.0.0/16")
+				global.GlobalCIDR = []string{"169.254.8.0/21"}
+				local, _ := topology.TransitSubnets(hubA, edge1, []*submarinerv1.ClusterSpec{hubA, edge1, global})
+				Expect(local).To(Equal([]string{"169.254.8.0/21"}))
+			})
+		})
+	})
+
+	Describe("IsValidRole", func() {
+		It("should accept the known roles and no role", func() {
+			Expect(topology.IsValidRole(submarinerv1.ClusterRoleHub)).To(BeTrue())
+			Expect(topology.IsValidRole(submarinerv1.ClusterRoleSpoke)).To(BeTrue())
+			Expect(topology.IsValidRole("")).To(BeTrue())
+			Expect(topology.IsValidRole("leaf")).To(BeFalse())
+		})
+	})
+})
+
+func newCluster(id string, role submarinerv1.ClusterRole, clusterCIDR string) *submarinerv1.ClusterSpec {
+	return &submarinerv1.ClusterSpec{
+		ClusterID:   id,
+		Role:        role,
+		ClusterCIDR: []string{clusterCIDR},
+	}
+}
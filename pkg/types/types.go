@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	subv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
 )
 
@@ -11,6 +13,19 @@ type SubmarinerCluster struct {
 
 type SubmarinerEndpoint struct {
 	Spec subv1.EndpointSpec `json:"spec"`
+
+	// The subnets of other clusters whose traffic is forwarded by a hub through the cable to the endpoint, on the
+	// local side when the local cluster is the hub and on the remote side when the remote cluster is
+	LocalTransitSubnets  []string `json:"-"`
+	RemoteTransitSubnets []string `json:"-"`
+}
+
+type SubmarinerServiceImport struct {
+	Spec subv1.ServiceImportSpec `json:"spec"`
+}
+
+type SubmarinerCIDRReservation struct {
+	Spec subv1.CIDRReservationSpec `json:"spec"`
 }
 
 type SubmarinerSpecification struct {
@@ -23,6 +38,43 @@ type SubmarinerSpecification struct {
 	ColorCodes  []string
 	NatEnabled  bool
 	Broker      string
+	GlobalCidr  []string
+
+	// The CIDR of the node network, which routes the host network traffic to the other clusters when it's set
+	NodeCidr []string
+
+	// The labels the peering policies of the other clusters select this cluster by
+	ClusterLabels map[string]string
+
+	// The role of this cluster in a hub-and-spoke topology, hub or spoke, or none for a full mesh
+	ClusterRole subv1.ClusterRole
+
+	// The broker IPAM pools from which the cluster and service CIDRs are reserved when they aren't set
+	ClusterCidrPool string
+	ClusterCidrSize int `default:"65536"`
+	ServiceCidrPool string
+	ServiceCidrSize int `default:"65536"`
+
+	GlobalnetEnabled     bool
+	GlobalnetCidrRange   string `default:"169.254.0.0/16"`
+	GlobalnetClusterSize int    `default:"8192"`
+
+	HeartbeatInterval time.Duration `default:"10s"`
+	LeaseTimeout      time.Duration `default:"60s"`
+	ReaperInterval    time.Duration `default:"30s"`
+
+	// The number of workers installing the cables
+	TunnelWorkers int `default:"1"`
+}
+
+// ClusterNetwork holds the CIDRs discovered from the local cluster
+type ClusterNetwork struct {
+	ClusterCIDRs []string
+	ServiceCIDRs []string
+	// Derived from the podCIDRs of the nodes and the kubernetes Service IP, they're only used when the CIDRs
+	// aren't set explicitly nor reserved from a pool
+	GuessedClusterCIDRs []string
+	GuessedServiceCIDRs []string
 }
 
 type Secure struct {
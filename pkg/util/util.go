@@ -9,6 +9,8 @@ import (
 	"syscall"
 
 	subv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/datastore"
+	"github.com/rancher/submariner/pkg/ipam"
 	"github.com/rancher/submariner/pkg/types"
 	"github.com/rdegges/go-ipify"
 	"github.com/vishvananda/netlink"
@@ -75,13 +77,61 @@ func FlattenColors(colorCodes []string) string {
 	return flattenedColors
 }
 
-func GetLocalCluster(ss types.SubmarinerSpecification) (types.SubmarinerCluster, error) {
+// GetLocalCluster returns the local cluster as configured. The cluster and service CIDRs which aren't set are taken
+// from the discovered network, or reserved from their broker pool when none were discovered and a pool is
+// configured, and are guessed otherwise. The global CIDR is reserved as well when globalnet is enabled.
+func GetLocalCluster(ss types.SubmarinerSpecification, discovered types.ClusterNetwork, ds datastore.Datastore) (types.SubmarinerCluster, error) {
 	var localCluster types.SubmarinerCluster
 	localCluster.ID = ss.ClusterID
 	localCluster.Spec.ClusterID = ss.ClusterID
 	localCluster.Spec.ClusterCIDR = ss.ClusterCidr
 	localCluster.Spec.ServiceCIDR = ss.ServiceCidr
+	localCluster.Spec.GlobalCIDR = ss.GlobalCidr
+	localCluster.Spec.NodeCIDR = ss.NodeCidr
 	localCluster.Spec.ColorCodes = ss.ColorCodes
+	localCluster.Spec.Labels = ss.ClusterLabels
+	localCluster.Spec.Role = ss.ClusterRole
+
+	if len(localCluster.Spec.ClusterCIDR) == 0 {
+		localCluster.Spec.ClusterCIDR = discovered.ClusterCIDRs
+	}
+
+	if len(localCluster.Spec.ServiceCIDR) == 0 {
+		localCluster.Spec.ServiceCIDR = discovered.ServiceCIDRs
+	}
+
+	if len(localCluster.Spec.ClusterCIDR) == 0 && ss.ClusterCidrPool != "" {
+		cidr, err := ipam.ReserveCIDR(ds, ss.ClusterID, ss.ColorCodes, ipam.ClusterPool, ss.ClusterCidrPool, ss.ClusterCidrSize)
+		if err != nil {
+			return types.SubmarinerCluster{}, err
+		}
+		localCluster.Spec.ClusterCIDR = []string{cidr}
+	}
+
+	if len(localCluster.Spec.ServiceCIDR) == 0 && ss.ServiceCidrPool != "" {
+		cidr, err := ipam.ReserveCIDR(ds, ss.ClusterID, ss.ColorCodes, ipam.ServicePool, ss.ServiceCidrPool, ss.ServiceCidrSize)
+		if err != nil {
+			return types.SubmarinerCluster{}, err
+		}
+		localCluster.Spec.ServiceCIDR = []string{cidr}
+	}
+
+	if len(localCluster.Spec.GlobalCIDR) == 0 && ss.GlobalnetEnabled {
+		cidr, err := ipam.AllocateClusterGlobalCIDR(ds, ss.ClusterID, ss.ColorCodes, ss.GlobalnetCidrRange,
+			ss.GlobalnetClusterSize)
+		if err != nil {
+			return types.SubmarinerCluster{}, err
+		}
+		localCluster.Spec.GlobalCIDR = []string{cidr}
+	}
+
+	if len(localCluster.Spec.ClusterCIDR) == 0 && len(discovered.GuessedClusterCIDRs) > 0 {
+		localCluster.Spec.ClusterCIDR = discovered.GuessedClusterCIDRs
+	}
+
+	if len(localCluster.Spec.ServiceCIDR) == 0 && len(discovered.GuessedServiceCIDRs) > 0 {
+		localCluster.Spec.ServiceCIDR = discovered.GuessedServiceCIDRs
+	}
 	return localCluster, nil
 }
 
@@ -145,6 +195,28 @@ func GetClusterCRDName(cluster *types.SubmarinerCluster) (string, error) {
 	return cluster.Spec.ClusterID, nil
 }
 
+func GetServiceImportCRDName(serviceImport *types.SubmarinerServiceImport) (string, error) {
+	return GetServiceImportCRDNameFromParams(serviceImport.Spec.ClusterID, serviceImport.Spec.Namespace,
+		serviceImport.Spec.ServiceName)
+}
+
+func GetServiceImportCRDNameFromParams(clusterID, namespace, serviceName string) (string, error) {
+	if clusterID == "" || namespace == "" || serviceName == "" {
+		return "", fmt.Errorf("error, cluster ID, namespace or service name was empty")
+	}
+
+	return fmt.Sprintf("%s-%s-%s", clusterID, namespace, serviceName), nil
+}
+
+func GetCIDRReservationCRDName(reservation *types.SubmarinerCIDRReservation) (string, error) {
+	if reservation.Spec.Pool == "" || reservation.Spec.CIDR == "" {
+		return "", fmt.Errorf("error, pool or CIDR was empty")
+	}
+
+	return fmt.Sprintf("%s-%s", reservation.Spec.Pool, strings.NewReplacer(".", "-", "/", "-", ":", "-").
+		Replace(reservation.Spec.CIDR)), nil
+}
+
 func CompareEndpointSpec(left, right subv1.EndpointSpec) bool {
 	if left.ClusterID == right.ClusterID && left.CableName == right.CableName && left.Hostname == right.Hostname {
 		return true
@@ -152,6 +224,16 @@ func CompareEndpointSpec(left, right subv1.EndpointSpec) bool {
 	return false
 }
 
+// GetClusterRoutableCIDRs returns the CIDRs through which the other clusters reach the cluster: its global CIDR
+// when globalnet is enabled, its cluster, service and node CIDRs otherwise
+func GetClusterRoutableCIDRs(spec subv1.ClusterSpec) []string {
+	if len(spec.GlobalCIDR) > 0 {
+		return append([]string{}, spec.GlobalCIDR...)
+	}
+	cidrs := append(append([]string{}, spec.ClusterCIDR...), spec.ServiceCIDR...)
+	return append(cidrs, spec.NodeCIDR...)
+}
+
 func GetDefaultGatewayInterface() (*net.Interface, error) {
 	routes, err := netlink.RouteList(nil, syscall.AF_INET)
 	if err != nil {
@@ -173,3 +255,86 @@ func GetDefaultGatewayInterface() (*net.Interface, error) {
 
 	return nil, fmt.Errorf("unable to find default route")
 }
+
+// GetDataInterface returns the interface which carries the pod traffic: the interface with the given name, the
+// interface with an address in the given CIDR, or the interface of the route to the given IP. At most one of them may
+// be given, the interface of the default route is returned when none is.
+func GetDataInterface(name, cidr, routeTo string) (*net.Interface, error) {
+	given := 0
+	for _, selector := range []string{name, cidr, routeTo} {
+		if selector != "" {
+			given++
+		}
+	}
+
+	switch {
+	case given > 1:
+		return nil, fmt.Errorf("Only one of the interface name, CIDR and route destination may be given")
+	case name != "":
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("Error retrieving the interface %s: %v", name, err)
+		}
+		return iface, nil
+	case cidr != "":
+		return GetInterfaceInCIDR(cidr)
+	case routeTo != "":
+		return GetInterfaceToIP(routeTo)
+	}
+
+	iface, err := GetDefaultGatewayInterface()
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving the interface of the default route: %v", err)
+	}
+	return iface, nil
+}
+
+// GetInterfaceInCIDR returns the interface with an IPv4 address in the given CIDR
+func GetInterfaceInCIDR(cidr string) (*net.Interface, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the CIDR %s: %v", cidr, err)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the interfaces: %v", err)
+	}
+
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("Error retrieving the addresses of %s: %v", ifaces[i].Name, err)
+		}
+
+		for _, addr := range addrs {
+			if ifaceNet, ok := addr.(*net.IPNet); ok && ifaceNet.IP.To4() != nil && ipNet.Contains(ifaceNet.IP) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("No interface has an address in %s", cidr)
+}
+
+// GetInterfaceToIP returns the interface of the route to the given IP
+func GetInterfaceToIP(ip string) (*net.Interface, error) {
+	dst := net.ParseIP(ip)
+	if dst == nil || dst.To4() == nil {
+		return nil, fmt.Errorf("%s is not a valid IPv4 address", ip)
+	}
+
+	routes, err := netlink.RouteGet(dst)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving the route to %s: %v", ip, err)
+	}
+
+	if len(routes) == 0 || routes[0].LinkIndex == 0 {
+		return nil, fmt.Errorf("The interface of the route to %s could not be determined", ip)
+	}
+
+	iface, err := net.InterfaceByIndex(routes[0].LinkIndex)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving the interface of the route to %s: %v", ip, err)
+	}
+	return iface, nil
+}
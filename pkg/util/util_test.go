@@ -2,11 +2,13 @@ package util_test
 
 import (
 	"net"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	subv1 "github.com/rancher/submariner/pkg/apis/submariner.io/v1"
+	"github.com/rancher/submariner/pkg/datastore/inmemory"
 	"github.com/rancher/submariner/pkg/types"
 	"github.com/rancher/submariner/pkg/util"
 )
@@ -26,8 +28,16 @@ var _ = Describe("Util", func() {
 
 	Describe("Function GetClusterCRDName", testGetClusterCRDName)
 
+	Describe("Function GetServiceImportCRDName", testGetServiceImportCRDName)
+
+	Describe("Function GetCIDRReservationCRDName", testGetCIDRReservationCRDName)
+
 	Describe("Function CompareEndpointSpec", testCompareEndpointSpec)
 
+	Describe("Function GetClusterRoutableCIDRs", testGetClusterRoutableCIDRs)
+
+	Describe("Function GetDataInterface", testGetDataInterface)
+
 })
 
 func testParseSecure() {
@@ -81,7 +91,8 @@ func testGetLocalCluster() {
 			ClusterCidr: clusterCidr,
 			ServiceCidr: serviceCidr,
 			ColorCodes:  colorCodes,
-		})
+			NodeCidr:    []string{"192.168.10.0/24"},
+		}, types.ClusterNetwork{}, inmemory.NewDatastore())
 
 		Expect(err).ToNot(HaveOccurred())
 		Expect(cluster.ID).To(Equal(clusterId))
@@ -89,6 +100,62 @@ func testGetLocalCluster() {
 		Expect(cluster.Spec.ServiceCIDR).To(Equal(serviceCidr))
 		Expect(cluster.Spec.ClusterCIDR).To(Equal(clusterCidr))
 		Expect(cluster.Spec.ColorCodes).To(Equal(colorCodes))
+		Expect(cluster.Spec.NodeCIDR).To(Equal([]string{"192.168.10.0/24"}))
+	})
+
+	Context("without CIDRs but with discovered ones", func() {
+		It("should use the discovered CIDRs unless they're set explicitly", func() {
+			cluster, err := util.GetLocalCluster(types.SubmarinerSpecification{
+				ClusterID:       "east",
+				ServiceCidr:     []string{"10.43.0.0/16"},
+				ClusterCidrPool: "10.128.0.0/12",
+				ClusterCidrSize: 65536,
+			}, types.ClusterNetwork{
+				ClusterCIDRs: []string{"10.42.0.0/16"},
+				ServiceCIDRs: []string{"10.96.0.0/12"},
+			}, inmemory.NewDatastore())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cluster.Spec.ClusterCIDR).To(Equal([]string{"10.42.0.0/16"}))
+			Expect(cluster.Spec.ServiceCIDR).To(Equal([]string{"10.43.0.0/16"}))
+		})
+	})
+
+	Context("without CIDRs but with guessed ones", func() {
+		It("should prefer the CIDRs reserved from the pools", func() {
+			cluster, err := util.GetLocalCluster(types.SubmarinerSpecification{
+				ClusterID:       "east",
+				ServiceCidrPool: "10.128.0.0/12",
+				ServiceCidrSize: 65536,
+			}, types.ClusterNetwork{
+				GuessedClusterCIDRs: []string{"10.42.0.0/16"},
+				GuessedServiceCIDRs: []string{"10.96.0.0/12"},
+			}, inmemory.NewDatastore())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cluster.Spec.ClusterCIDR).To(Equal([]string{"10.42.0.0/16"}))
+			Expect(cluster.Spec.ServiceCIDR).To(Equal([]string{"10.128.0.0/16"}))
+		})
+	})
+
+	Context("without CIDRs but with IPAM pools", func() {
+		It("should reserve the CIDRs from the pools", func() {
+			cluster, err := util.GetLocalCluster(types.SubmarinerSpecification{
+				ClusterID:            "east",
+				ClusterCidrPool:      "10.128.0.0/12",
+				ClusterCidrSize:      65536,
+				ServiceCidrPool:      "10.128.0.0/12",
+				ServiceCidrSize:      65536,
+				GlobalnetEnabled:     true,
+				GlobalnetCidrRange:   "169.254.0.0/16",
+				GlobalnetClusterSize: 8192,
+			}, types.ClusterNetwork{}, inmemory.NewDatastore())
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cluster.Spec.ClusterCIDR).To(Equal([]string{"10.128.0.0/16"}))
+			Expect(cluster.Spec.ServiceCIDR).To(Equal([]string{"10.129.0.0/16"}))
+			Expect(cluster.Spec.GlobalCIDR).To(Equal([]string{"169.254.0.0/19"}))
+		})
 	})
 }
 
@@ -186,6 +253,66 @@ func testGetClusterCRDName() {
 	})
 }
 
+func testGetServiceImportCRDName() {
+	Context("with valid SubmarinerServiceImport input", func() {
+		It("should return <cluster ID>-<namespace>-<service name>", func() {
+			name, err := util.GetServiceImportCRDName(&types.SubmarinerServiceImport{
+				Spec: subv1.ServiceImportSpec{
+					ClusterID:   "west",
+					Namespace:   "default",
+					ServiceName: "nginx",
+				},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal("west-default-nginx"))
+		})
+	})
+
+	Context("with a nil service name", func() {
+		It("should return an error", func() {
+			_, err := util.GetServiceImportCRDName(&types.SubmarinerServiceImport{
+				Spec: subv1.ServiceImportSpec{
+					ClusterID: "west",
+					Namespace: "default",
+				},
+			})
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+}
+
+func testGetCIDRReservationCRDName() {
+	Context("with valid SubmarinerCIDRReservation input", func() {
+		It("should return <pool>-<CIDR> with the separators replaced", func() {
+			name, err := util.GetCIDRReservationCRDName(&types.SubmarinerCIDRReservation{
+				Spec: subv1.CIDRReservationSpec{
+					ClusterID: "west",
+					Pool:      "cluster",
+					CIDR:      "10.128.0.0/16",
+				},
+			})
+
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal("cluster-10-128-0-0-16"))
+		})
+	})
+
+	Context("with an empty CIDR", func() {
+		It("should return an error", func() {
+			_, err := util.GetCIDRReservationCRDName(&types.SubmarinerCIDRReservation{
+				Spec: subv1.CIDRReservationSpec{
+					ClusterID: "west",
+					Pool:      "cluster",
+				},
+			})
+
+			Expect(err).To(HaveOccurred())
+		})
+	})
+}
+
 func testCompareEndpointSpec() {
 	Context("with equal input", func() {
 		It("should return true", func() {
@@ -251,3 +378,88 @@ func testCompareEndpointSpec() {
 		})
 	})
 }
+
+func testGetClusterRoutableCIDRs() {
+	spec := subv1.ClusterSpec{
+		ClusterCIDR: []string{"10.0.0.0/16"},
+		ServiceCIDR: []string{"10.96.0.0/16"},
+	}
+
+	Context("without a global CIDR", func() {
+		It("should return the cluster and service CIDRs", func() {
+			Expect(util.GetClusterRoutableCIDRs(spec)).To(Equal([]string{"10.0.0.0/16", "10.96.0.0/16"}))
+		})
+
+		It("should return the node CIDR as well when it's set", func() {
+			nodeSpec := spec
+			nodeSpec.NodeCIDR = []string{"192.168.10.0/24"}
+			Expect(util.GetClusterRoutableCIDRs(nodeSpec)).To(Equal([]string{"10.0.0.0/16", "10.96.0.0/16", "192.168.10.0/24"}))
+		})
+	})
+
+	Context("with a global CIDR", func() {
+		It("should return the global CIDR only", func() {
+			globalSpec := spec
+			globalSpec.GlobalCIDR = []string{"169.254.0.0/19"}
+			globalSpec.NodeCIDR = []string{"192.168.10.0/24"}
+			Expect(util.GetClusterRoutableCIDRs(globalSpec)).To(Equal([]string{"169.254.0.0/19"}))
+		})
+	})
+}
+
+func testGetDataInterface() {
+	// The loopback interface is the only one every network namespace has
+	Context("with an interface name", func() {
+		It("should return the interface", func() {
+			iface, err := util.GetDataInterface("lo", "", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iface.Name).To(Equal("lo"))
+		})
+
+		It("should return an error when it doesn't exist", func() {
+			_, err := util.GetDataInterface("missing0", "", "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with a CIDR", func() {
+		It("should return the interface with an address in it", func() {
+			iface, err := util.GetDataInterface("", "127.0.0.0/8", "")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iface.Name).To(Equal("lo"))
+		})
+
+		It("should return an error when no interface has an address in it", func() {
+			_, err := util.GetDataInterface("", "0.0.0.0/32", "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should return an error when it's invalid", func() {
+			_, err := util.GetDataInterface("", "127.0.0.1", "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with a route destination", func() {
+		It("should return the interface of the route", func() {
+			iface, err := util.GetDataInterface("", "", "127.0.0.1")
+			if err != nil && strings.Contains(err.Error(), "Error retrieving the route") {
+				Skip(err.Error())
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iface.Name).To(Equal("lo"))
+		})
+
+		It("should return an error when it's invalid", func() {
+			_, err := util.GetDataInterface("", "", "127.0.0")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with several selectors", func() {
+		It("should return an error", func() {
+			_, err := util.GetDataInterface("lo", "127.0.0.0/8", "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+}